@@ -0,0 +1,175 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	models "github.com/BerkatPS/internal"
+	"github.com/BerkatPS/pkg/principal"
+	"github.com/BerkatPS/pkg/rbac"
+	"github.com/BerkatPS/pkg/utils"
+)
+
+// apiKeyPrefixLength is how much of a key is kept in the clear to tell keys apart
+const apiKeyPrefixLength = len(utils.APIKeyPrefix) + 8
+
+var (
+	// ErrInvalidAPIKey is returned for an API key that is unknown, revoked or expired
+	ErrInvalidAPIKey = errors.New("invalid API key")
+	// ErrInvalidAPIKeyRequest is returned when a new key lacks a name or asks for unknown scopes or a past expiry
+	ErrInvalidAPIKeyRequest = errors.New("invalid API key request")
+	// ErrAPIKeyNotAllowed is returned when an API key asks for another key, or a non-admin for an organization key
+	ErrAPIKeyNotAllowed = errors.New("not allowed to create this API key")
+	// ErrAPIKeyNotFound is returned for a key that does not exist or that the caller may not manage
+	ErrAPIKeyNotFound = errors.New("API key not found")
+)
+
+func (a *authService) CreateAPIKey(ctx context.Context, caller *principal.Principal, name string, scopes []string, expiresAt time.Time, organization bool) (*models.CreatedAPIKey, error) {
+	// A leaked key must not be able to mint more keys
+	if caller.IsAPIKey() {
+		return nil, fmt.Errorf("%w: API keys cannot create API keys", ErrAPIKeyNotAllowed)
+	}
+	if organization && !caller.IsAdmin() {
+		return nil, fmt.Errorf("%w: only admins can create organization keys", ErrAPIKeyNotAllowed)
+	}
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, fmt.Errorf("%w: name is required", ErrInvalidAPIKeyRequest)
+	}
+	normalized, err := normalizeScopes(scopes)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	if !expiresAt.IsZero() && !expiresAt.After(now) {
+		return nil, fmt.Errorf("%w: expires_at must be in the future", ErrInvalidAPIKeyRequest)
+	}
+
+	secret, err := utils.GenerateSecureToken(32)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate API key: %v", err)
+	}
+	key := utils.APIKeyPrefix + secret
+
+	record := &models.APIKey{
+		Name:      name,
+		Prefix:    key[:apiKeyPrefixLength],
+		KeyHash:   utils.HashToken(key),
+		CreatedBy: caller.UserID,
+		Scopes:    strings.Join(normalized, " "),
+		ExpiresAt: expiresAt,
+		CreatedAt: now,
+	}
+	if !organization {
+		record.OwnerID = caller.UserID
+	}
+	if err := a.AuthRepo.CreateAPIKey(ctx, record); err != nil {
+		return nil, err
+	}
+
+	record.KeyHash = ""
+	return &models.CreatedAPIKey{Key: key, APIKey: record}, nil
+}
+
+// normalizeScopes lowercases and deduplicates scopes, rejecting unknown ones
+func normalizeScopes(scopes []string) ([]string, error) {
+	seen := make(map[string]bool, len(scopes))
+	normalized := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		scope = strings.ToLower(strings.TrimSpace(scope))
+		if !rbac.IsScope(scope) {
+			return nil, fmt.Errorf("%w: unknown scope %q, expected one of %s", ErrInvalidAPIKeyRequest, scope,
+				strings.Join(rbac.AllScopes(), ", "))
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			normalized = append(normalized, scope)
+		}
+	}
+	if len(normalized) == 0 {
+		return nil, fmt.Errorf("%w: at least one scope is required", ErrInvalidAPIKeyRequest)
+	}
+	return normalized, nil
+}
+
+func (a *authService) ListAPIKeys(ctx context.Context, caller *principal.Principal) ([]models.APIKey, error) {
+	keys, err := a.AuthRepo.ListAPIKeys(ctx, caller.UserID, caller.IsAdmin())
+	if err != nil {
+		return nil, err
+	}
+	for i := range keys {
+		keys[i].KeyHash = ""
+	}
+	return keys, nil
+}
+
+func (a *authService) RevokeAPIKey(ctx context.Context, caller *principal.Principal, keyID int64) error {
+	key, err := a.AuthRepo.FindAPIKeyByID(ctx, keyID)
+	if err != nil {
+		return err
+	}
+	// Someone else's key is reported as missing rather than forbidden, so its existence is not revealed
+	if key == nil || (key.OwnerID != caller.UserID && !caller.IsAdmin()) {
+		return ErrAPIKeyNotFound
+	}
+	return a.AuthRepo.RevokeAPIKey(ctx, key.ID)
+}
+
+func (a *authService) LoadAPIKeyPrincipal(ctx context.Context, apiKey string) (*principal.Principal, error) {
+	key, err := a.AuthRepo.FindAPIKeyByHash(ctx, utils.HashToken(apiKey))
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	if key == nil || !key.RevokedAt.IsZero() || (!key.ExpiresAt.IsZero() && !now.Before(key.ExpiresAt)) {
+		return nil, ErrInvalidAPIKey
+	}
+
+	// An organization key reaches every project, limited only by its scopes. What it creates is attributed to
+	// the admin who created it, so it stops working once that user is removed or is no longer an admin.
+	caller := &principal.Principal{
+		UserID:   key.CreatedBy,
+		Role:     rbac.RoleAdmin,
+		APIKeyID: key.ID,
+		Scopes:   strings.Fields(key.Scopes),
+	}
+	if key.OwnerID == 0 {
+		creator, err := a.findKeyUser(ctx, key.CreatedBy)
+		if err != nil {
+			return nil, err
+		}
+		if creator.Role != rbac.RoleAdmin {
+			return nil, ErrInvalidAPIKey
+		}
+	} else {
+		// A personal key acts as its owner with the owner's current role, and dies with the owner's account
+		owner, err := a.findKeyUser(ctx, key.OwnerID)
+		if err != nil {
+			return nil, err
+		}
+		memberships, err := a.AuthRepo.FindMemberships(ctx, owner.ID)
+		if err != nil {
+			return nil, err
+		}
+		caller.UserID, caller.Role, caller.Memberships = owner.ID, owner.Role, memberships
+	}
+
+	if err := a.AuthRepo.TouchAPIKey(ctx, key.ID, now); err != nil {
+		log.Printf("failed to record use of API key %d: %v", key.ID, err)
+	}
+	return caller, nil
+}
+
+// findKeyUser loads the user an API key acts as. A user that no longer exists makes the key invalid.
+func (a *authService) findKeyUser(ctx context.Context, userID int64) (*models.User, error) {
+	user, err := a.AuthRepo.FindUserByID(ctx, userID)
+	if errors.Is(err, ErrUserNotFound) || (err == nil && user == nil) {
+		return nil, ErrInvalidAPIKey
+	}
+	return user, err
+}
@@ -2,9 +2,15 @@ package auth
 
 import (
 	"encoding/json"
+	"errors"
 	models "github.com/BerkatPS/internal"
+	"github.com/BerkatPS/pkg/principal"
 	"github.com/BerkatPS/pkg/utils"
+	"io"
+	"math"
 	"net/http"
+	"strconv"
+	"time"
 )
 
 // AuthController handles HTTP requests related to authentication
@@ -51,8 +57,18 @@ func (a *AuthController) CreateUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := a.AuthService.CreateUser(ctx, &user); err != nil {
-		utils.JSONErrorResponse(w, http.StatusInternalServerError, map[string]interface{}{
+	// Registration is public; a caller only matters when it is an admin assigning a privileged role
+	var actorID int64
+	if caller, ok := principal.FromContext(ctx); ok {
+		actorID = caller.UserID
+	}
+
+	if err := a.AuthService.CreateUser(ctx, &user, actorID); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, ErrRoleNotAllowed) {
+			status = http.StatusForbidden
+		}
+		utils.JSONErrorResponse(w, status, map[string]interface{}{
 			"status":  "error",
 			"message": "Failed to create user: " + err.Error(),
 		})
@@ -81,9 +97,19 @@ func (a *AuthController) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	token, err := a.AuthService.Login(ctx, credentials.Email, credentials.Password)
+	tokens, err := a.AuthService.Login(ctx, credentials.Email, credentials.Password, utils.ClientIP(r))
 	if err != nil {
-		utils.JSONErrorResponse(w, http.StatusUnauthorized, map[string]interface{}{
+		var throttled *ThrottledError
+		status := http.StatusInternalServerError
+		switch {
+		case errors.As(err, &throttled):
+			status = http.StatusTooManyRequests
+			retryAfter := int64(math.Ceil(time.Until(throttled.RetryAt).Seconds()))
+			w.Header().Set("Retry-After", strconv.FormatInt(max(retryAfter, 1), 10))
+		case errors.Is(err, ErrInvalidCredentials):
+			status = http.StatusUnauthorized
+		}
+		utils.JSONErrorResponse(w, status, map[string]interface{}{
 			"status":  "error",
 			"message": "Authentication failed: " + err.Error(),
 		})
@@ -93,24 +119,74 @@ func (a *AuthController) Login(w http.ResponseWriter, r *http.Request) {
 	utils.JSONResponse(w, http.StatusOK, map[string]interface{}{
 		"status":  "success",
 		"message": "Login successful",
-		"token":   token,
+		"token":   tokens.AccessToken,
+		"data":    tokens,
 	})
 }
 
-// Logout invalidates the user's session by clearing their token
+// RefreshToken exchanges a refresh token for a new access token and refresh token
+func (a *AuthController) RefreshToken(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context() // Get the context from the request
+
+	var request struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		utils.JSONErrorResponse(w, http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": "Invalid refresh payload: " + err.Error(),
+		})
+		return
+	}
+
+	tokens, err := a.AuthService.Refresh(ctx, request.RefreshToken)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, ErrInvalidRefreshToken) || errors.Is(err, ErrRefreshTokenReused) {
+			status = http.StatusUnauthorized
+		}
+		utils.JSONErrorResponse(w, status, map[string]interface{}{
+			"status":  "error",
+			"message": "Failed to refresh token: " + err.Error(),
+		})
+		return
+	}
+
+	utils.JSONResponse(w, http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "Token refreshed successfully",
+		"token":   tokens.AccessToken,
+		"data":    tokens,
+	})
+}
+
+// Logout ends the session of the access token, revoking its refresh tokens. With {"all_sessions": true}
+// every session of the user is ended.
 func (a *AuthController) Logout(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context() // Get the context from the request
 
-	userID, ok := r.Context().Value("userID").(int64)
-	if !ok {
+	caller, err := principal.Require(ctx)
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusUnauthorized, map[string]interface{}{
+			"status":  "error",
+			"message": "Unauthorized: " + err.Error(),
+		})
+		return
+	}
+
+	// The body is optional
+	var request struct {
+		AllSessions bool `json:"all_sessions"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil && err != io.EOF {
 		utils.JSONErrorResponse(w, http.StatusBadRequest, map[string]interface{}{
 			"status":  "error",
-			"message": "User ID not found in context",
+			"message": "Invalid logout payload: " + err.Error(),
 		})
 		return
 	}
 
-	if err := a.AuthService.Logout(ctx, userID); err != nil {
+	if err := a.AuthService.Logout(ctx, caller.UserID, caller.SessionID, request.AllSessions); err != nil {
 		utils.JSONErrorResponse(w, http.StatusInternalServerError, map[string]interface{}{
 			"status":  "error",
 			"message": "Failed to logout user: " + err.Error(),
@@ -124,12 +200,305 @@ func (a *AuthController) Logout(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-// ResetPassword updates the user's password
+// GetProfile returns the account of the caller
+func (a *AuthController) GetProfile(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context() // Get the context from the request
+
+	caller, err := principal.Require(ctx)
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusUnauthorized, map[string]interface{}{
+			"status":  "error",
+			"message": "Unauthorized: " + err.Error(),
+		})
+		return
+	}
+
+	profile, err := a.AuthService.GetProfile(ctx, caller)
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusInternalServerError, map[string]interface{}{
+			"status":  "error",
+			"message": "Failed to retrieve profile: " + err.Error(),
+		})
+		return
+	}
+
+	utils.JSONResponse(w, http.StatusOK, map[string]interface{}{
+		"status": "success",
+		"data":   profile,
+	})
+}
+
+// UpdateProfile changes the username and email of the caller
+func (a *AuthController) UpdateProfile(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context() // Get the context from the request
+
+	caller, err := principal.Require(ctx)
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusUnauthorized, map[string]interface{}{
+			"status":  "error",
+			"message": "Unauthorized: " + err.Error(),
+		})
+		return
+	}
+
+	var request struct {
+		Username string `json:"username"`
+		Email    string `json:"email"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		utils.JSONErrorResponse(w, http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": "Invalid profile payload: " + err.Error(),
+		})
+		return
+	}
+
+	profile, err := a.AuthService.UpdateProfile(ctx, caller, request.Username, request.Email)
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, ErrInvalidProfile):
+			status = http.StatusBadRequest
+		case errors.Is(err, ErrEmailTaken):
+			status = http.StatusConflict
+		}
+		utils.JSONErrorResponse(w, status, map[string]interface{}{
+			"status":  "error",
+			"message": "Failed to update profile: " + err.Error(),
+		})
+		return
+	}
+
+	utils.JSONResponse(w, http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "Profile updated successfully",
+		"data":    profile,
+	})
+}
+
+// UnlockAccount lifts the lockout an account got from failed logins
+func (a *AuthController) UnlockAccount(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context() // Get the context from the request
+
+	userID, err := utils.ParseInt64Param(r)
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": "Invalid user ID: " + err.Error(),
+		})
+		return
+	}
+
+	actorID, err := principal.UserIDFromRequest(r)
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusUnauthorized, map[string]interface{}{
+			"status":  "error",
+			"message": "Unauthorized: " + err.Error(),
+		})
+		return
+	}
+
+	if err := a.AuthService.UnlockAccount(ctx, userID, actorID); err != nil {
+		utils.JSONErrorResponse(w, http.StatusInternalServerError, map[string]interface{}{
+			"status":  "error",
+			"message": "Failed to unlock account: " + err.Error(),
+		})
+		return
+	}
+
+	utils.JSONResponse(w, http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "Account unlocked successfully",
+	})
+}
+
+// ListLoginAttempts returns the login audit trail, filtered by the email and ip query parameters
+func (a *AuthController) ListLoginAttempts(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context() // Get the context from the request
+
+	limit, err := utils.ParseInt64Query(r, "limit")
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": "Invalid limit: " + err.Error(),
+		})
+		return
+	}
+
+	query := r.URL.Query()
+	attempts, err := a.AuthService.ListLoginAttempts(ctx, query.Get("email"), query.Get("ip"), int(limit))
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusInternalServerError, map[string]interface{}{
+			"status":  "error",
+			"message": "Failed to retrieve login attempts: " + err.Error(),
+		})
+		return
+	}
+
+	utils.JSONResponse(w, http.StatusOK, map[string]interface{}{
+		"status": "success",
+		"data":   attempts,
+	})
+}
+
+// CreateAPIKey issues an API key. The key is in this response only.
+func (a *AuthController) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context() // Get the context from the request
+
+	caller, err := principal.Require(ctx)
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusUnauthorized, map[string]interface{}{
+			"status":  "error",
+			"message": "Unauthorized: " + err.Error(),
+		})
+		return
+	}
+
+	var request struct {
+		Name         string     `json:"name"`
+		Scopes       []string   `json:"scopes"`
+		ExpiresAt    *time.Time `json:"expires_at"`
+		Organization bool       `json:"organization"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		utils.JSONErrorResponse(w, http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": "Invalid API key payload: " + err.Error(),
+		})
+		return
+	}
+
+	var expiresAt time.Time
+	if request.ExpiresAt != nil {
+		expiresAt = *request.ExpiresAt
+	}
+	created, err := a.AuthService.CreateAPIKey(ctx, caller, request.Name, request.Scopes, expiresAt, request.Organization)
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, ErrInvalidAPIKeyRequest):
+			status = http.StatusBadRequest
+		case errors.Is(err, ErrAPIKeyNotAllowed):
+			status = http.StatusForbidden
+		}
+		utils.JSONErrorResponse(w, status, map[string]interface{}{
+			"status":  "error",
+			"message": "Failed to create API key: " + err.Error(),
+		})
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	utils.JSONResponse(w, http.StatusCreated, map[string]interface{}{
+		"status":  "success",
+		"message": "API key created, store it now as it will not be shown again",
+		"data":    created,
+	})
+}
+
+// ListAPIKeys returns the API keys of the caller without their secrets
+func (a *AuthController) ListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context() // Get the context from the request
+
+	caller, err := principal.Require(ctx)
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusUnauthorized, map[string]interface{}{
+			"status":  "error",
+			"message": "Unauthorized: " + err.Error(),
+		})
+		return
+	}
+
+	keys, err := a.AuthService.ListAPIKeys(ctx, caller)
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusInternalServerError, map[string]interface{}{
+			"status":  "error",
+			"message": "Failed to retrieve API keys: " + err.Error(),
+		})
+		return
+	}
+
+	utils.JSONResponse(w, http.StatusOK, map[string]interface{}{
+		"status": "success",
+		"data":   keys,
+	})
+}
+
+// RevokeAPIKey stops an API key from being accepted
+func (a *AuthController) RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context() // Get the context from the request
+
+	keyID, err := utils.ParseInt64Param(r)
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": "Invalid API key ID: " + err.Error(),
+		})
+		return
+	}
+
+	caller, err := principal.Require(ctx)
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusUnauthorized, map[string]interface{}{
+			"status":  "error",
+			"message": "Unauthorized: " + err.Error(),
+		})
+		return
+	}
+
+	if err := a.AuthService.RevokeAPIKey(ctx, caller, keyID); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, ErrAPIKeyNotFound) {
+			status = http.StatusNotFound
+		}
+		utils.JSONErrorResponse(w, status, map[string]interface{}{
+			"status":  "error",
+			"message": "Failed to revoke API key: " + err.Error(),
+		})
+		return
+	}
+
+	utils.JSONResponse(w, http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "API key revoked successfully",
+	})
+}
+
+// ForgotPassword mails a password reset token. The answer is the same whether or not the email has an account.
+func (a *AuthController) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context() // Get the context from the request
+
+	var request struct {
+		Email string `json:"email"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		utils.JSONErrorResponse(w, http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": "Invalid forgot password payload: " + err.Error(),
+		})
+		return
+	}
+
+	if err := a.AuthService.RequestPasswordReset(ctx, request.Email); err != nil {
+		utils.JSONErrorResponse(w, http.StatusInternalServerError, map[string]interface{}{
+			"status":  "error",
+			"message": "Failed to request password reset: " + err.Error(),
+		})
+		return
+	}
+
+	utils.JSONResponse(w, http.StatusAccepted, map[string]interface{}{
+		"status":  "success",
+		"message": "If the email belongs to an account, a password reset link has been sent to it",
+	})
+}
+
+// ResetPassword sets a new password with a token from ForgotPassword
 func (a *AuthController) ResetPassword(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context() // Get the context from the request
 
 	var request struct {
-		UserID   int64  `json:"user_id"`
+		Token    string `json:"token"`
 		Password string `json:"password"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
@@ -140,8 +509,12 @@ func (a *AuthController) ResetPassword(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := a.AuthService.ResetPassword(ctx, request.UserID, request.Password); err != nil {
-		utils.JSONErrorResponse(w, http.StatusInternalServerError, map[string]interface{}{
+	if err := a.AuthService.ConfirmPasswordReset(ctx, request.Token, request.Password); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, ErrInvalidResetToken) || errors.Is(err, ErrWeakPassword) {
+			status = http.StatusBadRequest
+		}
+		utils.JSONErrorResponse(w, status, map[string]interface{}{
 			"status":  "error",
 			"message": "Failed to reset password: " + err.Error(),
 		})
@@ -150,6 +523,6 @@ func (a *AuthController) ResetPassword(w http.ResponseWriter, r *http.Request) {
 
 	utils.JSONResponse(w, http.StatusOK, map[string]interface{}{
 		"status":  "success",
-		"message": "Password reset successfully",
+		"message": "Password reset successfully, please log in again",
 	})
 }
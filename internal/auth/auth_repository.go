@@ -3,9 +3,11 @@ package auth
 import (
 	"context"
 	"database/sql"
-	"errors"
 	"fmt"
 	models "github.com/BerkatPS/internal"
+	"github.com/BerkatPS/pkg/principal"
+	"github.com/BerkatPS/pkg/rbac"
+	"time"
 )
 
 const (
@@ -14,7 +16,57 @@ const (
 	insertUserQuery        = "INSERT INTO users (username, email, password, role) VALUES ($1, $2, $3, $4)"
 	updatePasswordQuery    = "UPDATE users SET password = $1 WHERE id = $2"
 	updateUserTokenQuery   = "UPDATE users SET refresh_token = $1 WHERE id = $2"
-	selectAllUsersQuery    = "SELECT id, username, email, password, role FROM users"
+	updateProfileQuery     = "UPDATE users SET username = $1, email = $2 WHERE id = $3"
+	selectAllUsersQuery    = "SELECT id, username, email, role FROM users"
+	selectAdminExistsQuery = "SELECT EXISTS (SELECT 1 FROM users WHERE LOWER(role) = 'admin')"
+
+	insertRefreshTokenQuery = "INSERT INTO refresh_tokens (user_id, family_id, token_hash, expires_at, created_at) VALUES ($1, $2, $3, $4, $5) RETURNING id"
+	selectRefreshTokenQuery = `SELECT id, user_id, family_id, token_hash, expires_at, created_at,
+		COALESCE(rotated_at, '0001-01-01'::timestamp), COALESCE(revoked_at, '0001-01-01'::timestamp)
+		FROM refresh_tokens WHERE token_hash = $1`
+	rotateRefreshTokenQuery = "UPDATE refresh_tokens SET rotated_at = $1 WHERE id = $2 AND rotated_at IS NULL AND revoked_at IS NULL"
+	revokeTokenFamilyQuery  = "UPDATE refresh_tokens SET revoked_at = $1 WHERE family_id = $2 AND revoked_at IS NULL"
+	revokeUserTokensQuery   = "UPDATE refresh_tokens SET revoked_at = $1 WHERE user_id = $2 AND revoked_at IS NULL"
+	// Requesting a new reset retires the ones still outstanding, so only the latest email works
+	retirePasswordResetsQuery = "UPDATE password_reset_tokens SET used_at = $1 WHERE user_id = $2 AND used_at IS NULL"
+	insertPasswordResetQuery  = "INSERT INTO password_reset_tokens (user_id, token_hash, expires_at, created_at) VALUES ($1, $2, $3, $4) RETURNING id"
+	redeemPasswordResetQuery  = `UPDATE password_reset_tokens SET used_at = $1
+		WHERE token_hash = $2 AND used_at IS NULL AND expires_at > $1 RETURNING user_id`
+	insertLoginAttemptQuery = `INSERT INTO login_attempts (user_id, email, ip_address, outcome, reason, actor_id, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id`
+	// A success or an unlock starts the count of an account over
+	selectAccountFailuresQuery = `SELECT COUNT(*), COALESCE(MAX(created_at), '0001-01-01'::timestamp) FROM login_attempts
+		WHERE email = $1 AND outcome = 'FAILED' AND created_at > $2::timestamp
+		AND created_at > COALESCE((SELECT MAX(created_at) FROM login_attempts
+			WHERE email = $1 AND outcome IN ('SUCCEEDED', 'UNLOCKED')), $2::timestamp)`
+	selectIPFailuresQuery = `SELECT COUNT(*), COALESCE(MAX(created_at), '0001-01-01'::timestamp) FROM login_attempts
+		WHERE ip_address = $1 AND outcome = 'FAILED' AND created_at > $2`
+	selectLoginAttemptsQuery = `SELECT id, user_id, email, ip_address, outcome, reason, actor_id, created_at FROM login_attempts
+		WHERE ($1 = '' OR email = $1) AND ($2 = '' OR ip_address = $2) ORDER BY created_at DESC, id DESC LIMIT $3`
+	apiKeyColumns = `id, name, prefix, key_hash, owner_id, created_by, scopes,
+		COALESCE(expires_at, '0001-01-01'::timestamp), COALESCE(last_used_at, '0001-01-01'::timestamp), created_at,
+		COALESCE(revoked_at, '0001-01-01'::timestamp)`
+	insertAPIKeyQuery = `INSERT INTO api_keys (name, prefix, key_hash, owner_id, created_by, scopes, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id`
+	selectAPIKeyByHashQuery = "SELECT " + apiKeyColumns + " FROM api_keys WHERE key_hash = $1"
+	selectAPIKeyByIDQuery   = "SELECT " + apiKeyColumns + " FROM api_keys WHERE id = $1"
+	// Organization keys have no owner, so the admins listing them ask for owner 0 as well
+	selectAPIKeysQuery = "SELECT " + apiKeyColumns + " FROM api_keys WHERE owner_id = $1 OR ($2 AND owner_id = 0) ORDER BY created_at DESC, id DESC"
+	revokeAPIKeyQuery  = "UPDATE api_keys SET revoked_at = $1 WHERE id = $2 AND revoked_at IS NULL"
+	// Busy integrations call many times a minute; the last use only needs minute precision
+	touchAPIKeyQuery = `UPDATE api_keys SET last_used_at = $1
+		WHERE id = $2 AND (last_used_at IS NULL OR last_used_at < $1::timestamp - INTERVAL '1 minute')`
+	// The manager of a project is its project manager; a member without a team role keeps their own role
+	selectMembershipsQuery = `SELECT p.id,
+		CASE WHEN COALESCE(p.manager_id, 0) = $1 THEN $2 ELSE COALESCE(NULLIF(t.role, ''), u.role, '') END
+		FROM projects p
+		LEFT JOIN project_team t ON t.project_id = p.id AND t.user_id = $1
+		JOIN users u ON u.id = $1
+		WHERE COALESCE(p.manager_id, 0) = $1 OR t.user_id IS NOT NULL
+		ORDER BY p.id`
+	// A session stays open while its family has a current token that is neither revoked nor expired
+	selectSessionActiveQuery = `SELECT EXISTS (SELECT 1 FROM refresh_tokens WHERE family_id = $1 AND user_id = $2
+		AND rotated_at IS NULL AND revoked_at IS NULL AND expires_at > $3)`
 )
 
 // AuthRepository defines the methods for interacting with user data
@@ -24,7 +76,52 @@ type AuthRepository interface {
 	UpdateUserToken(ctx context.Context, userID int64, token string) error
 	FindUserByID(ctx context.Context, userID int64) (*models.User, error)
 	UpdatePassword(ctx context.Context, userID int64, newPassword string) error
+	UpdateProfile(ctx context.Context, userID int64, username, email string) error
 	ShowAllUsers(ctx context.Context) ([]models.User, error)
+	HasAdmin(ctx context.Context) (bool, error)
+
+	CreateRefreshToken(ctx context.Context, token *models.RefreshToken) error
+	// FindRefreshTokenByHash returns nil when no token has the hash
+	FindRefreshTokenByHash(ctx context.Context, tokenHash string) (*models.RefreshToken, error)
+	// RotateRefreshToken marks the current token as used and stores its successor in one transaction. It reports
+	// false, storing nothing, when the token was already rotated or revoked in the meantime.
+	RotateRefreshToken(ctx context.Context, currentID int64, next *models.RefreshToken) (bool, error)
+	RevokeTokenFamily(ctx context.Context, familyID string) error
+	RevokeUserTokens(ctx context.Context, userID int64) error
+	IsSessionActive(ctx context.Context, userID int64, familyID string) (bool, error)
+
+	// CreatePasswordReset stores a reset token, retiring the user's earlier ones
+	CreatePasswordReset(ctx context.Context, token *models.PasswordResetToken) error
+	// RedeemPasswordReset uses up the reset token with the hash, sets the new password of its user and revokes
+	// their sessions in one transaction. It reports false, changing nothing, for a token that is unknown, used
+	// or expired.
+	RedeemPasswordReset(ctx context.Context, tokenHash, passwordHash string) (bool, error)
+	// LockLogins holds back other logins with the email or from the IP address, on every replica, until unlock is
+	// called, so their failures are counted and recorded one at a time
+	LockLogins(ctx context.Context, email, ip string) (unlock func(), err error)
+	RecordLoginAttempt(ctx context.Context, attempt *models.LoginAttempt) error
+	// CountAccountFailures counts the failed logins with the email since the later of since and its last
+	// successful login or unlock, and returns when the latest one happened
+	CountAccountFailures(ctx context.Context, email string, since time.Time) (int, time.Time, error)
+	// CountIPFailures counts the failed logins from the IP address since since, and returns when the latest
+	// one happened
+	CountIPFailures(ctx context.Context, ip string, since time.Time) (int, time.Time, error)
+	// ListLoginAttempts returns the latest attempts, newest first, optionally only those with the email or IP
+	ListLoginAttempts(ctx context.Context, email, ip string, limit int) ([]models.LoginAttempt, error)
+
+	CreateAPIKey(ctx context.Context, key *models.APIKey) error
+	// FindAPIKeyByHash returns nil when no key has the hash
+	FindAPIKeyByHash(ctx context.Context, keyHash string) (*models.APIKey, error)
+	// FindAPIKeyByID returns nil when there is no such key
+	FindAPIKeyByID(ctx context.Context, keyID int64) (*models.APIKey, error)
+	// ListAPIKeys returns the keys of a user, and those of the organization when includeOrganization is set
+	ListAPIKeys(ctx context.Context, ownerID int64, includeOrganization bool) ([]models.APIKey, error)
+	RevokeAPIKey(ctx context.Context, keyID int64) error
+	// TouchAPIKey records that a key was used at now
+	TouchAPIKey(ctx context.Context, keyID int64, now time.Time) error
+
+	// FindMemberships lists the projects the user manages or is on the team of, with their role on each
+	FindMemberships(ctx context.Context, userID int64) ([]principal.Membership, error)
 }
 
 type authRepository struct {
@@ -47,7 +144,7 @@ func (r *authRepository) ShowAllUsers(ctx context.Context) ([]models.User, error
 	var users []models.User
 	for rows.Next() {
 		var user models.User
-		if err := rows.Scan(&user.ID, &user.Username, &user.Email, &user.Password, &user.Role); err != nil {
+		if err := rows.Scan(&user.ID, &user.Username, &user.Email, &user.Role); err != nil {
 			return nil, fmt.Errorf("failed to scan user: %w", err)
 		}
 		users = append(users, user)
@@ -67,6 +164,22 @@ func (r *authRepository) UpdatePassword(ctx context.Context, userID int64, newPa
 	return nil
 }
 
+// UpdateProfile changes the username and email of a user
+func (r *authRepository) UpdateProfile(ctx context.Context, userID int64, username, email string) error {
+	result, err := r.db.ExecContext(ctx, updateProfileQuery, username, email, userID)
+	if err != nil {
+		return fmt.Errorf("failed to update profile: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to update profile: %w", err)
+	}
+	if rows == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
 // FindUserByID retrieves a user by their ID
 func (r *authRepository) FindUserByID(ctx context.Context, userID int64) (*models.User, error) {
 	row := r.db.QueryRowContext(ctx, selectUserByIDQuery, userID)
@@ -74,7 +187,7 @@ func (r *authRepository) FindUserByID(ctx context.Context, userID int64) (*model
 	user := &models.User{}
 	if err := row.Scan(&user.ID, &user.Username, &user.Email, &user.Password, &user.Role); err != nil {
 		if err == sql.ErrNoRows {
-			return nil, errors.New("user not found")
+			return nil, ErrUserNotFound
 		}
 		return nil, fmt.Errorf("failed to find user by ID: %w", err)
 	}
@@ -103,6 +216,15 @@ func (r *authRepository) FindUserByEmail(ctx context.Context, email string) (*mo
 	return &user, nil
 }
 
+// HasAdmin reports whether any user has the admin role
+func (r *authRepository) HasAdmin(ctx context.Context) (bool, error) {
+	var exists bool
+	if err := r.db.QueryRowContext(ctx, selectAdminExistsQuery).Scan(&exists); err != nil {
+		return false, fmt.Errorf("failed to check for admins: %w", err)
+	}
+	return exists, nil
+}
+
 // CreateUser adds a new user to the database
 func (r *authRepository) CreateUser(ctx context.Context, user *models.User) error {
 	_, err := r.db.ExecContext(ctx, insertUserQuery, user.Username, user.Email, user.Password, user.Role)
@@ -111,3 +233,331 @@ func (r *authRepository) CreateUser(ctx context.Context, user *models.User) erro
 	}
 	return nil
 }
+
+// CreateRefreshToken stores the first token of a new family
+func (r *authRepository) CreateRefreshToken(ctx context.Context, token *models.RefreshToken) error {
+	err := r.db.QueryRowContext(ctx, insertRefreshTokenQuery,
+		token.UserID, token.FamilyID, token.TokenHash, token.ExpiresAt, token.CreatedAt).Scan(&token.ID)
+	if err != nil {
+		return fmt.Errorf("failed to create refresh token: %w", err)
+	}
+	return nil
+}
+
+// FindRefreshTokenByHash retrieves a refresh token by the hash of its value
+func (r *authRepository) FindRefreshTokenByHash(ctx context.Context, tokenHash string) (*models.RefreshToken, error) {
+	token := &models.RefreshToken{}
+	err := r.db.QueryRowContext(ctx, selectRefreshTokenQuery, tokenHash).Scan(&token.ID, &token.UserID, &token.FamilyID,
+		&token.TokenHash, &token.ExpiresAt, &token.CreatedAt, &token.RotatedAt, &token.RevokedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to query refresh token: %w", err)
+	}
+	return token, nil
+}
+
+// RotateRefreshToken replaces a refresh token with its successor
+func (r *authRepository) RotateRefreshToken(ctx context.Context, currentID int64, next *models.RefreshToken) (bool, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, rotateRefreshTokenQuery, next.CreatedAt, currentID)
+	if err != nil {
+		return false, fmt.Errorf("failed to rotate refresh token: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to rotate refresh token: %w", err)
+	}
+	// Another request got here first with the same token
+	if rows == 0 {
+		return false, nil
+	}
+
+	err = tx.QueryRowContext(ctx, insertRefreshTokenQuery,
+		next.UserID, next.FamilyID, next.TokenHash, next.ExpiresAt, next.CreatedAt).Scan(&next.ID)
+	if err != nil {
+		return false, fmt.Errorf("failed to create refresh token: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return true, nil
+}
+
+// RevokeTokenFamily revokes every token of a login session
+func (r *authRepository) RevokeTokenFamily(ctx context.Context, familyID string) error {
+	_, err := r.db.ExecContext(ctx, revokeTokenFamilyQuery, time.Now(), familyID)
+	if err != nil {
+		return fmt.Errorf("failed to revoke refresh tokens: %w", err)
+	}
+	return nil
+}
+
+// RevokeUserTokens revokes every session of a user
+func (r *authRepository) RevokeUserTokens(ctx context.Context, userID int64) error {
+	_, err := r.db.ExecContext(ctx, revokeUserTokensQuery, time.Now(), userID)
+	if err != nil {
+		return fmt.Errorf("failed to revoke refresh tokens: %w", err)
+	}
+	return nil
+}
+
+// IsSessionActive reports whether a login session has not been logged out, revoked or let expire
+func (r *authRepository) IsSessionActive(ctx context.Context, userID int64, familyID string) (bool, error) {
+	var active bool
+	if err := r.db.QueryRowContext(ctx, selectSessionActiveQuery, familyID, userID, time.Now()).Scan(&active); err != nil {
+		return false, fmt.Errorf("failed to check session: %w", err)
+	}
+	return active, nil
+}
+
+// CreatePasswordReset stores a password reset token
+func (r *authRepository) CreatePasswordReset(ctx context.Context, token *models.PasswordResetToken) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, retirePasswordResetsQuery, token.CreatedAt, token.UserID); err != nil {
+		return fmt.Errorf("failed to retire password resets: %w", err)
+	}
+	err = tx.QueryRowContext(ctx, insertPasswordResetQuery,
+		token.UserID, token.TokenHash, token.ExpiresAt, token.CreatedAt).Scan(&token.ID)
+	if err != nil {
+		return fmt.Errorf("failed to create password reset: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// RedeemPasswordReset resets a password with a reset token
+func (r *authRepository) RedeemPasswordReset(ctx context.Context, tokenHash, passwordHash string) (bool, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	var userID int64
+	err = tx.QueryRowContext(ctx, redeemPasswordResetQuery, now, tokenHash).Scan(&userID)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to redeem password reset: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, updatePasswordQuery, passwordHash, userID); err != nil {
+		return false, fmt.Errorf("failed to update password: %w", err)
+	}
+	// Whoever knew the old password is logged out
+	if _, err := tx.ExecContext(ctx, revokeUserTokensQuery, now, userID); err != nil {
+		return false, fmt.Errorf("failed to revoke refresh tokens: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return true, nil
+}
+
+// RecordLoginAttempt adds an entry to the login audit trail
+func (r *authRepository) RecordLoginAttempt(ctx context.Context, attempt *models.LoginAttempt) error {
+	err := r.db.QueryRowContext(ctx, insertLoginAttemptQuery, attempt.UserID, attempt.Email, attempt.IPAddress,
+		attempt.Outcome, attempt.Reason, attempt.ActorID, attempt.CreatedAt).Scan(&attempt.ID)
+	if err != nil {
+		return fmt.Errorf("failed to record login attempt: %w", err)
+	}
+	return nil
+}
+
+// LockLogins takes transaction-scoped advisory locks on the email and the IP address. The email is always
+// locked first, so two logins never wait on each other's lock.
+func (r *authRepository) LockLogins(ctx context.Context, email, ip string) (func(), error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock logins: %w", err)
+	}
+
+	keys := []string{"email:" + email}
+	if ip != "" {
+		keys = append(keys, "ip:"+ip)
+	}
+	for _, key := range keys {
+		if _, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock(hashtext('login:' || $1))", key); err != nil {
+			tx.Rollback()
+			return nil, fmt.Errorf("failed to lock logins: %w", err)
+		}
+	}
+	// Nothing is written in the transaction; ending it releases the locks
+	return func() { tx.Rollback() }, nil
+}
+
+// CountAccountFailures counts the recent failed logins of an account
+func (r *authRepository) CountAccountFailures(ctx context.Context, email string, since time.Time) (int, time.Time, error) {
+	var count int
+	var last time.Time
+	if err := r.db.QueryRowContext(ctx, selectAccountFailuresQuery, email, since).Scan(&count, &last); err != nil {
+		return 0, time.Time{}, fmt.Errorf("failed to count failed logins: %w", err)
+	}
+	return count, last, nil
+}
+
+// CountIPFailures counts the recent failed logins from an IP address
+func (r *authRepository) CountIPFailures(ctx context.Context, ip string, since time.Time) (int, time.Time, error) {
+	var count int
+	var last time.Time
+	if err := r.db.QueryRowContext(ctx, selectIPFailuresQuery, ip, since).Scan(&count, &last); err != nil {
+		return 0, time.Time{}, fmt.Errorf("failed to count failed logins: %w", err)
+	}
+	return count, last, nil
+}
+
+// ListLoginAttempts retrieves the login audit trail
+func (r *authRepository) ListLoginAttempts(ctx context.Context, email, ip string, limit int) ([]models.LoginAttempt, error) {
+	rows, err := r.db.QueryContext(ctx, selectLoginAttemptsQuery, email, ip, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query login attempts: %w", err)
+	}
+	defer rows.Close()
+
+	attempts := []models.LoginAttempt{}
+	for rows.Next() {
+		var attempt models.LoginAttempt
+		if err := rows.Scan(&attempt.ID, &attempt.UserID, &attempt.Email, &attempt.IPAddress, &attempt.Outcome,
+			&attempt.Reason, &attempt.ActorID, &attempt.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan login attempt: %w", err)
+		}
+		attempts = append(attempts, attempt)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read login attempts: %w", err)
+	}
+	return attempts, nil
+}
+
+// CreateAPIKey stores a new API key
+func (r *authRepository) CreateAPIKey(ctx context.Context, key *models.APIKey) error {
+	err := r.db.QueryRowContext(ctx, insertAPIKeyQuery, key.Name, key.Prefix, key.KeyHash, key.OwnerID, key.CreatedBy,
+		key.Scopes, nullTime(key.ExpiresAt), key.CreatedAt).Scan(&key.ID)
+	if err != nil {
+		return fmt.Errorf("failed to create API key: %w", err)
+	}
+	return nil
+}
+
+// FindAPIKeyByHash retrieves an API key by the hash of its value
+func (r *authRepository) FindAPIKeyByHash(ctx context.Context, keyHash string) (*models.APIKey, error) {
+	key, err := scanAPIKey(r.db.QueryRowContext(ctx, selectAPIKeyByHashQuery, keyHash))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query API key: %w", err)
+	}
+	return key, nil
+}
+
+// FindAPIKeyByID retrieves an API key by its ID
+func (r *authRepository) FindAPIKeyByID(ctx context.Context, keyID int64) (*models.APIKey, error) {
+	key, err := scanAPIKey(r.db.QueryRowContext(ctx, selectAPIKeyByIDQuery, keyID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query API key: %w", err)
+	}
+	return key, nil
+}
+
+// ListAPIKeys retrieves the API keys of a user
+func (r *authRepository) ListAPIKeys(ctx context.Context, ownerID int64, includeOrganization bool) ([]models.APIKey, error) {
+	rows, err := r.db.QueryContext(ctx, selectAPIKeysQuery, ownerID, includeOrganization)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query API keys: %w", err)
+	}
+	defer rows.Close()
+
+	keys := []models.APIKey{}
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan API key: %w", err)
+		}
+		keys = append(keys, *key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read API keys: %w", err)
+	}
+	return keys, nil
+}
+
+// RevokeAPIKey stops an API key from being accepted
+func (r *authRepository) RevokeAPIKey(ctx context.Context, keyID int64) error {
+	_, err := r.db.ExecContext(ctx, revokeAPIKeyQuery, time.Now(), keyID)
+	if err != nil {
+		return fmt.Errorf("failed to revoke API key: %w", err)
+	}
+	return nil
+}
+
+// TouchAPIKey updates the last use of an API key
+func (r *authRepository) TouchAPIKey(ctx context.Context, keyID int64, now time.Time) error {
+	_, err := r.db.ExecContext(ctx, touchAPIKeyQuery, now, keyID)
+	if err != nil {
+		return fmt.Errorf("failed to record API key use: %w", err)
+	}
+	return nil
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanAPIKey(row rowScanner) (*models.APIKey, error) {
+	key := &models.APIKey{}
+	err := row.Scan(&key.ID, &key.Name, &key.Prefix, &key.KeyHash, &key.OwnerID, &key.CreatedBy, &key.Scopes,
+		&key.ExpiresAt, &key.LastUsedAt, &key.CreatedAt, &key.RevokedAt)
+	if err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: !t.IsZero()}
+}
+
+// FindMemberships retrieves the project teams of a user
+func (r *authRepository) FindMemberships(ctx context.Context, userID int64) ([]principal.Membership, error) {
+	rows, err := r.db.QueryContext(ctx, selectMembershipsQuery, userID, rbac.RoleProjectManager)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query memberships: %w", err)
+	}
+	defer rows.Close()
+
+	memberships := []principal.Membership{}
+	for rows.Next() {
+		var membership principal.Membership
+		if err := rows.Scan(&membership.ProjectID, &membership.Role); err != nil {
+			return nil, fmt.Errorf("failed to scan membership: %w", err)
+		}
+		memberships = append(memberships, membership)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over memberships: %w", err)
+	}
+	return memberships, nil
+}
@@ -2,28 +2,112 @@ package auth
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	models "github.com/BerkatPS/internal"
+	"github.com/BerkatPS/pkg/config"
+	"github.com/BerkatPS/pkg/mail"
+	"github.com/BerkatPS/pkg/principal"
+	"github.com/BerkatPS/pkg/rbac"
 	"github.com/BerkatPS/pkg/utils"
+	netmail "net/mail"
+	"strings"
+	"time"
 )
 
+var (
+	// ErrInvalidRefreshToken is returned for a refresh token that is unknown, expired or revoked
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	// ErrRoleNotAllowed is returned when someone other than an admin registers a user with a privileged role
+	ErrRoleNotAllowed = errors.New("only admins can assign this role")
+	// ErrRefreshTokenReused is returned when an already rotated refresh token is presented again. The token
+	// may have been stolen, so its whole family is revoked.
+	ErrRefreshTokenReused = errors.New("refresh token reused, session revoked")
+	// ErrInvalidProfile is returned when a profile update leaves the username or email unusable
+	ErrInvalidProfile = errors.New("invalid profile")
+	// ErrEmailTaken is returned when a profile update picks an email another user already has
+	ErrEmailTaken = errors.New("email is already in use")
+	// ErrUserNotFound is returned when no user has the ID
+	ErrUserNotFound = errors.New("user not found")
+)
+
+// Profile is a user's view of their own account. The role and memberships are the ones permissions are
+// checked against.
+type Profile struct {
+	ID          int64                  `json:"id"`
+	Username    string                 `json:"username"`
+	Email       string                 `json:"email"`
+	Role        string                 `json:"role"`
+	Memberships []principal.Membership `json:"memberships"`
+}
+
 // AuthService defines the interface for authentication-related operations
 type AuthService interface {
 	FindUserByEmail(ctx context.Context, email string) (*models.User, error)
-	CreateUser(ctx context.Context, user *models.User) error
-	Login(ctx context.Context, email, password string) (string, error)
-	Logout(ctx context.Context, userID int64) error
-	ResetPassword(ctx context.Context, userID int64, newPassword string) error
+	// CreateUser registers a user. Anyone may register as a worker; other roles need an admin as actorID,
+	// except for the first admin of a fresh installation.
+	CreateUser(ctx context.Context, user *models.User, actorID int64) error
+	// Login authenticates the user and starts a login session with a short-lived access token and a refresh token.
+	// Repeated failures on the email or from ip hold back further attempts with a ThrottledError.
+	Login(ctx context.Context, email, password, ip string) (*models.TokenPair, error)
+	// UnlockAccount lifts the lockout of a user's account after failed logins
+	UnlockAccount(ctx context.Context, userID, actorID int64) error
+	// ListLoginAttempts returns the login audit trail, newest first, optionally only for an email or IP address
+	ListLoginAttempts(ctx context.Context, email, ip string, limit int) ([]models.LoginAttempt, error)
+	// Refresh exchanges a refresh token for a new pair. The refresh token is single-use.
+	Refresh(ctx context.Context, refreshToken string) (*models.TokenPair, error)
+	// Logout ends the login session, or every session of the user when allSessions is set
+	Logout(ctx context.Context, userID int64, sessionID string, allSessions bool) error
+	// SessionActive reports whether the access tokens of a login session are still accepted
+	SessionActive(ctx context.Context, userID int64, sessionID string) (bool, error)
+	// FindUserRole returns the role permissions are checked against
+	FindUserRole(ctx context.Context, userID int64) (string, error)
+	// LoadPrincipal builds the principal of a verified access token: the user's role and project memberships
+	LoadPrincipal(ctx context.Context, claims *utils.AccessClaims) (*principal.Principal, error)
+	// LoadAPIKeyPrincipal builds the principal of an API key: the owner's role and memberships, narrowed by the
+	// key's scopes. It records the use of the key.
+	LoadAPIKeyPrincipal(ctx context.Context, apiKey string) (*principal.Principal, error)
+	// CreateAPIKey issues a key for the caller, or for the organization when an admin asks for it. The key is
+	// only returned here; it is stored hashed.
+	CreateAPIKey(ctx context.Context, caller *principal.Principal, name string, scopes []string, expiresAt time.Time, organization bool) (*models.CreatedAPIKey, error)
+	// ListAPIKeys returns the caller's keys, and the organization's as well for admins
+	ListAPIKeys(ctx context.Context, caller *principal.Principal) ([]models.APIKey, error)
+	// RevokeAPIKey stops a key of the caller from being accepted. Admins may revoke any key.
+	RevokeAPIKey(ctx context.Context, caller *principal.Principal, keyID int64) error
+	// GetProfile returns the caller's own account
+	GetProfile(ctx context.Context, caller *principal.Principal) (*Profile, error)
+	// UpdateProfile changes the caller's username and email. Empty values keep the current ones; the role
+	// cannot be changed here.
+	UpdateProfile(ctx context.Context, caller *principal.Principal, username, email string) (*Profile, error)
+	// RequestPasswordReset mails a single-use reset token to the user with the email. Unknown emails are
+	// accepted silently so the endpoint does not reveal who has an account.
+	RequestPasswordReset(ctx context.Context, email string) error
+	// ConfirmPasswordReset sets a new password with a reset token and logs the user out everywhere
+	ConfirmPasswordReset(ctx context.Context, token, newPassword string) error
 	ShowAllUsers(ctx context.Context) ([]models.User, error)
 }
 
 type authService struct {
 	AuthRepo AuthRepository
+	Mailer   mail.Sender
+
+	resetTTL    time.Duration
+	resetURL    string
+	loginPolicy LoginPolicy
 }
 
-// NewAuthService creates a new instance of AuthService
-func NewAuthService(AuthRepo AuthRepository) AuthService {
-	return &authService{AuthRepo}
+// NewAuthService creates a new instance of AuthService. Password reset emails go out through mailer.
+func NewAuthService(AuthRepo AuthRepository, mailer mail.Sender, cfg *config.Config) AuthService {
+	// Hashed up front, so the first login with an unknown email takes no longer than the ones after it
+	dummyPasswordHash()
+
+	return &authService{
+		AuthRepo:    AuthRepo,
+		Mailer:      mailer,
+		resetTTL:    cfg.PasswordResetTTL,
+		resetURL:    cfg.PasswordResetURL,
+		loginPolicy: newLoginPolicy(cfg),
+	}
 }
 
 // ShowAllUsers retrieves all users from the repository
@@ -31,35 +115,191 @@ func (a *authService) ShowAllUsers(ctx context.Context) ([]models.User, error) {
 	return a.AuthRepo.ShowAllUsers(ctx)
 }
 
-// Logout clears the user's token, effectively logging them out
-func (a *authService) Logout(ctx context.Context, userID int64) error {
-	return a.AuthRepo.UpdateUserToken(ctx, userID, "")
+// Logout revokes the refresh tokens of the session, which also stops its access tokens from being accepted
+func (a *authService) Logout(ctx context.Context, userID int64, sessionID string, allSessions bool) error {
+	if allSessions {
+		return a.AuthRepo.RevokeUserTokens(ctx, userID)
+	}
+	return a.AuthRepo.RevokeTokenFamily(ctx, sessionID)
+}
+
+func (a *authService) SessionActive(ctx context.Context, userID int64, sessionID string) (bool, error) {
+	return a.AuthRepo.IsSessionActive(ctx, userID, sessionID)
 }
 
-// ResetPassword updates the user's password after hashing it
-func (a *authService) ResetPassword(ctx context.Context, userID int64, newPassword string) error {
-	hashedPassword, err := utils.HashPassword(newPassword)
+// FindUserRole retrieves the role of a user
+func (a *authService) FindUserRole(ctx context.Context, userID int64) (string, error) {
+	user, err := a.AuthRepo.FindUserByID(ctx, userID)
 	if err != nil {
-		return fmt.Errorf("failed to hash password: %v", err)
+		return "", err
 	}
-	return a.AuthRepo.UpdatePassword(ctx, userID, hashedPassword)
+	return user.Role, nil
 }
 
-// Login authenticates the user and returns a JWT token if successful
-func (a *authService) Login(ctx context.Context, email, password string) (string, error) {
-	user, err := a.AuthRepo.FindUserByEmail(ctx, email)
+func (a *authService) LoadPrincipal(ctx context.Context, claims *utils.AccessClaims) (*principal.Principal, error) {
+	user, err := a.AuthRepo.FindUserByID(ctx, claims.UserID)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+	memberships, err := a.AuthRepo.FindMemberships(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &principal.Principal{
+		UserID:      user.ID,
+		Role:        user.Role,
+		Memberships: memberships,
+		TokenID:     claims.TokenID,
+		SessionID:   claims.SessionID,
+	}, nil
+}
+
+func (a *authService) GetProfile(ctx context.Context, caller *principal.Principal) (*Profile, error) {
+	user, err := a.AuthRepo.FindUserByID(ctx, caller.UserID)
+	if err != nil {
+		return nil, err
 	}
-	if !utils.CheckPasswordHash(password, user.Password) {
-		return "", fmt.Errorf("invalid password")
+	return newProfile(user, caller.Memberships), nil
+}
+
+func (a *authService) UpdateProfile(ctx context.Context, caller *principal.Principal, username, email string) (*Profile, error) {
+	user, err := a.AuthRepo.FindUserByID(ctx, caller.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	if username = strings.TrimSpace(username); username != "" {
+		user.Username = username
+	}
+	if email = strings.TrimSpace(email); email != "" {
+		address, err := netmail.ParseAddress(email)
+		if err != nil || address.Address != email {
+			return nil, fmt.Errorf("%w: %q is not an email address", ErrInvalidProfile, email)
+		}
+		if email != user.Email {
+			existingUser, err := a.AuthRepo.FindUserByEmail(ctx, email)
+			if err != nil {
+				return nil, fmt.Errorf("failed to check existing user: %v", err)
+			}
+			if existingUser != nil && existingUser.ID != user.ID {
+				return nil, ErrEmailTaken
+			}
+		}
+		user.Email = email
+	}
+
+	if err := a.AuthRepo.UpdateProfile(ctx, user.ID, user.Username, user.Email); err != nil {
+		return nil, err
+	}
+	return newProfile(user, caller.Memberships), nil
+}
+
+func newProfile(user *models.User, memberships []principal.Membership) *Profile {
+	if memberships == nil {
+		memberships = []principal.Membership{}
+	}
+	return &Profile{
+		ID:          user.ID,
+		Username:    user.Username,
+		Email:       user.Email,
+		Role:        user.Role,
+		Memberships: memberships,
+	}
+}
+
+// Login authenticates the user and returns the tokens of a new session if successful
+func (a *authService) Login(ctx context.Context, email, password, ip string) (*models.TokenPair, error) {
+	user, err := a.authenticate(ctx, email, password, ip)
+	if err != nil {
+		return nil, err
 	}
 
-	token, err := utils.GenerateToken(int(user.ID))
+	familyID, err := utils.GenerateSecureToken(16)
 	if err != nil {
-		return "", fmt.Errorf("failed to generate token: %v", err)
+		return nil, fmt.Errorf("failed to generate token: %v", err)
+	}
+
+	refreshToken, record, err := newRefreshToken(user.ID, familyID)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.AuthRepo.CreateRefreshToken(ctx, record); err != nil {
+		return nil, err
 	}
-	return token, nil
+	return issueTokenPair(record, refreshToken)
+}
+
+// Refresh rotates the refresh token. Presenting a token that was already rotated means two parties hold the
+// same family, so the family is revoked and both have to log in again.
+func (a *authService) Refresh(ctx context.Context, refreshToken string) (*models.TokenPair, error) {
+	if refreshToken == "" {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	current, err := a.AuthRepo.FindRefreshTokenByHash(ctx, utils.HashToken(refreshToken))
+	if err != nil {
+		return nil, err
+	}
+	if current == nil || !current.RevokedAt.IsZero() || !time.Now().Before(current.ExpiresAt) {
+		return nil, ErrInvalidRefreshToken
+	}
+	if !current.RotatedAt.IsZero() {
+		return nil, a.revokeReusedFamily(ctx, current.FamilyID)
+	}
+
+	nextToken, next, err := newRefreshToken(current.UserID, current.FamilyID)
+	if err != nil {
+		return nil, err
+	}
+	rotated, err := a.AuthRepo.RotateRefreshToken(ctx, current.ID, next)
+	if err != nil {
+		return nil, err
+	}
+	// A concurrent refresh with the same token won the rotation
+	if !rotated {
+		return nil, a.revokeReusedFamily(ctx, current.FamilyID)
+	}
+	return issueTokenPair(next, nextToken)
+}
+
+func (a *authService) revokeReusedFamily(ctx context.Context, familyID string) error {
+	if err := a.AuthRepo.RevokeTokenFamily(ctx, familyID); err != nil {
+		return fmt.Errorf("%w: %v", ErrRefreshTokenReused, err)
+	}
+	return ErrRefreshTokenReused
+}
+
+// newRefreshToken generates a refresh token of the family and the record of its hash
+func newRefreshToken(userID int64, familyID string) (string, *models.RefreshToken, error) {
+	token, err := utils.GenerateSecureToken(32)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to generate token: %v", err)
+	}
+
+	now := time.Now()
+	return token, &models.RefreshToken{
+		UserID:    userID,
+		FamilyID:  familyID,
+		TokenHash: utils.HashToken(token),
+		ExpiresAt: now.Add(config.LoadConfig().RefreshTokenTTL),
+		CreatedAt: now,
+	}, nil
+}
+
+// issueTokenPair signs an access token for the session of a stored refresh token
+func issueTokenPair(record *models.RefreshToken, refreshToken string) (*models.TokenPair, error) {
+	accessToken, expiresAt, err := utils.GenerateAccessToken(record.UserID, record.FamilyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate token: %v", err)
+	}
+	return &models.TokenPair{
+		AccessToken:      accessToken,
+		RefreshToken:     refreshToken,
+		TokenType:        "Bearer",
+		ExpiresIn:        int64(time.Until(expiresAt).Round(time.Second).Seconds()),
+		RefreshExpiresAt: record.ExpiresAt,
+	}, nil
 }
 
 // FindUserByEmail retrieves a user by email from the repository
@@ -68,7 +308,11 @@ func (a *authService) FindUserByEmail(ctx context.Context, email string) (*model
 }
 
 // CreateUser creates a new user after checking if the email already exists
-func (a *authService) CreateUser(ctx context.Context, user *models.User) error {
+func (a *authService) CreateUser(ctx context.Context, user *models.User, actorID int64) error {
+	if err := a.checkAssignableRole(ctx, user, actorID); err != nil {
+		return err
+	}
+
 	existingUser, err := a.AuthRepo.FindUserByEmail(ctx, user.Email)
 	if err != nil {
 		return fmt.Errorf("failed to check existing user: %v", err)
@@ -85,3 +329,38 @@ func (a *authService) CreateUser(ctx context.Context, user *models.User) error {
 	user.Password = hashedPassword
 	return a.AuthRepo.CreateUser(ctx, user)
 }
+
+// checkAssignableRole normalizes the requested role and makes sure the actor may grant it
+func (a *authService) checkAssignableRole(ctx context.Context, user *models.User, actorID int64) error {
+	if user.Role == "" {
+		user.Role = rbac.RoleWorker
+	}
+	user.Role = rbac.NormalizeRole(user.Role)
+	if !rbac.IsRole(user.Role) {
+		return fmt.Errorf("unknown role %q", user.Role)
+	}
+	if user.Role == rbac.RoleWorker {
+		return nil
+	}
+
+	if actorID > 0 {
+		actorRole, err := a.FindUserRole(ctx, actorID)
+		if err != nil {
+			return fmt.Errorf("failed to check actor role: %v", err)
+		}
+		if rbac.NormalizeRole(actorRole) == rbac.RoleAdmin {
+			return nil
+		}
+		return ErrRoleNotAllowed
+	}
+
+	// A fresh installation has nobody to grant the first admin
+	hasAdmin, err := a.AuthRepo.HasAdmin(ctx)
+	if err != nil {
+		return err
+	}
+	if user.Role == rbac.RoleAdmin && !hasAdmin {
+		return nil
+	}
+	return ErrRoleNotAllowed
+}
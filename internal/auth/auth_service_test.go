@@ -0,0 +1,158 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	models "github.com/BerkatPS/internal"
+	"github.com/BerkatPS/pkg/utils"
+)
+
+// refreshTokenRepo keeps refresh tokens in memory. The embedded AuthRepository is nil, so any other method
+// the service calls panics and fails the test.
+type refreshTokenRepo struct {
+	AuthRepository
+
+	tokens    []*models.RefreshToken
+	lostRace  bool  // RotateRefreshToken behaves as if a concurrent refresh rotated the token first
+	revokeErr error // RevokeTokenFamily fails with it
+	revoked   []string
+}
+
+func (f *refreshTokenRepo) FindRefreshTokenByHash(ctx context.Context, tokenHash string) (*models.RefreshToken, error) {
+	for _, token := range f.tokens {
+		if token.TokenHash == tokenHash {
+			copied := *token
+			return &copied, nil
+		}
+	}
+	return nil, nil
+}
+
+func (f *refreshTokenRepo) RotateRefreshToken(ctx context.Context, currentID int64, next *models.RefreshToken) (bool, error) {
+	if f.lostRace {
+		return false, nil
+	}
+	for _, token := range f.tokens {
+		if token.ID == currentID {
+			if !token.RotatedAt.IsZero() || !token.RevokedAt.IsZero() {
+				return false, nil
+			}
+			token.RotatedAt = time.Now()
+		}
+	}
+	next.ID = int64(len(f.tokens) + 1)
+	f.tokens = append(f.tokens, next)
+	return true, nil
+}
+
+func (f *refreshTokenRepo) RevokeTokenFamily(ctx context.Context, familyID string) error {
+	f.revoked = append(f.revoked, familyID)
+	if f.revokeErr != nil {
+		return f.revokeErr
+	}
+	for _, token := range f.tokens {
+		if token.FamilyID == familyID && token.RevokedAt.IsZero() {
+			token.RevokedAt = time.Now()
+		}
+	}
+	return nil
+}
+
+func TestRefresh(t *testing.T) {
+	now := time.Now()
+	stored := func(token string, change func(*models.RefreshToken)) *models.RefreshToken {
+		record := &models.RefreshToken{ID: 1, UserID: 7, FamilyID: "family", TokenHash: utils.HashToken(token),
+			ExpiresAt: now.Add(time.Hour), CreatedAt: now.Add(-time.Hour)}
+		if change != nil {
+			change(record)
+		}
+		return record
+	}
+
+	tests := []struct {
+		name        string
+		present     string
+		token       *models.RefreshToken
+		lostRace    bool
+		revokeErr   error
+		wantErr     error
+		wantRevoked bool
+	}{
+		{name: "current token rotates", present: "current", token: stored("current", nil)},
+		{name: "empty token", present: "", token: stored("current", nil), wantErr: ErrInvalidRefreshToken},
+		{name: "unknown token", present: "guessed", token: stored("current", nil), wantErr: ErrInvalidRefreshToken},
+		{
+			name: "expired token", present: "current", wantErr: ErrInvalidRefreshToken,
+			token: stored("current", func(r *models.RefreshToken) { r.ExpiresAt = now.Add(-time.Minute) }),
+		},
+		{
+			name: "revoked token", present: "current", wantErr: ErrInvalidRefreshToken,
+			token: stored("current", func(r *models.RefreshToken) { r.RevokedAt = now.Add(-time.Minute) }),
+		},
+		{
+			name: "rotated token is reuse", present: "current", wantErr: ErrRefreshTokenReused, wantRevoked: true,
+			token: stored("current", func(r *models.RefreshToken) { r.RotatedAt = now.Add(-time.Minute) }),
+		},
+		{
+			name: "expired rotated token is not reuse", present: "current", wantErr: ErrInvalidRefreshToken,
+			token: stored("current", func(r *models.RefreshToken) {
+				r.RotatedAt, r.ExpiresAt = now.Add(-time.Hour), now.Add(-time.Minute)
+			}),
+		},
+		{name: "lost concurrent rotation is reuse", present: "current", token: stored("current", nil), lostRace: true,
+			wantErr: ErrRefreshTokenReused, wantRevoked: true},
+		{
+			name: "reuse reported when revoking fails", present: "current", revokeErr: errors.New("connection reset"),
+			wantErr: ErrRefreshTokenReused, wantRevoked: true,
+			token: stored("current", func(r *models.RefreshToken) { r.RotatedAt = now.Add(-time.Minute) }),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &refreshTokenRepo{tokens: []*models.RefreshToken{tt.token}, lostRace: tt.lostRace, revokeErr: tt.revokeErr}
+			service := &authService{AuthRepo: repo}
+
+			pair, err := service.Refresh(context.Background(), tt.present)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("error = %v, want %v", err, tt.wantErr)
+				}
+			} else {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if pair.RefreshToken == "" || pair.RefreshToken == tt.present || pair.AccessToken == "" {
+					t.Errorf("token pair = %+v, want a new refresh token and an access token", pair)
+				}
+			}
+
+			if revoked := len(repo.revoked) > 0; revoked != tt.wantRevoked {
+				t.Errorf("family revoked = %v, want %v", revoked, tt.wantRevoked)
+			}
+		})
+	}
+}
+
+// A stolen token replayed after its owner refreshed ends the session for both of them
+func TestRefreshReuseRevokesSuccessor(t *testing.T) {
+	repo := &refreshTokenRepo{tokens: []*models.RefreshToken{{
+		ID: 1, UserID: 7, FamilyID: "family", TokenHash: utils.HashToken("first"), ExpiresAt: time.Now().Add(time.Hour),
+	}}}
+	service := &authService{AuthRepo: repo}
+	ctx := context.Background()
+
+	second, err := service.Refresh(ctx, "first")
+	if err != nil {
+		t.Fatalf("first refresh: %v", err)
+	}
+	if _, err := service.Refresh(ctx, "first"); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Fatalf("replayed refresh error = %v, want %v", err, ErrRefreshTokenReused)
+	}
+	if _, err := service.Refresh(ctx, second.RefreshToken); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("successor refresh error = %v, want %v", err, ErrInvalidRefreshToken)
+	}
+}
@@ -0,0 +1,208 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	models "github.com/BerkatPS/internal"
+	"github.com/BerkatPS/pkg/config"
+	"github.com/BerkatPS/pkg/utils"
+)
+
+// Outcomes of a login attempt in the audit trail
+const (
+	LoginSucceeded = "SUCCEEDED"
+	LoginFailed    = "FAILED"
+	LoginThrottled = "THROTTLED"
+	LoginUnlocked  = "UNLOCKED"
+)
+
+// loginBaseDelay is the wait after the first failure that is not free; it doubles with every further failure
+const loginBaseDelay = time.Second
+
+var (
+	// ErrInvalidCredentials is returned for a wrong password and for an email without an account alike
+	ErrInvalidCredentials = errors.New("invalid email or password")
+	// ErrLoginThrottled matches every ThrottledError
+	ErrLoginThrottled = errors.New("too many failed logins")
+)
+
+// ThrottledError is returned while failed logins hold back the account or the IP address. The password is not
+// checked, so guessing on gains nothing.
+type ThrottledError struct {
+	RetryAt time.Time
+	Locked  bool // The failures reached the lockout threshold rather than just the backoff
+}
+
+func (e *ThrottledError) Error() string {
+	if e.Locked {
+		return "too many failed logins, temporarily locked until " + e.RetryAt.Format(time.RFC3339)
+	}
+	return "too many failed logins, retry after " + e.RetryAt.Format(time.RFC3339)
+}
+
+func (e *ThrottledError) Is(target error) bool {
+	return target == ErrLoginThrottled
+}
+
+// LoginPolicy decides how long failed logins hold back further attempts
+type LoginPolicy struct {
+	MaxFailures   int           // Failures that lock an account
+	MaxIPFailures int           // Failures that lock out an IP address
+	Lockout       time.Duration // Length of a lockout, and the longest backoff
+	Window        time.Duration // How long a failure counts
+}
+
+func newLoginPolicy(cfg *config.Config) LoginPolicy {
+	return LoginPolicy{
+		MaxFailures:   cfg.LoginMaxFailures,
+		MaxIPFailures: cfg.LoginIPMaxFailures,
+		Lockout:       cfg.LoginLockout,
+		Window:        cfg.LoginFailureWindow,
+	}
+}
+
+// retryAt returns when the next attempt is allowed after failures failures, the latest at last. The first free
+// failures cost nothing. After that the wait doubles with each failure, and max failures lock out for Lockout.
+func (p LoginPolicy) retryAt(failures, free, max int, last time.Time) (time.Time, bool) {
+	if failures <= free {
+		return time.Time{}, false
+	}
+	if failures >= max {
+		return last.Add(p.Lockout), true
+	}
+	delay := p.Lockout
+	if shift := failures - free - 1; shift < 30 && loginBaseDelay<<shift < p.Lockout {
+		delay = loginBaseDelay << shift
+	}
+	return last.Add(delay), false
+}
+
+// checkThrottle returns a ThrottledError when the account or the IP address has to wait. An account may
+// retype its password once for free; an IP address, which may be shared by a whole site office, gets half of
+// its lockout threshold.
+func (a *authService) checkThrottle(ctx context.Context, email, ip string, now time.Time) error {
+	since := now.Add(-a.loginPolicy.Window)
+
+	failures, last, err := a.AuthRepo.CountAccountFailures(ctx, email, since)
+	if err != nil {
+		return err
+	}
+	retryAt, locked := a.loginPolicy.retryAt(failures, 1, a.loginPolicy.MaxFailures, last)
+
+	if ip != "" {
+		ipFailures, ipLast, err := a.AuthRepo.CountIPFailures(ctx, ip, since)
+		if err != nil {
+			return err
+		}
+		ipRetryAt, ipLocked := a.loginPolicy.retryAt(ipFailures, a.loginPolicy.MaxIPFailures/2, a.loginPolicy.MaxIPFailures, ipLast)
+		if ipRetryAt.After(retryAt) {
+			retryAt, locked = ipRetryAt, ipLocked
+		}
+	}
+
+	if now.Before(retryAt) {
+		return &ThrottledError{RetryAt: retryAt, Locked: locked}
+	}
+	return nil
+}
+
+var (
+	dummyHashOnce sync.Once
+	dummyHash     string
+)
+
+// dummyPasswordHash is compared against when the email has no account, so an unknown email costs as much
+// time as a wrong password
+func dummyPasswordHash() string {
+	dummyHashOnce.Do(func() {
+		hash, err := utils.HashPassword("not the password of any account")
+		if err != nil {
+			log.Printf("failed to hash the dummy password: %v", err)
+		}
+		dummyHash = hash
+	})
+	return dummyHash
+}
+
+// authenticate checks the credentials behind the throttle and records the attempt in the audit trail
+func (a *authService) authenticate(ctx context.Context, email, password, ip string) (*models.User, error) {
+	email = strings.TrimSpace(email)
+	attempt := &models.LoginAttempt{
+		Email:     strings.ToLower(email),
+		IPAddress: ip,
+	}
+
+	// Without the lock, parallel guesses would all pass the throttle before any of their failures is recorded
+	unlock, err := a.AuthRepo.LockLogins(ctx, attempt.Email, ip)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	attempt.CreatedAt = time.Now()
+
+	if err := a.checkThrottle(ctx, attempt.Email, ip, attempt.CreatedAt); err != nil {
+		if errors.Is(err, ErrLoginThrottled) {
+			attempt.Outcome, attempt.Reason = LoginThrottled, err.Error()
+			a.recordLoginAttempt(ctx, attempt)
+		}
+		return nil, err
+	}
+
+	user, err := a.AuthRepo.FindUserByEmail(ctx, email)
+	if err != nil {
+		return nil, err
+	}
+
+	hash, reason := dummyPasswordHash(), "unknown email"
+	if user != nil {
+		attempt.UserID = user.ID
+		hash, reason = user.Password, "wrong password"
+	}
+	if !utils.CheckPasswordHash(password, hash) || user == nil {
+		attempt.Outcome, attempt.Reason = LoginFailed, reason
+		// An unrecorded failure would not count towards the lockout, so it fails the login
+		if err := a.AuthRepo.RecordLoginAttempt(ctx, attempt); err != nil {
+			return nil, err
+		}
+		return nil, ErrInvalidCredentials
+	}
+
+	attempt.Outcome = LoginSucceeded
+	a.recordLoginAttempt(ctx, attempt)
+	return user, nil
+}
+
+// recordLoginAttempt writes an audit entry whose loss does not weaken the throttle
+func (a *authService) recordLoginAttempt(ctx context.Context, attempt *models.LoginAttempt) {
+	if err := a.AuthRepo.RecordLoginAttempt(ctx, attempt); err != nil {
+		log.Printf("failed to record %s login of %s: %v", attempt.Outcome, attempt.Email, err)
+	}
+}
+
+func (a *authService) UnlockAccount(ctx context.Context, userID, actorID int64) error {
+	user, err := a.AuthRepo.FindUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	return a.AuthRepo.RecordLoginAttempt(ctx, &models.LoginAttempt{
+		UserID:    user.ID,
+		Email:     strings.ToLower(strings.TrimSpace(user.Email)),
+		Outcome:   LoginUnlocked,
+		Reason:    fmt.Sprintf("unlocked by user %d", actorID),
+		ActorID:   actorID,
+		CreatedAt: time.Now(),
+	})
+}
+
+func (a *authService) ListLoginAttempts(ctx context.Context, email, ip string, limit int) ([]models.LoginAttempt, error) {
+	if limit <= 0 || limit > 500 {
+		limit = 100
+	}
+	return a.AuthRepo.ListLoginAttempts(ctx, strings.ToLower(strings.TrimSpace(email)), strings.TrimSpace(ip), limit)
+}
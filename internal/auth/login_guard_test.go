@@ -0,0 +1,233 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	models "github.com/BerkatPS/internal"
+)
+
+var testLoginPolicy = LoginPolicy{MaxFailures: 5, MaxIPFailures: 20, Lockout: 15 * time.Minute, Window: time.Hour}
+
+func TestLoginPolicyRetryAt(t *testing.T) {
+	last := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		failures   int
+		free       int
+		max        int
+		wantDelay  time.Duration
+		wantLocked bool
+		wantFree   bool
+	}{
+		{name: "no failures", failures: 0, free: 1, max: 5, wantFree: true},
+		{name: "free retype", failures: 1, free: 1, max: 5, wantFree: true},
+		{name: "first backoff", failures: 2, free: 1, max: 5, wantDelay: time.Second},
+		{name: "backoff doubles", failures: 3, free: 1, max: 5, wantDelay: 2 * time.Second},
+		{name: "backoff doubles again", failures: 4, free: 1, max: 5, wantDelay: 4 * time.Second},
+		{name: "threshold locks", failures: 5, free: 1, max: 5, wantDelay: 15 * time.Minute, wantLocked: true},
+		{name: "past threshold stays locked", failures: 9, free: 1, max: 5, wantDelay: 15 * time.Minute, wantLocked: true},
+		{name: "backoff capped at lockout", failures: 15, free: 1, max: 100, wantDelay: 15 * time.Minute},
+		{name: "huge shift does not overflow", failures: 80, free: 1, max: 100, wantDelay: 15 * time.Minute},
+		{name: "IP gets half its threshold free", failures: 10, free: 10, max: 20, wantFree: true},
+		{name: "IP backoff after free half", failures: 11, free: 10, max: 20, wantDelay: time.Second},
+		{name: "IP lockout", failures: 20, free: 10, max: 20, wantDelay: 15 * time.Minute, wantLocked: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			retryAt, locked := testLoginPolicy.retryAt(tt.failures, tt.free, tt.max, last)
+			if tt.wantFree {
+				if !retryAt.IsZero() || locked {
+					t.Errorf("retryAt = %s, locked = %v, want no wait", retryAt, locked)
+				}
+				return
+			}
+			if want := last.Add(tt.wantDelay); !retryAt.Equal(want) || locked != tt.wantLocked {
+				t.Errorf("retryAt = %s, locked = %v, want %s, %v", retryAt, locked, want, tt.wantLocked)
+			}
+		})
+	}
+}
+
+// loginFailureRepo reports fixed failure counts. The embedded AuthRepository is nil, so any other method the
+// service calls panics and fails the test.
+type loginFailureRepo struct {
+	AuthRepository
+
+	accountFailures int
+	accountLast     time.Time
+	ipFailures      int
+	ipLast          time.Time
+	err             error
+
+	since    time.Time
+	ipCalled bool
+}
+
+func (f *loginFailureRepo) CountAccountFailures(ctx context.Context, email string, since time.Time) (int, time.Time, error) {
+	f.since = since
+	return f.accountFailures, f.accountLast, f.err
+}
+
+func (f *loginFailureRepo) CountIPFailures(ctx context.Context, ip string, since time.Time) (int, time.Time, error) {
+	f.ipCalled = true
+	return f.ipFailures, f.ipLast, f.err
+}
+
+func TestCheckThrottle(t *testing.T) {
+	now := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	ago := func(d time.Duration) time.Time { return now.Add(-d) }
+
+	tests := []struct {
+		name        string
+		repo        loginFailureRepo
+		ip          string
+		wantErr     error
+		wantRetryAt time.Time
+		wantLocked  bool
+	}{
+		{name: "clean account", repo: loginFailureRepo{}, ip: "203.0.113.9"},
+		{name: "free retype", repo: loginFailureRepo{accountFailures: 1, accountLast: ago(0)}, ip: "203.0.113.9"},
+		{
+			name: "in backoff", repo: loginFailureRepo{accountFailures: 3, accountLast: ago(time.Second)},
+			wantErr: ErrLoginThrottled, wantRetryAt: now.Add(time.Second),
+		},
+		{name: "backoff elapsed", repo: loginFailureRepo{accountFailures: 3, accountLast: ago(2 * time.Second)}},
+		{
+			name: "account locked", repo: loginFailureRepo{accountFailures: 5, accountLast: ago(time.Minute)},
+			wantErr: ErrLoginThrottled, wantRetryAt: now.Add(14 * time.Minute), wantLocked: true,
+		},
+		{name: "lockout expired", repo: loginFailureRepo{accountFailures: 5, accountLast: ago(15 * time.Minute)}},
+		{
+			name: "IP locked across accounts", ip: "203.0.113.9",
+			repo:    loginFailureRepo{ipFailures: 20, ipLast: ago(time.Minute)},
+			wantErr: ErrLoginThrottled, wantRetryAt: now.Add(14 * time.Minute), wantLocked: true,
+		},
+		{name: "shared IP below its free half", ip: "203.0.113.9", repo: loginFailureRepo{ipFailures: 10, ipLast: ago(0)}},
+		{
+			name: "later of account and IP wait", ip: "203.0.113.9",
+			repo: loginFailureRepo{
+				accountFailures: 4, accountLast: ago(time.Second),
+				ipFailures: 20, ipLast: ago(10 * time.Minute),
+			},
+			wantErr: ErrLoginThrottled, wantRetryAt: now.Add(5 * time.Minute), wantLocked: true,
+		},
+		{name: "repository failure", repo: loginFailureRepo{err: errors.New("connection reset")}, wantErr: errors.New("connection reset")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := tt.repo
+			service := &authService{AuthRepo: &repo, loginPolicy: testLoginPolicy}
+
+			err := service.checkThrottle(context.Background(), "site@example.com", tt.ip, now)
+			switch {
+			case tt.wantErr == nil:
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+			case errors.Is(tt.wantErr, ErrLoginThrottled):
+				var throttled *ThrottledError
+				if !errors.As(err, &throttled) {
+					t.Fatalf("error = %v, want a ThrottledError", err)
+				}
+				if !throttled.RetryAt.Equal(tt.wantRetryAt) || throttled.Locked != tt.wantLocked {
+					t.Errorf("retry at %s, locked %v, want %s, %v", throttled.RetryAt, throttled.Locked, tt.wantRetryAt, tt.wantLocked)
+				}
+			default:
+				if err == nil || err.Error() != tt.wantErr.Error() {
+					t.Fatalf("error = %v, want %v", err, tt.wantErr)
+				}
+			}
+
+			if want := now.Add(-testLoginPolicy.Window); !repo.since.Equal(want) {
+				t.Errorf("failures counted since %s, want %s", repo.since, want)
+			}
+			if repo.ipCalled != (tt.ip != "") {
+				t.Errorf("IP failures counted = %v, want %v", repo.ipCalled, tt.ip != "")
+			}
+		})
+	}
+}
+
+// lockingLoginRepo keeps login attempts in memory behind a lock standing in for the advisory locks. The embedded
+// AuthRepository is nil, so any other method the service calls panics and fails the test.
+type lockingLoginRepo struct {
+	AuthRepository
+
+	login    sync.Mutex
+	mu       sync.Mutex
+	attempts []models.LoginAttempt
+}
+
+func (f *lockingLoginRepo) LockLogins(ctx context.Context, email, ip string) (func(), error) {
+	f.login.Lock()
+	return f.login.Unlock, nil
+}
+
+func (f *lockingLoginRepo) countFailures(match func(models.LoginAttempt) bool, since time.Time) (int, time.Time, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var count int
+	var last time.Time
+	for _, attempt := range f.attempts {
+		if attempt.Outcome == LoginFailed && !attempt.CreatedAt.Before(since) && match(attempt) {
+			count++
+			if attempt.CreatedAt.After(last) {
+				last = attempt.CreatedAt
+			}
+		}
+	}
+	return count, last, nil
+}
+
+func (f *lockingLoginRepo) CountAccountFailures(ctx context.Context, email string, since time.Time) (int, time.Time, error) {
+	return f.countFailures(func(a models.LoginAttempt) bool { return a.Email == email }, since)
+}
+
+func (f *lockingLoginRepo) CountIPFailures(ctx context.Context, ip string, since time.Time) (int, time.Time, error) {
+	return f.countFailures(func(a models.LoginAttempt) bool { return a.IPAddress == ip }, since)
+}
+
+func (f *lockingLoginRepo) FindUserByEmail(ctx context.Context, email string) (*models.User, error) {
+	return nil, nil
+}
+
+func (f *lockingLoginRepo) RecordLoginAttempt(ctx context.Context, attempt *models.LoginAttempt) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.attempts = append(f.attempts, *attempt)
+	return nil
+}
+
+// Parallel guesses queue up behind the lock, so the lockout stops every guess after the threshold
+func TestAuthenticateParallelGuessesAreThrottled(t *testing.T) {
+	repo := &lockingLoginRepo{}
+	policy := testLoginPolicy
+	policy.MaxFailures = 2
+	service := &authService{AuthRepo: repo, loginPolicy: policy}
+
+	const guesses = 8
+	var wg sync.WaitGroup
+	for i := 0; i < guesses; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			service.authenticate(context.Background(), "site@example.com", "guess", "203.0.113.9")
+		}()
+	}
+	wg.Wait()
+
+	outcomes := map[string]int{}
+	for _, attempt := range repo.attempts {
+		outcomes[attempt.Outcome]++
+	}
+	if outcomes[LoginFailed] != 2 || outcomes[LoginThrottled] != guesses-2 {
+		t.Errorf("outcomes = %v, want 2 %s and %d %s", outcomes, LoginFailed, guesses-2, LoginThrottled)
+	}
+}
@@ -0,0 +1,107 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	models "github.com/BerkatPS/internal"
+	"github.com/BerkatPS/pkg/mail"
+	"github.com/BerkatPS/pkg/utils"
+)
+
+// MinPasswordLength is the shortest password a reset accepts
+const MinPasswordLength = 8
+
+var (
+	// ErrInvalidResetToken is returned for a reset token that is unknown, already used, replaced or expired
+	ErrInvalidResetToken = errors.New("invalid or expired reset token")
+	// ErrWeakPassword is returned for a new password shorter than MinPasswordLength
+	ErrWeakPassword = fmt.Errorf("password must be at least %d characters", MinPasswordLength)
+)
+
+func (a *authService) RequestPasswordReset(ctx context.Context, email string) error {
+	email = strings.TrimSpace(email)
+	if email == "" {
+		return nil
+	}
+
+	user, err := a.AuthRepo.FindUserByEmail(ctx, email)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return nil
+	}
+
+	token, err := utils.GenerateSecureToken(32)
+	if err != nil {
+		return fmt.Errorf("failed to generate token: %v", err)
+	}
+	now := time.Now()
+	record := &models.PasswordResetToken{
+		UserID:    user.ID,
+		TokenHash: utils.HashToken(token),
+		ExpiresAt: now.Add(a.resetTTL),
+		CreatedAt: now,
+	}
+	if err := a.AuthRepo.CreatePasswordReset(ctx, record); err != nil {
+		return err
+	}
+
+	// A failed delivery is not reported to the caller, which would tell them the email has an account
+	if err := a.Mailer.Send(ctx, a.resetMessage(user, token, record.ExpiresAt)); err != nil {
+		log.Printf("failed to send password reset email to user %d: %v", user.ID, err)
+	}
+	return nil
+}
+
+func (a *authService) ConfirmPasswordReset(ctx context.Context, token, newPassword string) error {
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return ErrInvalidResetToken
+	}
+	if len(newPassword) < MinPasswordLength {
+		return ErrWeakPassword
+	}
+
+	hashedPassword, err := utils.HashPassword(newPassword)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %v", err)
+	}
+	redeemed, err := a.AuthRepo.RedeemPasswordReset(ctx, utils.HashToken(token), hashedPassword)
+	if err != nil {
+		return err
+	}
+	if !redeemed {
+		return ErrInvalidResetToken
+	}
+	return nil
+}
+
+// resetMessage links to the reset page when one is configured and hands out the bare token otherwise
+func (a *authService) resetMessage(user *models.User, token string, expiresAt time.Time) mail.Message {
+	instructions := "Use this token to choose a new password:\n\n" + token
+	if a.resetURL != "" {
+		separator := "?"
+		if strings.Contains(a.resetURL, "?") {
+			separator = "&"
+		}
+		instructions = "Open this link to choose a new password:\n\n" + a.resetURL + separator + "token=" + url.QueryEscape(token)
+	}
+
+	body := fmt.Sprintf("Hello %s,\n\nSomeone asked to reset the password of your account. %s\n\n"+
+		"It can be used once and expires at %s. Resetting the password signs you out on every device.\n"+
+		"If you did not ask for this, you can ignore this email.\n",
+		user.Username, instructions, expiresAt.Format(time.RFC1123))
+
+	return mail.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body:    body,
+	}
+}
@@ -1,12 +1,24 @@
 package auth
 
-import "net/http"
+import (
+	"net/http"
 
-func RegisterRoutes(router *http.ServeMux, handler *AuthController) {
+	"github.com/BerkatPS/pkg/rbac"
+)
+
+func RegisterRoutes(router *http.ServeMux, handler *AuthController, authz *rbac.Enforcer) {
 	router.HandleFunc("POST /register", handler.CreateUser)
 	router.HandleFunc("POST /login", handler.Login)
 	router.HandleFunc("POST /logout", handler.Logout)
-	//router.HandleFunc("POST /refresh", handler.RefreshToken)
+	router.HandleFunc("POST /refresh", handler.RefreshToken)
+	router.HandleFunc("POST /forgot-password", handler.ForgotPassword)
 	router.HandleFunc("POST /reset-password", handler.ResetPassword)
-	router.HandleFunc("GET /users", handler.ShowAllUsers)
+	router.HandleFunc("GET /users", authz.Require(rbac.UserManage, handler.ShowAllUsers))
+	router.HandleFunc("POST /users/{id}/unlock", authz.Require(rbac.UserManage, handler.UnlockAccount))
+	router.HandleFunc("GET /login-attempts", authz.Require(rbac.UserManage, handler.ListLoginAttempts))
+	router.HandleFunc("POST /api-keys", handler.CreateAPIKey)
+	router.HandleFunc("GET /api-keys", handler.ListAPIKeys)
+	router.HandleFunc("DELETE /api-keys/{id}", handler.RevokeAPIKey)
+	router.HandleFunc("GET /me", handler.GetProfile)
+	router.HandleFunc("PUT /me", handler.UpdateProfile)
 }
@@ -6,6 +6,7 @@ import (
 	"reflect"
 	"strings"
 	"time"
+	"unicode"
 )
 
 // AutoMigrate creates or updates the database schema based on the provided models.
@@ -43,13 +44,37 @@ func AutoMigrate(db *sql.DB, models ...interface{}) error {
 	return nil
 }
 
+// tableNamer lets a model override the table name derived from its struct name.
+type tableNamer interface {
+	TableName() string
+}
+
 // getTableName converts the model's struct name to a snake_case table name.
 func getTableName(model interface{}) string {
+	if namer, ok := model.(tableNamer); ok {
+		return namer.TableName()
+	}
+
 	modelType := reflect.TypeOf(model)
 	if modelType.Kind() == reflect.Ptr {
 		modelType = modelType.Elem()
 	}
-	return strings.ToLower(modelType.Name()) + "s"
+	return toSnakeCase(modelType.Name()) + "s"
+}
+
+// toSnakeCase converts a CamelCase identifier such as SafetyIncident to safety_incident.
+func toSnakeCase(name string) string {
+	var b strings.Builder
+	for i, r := range name {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
 }
 
 // getFields generates the SQL columns definition from the model's struct fields.
@@ -64,8 +89,8 @@ func getFields(model interface{}) string {
 	for i := 0; i < modelType.NumField(); i++ {
 		field := modelType.Field(i)
 
-		// Skip if the field is a struct or a pointer to a struct (likely a relation)
-		if field.Type.Kind() == reflect.Struct || (field.Type.Kind() == reflect.Ptr && field.Type.Elem().Kind() == reflect.Struct) {
+		// Skip if the field is a struct, a pointer to a struct or a slice (likely a relation)
+		if isRelation(field.Type) {
 			continue
 		}
 
@@ -76,12 +101,27 @@ func getFields(model interface{}) string {
 		}
 
 		columnType := getSQLType(field.Type)
+		if columnName == "id" {
+			columnType = "SERIAL PRIMARY KEY"
+		}
 		fields = append(fields, fmt.Sprintf("%s %s", columnName, columnType))
 	}
 
 	return strings.Join(fields, ", ")
 }
 
+// isRelation reports whether a field type describes a relation rather than a column.
+// time.Time is a struct but maps to a TIMESTAMP column, so it is not treated as a relation.
+func isRelation(fieldType reflect.Type) bool {
+	if fieldType.Kind() == reflect.Ptr {
+		fieldType = fieldType.Elem()
+	}
+	if fieldType == reflect.TypeOf(time.Time{}) {
+		return false
+	}
+	return fieldType.Kind() == reflect.Struct || fieldType.Kind() == reflect.Slice
+}
+
 // getSQLType maps Go types to SQL types.
 func getSQLType(fieldType reflect.Type) string {
 	if fieldType.Kind() == reflect.Ptr {
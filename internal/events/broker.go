@@ -0,0 +1,155 @@
+package events
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	EventMessageCreated       = "message.created"
+	EventTaskStatusChanged    = "task.status_changed"
+	EventExpenseCreated       = "expense.created"
+	EventQualityCheckRecorded = "quality.check_recorded"
+	EventTaskOverdue          = "task.overdue"
+	EventRFIOverdue           = "rfi.overdue"
+
+	// DefaultHistorySize is how many recent events per project are kept so reconnecting clients can resume
+	DefaultHistorySize = 500
+
+	subscriberBuffer = 64
+)
+
+// Event is a single project event pushed to stream subscribers
+type Event struct {
+	ID        int64       `json:"id"`
+	ProjectID int64       `json:"project_id"`
+	Type      string      `json:"type"`
+	Data      interface{} `json:"data"`
+	Timestamp time.Time   `json:"timestamp"`
+}
+
+// Publisher is implemented by anything services can hand project events to
+type Publisher interface {
+	Publish(projectID int64, eventType string, data interface{})
+}
+
+// Subscription receives the live events of one project. C is closed when the subscriber
+// falls too far behind or unsubscribes; the client is expected to reconnect and resume.
+type Subscription struct {
+	C         <-chan Event
+	ch        chan Event
+	projectID int64
+}
+
+// Broker fans project events out to subscribers and keeps a short per-project history for resuming.
+// It is in-process: every replica only sees the events published on it.
+type Broker struct {
+	mu          sync.Mutex
+	seq         int64
+	historySize int
+	history     map[int64][]Event
+	trimmed     map[int64]int64 // newest event ID dropped from each project's history
+	subscribers map[int64]map[*Subscription]struct{}
+}
+
+func NewBroker(historySize int) *Broker {
+	if historySize <= 0 {
+		historySize = DefaultHistorySize
+	}
+	return &Broker{
+		// Seed IDs from the clock so IDs from before a restart are never mistaken for new ones
+		seq:         time.Now().UnixMilli() * 1000,
+		historySize: historySize,
+		history:     make(map[int64][]Event),
+		trimmed:     make(map[int64]int64),
+		subscribers: make(map[int64]map[*Subscription]struct{}),
+	}
+}
+
+// Publish records the event and delivers it to every subscriber of the project without blocking
+func (b *Broker) Publish(projectID int64, eventType string, data interface{}) {
+	if projectID <= 0 {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.seq++
+	event := Event{
+		ID:        b.seq,
+		ProjectID: projectID,
+		Type:      eventType,
+		Data:      data,
+		Timestamp: time.Now(),
+	}
+
+	history := append(b.history[projectID], event)
+	if len(history) > b.historySize {
+		drop := len(history) - b.historySize
+		b.trimmed[projectID] = history[drop-1].ID
+		history = history[drop:]
+	}
+	b.history[projectID] = history
+
+	for sub := range b.subscribers[projectID] {
+		select {
+		case sub.ch <- event:
+		default:
+			// Slow consumer: drop it rather than block publishers, it will resume from its last event ID
+			b.removeLocked(sub)
+		}
+	}
+}
+
+// Subscribe registers a subscriber for the project. When lastEventID is set, the events published
+// after it are returned for replay; resync is true when they are no longer all in the history.
+func (b *Broker) Subscribe(projectID, lastEventID int64) (sub *Subscription, replay []Event, resync bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	ch := make(chan Event, subscriberBuffer)
+	sub = &Subscription{C: ch, ch: ch, projectID: projectID}
+
+	if b.subscribers[projectID] == nil {
+		b.subscribers[projectID] = make(map[*Subscription]struct{})
+	}
+	b.subscribers[projectID][sub] = struct{}{}
+
+	if lastEventID > 0 {
+		history := b.history[projectID]
+		switch {
+		case lastEventID > b.seq:
+			resync = true
+		case lastEventID < b.trimmed[projectID]:
+			resync = true
+		}
+		for _, event := range history {
+			if event.ID > lastEventID {
+				replay = append(replay, event)
+			}
+		}
+	}
+	return sub, replay, resync
+}
+
+func (b *Broker) Unsubscribe(sub *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.removeLocked(sub)
+}
+
+func (b *Broker) removeLocked(sub *Subscription) {
+	subs, ok := b.subscribers[sub.projectID]
+	if !ok {
+		return
+	}
+	if _, ok := subs[sub]; !ok {
+		return
+	}
+	delete(subs, sub)
+	close(sub.ch)
+	if len(subs) == 0 {
+		delete(b.subscribers, sub.projectID)
+	}
+}
@@ -0,0 +1,121 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/BerkatPS/pkg/utils"
+)
+
+const heartbeatInterval = 25 * time.Second
+
+type EventsController struct {
+	Broker *Broker
+}
+
+func NewEventsController(broker *Broker) *EventsController {
+	return &EventsController{broker}
+}
+
+// StreamProjectEvents streams a project's events as Server-Sent Events. The route lets only the project's
+// members and admins subscribe.
+// Browsers' EventSource cannot set headers, so AuthMiddleware also accepts the JWT as ?access_token= here.
+// Reconnecting clients resume with the Last-Event-ID header (or ?last_event_id=); a "resync" event
+// tells them the gap is no longer buffered and they should reload through the REST endpoints.
+func (e *EventsController) StreamProjectEvents(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	projectID, err := utils.ParseInt64Param(r)
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": "Invalid project ID: " + err.Error(),
+		})
+		return
+	}
+
+	lastEventID, err := parseLastEventID(r)
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": "Invalid last event ID: " + err.Error(),
+		})
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		utils.JSONErrorResponse(w, http.StatusInternalServerError, map[string]interface{}{
+			"status":  "error",
+			"message": "Streaming is not supported",
+		})
+		return
+	}
+
+	sub, replay, resync := e.Broker.Subscribe(projectID, lastEventID)
+	defer e.Broker.Unsubscribe(sub)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	if resync {
+		fmt.Fprint(w, "event: resync\ndata: {}\n\n")
+	}
+	for _, event := range replay {
+		if err := writeEvent(w, event); err != nil {
+			return
+		}
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-sub.C:
+			if !ok {
+				// Dropped for falling behind; the client reconnects with its Last-Event-ID
+				return
+			}
+			if err := writeEvent(w, event); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+func writeEvent(w http.ResponseWriter, event Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+	return err
+}
+
+func parseLastEventID(r *http.Request) (int64, error) {
+	raw := r.Header.Get("Last-Event-ID")
+	if raw == "" {
+		raw = r.URL.Query().Get("last_event_id")
+	}
+	if raw == "" {
+		return 0, nil
+	}
+	return strconv.ParseInt(raw, 10, 64)
+}
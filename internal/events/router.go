@@ -0,0 +1,11 @@
+package events
+
+import (
+	"net/http"
+
+	"github.com/BerkatPS/pkg/rbac"
+)
+
+func RegisterRoutes(router *http.ServeMux, handler *EventsController, authz *rbac.Enforcer) {
+	router.HandleFunc("GET /events/project/{id}", authz.RequireProject(rbac.ProjectRead, rbac.PathProject("id"), handler.StreamProjectEvents))
+}
@@ -3,6 +3,9 @@ package expense
 import (
 	"encoding/json"
 	"net/http"
+	"time"
+	"github.com/BerkatPS/pkg/export"
+	"github.com/BerkatPS/pkg/principal"
 	"github.com/BerkatPS/pkg/utils"
 	models "github.com/BerkatPS/internal"
 	
@@ -74,6 +77,43 @@ func (c *ExpenseController) UpdateExpense(w http.ResponseWriter, r *http.Request
 	})
 }
 
+// ApproveExpense approves an expense in the name of the caller
+func (c *ExpenseController) ApproveExpense(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	userID, err := principal.UserIDFromRequest(r)
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusUnauthorized, map[string]interface{}{
+			"status":  "error",
+			"message": "Unauthorized: " + err.Error(),
+		})
+		return
+	}
+
+	id, err := utils.ParseInt64Param(r)
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": "invalid expense id",
+		})
+		return
+	}
+
+	if err := c.ExpenseService.ApproveExpense(ctx, id, userID); err != nil {
+		utils.JSONErrorResponse(w, http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	utils.JSONResponse(w, http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "expense approved successfully",
+	})
+}
+
 func (c *ExpenseController) DeleteExpense(w http.ResponseWriter, r *http.Request) {
 
 	ctx := r.Context()
@@ -146,7 +186,7 @@ func (c *ExpenseController) GetExpensesByStatus(w http.ResponseWriter, r *http.R
 		return
 	}
 
-	expenses, err := c.ExpenseService.GetExpensesByStatus(ctx, expense.Project.Status)
+	expenses, err := c.ExpenseService.GetExpensesByStatus(ctx, expense.Project.Status, principal.VisibleProjectIDs(ctx))
 	if err != nil {
 		utils.JSONErrorResponse(w, http.StatusBadRequest, map[string]interface{}{
 			"status":  "error",
@@ -176,7 +216,7 @@ func (c *ExpenseController) GetExpensesByApprover(w http.ResponseWriter, r *http
 		return
 	}
 
-	expenses, err := c.ExpenseService.GetExpensesByApprover(ctx, expense.ApprovedBy)
+	expenses, err := c.ExpenseService.GetExpensesByApprover(ctx, expense.ApprovedBy, principal.VisibleProjectIDs(ctx))
 
 	if err != nil {
 		utils.JSONErrorResponse(w, http.StatusBadRequest, map[string]interface{}{
@@ -286,3 +326,47 @@ func (c *ExpenseController) GetExpensesByProjectID(w http.ResponseWriter, r *htt
 		"data":    expenses,
 	})
 }
+
+var expenseExportHeaders = []string{"ID", "Project ID", "Project", "Date", "Description", "Amount", "Approved By ID", "Approved By"}
+
+// ExportExpenses downloads expenses as ?format=csv or xlsx, filtered by ?project_id=, ?approver_id=,
+// ?start_date= and ?end_date=
+func (c *ExpenseController) ExportExpenses(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	filter, format, err := parseExpenseExport(r)
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	export.Stream(w, format, "Expenses", export.FileName("expenses", time.Now()), expenseExportHeaders,
+		func(emit func(values ...interface{}) error) error {
+			return c.ExpenseService.ExportExpenses(ctx, filter, func(e models.Expense) error {
+				return emit(e.ID, e.ProjectID, e.Project.Name, e.Date, e.Description, e.Amount, e.ApprovedBy, e.ApprovedUser.Username)
+			})
+		})
+}
+
+func parseExpenseExport(r *http.Request) (ExpenseFilter, string, error) {
+	var filter ExpenseFilter
+
+	format, err := export.ParseFormat(r)
+	if err != nil {
+		return filter, "", err
+	}
+	if filter.ProjectID, err = utils.ParseInt64Query(r, "project_id"); err != nil {
+		return filter, "", err
+	}
+	if filter.ApproverID, err = utils.ParseInt64Query(r, "approver_id"); err != nil {
+		return filter, "", err
+	}
+	if filter.From, filter.To, err = export.ParseDateRange(r); err != nil {
+		return filter, "", err
+	}
+	return filter, format, nil
+}
@@ -6,6 +6,7 @@ import (
 	"errors"
 	"time"
 	models "github.com/BerkatPS/internal"
+	"github.com/lib/pq"
 
 )
 
@@ -13,12 +14,18 @@ type ExpenseRepository interface {
 	CreateExpense(ctx context.Context, expense models.Expense) error
 	UpdateExpense(ctx context.Context, expense models.Expense) error
 	DeleteExpense(ctx context.Context, id int64) error
+	// ApproveExpense records the approver; it fails when the expense does not exist
+	ApproveExpense(ctx context.Context, id, approverID int64) error
 	GetExpenseById(ctx context.Context, id int64) (models.Expense, error)
-	GetExpensesByStatus(ctx context.Context, status string) ([]models.Expense, error)
-	GetExpensesByApprover(ctx context.Context, approverID int64) ([]models.Expense, error)
+	// GetExpensesByStatus and GetExpensesByApprover keep to the expenses of projectIDs; nil matches every project
+	GetExpensesByStatus(ctx context.Context, status string, projectIDs []int64) ([]models.Expense, error)
+	GetExpensesByApprover(ctx context.Context, approverID int64, projectIDs []int64) ([]models.Expense, error)
 	GetExpensesByDateRange(ctx context.Context, startDate, endDate time.Time) ([]models.Expense, error)
 	GetTotalExpensesByProjectID(ctx context.Context, projectID int64) (float64, error)
 	GetExpensesByProjectID(ctx context.Context, projectID int64) ([]models.Expense, error)
+	// StreamExpenses hands every expense matching the filter to fn, in date order, without collecting them.
+	// Project and ApprovedUser carry the project name and approver username.
+	StreamExpenses(ctx context.Context, filter ExpenseFilter, fn func(models.Expense) error) error
 }
 
 type expenseRepository struct {
@@ -94,13 +101,13 @@ func (r *expenseRepository) GetExpensesByDateRange(ctx context.Context, startDat
 }
 
 
-func (r *expenseRepository) GetExpensesByStatus(ctx context.Context, status string) ([]models.Expense, error) {
+func (r *expenseRepository) GetExpensesByStatus(ctx context.Context, status string, projectIDs []int64) ([]models.Expense, error) {
 	query := `
 		SELECT id, project_id, description, amount, date, approved_by 
 		FROM expenses 
-		WHERE status = $1
+		WHERE status = $1 AND ($2::bigint[] IS NULL OR project_id = ANY($2))
 	`
-	rows, err := r.db.QueryContext(ctx, query, status)
+	rows, err := r.db.QueryContext(ctx, query, status, pq.Array(projectIDs))
 	if err != nil {
 		return nil, err
 	}
@@ -118,13 +125,13 @@ func (r *expenseRepository) GetExpensesByStatus(ctx context.Context, status stri
 	return expenses, nil
 }
 
-func (r *expenseRepository) GetExpensesByApprover(ctx context.Context, approverID int64) ([]models.Expense, error) {
+func (r *expenseRepository) GetExpensesByApprover(ctx context.Context, approverID int64, projectIDs []int64) ([]models.Expense, error) {
 	query := `
 		SELECT id, project_id, description, amount, date, approved_by 
 		FROM expenses 
-		WHERE approved_by = $1
+		WHERE approved_by = $1 AND ($2::bigint[] IS NULL OR project_id = ANY($2))
 	`
-	rows, err := r.db.QueryContext(ctx, query, approverID)
+	rows, err := r.db.QueryContext(ctx, query, approverID, pq.Array(projectIDs))
 	if err != nil {
 		return nil, err
 	}
@@ -165,6 +172,23 @@ func (e *expenseRepository) UpdateExpense(ctx context.Context, expense models.Ex
 	return nil
 }
 
+func (e *expenseRepository) ApproveExpense(ctx context.Context, id, approverID int64) error {
+	query := "UPDATE expenses SET approved_by = $1 WHERE id = $2"
+
+	result, err := e.db.ExecContext(ctx, query, approverID, id)
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return errors.New("expense not found")
+	}
+	return nil
+}
+
 func (e *expenseRepository) DeleteExpense(ctx context.Context, id int64) error {
 	query := "DELETE FROM expenses WHERE id = $1"
 
@@ -193,4 +217,47 @@ func (e *expenseRepository) GetExpenseById(ctx context.Context, id int64) (model
 	}
 
 	return expense, nil
-}
\ No newline at end of file
+}
+
+func (e *expenseRepository) StreamExpenses(ctx context.Context, filter ExpenseFilter, fn func(models.Expense) error) error {
+	query := `
+		SELECT e.id, e.project_id, e.description, e.amount, e.date, COALESCE(e.approved_by, 0),
+			COALESCE(p.name, ''), COALESCE(u.username, '')
+		FROM expenses e
+		LEFT JOIN projects p ON p.id = e.project_id
+		LEFT JOIN users u ON u.id = e.approved_by
+		WHERE ($1 = 0 OR e.project_id = $1)
+			AND ($2 = 0 OR e.approved_by = $2)
+			AND ($3::timestamp IS NULL OR e.date >= $3)
+			AND ($4::timestamp IS NULL OR e.date < $4)
+		ORDER BY e.date, e.id
+	`
+	rows, err := e.db.QueryContext(ctx, query, filter.ProjectID, filter.ApproverID, nullTime(filter.From), nullTime(filter.To))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		expense := models.Expense{Project: &models.Project{}, ApprovedUser: &models.User{}}
+		if err := rows.Scan(&expense.ID, &expense.ProjectID, &expense.Description, &expense.Amount, &expense.Date, &expense.ApprovedBy,
+			&expense.Project.Name, &expense.ApprovedUser.Username); err != nil {
+			return err
+		}
+		expense.Project.ID = expense.ProjectID
+		expense.ApprovedUser.ID = expense.ApprovedBy
+		if err := fn(expense); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// nullTime passes an unset bound as NULL so the filter ignores it
+func nullTime(t time.Time) interface{} {
+	if t.IsZero() {
+		return nil
+	}
+	return t
+}
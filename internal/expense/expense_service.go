@@ -5,27 +5,42 @@ import (
 	"errors"
 	"time"
 	models "github.com/BerkatPS/internal"
-
+	"github.com/BerkatPS/internal/events"
 )
 
 type ExpenseService interface {
 	CreateExpense(ctx context.Context, expense models.Expense) error
 	UpdateExpense(ctx context.Context, expense models.Expense) error
 	DeleteExpense(ctx context.Context, id int64) error
+	// ApproveExpense marks the expense as approved by approverID. Expenses are created unapproved.
+	ApproveExpense(ctx context.Context, id, approverID int64) error
 	GetExpenseById(ctx context.Context, id int64) (models.Expense, error)
-	GetExpensesByStatus(ctx context.Context, status string) ([]models.Expense, error)
-	GetExpensesByApprover(ctx context.Context, approverID int64) ([]models.Expense, error)
+	// GetExpensesByStatus and GetExpensesByApprover keep to the expenses of projectIDs; nil matches every project
+	GetExpensesByStatus(ctx context.Context, status string, projectIDs []int64) ([]models.Expense, error)
+	GetExpensesByApprover(ctx context.Context, approverID int64, projectIDs []int64) ([]models.Expense, error)
 	GetExpensesByDateRange(ctx context.Context, startDate, endDate time.Time) ([]models.Expense, error)
 	GetTotalExpensesByProjectID(ctx context.Context, projectID int64) (float64, error)
 	GetExpensesByProjectID(ctx context.Context, projectID int64) ([]models.Expense, error)
+	// ExportExpenses streams the expenses matching the filter to fn, for CSV and XLSX exports
+	ExportExpenses(ctx context.Context, filter ExpenseFilter, fn func(models.Expense) error) error
+}
+
+// ExpenseFilter narrows an expense export. Zero fields are not applied; From is inclusive and To exclusive.
+type ExpenseFilter struct {
+	ProjectID  int64
+	ApproverID int64
+	From       time.Time
+	To         time.Time
 }
 
 type expenseService struct {
 	ExpenseRepo ExpenseRepository
+	Events      events.Publisher
 }
 
-func NewExpenseService(expenseRepo ExpenseRepository) ExpenseService {
-	return &expenseService{ExpenseRepo: expenseRepo}
+// NewExpenseService creates an ExpenseService. publisher may be nil, in which case new expenses are not streamed.
+func NewExpenseService(expenseRepo ExpenseRepository, publisher events.Publisher) ExpenseService {
+	return &expenseService{ExpenseRepo: expenseRepo, Events: publisher}
 }
 
 func (s *expenseService) CreateExpense(ctx context.Context, expense models.Expense) error {
@@ -41,10 +56,13 @@ func (s *expenseService) CreateExpense(ctx context.Context, expense models.Expen
 	if expense.Date.IsZero() {
 		return errors.New("expense date is required")
 	}
-	if expense.ApprovedBy <= 0 {
-		return errors.New("approver id is required")
+	if err := s.ExpenseRepo.CreateExpense(ctx, expense); err != nil {
+		return err
+	}
+	if s.Events != nil {
+		s.Events.Publish(expense.ProjectID, events.EventExpenseCreated, expense)
 	}
-	return s.ExpenseRepo.CreateExpense(ctx, expense)
+	return nil
 }
 
 func (s *expenseService) UpdateExpense(ctx context.Context, expense models.Expense) error {
@@ -65,11 +83,17 @@ func (s *expenseService) UpdateExpense(ctx context.Context, expense models.Expen
 		return errors.New("expense date is required")
 	}
 
-	if expense.ApprovedBy <= 0 {
+	return s.ExpenseRepo.UpdateExpense(ctx, expense)
+}
+
+func (s *expenseService) ApproveExpense(ctx context.Context, id, approverID int64) error {
+	if id <= 0 {
+		return errors.New("expense id is required")
+	}
+	if approverID <= 0 {
 		return errors.New("approver id is required")
 	}
-
-	return s.ExpenseRepo.UpdateExpense(ctx, expense)
+	return s.ExpenseRepo.ApproveExpense(ctx, id, approverID)
 }
 
 func (s *expenseService) DeleteExpense(ctx context.Context, id int64) error {
@@ -87,19 +111,19 @@ func (s *expenseService) GetExpenseById(ctx context.Context, id int64) (models.E
 	return s.ExpenseRepo.GetExpenseById(ctx, id)
 }
 
-func (s *expenseService) GetExpensesByStatus(ctx context.Context, status string) ([]models.Expense, error) {
+func (s *expenseService) GetExpensesByStatus(ctx context.Context, status string, projectIDs []int64) ([]models.Expense, error) {
 	if status == "" {
 		return nil, errors.New("expense status is required")
 	}
 
-	return s.ExpenseRepo.GetExpensesByStatus(ctx, status)
+	return s.ExpenseRepo.GetExpensesByStatus(ctx, status, projectIDs)
 }
 
-func (s *expenseService) GetExpensesByApprover(ctx context.Context, approverID int64) ([]models.Expense, error) {
+func (s *expenseService) GetExpensesByApprover(ctx context.Context, approverID int64, projectIDs []int64) ([]models.Expense, error) {
 	if approverID <= 0 {
 		return nil, errors.New("approver id is required")
 	}
-	return s.ExpenseRepo.GetExpensesByApprover(ctx, approverID)
+	return s.ExpenseRepo.GetExpensesByApprover(ctx, approverID, projectIDs)
 }
 
 func (s *expenseService) GetExpensesByDateRange(ctx context.Context, startDate, endDate time.Time) ([]models.Expense, error) {
@@ -127,3 +151,13 @@ func (s *expenseService) GetExpensesByProjectID(ctx context.Context, projectID i
 	}
 	return s.ExpenseRepo.GetExpensesByProjectID(ctx, projectID)
 }
+
+func (s *expenseService) ExportExpenses(ctx context.Context, filter ExpenseFilter, fn func(models.Expense) error) error {
+	if filter.ProjectID < 0 || filter.ApproverID < 0 {
+		return errors.New("invalid project or approver id")
+	}
+	if !filter.From.IsZero() && !filter.To.IsZero() && !filter.To.After(filter.From) {
+		return errors.New("end date must not be before start date")
+	}
+	return s.ExpenseRepo.StreamExpenses(ctx, filter, fn)
+}
@@ -1,15 +1,21 @@
 package expense
 
-import "net/http"
-
-
-func RegisterRoutes(router *http.ServeMux, handler *ExpenseController) {
-	router.HandleFunc("POST /expenses", handler.CreateExpense)
-	router.HandleFunc("PUT /expenses", handler.UpdateExpense)
-	router.HandleFunc("DELETE /expenses", handler.DeleteExpense)
-	router.HandleFunc("GET /expenses", handler.GetExpenseById)
-	router.HandleFunc("GET /expenses/approver", handler.GetExpensesByApprover)
-	router.HandleFunc("GET /expenses/total/{projectID}", handler.GetTotalExpensesByProjectID)
-	router.HandleFunc("GET /expenses/status", handler.GetExpensesByStatus)
-	router.HandleFunc("GET /expenses/project", handler.GetExpensesByProjectID)
+import (
+	"net/http"
+
+	"github.com/BerkatPS/pkg/rbac"
+)
+
+
+func RegisterRoutes(router *http.ServeMux, handler *ExpenseController, authz *rbac.Enforcer) {
+	router.HandleFunc("POST /expenses", authz.RequireProject(rbac.ExpenseCreate, rbac.BodyProject("project_id"), handler.CreateExpense))
+	router.HandleFunc("PUT /expenses", authz.RequireProject(rbac.ExpenseUpdate, authz.BodyResource(rbac.ResourceExpense, "id"), handler.UpdateExpense))
+	router.HandleFunc("PUT /expenses/{id}/approve", authz.RequireProject(rbac.ExpenseApprove, authz.PathResource(rbac.ResourceExpense, "id"), handler.ApproveExpense))
+	router.HandleFunc("DELETE /expenses", authz.RequireProject(rbac.ExpenseDelete, authz.BodyResource(rbac.ResourceExpense, "id"), handler.DeleteExpense))
+	router.HandleFunc("GET /expenses/{id}", authz.RequireProject(rbac.ExpenseRead, authz.PathResource(rbac.ResourceExpense, "id"), handler.GetExpenseById))
+	router.HandleFunc("GET /expenses/approver", authz.Require(rbac.ExpenseRead, handler.GetExpensesByApprover))
+	router.HandleFunc("GET /expenses/total/{projectID}", authz.RequireProject(rbac.ExpenseRead, rbac.PathProject("projectID"), handler.GetTotalExpensesByProjectID))
+	router.HandleFunc("GET /expenses/status", authz.Require(rbac.ExpenseRead, handler.GetExpensesByStatus))
+	router.HandleFunc("GET /expenses/project/{id}", authz.RequireProject(rbac.ExpenseRead, rbac.PathProject("id"), handler.GetExpensesByProjectID))
+	router.HandleFunc("GET /expenses/export", authz.RequireProject(rbac.ExpenseRead, rbac.QueryProject("project_id"), handler.ExportExpenses))
 }
\ No newline at end of file
@@ -0,0 +1,405 @@
+package message
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	models "github.com/BerkatPS/internal"
+	"github.com/BerkatPS/pkg/principal"
+	"github.com/BerkatPS/pkg/utils"
+)
+
+type MessageController struct {
+	MessageService MessageService
+}
+
+func NewMessageController(messageService MessageService) *MessageController {
+	return &MessageController{messageService}
+}
+
+func (m *MessageController) PostMessage(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	userID, err := principal.UserIDFromRequest(r)
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusUnauthorized, map[string]interface{}{
+			"status":  "error",
+			"message": "Unauthorized: " + err.Error(),
+		})
+		return
+	}
+
+	projectID, err := utils.ParseInt64Param(r)
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": "Invalid project ID: " + err.Error(),
+		})
+		return
+	}
+
+	var postMessageRequest struct {
+		Content  string `json:"content"`
+		ParentID int64  `json:"parent_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&postMessageRequest); err != nil {
+		utils.JSONErrorResponse(w, http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": "Invalid request body: " + err.Error(),
+		})
+		return
+	}
+
+	message := &models.Message{
+		SenderID:  userID,
+		ProjectID: projectID,
+		Content:   postMessageRequest.Content,
+		ParentID:  postMessageRequest.ParentID,
+	}
+
+	if err := m.MessageService.PostMessage(ctx, message); err != nil {
+		utils.JSONErrorResponse(w, messageErrorStatus(err), map[string]interface{}{
+			"status":  "error",
+			"message": "Failed to post message: " + err.Error(),
+		})
+		return
+	}
+
+	utils.JSONResponse(w, http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "Message posted successfully",
+		"data":    message,
+	})
+}
+
+// FindProjectMessages pages through a project's channel, newest first.
+// ?before= and ?after= take RFC3339 timestamps; next_before in the response fetches the following page.
+func (m *MessageController) FindProjectMessages(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	userID, err := principal.UserIDFromRequest(r)
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusUnauthorized, map[string]interface{}{
+			"status":  "error",
+			"message": "Unauthorized: " + err.Error(),
+		})
+		return
+	}
+
+	projectID, err := utils.ParseInt64Param(r)
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": "Invalid project ID: " + err.Error(),
+		})
+		return
+	}
+
+	before, after, limit, err := parsePageQuery(r)
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": "Invalid query parameters: " + err.Error(),
+		})
+		return
+	}
+
+	messages, err := m.MessageService.FindProjectMessages(ctx, projectID, userID, before, after, limit)
+	if err != nil {
+		utils.JSONErrorResponse(w, messageErrorStatus(err), map[string]interface{}{
+			"status":  "error",
+			"message": "Failed to retrieve messages: " + err.Error(),
+		})
+		return
+	}
+
+	var nextBefore interface{}
+	if len(messages) > 0 {
+		nextBefore = messages[len(messages)-1].Timestamp.Format(time.RFC3339Nano)
+	}
+
+	utils.JSONResponse(w, http.StatusOK, map[string]interface{}{
+		"status":      "success",
+		"message":     "Messages found successfully",
+		"data":        messages,
+		"next_before": nextBefore,
+	})
+}
+
+// FindThread returns the thread a message belongs to: its root followed by every reply
+func (m *MessageController) FindThread(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	userID, err := principal.UserIDFromRequest(r)
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusUnauthorized, map[string]interface{}{
+			"status":  "error",
+			"message": "Unauthorized: " + err.Error(),
+		})
+		return
+	}
+
+	messageID, err := utils.ParseInt64Param(r)
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": "Invalid message ID: " + err.Error(),
+		})
+		return
+	}
+
+	thread, err := m.MessageService.FindThread(ctx, messageID, userID)
+	if err != nil {
+		utils.JSONErrorResponse(w, messageErrorStatus(err), map[string]interface{}{
+			"status":  "error",
+			"message": "Failed to retrieve thread: " + err.Error(),
+		})
+		return
+	}
+
+	utils.JSONResponse(w, http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "Thread found successfully",
+		"data":    thread,
+	})
+}
+
+func (m *MessageController) EditMessage(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	userID, err := principal.UserIDFromRequest(r)
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusUnauthorized, map[string]interface{}{
+			"status":  "error",
+			"message": "Unauthorized: " + err.Error(),
+		})
+		return
+	}
+
+	messageID, err := utils.ParseInt64Param(r)
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": "Invalid message ID: " + err.Error(),
+		})
+		return
+	}
+
+	var editMessageRequest struct {
+		Content string `json:"content"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&editMessageRequest); err != nil {
+		utils.JSONErrorResponse(w, http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": "Invalid request body: " + err.Error(),
+		})
+		return
+	}
+
+	message, err := m.MessageService.EditMessage(ctx, messageID, userID, editMessageRequest.Content)
+	if err != nil {
+		utils.JSONErrorResponse(w, messageErrorStatus(err), map[string]interface{}{
+			"status":  "error",
+			"message": "Failed to edit message: " + err.Error(),
+		})
+		return
+	}
+
+	utils.JSONResponse(w, http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "Message edited successfully",
+		"data":    message,
+	})
+}
+
+func (m *MessageController) DeleteMessage(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	userID, err := principal.UserIDFromRequest(r)
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusUnauthorized, map[string]interface{}{
+			"status":  "error",
+			"message": "Unauthorized: " + err.Error(),
+		})
+		return
+	}
+
+	messageID, err := utils.ParseInt64Param(r)
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": "Invalid message ID: " + err.Error(),
+		})
+		return
+	}
+
+	if err := m.MessageService.DeleteMessage(ctx, messageID, userID); err != nil {
+		utils.JSONErrorResponse(w, messageErrorStatus(err), map[string]interface{}{
+			"status":  "error",
+			"message": "Failed to delete message: " + err.Error(),
+		})
+		return
+	}
+
+	utils.JSONResponse(w, http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "Message deleted successfully",
+	})
+}
+
+// FindMentionInbox lists the caller's mentions, newest first. ?unread=true hides those already read.
+func (m *MessageController) FindMentionInbox(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	userID, err := principal.UserIDFromRequest(r)
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusUnauthorized, map[string]interface{}{
+			"status":  "error",
+			"message": "Unauthorized: " + err.Error(),
+		})
+		return
+	}
+
+	query := r.URL.Query()
+	unreadOnly := query.Get("unread") == "true"
+
+	limit := 0
+	if raw := query.Get("limit"); raw != "" {
+		if limit, err = strconv.Atoi(raw); err != nil {
+			utils.JSONErrorResponse(w, http.StatusBadRequest, map[string]interface{}{
+				"status":  "error",
+				"message": "Invalid query parameters: " + err.Error(),
+			})
+			return
+		}
+	}
+
+	mentions, unread, err := m.MessageService.FindMentionInbox(ctx, userID, unreadOnly, limit)
+	if err != nil {
+		utils.JSONErrorResponse(w, messageErrorStatus(err), map[string]interface{}{
+			"status":  "error",
+			"message": "Failed to retrieve mentions: " + err.Error(),
+		})
+		return
+	}
+
+	utils.JSONResponse(w, http.StatusOK, map[string]interface{}{
+		"status":       "success",
+		"message":      "Mentions found successfully",
+		"data":         mentions,
+		"unread_count": unread,
+	})
+}
+
+func (m *MessageController) MarkMentionRead(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	userID, err := principal.UserIDFromRequest(r)
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusUnauthorized, map[string]interface{}{
+			"status":  "error",
+			"message": "Unauthorized: " + err.Error(),
+		})
+		return
+	}
+
+	mentionID, err := utils.ParseInt64Param(r)
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": "Invalid mention ID: " + err.Error(),
+		})
+		return
+	}
+
+	if err := m.MessageService.MarkMentionRead(ctx, mentionID, userID); err != nil {
+		utils.JSONErrorResponse(w, messageErrorStatus(err), map[string]interface{}{
+			"status":  "error",
+			"message": "Failed to mark mention as read: " + err.Error(),
+		})
+		return
+	}
+
+	utils.JSONResponse(w, http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "Mention marked as read",
+	})
+}
+
+func (m *MessageController) MarkAllMentionsRead(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	userID, err := principal.UserIDFromRequest(r)
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusUnauthorized, map[string]interface{}{
+			"status":  "error",
+			"message": "Unauthorized: " + err.Error(),
+		})
+		return
+	}
+
+	if err := m.MessageService.MarkAllMentionsRead(ctx, userID); err != nil {
+		utils.JSONErrorResponse(w, messageErrorStatus(err), map[string]interface{}{
+			"status":  "error",
+			"message": "Failed to mark mentions as read: " + err.Error(),
+		})
+		return
+	}
+
+	utils.JSONResponse(w, http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "All mentions marked as read",
+	})
+}
+
+func parsePageQuery(r *http.Request) (time.Time, time.Time, int, error) {
+	query := r.URL.Query()
+
+	var before, after time.Time
+	var err error
+	if raw := query.Get("before"); raw != "" {
+		if before, err = time.Parse(time.RFC3339Nano, raw); err != nil {
+			return time.Time{}, time.Time{}, 0, err
+		}
+	}
+	if raw := query.Get("after"); raw != "" {
+		if after, err = time.Parse(time.RFC3339Nano, raw); err != nil {
+			return time.Time{}, time.Time{}, 0, err
+		}
+	}
+
+	limit := 0
+	if raw := query.Get("limit"); raw != "" {
+		if limit, err = strconv.Atoi(raw); err != nil {
+			return time.Time{}, time.Time{}, 0, err
+		}
+	}
+	return before, after, limit, nil
+}
+
+// messageErrorStatus maps membership and authorship failures to 403 Forbidden and the other sentinel errors to
+// their status codes
+func messageErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrNotProjectMember), errors.Is(err, ErrNotAuthor):
+		return http.StatusForbidden
+	case errors.Is(err, ErrParentNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrMentionNotMember):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, ErrHasReplies):
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
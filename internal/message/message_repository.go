@@ -0,0 +1,370 @@
+package message
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	models "github.com/BerkatPS/internal"
+	"github.com/lib/pq"
+)
+
+type MessageRepository interface {
+	// CreateMessage stores the message together with its Mentions
+	CreateMessage(ctx context.Context, message *models.Message) error
+	FindMessageByID(ctx context.Context, id int64) (*models.Message, error)
+	// FindMessagesByProjectID pages through a project's top-level messages, newest first. Zero before/after means no bound.
+	FindMessagesByProjectID(ctx context.Context, projectID int64, before, after time.Time, limit int) ([]models.MessageThreadSummary, error)
+	// FindReplies returns the replies of a thread root, oldest first
+	FindReplies(ctx context.Context, rootID int64) ([]models.Message, error)
+	// UpdateMessageContent saves the new content and replaces the message's mentions, keeping the read state of those still present
+	UpdateMessageContent(ctx context.Context, message *models.Message) error
+	// DeleteMessage removes the message and its mentions. It returns ErrHasReplies, deleting nothing, when the
+	// message has replies.
+	DeleteMessage(ctx context.Context, id int64) error
+	IsProjectMember(ctx context.Context, projectID, userID int64) (bool, error)
+	FindUsersByUsernames(ctx context.Context, usernames []string) ([]models.User, error)
+	FindMentionsByMessageIDs(ctx context.Context, messageIDs []int64) ([]models.MessageMention, error)
+	FindMentionsByUserID(ctx context.Context, userID int64, unreadOnly bool, limit int) ([]models.MessageMention, error)
+	CountUnreadMentions(ctx context.Context, userID int64) (int, error)
+	MarkMentionRead(ctx context.Context, id, userID int64, readAt time.Time) error
+	MarkAllMentionsRead(ctx context.Context, userID int64, readAt time.Time) error
+}
+
+type messageRepository struct {
+	db *sql.DB
+}
+
+func NewMessageRepository(db *sql.DB) MessageRepository {
+	return &messageRepository{db}
+}
+
+func (m *messageRepository) CreateMessage(ctx context.Context, message *models.Message) error {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := "INSERT INTO messages (sender_id, project_id, content, timestamp, edited_at, parent_id) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id"
+
+	err = tx.QueryRowContext(ctx, query, message.SenderID, message.ProjectID, message.Content, message.Timestamp, message.EditedAt, message.ParentID).Scan(&message.ID)
+	if err != nil {
+		return err
+	}
+
+	for i := range message.Mentions {
+		message.Mentions[i].MessageID = message.ID
+		if err := insertMention(ctx, tx, &message.Mentions[i]); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (m *messageRepository) FindMessageByID(ctx context.Context, id int64) (*models.Message, error) {
+	query := "SELECT id, sender_id, project_id, content, timestamp, edited_at, parent_id FROM messages WHERE id = $1"
+
+	var message models.Message
+	err := m.db.QueryRowContext(ctx, query, id).Scan(&message.ID, &message.SenderID, &message.ProjectID, &message.Content, &message.Timestamp, &message.EditedAt, &message.ParentID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("message not found")
+		}
+		return nil, err
+	}
+	return &message, nil
+}
+
+func (m *messageRepository) FindMessagesByProjectID(ctx context.Context, projectID int64, before, after time.Time, limit int) ([]models.MessageThreadSummary, error) {
+	query := `
+		SELECT m.id, m.sender_id, m.project_id, m.content, m.timestamp, m.edited_at, m.parent_id,
+			COUNT(r.id), MAX(r.timestamp)
+		FROM messages m
+		LEFT JOIN messages r ON r.parent_id = m.id
+		WHERE m.project_id = $1
+			AND m.parent_id = 0
+			AND ($2::timestamp IS NULL OR m.timestamp < $2)
+			AND ($3::timestamp IS NULL OR m.timestamp > $3)
+		GROUP BY m.id
+		ORDER BY m.timestamp DESC, m.id DESC
+		LIMIT $4
+	`
+
+	rows, err := m.db.QueryContext(ctx, query, projectID, nullTime(before), nullTime(after), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var threads []models.MessageThreadSummary
+	for rows.Next() {
+		var thread models.MessageThreadSummary
+		var lastReplyAt sql.NullTime
+		if err := rows.Scan(&thread.ID, &thread.SenderID, &thread.ProjectID, &thread.Content, &thread.Timestamp, &thread.EditedAt, &thread.ParentID, &thread.ReplyCount, &lastReplyAt); err != nil {
+			return nil, err
+		}
+		thread.LastReplyAt = lastReplyAt.Time
+		threads = append(threads, thread)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return threads, nil
+}
+
+func (m *messageRepository) FindReplies(ctx context.Context, rootID int64) ([]models.Message, error) {
+	query := `
+		SELECT id, sender_id, project_id, content, timestamp, edited_at, parent_id
+		FROM messages
+		WHERE parent_id = $1
+		ORDER BY timestamp ASC, id ASC
+	`
+
+	rows, err := m.db.QueryContext(ctx, query, rootID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var replies []models.Message
+	for rows.Next() {
+		var reply models.Message
+		if err := rows.Scan(&reply.ID, &reply.SenderID, &reply.ProjectID, &reply.Content, &reply.Timestamp, &reply.EditedAt, &reply.ParentID); err != nil {
+			return nil, err
+		}
+		replies = append(replies, reply)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return replies, nil
+}
+
+func (m *messageRepository) UpdateMessageContent(ctx context.Context, message *models.Message) error {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := "UPDATE messages SET content = $1, edited_at = $2 WHERE id = $3"
+
+	if _, err := tx.ExecContext(ctx, query, message.Content, message.EditedAt, message.ID); err != nil {
+		return err
+	}
+
+	userIDs := make([]int64, 0, len(message.Mentions))
+	for _, mention := range message.Mentions {
+		userIDs = append(userIDs, mention.UserID)
+	}
+
+	deleteQuery := "DELETE FROM message_mentions WHERE message_id = $1 AND NOT (user_id = ANY($2))"
+	if _, err := tx.ExecContext(ctx, deleteQuery, message.ID, pq.Array(userIDs)); err != nil {
+		return err
+	}
+
+	existsQuery := "SELECT EXISTS (SELECT 1 FROM message_mentions WHERE message_id = $1 AND user_id = $2)"
+	for i := range message.Mentions {
+		mention := &message.Mentions[i]
+		mention.MessageID = message.ID
+
+		var exists bool
+		if err := tx.QueryRowContext(ctx, existsQuery, message.ID, mention.UserID).Scan(&exists); err != nil {
+			return err
+		}
+		if exists {
+			continue
+		}
+		if err := insertMention(ctx, tx, mention); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (m *messageRepository) DeleteMessage(ctx context.Context, id int64) error {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// The reply check and the delete are one statement, so a reply posted meanwhile cannot be orphaned
+	query := "DELETE FROM messages WHERE id = $1 AND NOT EXISTS (SELECT 1 FROM messages WHERE parent_id = $1)"
+	result, err := tx.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrHasReplies
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM message_mentions WHERE message_id = $1", id); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (m *messageRepository) IsProjectMember(ctx context.Context, projectID, userID int64) (bool, error) {
+	query := "SELECT EXISTS (SELECT 1 FROM project_team WHERE project_id = $1 AND user_id = $2)"
+
+	var member bool
+	if err := m.db.QueryRowContext(ctx, query, projectID, userID).Scan(&member); err != nil {
+		return false, err
+	}
+	return member, nil
+}
+
+func (m *messageRepository) FindUsersByUsernames(ctx context.Context, usernames []string) ([]models.User, error) {
+	query := "SELECT id, username FROM users WHERE username = ANY($1)"
+
+	rows, err := m.db.QueryContext(ctx, query, pq.Array(usernames))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []models.User
+	for rows.Next() {
+		var user models.User
+		if err := rows.Scan(&user.ID, &user.Username); err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+func (m *messageRepository) FindMentionsByMessageIDs(ctx context.Context, messageIDs []int64) ([]models.MessageMention, error) {
+	query := `
+		SELECT id, message_id, project_id, user_id, username, read, read_at, created_at
+		FROM message_mentions
+		WHERE message_id = ANY($1)
+		ORDER BY id
+	`
+
+	rows, err := m.db.QueryContext(ctx, query, pq.Array(messageIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var mentions []models.MessageMention
+	for rows.Next() {
+		var mention models.MessageMention
+		if err := rows.Scan(&mention.ID, &mention.MessageID, &mention.ProjectID, &mention.UserID, &mention.Username, &mention.Read, &mention.ReadAt, &mention.CreatedAt); err != nil {
+			return nil, err
+		}
+		mentions = append(mentions, mention)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return mentions, nil
+}
+
+func (m *messageRepository) FindMentionsByUserID(ctx context.Context, userID int64, unreadOnly bool, limit int) ([]models.MessageMention, error) {
+	query := `
+		SELECT mm.id, mm.message_id, mm.project_id, mm.user_id, mm.username, mm.read, mm.read_at, mm.created_at,
+			m.id, m.sender_id, m.project_id, m.content, m.timestamp, m.edited_at, m.parent_id
+		FROM message_mentions mm
+		JOIN messages m ON m.id = mm.message_id
+		WHERE mm.user_id = $1
+			AND ($2 = FALSE OR mm.read = FALSE)
+		ORDER BY mm.created_at DESC, mm.id DESC
+		LIMIT $3
+	`
+
+	rows, err := m.db.QueryContext(ctx, query, userID, unreadOnly, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var mentions []models.MessageMention
+	for rows.Next() {
+		var mention models.MessageMention
+		var message models.Message
+		if err := rows.Scan(&mention.ID, &mention.MessageID, &mention.ProjectID, &mention.UserID, &mention.Username, &mention.Read, &mention.ReadAt, &mention.CreatedAt,
+			&message.ID, &message.SenderID, &message.ProjectID, &message.Content, &message.Timestamp, &message.EditedAt, &message.ParentID); err != nil {
+			return nil, err
+		}
+		mention.Message = &message
+		mentions = append(mentions, mention)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return mentions, nil
+}
+
+func (m *messageRepository) CountUnreadMentions(ctx context.Context, userID int64) (int, error) {
+	query := "SELECT COUNT(*) FROM message_mentions WHERE user_id = $1 AND read = FALSE"
+
+	var count int
+	if err := m.db.QueryRowContext(ctx, query, userID).Scan(&count); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+func (m *messageRepository) MarkMentionRead(ctx context.Context, id, userID int64, readAt time.Time) error {
+	query := "UPDATE message_mentions SET read = TRUE, read_at = $1 WHERE id = $2 AND user_id = $3 AND read = FALSE"
+
+	result, err := m.db.ExecContext(ctx, query, readAt, id, userID)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		// Already read is not an error; only a mention that is missing or belongs to someone else is
+		var exists bool
+		existsQuery := "SELECT EXISTS (SELECT 1 FROM message_mentions WHERE id = $1 AND user_id = $2)"
+		if err := m.db.QueryRowContext(ctx, existsQuery, id, userID).Scan(&exists); err != nil {
+			return err
+		}
+		if !exists {
+			return errors.New("mention not found")
+		}
+	}
+	return nil
+}
+
+func (m *messageRepository) MarkAllMentionsRead(ctx context.Context, userID int64, readAt time.Time) error {
+	query := "UPDATE message_mentions SET read = TRUE, read_at = $1 WHERE user_id = $2 AND read = FALSE"
+
+	_, err := m.db.ExecContext(ctx, query, readAt, userID)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func insertMention(ctx context.Context, tx *sql.Tx, mention *models.MessageMention) error {
+	query := `
+		INSERT INTO message_mentions (message_id, project_id, user_id, username, read, read_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id
+	`
+
+	return tx.QueryRowContext(ctx, query, mention.MessageID, mention.ProjectID, mention.UserID, mention.Username, mention.Read, mention.ReadAt, mention.CreatedAt).Scan(&mention.ID)
+}
+
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: !t.IsZero()}
+}
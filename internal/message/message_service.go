@@ -0,0 +1,373 @@
+package message
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	models "github.com/BerkatPS/internal"
+	"github.com/BerkatPS/internal/events"
+)
+
+const (
+	DefaultPageSize  = 50
+	MaxPageSize      = 200
+	MaxContentLength = 4000
+)
+
+// ErrNotProjectMember is returned when the caller is not on the project's team
+var ErrNotProjectMember = errors.New("user is not a member of this project")
+
+// ErrNotAuthor is returned when someone other than the author tries to change a message
+var ErrNotAuthor = errors.New("only the author can change this message")
+
+// ErrMentionNotMember is returned when a message @mentions a user who is not on the project's team
+var ErrMentionNotMember = errors.New("mentioned users are not members of this project")
+
+// ErrParentNotFound is returned when a reply points at a message that does not exist in the project
+var ErrParentNotFound = errors.New("parent message not found in this project")
+
+// ErrHasReplies is returned when deleting a thread root that has replies, which deleting would orphan or remove
+var ErrHasReplies = errors.New("message has replies and cannot be deleted")
+
+// mentionPattern matches @username where the @ does not follow a word character, so e-mail addresses are ignored
+var mentionPattern = regexp.MustCompile(`(?:^|[^\w@.])@([A-Za-z0-9_][A-Za-z0-9_.\-]*)`)
+
+type MessageService interface {
+	// PostMessage posts a top-level message, or a reply when ParentID is set. Replies to replies join the root's thread.
+	PostMessage(ctx context.Context, message *models.Message) error
+	// FindProjectMessages returns one page of a project's top-level messages with their reply counts, newest first
+	FindProjectMessages(ctx context.Context, projectID, userID int64, before, after time.Time, limit int) ([]models.MessageThreadSummary, error)
+	// FindThread returns the root of the thread containing the message with its replies, oldest first
+	FindThread(ctx context.Context, id, userID int64) (*models.Message, error)
+	EditMessage(ctx context.Context, id, userID int64, content string) (*models.Message, error)
+	DeleteMessage(ctx context.Context, id, userID int64) error
+	// FindMentionInbox returns the user's most recent mentions and how many are unread
+	FindMentionInbox(ctx context.Context, userID int64, unreadOnly bool, limit int) ([]models.MessageMention, int, error)
+	MarkMentionRead(ctx context.Context, id, userID int64) error
+	MarkAllMentionsRead(ctx context.Context, userID int64) error
+}
+
+type messageService struct {
+	MessageRepo MessageRepository
+	Events      events.Publisher
+}
+
+// NewMessageService creates a MessageService. publisher may be nil, in which case new messages are not streamed.
+func NewMessageService(messageRepo MessageRepository, publisher events.Publisher) MessageService {
+	return &messageService{messageRepo, publisher}
+}
+
+func (m *messageService) PostMessage(ctx context.Context, message *models.Message) error {
+	if message.ProjectID <= 0 {
+		return fmt.Errorf("invalid project ID")
+	}
+
+	if err := validateContent(message.Content); err != nil {
+		return err
+	}
+
+	if err := m.requireMember(ctx, message.ProjectID, message.SenderID); err != nil {
+		return err
+	}
+
+	if message.ParentID > 0 {
+		parent, err := m.MessageRepo.FindMessageByID(ctx, message.ParentID)
+		if err != nil || parent.ProjectID != message.ProjectID {
+			return ErrParentNotFound
+		}
+		// Threads are one level deep: a reply to a reply belongs to the same root
+		if parent.ParentID > 0 {
+			message.ParentID = parent.ParentID
+		}
+	}
+
+	message.Content = strings.TrimSpace(message.Content)
+	message.Timestamp = time.Now()
+	message.EditedAt = time.Time{}
+
+	mentions, err := m.resolveMentions(ctx, message)
+	if err != nil {
+		return err
+	}
+	message.Mentions = mentions
+
+	if err := m.MessageRepo.CreateMessage(ctx, message); err != nil {
+		return fmt.Errorf("failed to post message: %v", err)
+	}
+
+	if m.Events != nil {
+		m.Events.Publish(message.ProjectID, events.EventMessageCreated, message)
+	}
+	return nil
+}
+
+func (m *messageService) FindProjectMessages(ctx context.Context, projectID, userID int64, before, after time.Time, limit int) ([]models.MessageThreadSummary, error) {
+	if projectID <= 0 {
+		return nil, fmt.Errorf("invalid project ID")
+	}
+
+	if err := m.requireMember(ctx, projectID, userID); err != nil {
+		return nil, err
+	}
+
+	if limit <= 0 {
+		limit = DefaultPageSize
+	}
+	if limit > MaxPageSize {
+		limit = MaxPageSize
+	}
+
+	messages, err := m.MessageRepo.FindMessagesByProjectID(ctx, projectID, before, after, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve messages: %v", err)
+	}
+	return messages, nil
+}
+
+func (m *messageService) FindThread(ctx context.Context, id, userID int64) (*models.Message, error) {
+	if id <= 0 {
+		return nil, fmt.Errorf("invalid message ID")
+	}
+
+	message, err := m.MessageRepo.FindMessageByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve message: %v", err)
+	}
+
+	if err := m.requireMember(ctx, message.ProjectID, userID); err != nil {
+		return nil, err
+	}
+
+	root := message
+	if message.ParentID > 0 {
+		root, err = m.MessageRepo.FindMessageByID(ctx, message.ParentID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to retrieve thread root: %v", err)
+		}
+	}
+
+	replies, err := m.MessageRepo.FindReplies(ctx, root.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve replies: %v", err)
+	}
+
+	messageIDs := []int64{root.ID}
+	for _, reply := range replies {
+		messageIDs = append(messageIDs, reply.ID)
+	}
+	mentions, err := m.MessageRepo.FindMentionsByMessageIDs(ctx, messageIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve mentions: %v", err)
+	}
+
+	byMessage := make(map[int64][]models.MessageMention)
+	for _, mention := range mentions {
+		byMessage[mention.MessageID] = append(byMessage[mention.MessageID], mention)
+	}
+	for i := range replies {
+		replies[i].Mentions = byMessage[replies[i].ID]
+	}
+	root.Mentions = byMessage[root.ID]
+	root.Replies = replies
+
+	return root, nil
+}
+
+func (m *messageService) EditMessage(ctx context.Context, id, userID int64, content string) (*models.Message, error) {
+	if err := validateContent(content); err != nil {
+		return nil, err
+	}
+
+	message, err := m.loadOwnMessage(ctx, id, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	message.Content = strings.TrimSpace(content)
+	message.EditedAt = time.Now()
+
+	mentions, err := m.resolveMentions(ctx, message)
+	if err != nil {
+		return nil, err
+	}
+	message.Mentions = mentions
+
+	if err := m.MessageRepo.UpdateMessageContent(ctx, message); err != nil {
+		return nil, fmt.Errorf("failed to edit message: %v", err)
+	}
+	return message, nil
+}
+
+func (m *messageService) DeleteMessage(ctx context.Context, id, userID int64) error {
+	if _, err := m.loadOwnMessage(ctx, id, userID); err != nil {
+		return err
+	}
+
+	if err := m.MessageRepo.DeleteMessage(ctx, id); err != nil {
+		if errors.Is(err, ErrHasReplies) {
+			return err
+		}
+		return fmt.Errorf("failed to delete message: %v", err)
+	}
+	return nil
+}
+
+func (m *messageService) FindMentionInbox(ctx context.Context, userID int64, unreadOnly bool, limit int) ([]models.MessageMention, int, error) {
+	if userID <= 0 {
+		return nil, 0, fmt.Errorf("invalid user ID")
+	}
+
+	if limit <= 0 {
+		limit = DefaultPageSize
+	}
+	if limit > MaxPageSize {
+		limit = MaxPageSize
+	}
+
+	mentions, err := m.MessageRepo.FindMentionsByUserID(ctx, userID, unreadOnly, limit)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to retrieve mentions: %v", err)
+	}
+
+	unread, err := m.MessageRepo.CountUnreadMentions(ctx, userID)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to count unread mentions: %v", err)
+	}
+	return mentions, unread, nil
+}
+
+func (m *messageService) MarkMentionRead(ctx context.Context, id, userID int64) error {
+	if id <= 0 {
+		return fmt.Errorf("invalid mention ID")
+	}
+
+	if err := m.MessageRepo.MarkMentionRead(ctx, id, userID, time.Now()); err != nil {
+		return fmt.Errorf("failed to mark mention as read: %v", err)
+	}
+	return nil
+}
+
+func (m *messageService) MarkAllMentionsRead(ctx context.Context, userID int64) error {
+	if userID <= 0 {
+		return fmt.Errorf("invalid user ID")
+	}
+
+	if err := m.MessageRepo.MarkAllMentionsRead(ctx, userID, time.Now()); err != nil {
+		return fmt.Errorf("failed to mark mentions as read: %v", err)
+	}
+	return nil
+}
+
+// resolveMentions turns the @usernames in a message into mentions of project members.
+// Unknown usernames are left as plain text; known users outside the team reject the message.
+func (m *messageService) resolveMentions(ctx context.Context, message *models.Message) ([]models.MessageMention, error) {
+	usernames := parseMentions(message.Content)
+	if len(usernames) == 0 {
+		return nil, nil
+	}
+
+	users, err := m.MessageRepo.FindUsersByUsernames(ctx, usernames)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve mentions: %v", err)
+	}
+
+	var mentions []models.MessageMention
+	var outsiders []string
+	for _, user := range users {
+		if user.ID == message.SenderID {
+			continue
+		}
+
+		member, err := m.MessageRepo.IsProjectMember(ctx, message.ProjectID, user.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check project membership: %v", err)
+		}
+		if !member {
+			outsiders = append(outsiders, "@"+user.Username)
+			continue
+		}
+
+		mentions = append(mentions, models.MessageMention{
+			ProjectID: message.ProjectID,
+			UserID:    user.ID,
+			Username:  user.Username,
+			CreatedAt: time.Now(),
+		})
+	}
+
+	if len(outsiders) > 0 {
+		sort.Strings(outsiders)
+		return nil, fmt.Errorf("%w: %s", ErrMentionNotMember, strings.Join(outsiders, ", "))
+	}
+	return mentions, nil
+}
+
+// parseMentions returns the distinct usernames mentioned in content, in order of appearance
+func parseMentions(content string) []string {
+	var usernames []string
+	seen := make(map[string]bool)
+	for _, match := range mentionPattern.FindAllStringSubmatch(content, -1) {
+		// Trailing punctuation such as "@ana." ends the sentence, not the username
+		username := strings.TrimRight(match[1], ".-")
+		if username == "" || seen[username] {
+			continue
+		}
+		seen[username] = true
+		usernames = append(usernames, username)
+	}
+	return usernames
+}
+
+// loadOwnMessage fetches a message and checks the caller wrote it and is still on the project's team
+func (m *messageService) loadOwnMessage(ctx context.Context, id, userID int64) (*models.Message, error) {
+	if id <= 0 {
+		return nil, fmt.Errorf("invalid message ID")
+	}
+
+	message, err := m.MessageRepo.FindMessageByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve message: %v", err)
+	}
+
+	if message.SenderID != userID {
+		return nil, ErrNotAuthor
+	}
+
+	if err := m.requireMember(ctx, message.ProjectID, userID); err != nil {
+		return nil, err
+	}
+	return message, nil
+}
+
+func (m *messageService) requireMember(ctx context.Context, projectID, userID int64) error {
+	if userID <= 0 {
+		return fmt.Errorf("invalid user ID")
+	}
+
+	member, err := m.MessageRepo.IsProjectMember(ctx, projectID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to check project membership: %v", err)
+	}
+
+	if !member {
+		return ErrNotProjectMember
+	}
+	return nil
+}
+
+func validateContent(content string) error {
+	content = strings.TrimSpace(content)
+	if content == "" {
+		return fmt.Errorf("content cannot be empty")
+	}
+
+	if len(content) > MaxContentLength {
+		return fmt.Errorf("content cannot be longer than %d characters", MaxContentLength)
+	}
+	return nil
+}
@@ -0,0 +1,19 @@
+package message
+
+import (
+	"net/http"
+
+	"github.com/BerkatPS/pkg/rbac"
+)
+
+func RegisterRoutes(router *http.ServeMux, handler *MessageController, authz *rbac.Enforcer) {
+	router.HandleFunc("POST /messages/project/{id}", authz.RequireProject(rbac.MessagePost, rbac.PathProject("id"), handler.PostMessage))
+	router.HandleFunc("GET /messages/project/{id}", authz.RequireProject(rbac.MessageRead, rbac.PathProject("id"), handler.FindProjectMessages))
+	router.HandleFunc("GET /messages/thread/{id}", authz.RequireProject(rbac.MessageRead, authz.PathResource(rbac.ResourceMessage, "id"), handler.FindThread))
+	router.HandleFunc("PUT /messages/{id}", authz.RequireProject(rbac.MessagePost, authz.PathResource(rbac.ResourceMessage, "id"), handler.EditMessage))
+	router.HandleFunc("DELETE /messages/{id}", authz.RequireProject(rbac.MessagePost, authz.PathResource(rbac.ResourceMessage, "id"), handler.DeleteMessage))
+	// Mentions belong to the caller rather than to one project
+	router.HandleFunc("GET /messages/mentions", authz.Require(rbac.MessageRead, handler.FindMentionInbox))
+	router.HandleFunc("PUT /messages/mentions/read", authz.Require(rbac.MessageRead, handler.MarkAllMentionsRead))
+	router.HandleFunc("PUT /messages/mentions/{id}/read", authz.Require(rbac.MessageRead, handler.MarkMentionRead))
+}
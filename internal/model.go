@@ -50,17 +50,34 @@ type Project struct {
 	Presences       []Presence       `json:"presences"`        // One-to-Many relationship with Presence
 }
 
+// ProjectTeam is a user's membership of a project, with their role on that project
+type ProjectTeam struct {
+	ProjectID int64    `json:"project_id"`
+	UserID    int64    `json:"user_id"`
+	Role      string   `json:"role"`
+	Project   *Project `json:"project"` // Many-to-One
+	User      *User    `json:"user"`    // Many-to-One
+}
+
+// TableName keeps the table name used by the project team queries
+func (ProjectTeam) TableName() string {
+	return "project_team"
+}
+
 type Task struct {
-	ID           int64     `json:"id"`
-	ProjectID    int64     `json:"project_id"`
-	Name         string    `json:"name"`
-	Description  string    `json:"description"`
-	Status       string    `json:"status"`
-	StartDate    time.Time `json:"start_date"`
-	EndDate      time.Time `json:"end_date"`
-	AssignedToID int64     `json:"assigned_to_id"`
-	Project      *Project  `json:"project"`     // Many-to-One
-	AssignedTo   *User     `json:"assigned_to"` // Many-to-One
+	ID                 int64     `json:"id"`
+	ProjectID          int64     `json:"project_id"`
+	Name               string    `json:"name"`
+	Description        string    `json:"description"`
+	Status             string    `json:"status"`
+	StartDate          time.Time `json:"start_date"`
+	EndDate            time.Time `json:"end_date"`
+	AssignedToID       int64     `json:"assigned_to_id"`
+	RequiredPermitType string    `json:"required_permit_type"` // Permit-to-work type (e.g., HOT_WORK) needed before work starts
+	StartedAt          time.Time `json:"started_at"`           // When the task was marked in progress
+	CompletedAt        time.Time `json:"completed_at"`         // When the task was marked done
+	Project            *Project  `json:"project"`              // Many-to-One
+	AssignedTo         *User     `json:"assigned_to"`          // Many-to-One
 }
 
 type Expense struct {
@@ -75,25 +92,95 @@ type Expense struct {
 }
 
 type Document struct {
+	ID         int64     `json:"id"`
+	ProjectID  int64     `json:"project_id"`
+	Name       string    `json:"name"`
+	Type       string    `json:"type"`
+	URL        string    `json:"url"`
+	FileName   string    `json:"file_name"` // Original name of the uploaded file
+	Size       int64     `json:"size"`      // Bytes
+	MimeType   string    `json:"mime_type"`
+	SHA256     string    `json:"sha256"`      // Hex digest of the stored content
+	StorageKey string    `json:"storage_key"` // Key of the content in the blob store
+	UploadedBy int64     `json:"uploaded_by"`
+	UploadDate time.Time `json:"upload_date"`
+	// The file fields above mirror the current revision so existing readers keep working
+	CurrentRevisionID int64              `json:"current_revision_id"`
+	Revision          string             `json:"revision"`      // Label of the current revision, e.g. "B"
+	Status            string             `json:"status"`        // ACTIVE, or ARCHIVED once every revision is archived
+	Project           *Project           `json:"project"`       // Many-to-One
+	UploadedUser      *User              `json:"uploaded_user"` // Many-to-One
+	Revisions         []DocumentRevision `json:"revisions"`     // One-to-Many
+}
+
+// DocumentRevision is one uploaded version of a logical Document, e.g. Rev A, B, C of a drawing
+type DocumentRevision struct {
 	ID           int64     `json:"id"`
-	ProjectID    int64     `json:"project_id"`
-	Name         string    `json:"name"`
-	Type         string    `json:"type"`
-	URL          string    `json:"url"`
+	DocumentID   int64     `json:"document_id"`
+	Number       int       `json:"number"` // 1-based position in the document's history
+	Label        string    `json:"label"`
+	FileName     string    `json:"file_name"`
+	Size         int64     `json:"size"`
+	MimeType     string    `json:"mime_type"`
+	SHA256       string    `json:"sha256"`
+	StorageKey   string    `json:"storage_key"`
+	Notes        string    `json:"notes"` // Why this revision supersedes the previous one
+	Status       string    `json:"status"`
+	SupersededBy int64     `json:"superseded_by"`
 	UploadedBy   int64     `json:"uploaded_by"`
 	UploadDate   time.Time `json:"upload_date"`
-	Project      *Project  `json:"project"`       // Many-to-One
-	UploadedUser *User     `json:"uploaded_user"` // Many-to-One
+	ArchivedBy   int64     `json:"archived_by"`
+	ArchivedAt   time.Time `json:"archived_at"`
+	Document     *Document `json:"document"` // Many-to-One
+}
+
+// DocumentRevisionDiff compares the metadata of two revisions of a document. It is not a table.
+type DocumentRevisionDiff struct {
+	DocumentID     int64                 `json:"document_id"`
+	From           DocumentRevision      `json:"from"`
+	To             DocumentRevision      `json:"to"`
+	ContentChanged bool                  `json:"content_changed"`
+	Changes        []RevisionFieldChange `json:"changes"`
+}
+
+type RevisionFieldChange struct {
+	Field string      `json:"field"`
+	From  interface{} `json:"from"`
+	To    interface{} `json:"to"`
 }
 
 type Message struct {
+	ID        int64            `json:"id"`
+	SenderID  int64            `json:"sender_id"`
+	ProjectID int64            `json:"project_id"`
+	Content   string           `json:"content"`
+	Timestamp time.Time        `json:"timestamp"`
+	EditedAt  time.Time        `json:"edited_at"` // Zero until the author edits the message
+	ParentID  int64            `json:"parent_id"` // Thread root this message replies to, 0 for top-level messages
+	Sender    *User            `json:"sender"`    // Many-to-One
+	Project   *Project         `json:"project"`   // Many-to-One
+	Replies   []Message        `json:"replies"`   // One-to-Many
+	Mentions  []MessageMention `json:"mentions"`  // One-to-Many
+}
+
+// MessageThreadSummary is a top-level message as listed in a project channel. It is not a table.
+type MessageThreadSummary struct {
+	Message
+	ReplyCount  int       `json:"reply_count"`
+	LastReplyAt time.Time `json:"last_reply_at"`
+}
+
+// MessageMention is one @username in a message; together they form each user's mention inbox
+type MessageMention struct {
 	ID        int64     `json:"id"`
-	SenderID  int64     `json:"sender_id"`
+	MessageID int64     `json:"message_id"`
 	ProjectID int64     `json:"project_id"`
-	Content   string    `json:"content"`
-	Timestamp time.Time `json:"timestamp"`
-	Sender    *User     `json:"sender"`  // Many-to-One
-	Project   *Project  `json:"project"` // Many-to-One
+	UserID    int64     `json:"user_id"` // The mentioned user
+	Username  string    `json:"username"`
+	Read      bool      `json:"read"`
+	ReadAt    time.Time `json:"read_at"`
+	CreatedAt time.Time `json:"created_at"`
+	Message   *Message  `json:"message"` // Many-to-One
 }
 
 type QualityCheck struct {
@@ -108,26 +195,322 @@ type QualityCheck struct {
 }
 
 type SafetyIncident struct {
+	ID                  int64                `json:"id"`
+	ProjectID           int64                `json:"project_id"`
+	ReporterID          int64                `json:"reporter_id"` // 0 for anonymous reports
+	Anonymous           bool                 `json:"anonymous"`
+	Date                time.Time            `json:"date"`
+	Description         string               `json:"description"`
+	Severity            string               `json:"severity"`
+	Type                string               `json:"type"` // Classification used for KPIs (e.g., NEAR_MISS, FIRST_AID, RECORDABLE, LOST_TIME, FATALITY)
+	Status              string               `json:"status"`
+	RootCause           string               `json:"root_cause"`           // Root-cause analysis recorded during investigation
+	ClosureVerification string               `json:"closure_verification"` // How the corrective actions were verified before closing
+	VerifiedBy          int64                `json:"verified_by"`
+	Project             *Project             `json:"project"`            // Many-to-One
+	Reporter            *User                `json:"reporter"`           // Many-to-One
+	CorrectiveActions   []CorrectiveAction   `json:"corrective_actions"` // One-to-Many
+	Transitions         []IncidentTransition `json:"transitions"`        // One-to-Many, ordered by timestamp
+}
+
+type CorrectiveAction struct {
+	ID          int64           `json:"id"`
+	IncidentID  int64           `json:"incident_id"`
+	Description string          `json:"description"`
+	OwnerID     int64           `json:"owner_id"`
+	DueDate     time.Time       `json:"due_date"`
+	Status      string          `json:"status"`
+	CompletedAt time.Time       `json:"completed_at"`
+	CompletedBy int64           `json:"completed_by"`
+	Incident    *SafetyIncident `json:"incident"` // Many-to-One
+	Owner       *User           `json:"owner"`    // Many-to-One
+}
+
+// AnonymousReportToken links an anonymous report to the SHA-256 hash of the tracking token handed to its reporter
+type AnonymousReportToken struct {
+	ID         int64           `json:"id"`
+	IncidentID int64           `json:"incident_id"`
+	TokenHash  string          `json:"token_hash"`
+	Incident   *SafetyIncident `json:"incident"` // One-to-One
+}
+
+// AnonymousReportAttempt counts an anonymous report against the IP address it came from. It is not linked to the
+// report, only the SHA-256 hash of the address is stored, and the time is cut to the hour, so it cannot be
+// matched back to a report.
+type AnonymousReportAttempt struct {
+	ID        int64     `json:"id"`
+	IPHash    string    `json:"ip_hash"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type IncidentTransition struct {
+	ID         int64           `json:"id"`
+	IncidentID int64           `json:"incident_id"`
+	FromStatus string          `json:"from_status"`
+	ToStatus   string          `json:"to_status"`
+	ActorID    int64           `json:"actor_id"`
+	Notes      string          `json:"notes"`
+	Timestamp  time.Time       `json:"timestamp"`
+	Incident   *SafetyIncident `json:"incident"` // Many-to-One
+	Actor      *User           `json:"actor"`    // Many-to-One
+}
+
+// SafetyKPI holds incident rates for a project, or for the whole portfolio when ProjectID is 0
+type SafetyKPI struct {
+	ProjectID             int64       `json:"project_id"`
+	ProjectName           string      `json:"project_name"`
+	StartDate             time.Time   `json:"start_date"`
+	EndDate               time.Time   `json:"end_date"`
+	HoursWorked           float64     `json:"hours_worked"` // Labour exposure derived from presences
+	TotalIncidents        int         `json:"total_incidents"`
+	RecordableIncidents   int         `json:"recordable_incidents"`
+	LostTimeInjuries      int         `json:"lost_time_injuries"`
+	NearMisses            int         `json:"near_misses"`
+	UnverifiedReports     int         `json:"unverified_reports"` // Anonymous reports not triaged yet; left out of every other figure
+	TRIR                  float64     `json:"trir"`               // Recordable incidents per 200,000 hours worked
+	LTIFR                 float64     `json:"ltifr"`              // Lost-time injuries per 1,000,000 hours worked
+	SeverityScore         int         `json:"severity_score"`     // Sum of severity weights of all incidents in the range
+	SeverityRate          float64     `json:"severity_rate"`      // Severity score per 200,000 hours worked
+	LastIncidentDate      time.Time   `json:"last_incident_date"`
+	DaysSinceLastIncident *int        `json:"days_since_last_incident"` // nil when no incident has ever been recorded
+	Projects              []SafetyKPI `json:"projects,omitempty"`       // Per-project breakdown of a portfolio KPI
+}
+
+// WorkPermit authorises high-risk work (hot work, confined space, working at height) on a project or a single task
+type WorkPermit struct {
+	ID                int64                 `json:"id"`
+	ProjectID         int64                 `json:"project_id"`
+	TaskID            int64                 `json:"task_id"` // 0 when the permit covers the whole project
+	Type              string                `json:"type"`
+	Description       string                `json:"description"`
+	Status            string                `json:"status"` // DRAFT, ISSUED, ACTIVE, SUSPENDED or CLOSED
+	ValidFrom         time.Time             `json:"valid_from"`
+	ValidUntil        time.Time             `json:"valid_until"`
+	IssuerID          int64                 `json:"issuer_id"`
+	IssuerSignature   string                `json:"issuer_signature"`
+	IssuedAt          time.Time             `json:"issued_at"`
+	ReceiverID        int64                 `json:"receiver_id"`
+	ReceiverSignature string                `json:"receiver_signature"`
+	AcceptedAt        time.Time             `json:"accepted_at"`
+	SuspensionReason  string                `json:"suspension_reason"`
+	SuspendedBy       int64                 `json:"suspended_by"`
+	SuspendedAt       time.Time             `json:"suspended_at"`
+	ClosedBy          int64                 `json:"closed_by"`
+	ClosedAt          time.Time             `json:"closed_at"`
+	ClosureNotes      string                `json:"closure_notes"`
+	Project           *Project              `json:"project"`   // Many-to-One
+	Task              *Task                 `json:"task"`      // Many-to-One
+	Issuer            *User                 `json:"issuer"`    // Many-to-One
+	Receiver          *User                 `json:"receiver"`  // Many-to-One
+	Checklist         []PermitChecklistItem `json:"checklist"` // One-to-Many
+}
+
+type PermitChecklistItem struct {
+	ID        int64       `json:"id"`
+	PermitID  int64       `json:"permit_id"`
+	Hazard    string      `json:"hazard"`
+	Control   string      `json:"control"`
+	Checked   bool        `json:"checked"`
+	CheckedBy int64       `json:"checked_by"`
+	CheckedAt time.Time   `json:"checked_at"`
+	Permit    *WorkPermit `json:"permit"` // Many-to-One
+}
+
+// RFI is a Request for Information sent to a designer or consultant about a project
+type RFI struct {
+	ID             int64         `json:"id"`
+	ProjectID      int64         `json:"project_id"`
+	Number         int           `json:"number"` // Sequential per project, shown as RFI-001
+	Subject        string        `json:"subject"`
+	Question       string        `json:"question"`
+	RaisedBy       int64         `json:"raised_by"`
+	AddresseeID    int64         `json:"addressee_id"`
+	RequiredBy     time.Time     `json:"required_by"`
+	Status         string        `json:"status"`
+	IssuedAt       time.Time     `json:"issued_at"`
+	Response       string        `json:"response"` // The addressee's official response
+	RespondedBy    int64         `json:"responded_by"`
+	RespondedAt    time.Time     `json:"responded_at"`
+	ClosedAt       time.Time     `json:"closed_at"`
+	CreatedAt      time.Time     `json:"created_at"`
+	Project        *Project      `json:"project"`         // Many-to-One
+	Addressee      *User         `json:"addressee"`       // Many-to-One
+	Documents      []RFIDocument `json:"documents"`       // One-to-Many
+	UnblockedTasks []RFITask     `json:"unblocked_tasks"` // One-to-Many
+}
+
+func (RFI) TableName() string {
+	return "rfis"
+}
+
+// RFIDocument references a drawing or specification an RFI is about
+type RFIDocument struct {
+	ID         int64     `json:"id"`
+	RFIID      int64     `json:"rfi_id"`
+	DocumentID int64     `json:"document_id"`
+	RevisionID int64     `json:"revision_id"` // Revision current when the RFI referenced it, 0 if the document had none
+	Document   *Document `json:"document"`    // Many-to-One
+}
+
+func (RFIDocument) TableName() string {
+	return "rfi_documents"
+}
+
+// RFITask links an answered RFI to a task its response unblocks
+type RFITask struct {
+	ID       int64     `json:"id"`
+	RFIID    int64     `json:"rfi_id"`
+	TaskID   int64     `json:"task_id"`
+	LinkedBy int64     `json:"linked_by"`
+	LinkedAt time.Time `json:"linked_at"`
+	Task     *Task     `json:"task"` // Many-to-One
+}
+
+func (RFITask) TableName() string {
+	return "rfi_tasks"
+}
+
+// RFIResponseTime summarises how quickly a project's RFIs get answered. It is not a table.
+type RFIResponseTime struct {
+	ProjectID            int64   `json:"project_id"`
+	ProjectName          string  `json:"project_name"`
+	AnsweredCount        int     `json:"answered_count"`
+	AverageResponseHours float64 `json:"average_response_hours"`
+	OpenCount            int     `json:"open_count"`
+	OverdueCount         int     `json:"overdue_count"`
+}
+
+// Submittal is an item in a project's submittal register, such as a shop drawing or material sample awaiting approval
+type Submittal struct {
+	ID                   int64               `json:"id"`
+	ProjectID            int64               `json:"project_id"`
+	Number               int                 `json:"number"` // Sequential per project
+	Title                string              `json:"title"`
+	Type                 string              `json:"type"`
+	SpecSection          string              `json:"spec_section"`
+	Description          string              `json:"description"`
+	SubmittedBy          int64               `json:"submitted_by"`
+	ContractorReviewerID int64               `json:"contractor_reviewer_id"`
+	ConsultantReviewerID int64               `json:"consultant_reviewer_id"`
+	OwnerReviewerID      int64               `json:"owner_reviewer_id"`
+	RequiredOnSite       time.Time           `json:"required_on_site"`
+	LeadTimeDays         int                 `json:"lead_time_days"` // Procurement lead time; the order must be placed this many days before RequiredOnSite
+	Cycle                int                 `json:"cycle"`          // 1 for the first submission, incremented on every resubmission
+	Status               string              `json:"status"`
+	CurrentStep          string              `json:"current_step"` // Review step waiting for an outcome, empty once reviewing stops
+	CreatedAt            time.Time           `json:"created_at"`
+	ApprovedAt           time.Time           `json:"approved_at"`
+	Project              *Project            `json:"project"`   // Many-to-One
+	Documents            []SubmittalDocument `json:"documents"` // One-to-Many
+	Reviews              []SubmittalReview   `json:"reviews"`   // One-to-Many
+}
+
+// SubmittalDocument links a submittal cycle to an uploaded document revision
+type SubmittalDocument struct {
 	ID          int64     `json:"id"`
-	ProjectID   int64     `json:"project_id"`
-	ReporterID  int64     `json:"reporter_id"`
-	Date        time.Time `json:"date"`
-	Description string    `json:"description"`
-	Severity    string    `json:"severity"`
-	Status      string    `json:"status"`
-	Project     *Project  `json:"project"`  // Many-to-One
-	Reporter    *User     `json:"reporter"` // Many-to-One
+	SubmittalID int64     `json:"submittal_id"`
+	Cycle       int       `json:"cycle"`
+	DocumentID  int64     `json:"document_id"`
+	RevisionID  int64     `json:"revision_id"`
+	Document    *Document `json:"document"` // Many-to-One
+}
+
+// SubmittalReview is one step (contractor, consultant, owner) of one submittal cycle
+type SubmittalReview struct {
+	ID          int64     `json:"id"`
+	SubmittalID int64     `json:"submittal_id"`
+	Cycle       int       `json:"cycle"`
+	Step        string    `json:"step"`
+	Sequence    int       `json:"sequence"`
+	ReviewerID  int64     `json:"reviewer_id"`
+	Outcome     string    `json:"outcome"`
+	Comments    string    `json:"comments"`
+	ReviewedAt  time.Time `json:"reviewed_at"`
+}
+
+// SubmittalBlockingItem is a submittal that is not approved yet and therefore holds up procurement. It is not a table.
+type SubmittalBlockingItem struct {
+	SubmittalID    int64     `json:"submittal_id"`
+	ProjectID      int64     `json:"project_id"`
+	Number         int       `json:"number"`
+	Title          string    `json:"title"`
+	Type           string    `json:"type"`
+	Status         string    `json:"status"`
+	Cycle          int       `json:"cycle"`
+	CurrentStep    string    `json:"current_step"`
+	WaitingOn      int64     `json:"waiting_on"` // Reviewer of the current step, or the submitter when a resubmission is due
+	RequiredOnSite time.Time `json:"required_on_site"`
+	OrderBy        time.Time `json:"order_by"` // Latest date procurement can be ordered to arrive on time
+	DaysToOrderBy  int       `json:"days_to_order_by"`
+	Late           bool      `json:"late"` // The order-by date has passed
 }
 
 type Report struct {
-	ID           int64     `json:"id"`
-	ProjectID    int64     `json:"project_id"`
-	Type         string    `json:"type"`
-	Content      string    `json:"content"`
-	CreatedBy    int64     `json:"created_by"`
-	CreationDate time.Time `json:"creation_date"`
-	Project      *Project  `json:"project"` // Many-to-One
-	Creator      *User     `json:"creator"` // Many-to-One
+	ID           int64             `json:"id"`
+	ProjectID    int64             `json:"project_id"`
+	Type         string            `json:"type"`
+	Content      string            `json:"content"`
+	CreatedBy    int64             `json:"created_by"`
+	CreationDate time.Time         `json:"creation_date"`
+	ReportDate   time.Time         `json:"report_date"` // Day the report covers
+	UpdatedBy    int64             `json:"updated_by"`
+	UpdatedAt    time.Time         `json:"updated_at"`
+	Project      *Project          `json:"project"`          // Many-to-One
+	Creator      *User             `json:"creator"`          // Many-to-One
+	Daily        *DailySiteReport  `json:"daily,omitempty"`  // Decoded Content of a daily report
+	Weekly       *WeeklySiteReport `json:"weekly,omitempty"` // Decoded Content of a weekly report
+}
+
+// DailySiteReport is the content of a daily report: what happened on site on one day. It is not a table;
+// it is stored as JSON in Report.Content.
+type DailySiteReport struct {
+	Date            time.Time        `json:"date"`
+	Weather         string           `json:"weather"` // Written by the site team, kept when the report is recompiled
+	Notes           string           `json:"notes"`   // Written by the site team, kept when the report is recompiled
+	Attendance      DailyAttendance  `json:"attendance"`
+	TasksStarted    []Task           `json:"tasks_started"`
+	TasksCompleted  []Task           `json:"tasks_completed"`
+	Expenses        []Expense        `json:"expenses"`
+	ExpenseTotal    float64          `json:"expense_total"`
+	QualityChecks   []QualityCheck   `json:"quality_checks"`
+	QualitySummary  map[string]int   `json:"quality_summary"` // Number of checks per status
+	SafetyIncidents []SafetyIncident `json:"safety_incidents"`
+	CompiledAt      time.Time        `json:"compiled_at"`
+}
+
+// DailyAttendance summarises the presences recorded on a project for one day. It is not a table.
+type DailyAttendance struct {
+	Total    int            `json:"total"`
+	ByStatus map[string]int `json:"by_status"`
+	Entries  []Presence     `json:"entries"`
+}
+
+// WeeklySiteReport is the content of a weekly report: a project's site activity over seven days. It is not a table;
+// it is stored as JSON in Report.Content.
+type WeeklySiteReport struct {
+	StartDate          time.Time          `json:"start_date"`
+	EndDate            time.Time          `json:"end_date"` // Last day included
+	Days               []WeeklyDaySummary `json:"days"`
+	AttendanceTotal    int                `json:"attendance_total"` // Person-days recorded
+	AttendanceByStatus map[string]int     `json:"attendance_by_status"`
+	TasksStarted       []Task             `json:"tasks_started"`
+	TasksCompleted     []Task             `json:"tasks_completed"`
+	ExpenseCount       int                `json:"expense_count"`
+	ExpenseTotal       float64            `json:"expense_total"`
+	QualitySummary     map[string]int     `json:"quality_summary"` // Number of checks per status
+	SafetyIncidents    []SafetyIncident   `json:"safety_incidents"`
+	CompiledAt         time.Time          `json:"compiled_at"`
+}
+
+// WeeklyDaySummary is one day's figures in a weekly report. It is not a table.
+type WeeklyDaySummary struct {
+	Date            time.Time `json:"date"`
+	Attendance      int       `json:"attendance"`
+	TasksStarted    int       `json:"tasks_started"`
+	TasksCompleted  int       `json:"tasks_completed"`
+	ExpenseTotal    float64   `json:"expense_total"`
+	QualityChecks   int       `json:"quality_checks"`
+	SafetyIncidents int       `json:"safety_incidents"`
 }
 
 type QualityReport struct {
@@ -141,8 +524,200 @@ type QualityReport struct {
 	StartDate         time.Time      `json:"start_date"`
 	EndDate           time.Time      `json:"end_date"`
 	GeneratedDate     time.Time      `json:"generated_date"`
-	QualityChecks     []QualityCheck `json:"quality_checks"`     // List of quality checks included in the report
-	OverallStatus     string         `json:"overall_status"`     // Overall status of quality checks (e.g., Passed, Failed, Mixed)
+	QualityChecks     []QualityCheck `json:"quality_checks"` // List of quality checks included in the report
+	OverallStatus     string         `json:"overall_status"` // Overall status of quality checks (e.g., Passed, Failed, Mixed)
+	TotalChecks       int            `json:"total_checks"`
+	PassedChecks      int            `json:"passed_checks"`
+	FailedChecks      int            `json:"failed_checks"`
 	Comments          string         `json:"comments"`           // General comments on the report
 	AttachedDocuments []Document     `json:"attached_documents"` // Any related documents
 }
+
+// QualityReportCheck is a copy of a quality check as it was when the report was generated,
+// so later edits to the check do not change the report
+type QualityReportCheck struct {
+	ID              int64     `json:"id"`
+	QualityReportID int64     `json:"quality_report_id"`
+	QualityCheckID  int64     `json:"quality_check_id"`
+	ProjectID       int64     `json:"project_id"`
+	InspectorID     int64     `json:"inspector_id"`
+	Date            time.Time `json:"date"`
+	Status          string    `json:"status"`
+	Comments        string    `json:"comments"`
+}
+
+// QualityReportDocument attaches a document to a quality report, pinned to the revision current at the time
+type QualityReportDocument struct {
+	ID              int64 `json:"id"`
+	QualityReportID int64 `json:"quality_report_id"`
+	DocumentID      int64 `json:"document_id"`
+	RevisionID      int64 `json:"revision_id"`
+}
+
+// ReportTemplate is an uploaded layout for rendering site reports, written in Go template syntax.
+// A template with ProjectID 0 belongs to the organization and is available to every project.
+type ReportTemplate struct {
+	ID         int64     `json:"id"`
+	ProjectID  int64     `json:"project_id"`
+	Name       string    `json:"name"`
+	Format     string    `json:"format"`      // html or markdown
+	ReportType string    `json:"report_type"` // daily, weekly, or empty for any
+	Body       string    `json:"body"`
+	IsDefault  bool      `json:"is_default"` // Used when a report is rendered without choosing a template
+	CreatedBy  int64     `json:"created_by"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedBy  int64     `json:"updated_by"`
+	UpdatedAt  time.Time `json:"updated_at"`
+	Project    *Project  `json:"project"` // Many-to-One
+}
+
+// ReportTemplateData is what a report template is executed with. Field names are the contract with
+// uploaded templates, so they only ever grow. It is not a table.
+type ReportTemplateData struct {
+	Report      TemplateReport     `json:"report"`
+	Project     TemplateProject    `json:"project"`
+	Attendance  TemplateAttendance `json:"attendance"`
+	Tasks       TemplateTasks      `json:"tasks"`
+	Expenses    TemplateExpenses   `json:"expenses"`
+	Quality     TemplateQuality    `json:"quality"`
+	Incidents   []SafetyIncident   `json:"incidents"`
+	Days        []WeeklyDaySummary `json:"days"` // One entry per day of a weekly report; empty for daily reports
+	GeneratedAt time.Time          `json:"generated_at"`
+}
+
+// TemplateReport describes the report being rendered. It is not a table.
+type TemplateReport struct {
+	ID         int64     `json:"id"`
+	Type       string    `json:"type"`
+	StartDate  time.Time `json:"start_date"`
+	EndDate    time.Time `json:"end_date"` // Last day included; the same as StartDate for daily reports
+	Weather    string    `json:"weather"`
+	Notes      string    `json:"notes"`
+	CompiledAt time.Time `json:"compiled_at"`
+}
+
+// TemplateProject is the project a rendered report belongs to. It is not a table.
+type TemplateProject struct {
+	ID          int64   `json:"id"`
+	Name        string  `json:"name"`
+	Description string  `json:"description"`
+	Status      string  `json:"status"`
+	Budget      float64 `json:"budget"`
+	Manager     string  `json:"manager"` // Username of the project manager
+}
+
+// TemplateAttendance is the attendance of a rendered report. It is not a table.
+type TemplateAttendance struct {
+	Total    int            `json:"total"` // Person-days recorded
+	ByStatus map[string]int `json:"by_status"`
+}
+
+// TemplateTasks lists the tasks started and completed in a rendered report's period. It is not a table.
+type TemplateTasks struct {
+	Started   []Task `json:"started"`
+	Completed []Task `json:"completed"`
+}
+
+// TemplateExpenses totals the expenses of a rendered report. Items is only filled for daily reports. It is not a table.
+type TemplateExpenses struct {
+	Count int       `json:"count"`
+	Total float64   `json:"total"`
+	Items []Expense `json:"items"`
+}
+
+// TemplateQuality summarises the quality checks of a rendered report. Checks is only filled for daily reports.
+// It is not a table.
+type TemplateQuality struct {
+	Total   int            `json:"total"`
+	Summary map[string]int `json:"summary"` // Number of checks per status
+	Checks  []QualityCheck `json:"checks"`
+}
+
+// JobRun is one execution of a scheduled background job. Replicas claim a run before starting it,
+// so each scheduled slot runs once across the deployment.
+type JobRun struct {
+	ID           int64     `json:"id"`
+	JobName      string    `json:"job_name"`
+	ScheduledFor time.Time `json:"scheduled_for"` // Slot the run belongs to; the claim key together with JobName
+	Trigger      string    `json:"trigger"`       // SCHEDULE or MANUAL
+	Instance     string    `json:"instance"`      // Host and process that ran the job
+	Status       string    `json:"status"`        // RUNNING, SUCCEEDED or FAILED
+	Result       string    `json:"result"`        // Summary returned by the job
+	Error        string    `json:"error"`
+	StartedAt    time.Time `json:"started_at"`
+	FinishedAt   time.Time `json:"finished_at"`
+}
+
+// RefreshToken is one refresh token of a login session. Only the SHA-256 hash of the token is stored. Every
+// refresh exchanges the token for a new one of the same family, so a family is one login.
+type RefreshToken struct {
+	ID        int64     `json:"id"`
+	UserID    int64     `json:"user_id"`
+	FamilyID  string    `json:"family_id"` // Also the session ID carried by the access tokens of the login
+	TokenHash string    `json:"token_hash"`
+	ExpiresAt time.Time `json:"expires_at"`
+	CreatedAt time.Time `json:"created_at"`
+	RotatedAt time.Time `json:"rotated_at"` // When it was exchanged for its successor; zero while it is current
+	RevokedAt time.Time `json:"revoked_at"` // When its family was logged out or caught reusing a token
+	User      *User     `json:"user"`
+}
+
+// PasswordResetToken lets the owner of an email address set a new password once. Only the SHA-256 hash of
+// the token mailed to them is stored.
+type PasswordResetToken struct {
+	ID        int64     `json:"id"`
+	UserID    int64     `json:"user_id"`
+	TokenHash string    `json:"token_hash"`
+	ExpiresAt time.Time `json:"expires_at"`
+	CreatedAt time.Time `json:"created_at"`
+	UsedAt    time.Time `json:"used_at"` // When it was redeemed or replaced by a newer request; zero while it is usable
+	User      *User     `json:"user"`
+}
+
+// LoginAttempt is one entry of the login audit trail: a login that succeeded, failed or was held back, or an
+// admin lifting the lockout of an account. Failures since the last success or unlock drive the lockout.
+type LoginAttempt struct {
+	ID        int64     `json:"id"`
+	UserID    int64     `json:"user_id"` // 0 when the email belongs to no account
+	Email     string    `json:"email"`   // Lowercased as entered
+	IPAddress string    `json:"ip_address"`
+	Outcome   string    `json:"outcome"` // SUCCEEDED, FAILED, THROTTLED or UNLOCKED
+	Reason    string    `json:"reason"`
+	ActorID   int64     `json:"actor_id"` // Admin who unlocked the account
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// APIKey lets an integration such as an ERP or a site camera call the API without a login. Only the SHA-256
+// hash of the key is stored; the key itself is shown once, when it is created.
+type APIKey struct {
+	ID         int64     `json:"id"`
+	Name       string    `json:"name"`
+	Prefix     string    `json:"prefix"` // Start of the key, to tell keys apart without revealing them
+	KeyHash    string    `json:"key_hash"`
+	OwnerID    int64     `json:"owner_id"` // User the key acts for; 0 for a key of the organization
+	CreatedBy  int64     `json:"created_by"`
+	Scopes     string    `json:"scopes"`     // Space-separated, such as "expenses:write presence:write"
+	ExpiresAt  time.Time `json:"expires_at"` // Zero for a key that does not expire
+	LastUsedAt time.Time `json:"last_used_at"`
+	CreatedAt  time.Time `json:"created_at"`
+	RevokedAt  time.Time `json:"revoked_at"`
+}
+
+func (APIKey) TableName() string {
+	return "api_keys"
+}
+
+// CreatedAPIKey is a new API key together with its secret, which is not shown again. It is not a table.
+type CreatedAPIKey struct {
+	Key    string  `json:"key"`
+	APIKey *APIKey `json:"api_key"`
+}
+
+// TokenPair is what a login or a refresh hands out. It is not a table.
+type TokenPair struct {
+	AccessToken      string    `json:"access_token"`
+	RefreshToken     string    `json:"refresh_token"`
+	TokenType        string    `json:"token_type"`
+	ExpiresIn        int64     `json:"expires_in"` // Lifetime of the access token in seconds
+	RefreshExpiresAt time.Time `json:"refresh_expires_at"`
+}
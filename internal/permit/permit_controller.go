@@ -0,0 +1,383 @@
+package permit
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	models "github.com/BerkatPS/internal"
+	"github.com/BerkatPS/pkg/principal"
+	"github.com/BerkatPS/pkg/utils"
+)
+
+type PermitController struct {
+	PermitService PermitService
+}
+
+func NewPermitController(permitService PermitService) *PermitController {
+	return &PermitController{permitService}
+}
+
+func (p *PermitController) CreatePermit(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	var permit models.WorkPermit
+	if err := json.NewDecoder(r.Body).Decode(&permit); err != nil {
+		utils.JSONErrorResponse(w, http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": "Invalid request body: " + err.Error(),
+		})
+		return
+	}
+
+	if err := p.PermitService.CreatePermit(ctx, &permit); err != nil {
+		utils.JSONErrorResponse(w, http.StatusInternalServerError, map[string]interface{}{
+			"status":  "error",
+			"message": "Failed to create permit: " + err.Error(),
+		})
+		return
+	}
+
+	utils.JSONResponse(w, http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "Permit created successfully",
+		"data":    permit,
+	})
+}
+
+func (p *PermitController) FindPermitByID(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	permitID, err := utils.ParseInt64Param(r)
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": "Invalid permit ID: " + err.Error(),
+		})
+		return
+	}
+
+	permit, err := p.PermitService.FindPermitByID(ctx, permitID)
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusInternalServerError, map[string]interface{}{
+			"status":  "error",
+			"message": "Failed to retrieve permit: " + err.Error(),
+		})
+		return
+	}
+
+	utils.JSONResponse(w, http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "Permit found successfully",
+		"data":    permit,
+	})
+}
+
+// FindPermitsByProject lists a project's permits, optionally filtered by ?status=
+func (p *PermitController) FindPermitsByProject(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	projectID, err := utils.ParseInt64Param(r)
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": "Invalid project ID: " + err.Error(),
+		})
+		return
+	}
+
+	permits, err := p.PermitService.FindPermitsByProjectID(ctx, projectID, r.URL.Query().Get("status"))
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusInternalServerError, map[string]interface{}{
+			"status":  "error",
+			"message": "Failed to retrieve permits: " + err.Error(),
+		})
+		return
+	}
+
+	utils.JSONResponse(w, http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "Permits found successfully",
+		"data":    permits,
+	})
+}
+
+// FindFlaggedTasks lists in-progress tasks working without a valid required permit, optionally for one ?project_id=
+func (p *PermitController) FindFlaggedTasks(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	var projectID int64
+	if raw := r.URL.Query().Get("project_id"); raw != "" {
+		id, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			utils.JSONErrorResponse(w, http.StatusBadRequest, map[string]interface{}{
+				"status":  "error",
+				"message": "Invalid project ID: " + err.Error(),
+			})
+			return
+		}
+		projectID = id
+	}
+
+	flags, err := p.PermitService.FindFlaggedTasks(ctx, projectID)
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusInternalServerError, map[string]interface{}{
+			"status":  "error",
+			"message": "Failed to retrieve flagged tasks: " + err.Error(),
+		})
+		return
+	}
+
+	utils.JSONResponse(w, http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "Flagged tasks found successfully",
+		"data":    flags,
+	})
+}
+
+func (p *PermitController) CheckHazard(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	permitID, err := utils.ParseInt64Param(r)
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": "Invalid permit ID: " + err.Error(),
+		})
+		return
+	}
+
+	itemID, err := utils.ParseInt64PathValue(r, "item_id")
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": "Invalid checklist item ID: " + err.Error(),
+		})
+		return
+	}
+
+	actorID, err := principal.UserIDFromRequest(r)
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusUnauthorized, map[string]interface{}{
+			"status":  "error",
+			"message": "Unauthorized: " + err.Error(),
+		})
+		return
+	}
+
+	var checkHazardRequest struct {
+		Checked bool `json:"checked"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&checkHazardRequest); err != nil {
+		utils.JSONErrorResponse(w, http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": "Invalid request body: " + err.Error(),
+		})
+		return
+	}
+
+	if err := p.PermitService.CheckHazard(ctx, permitID, itemID, actorID, checkHazardRequest.Checked); err != nil {
+		utils.JSONErrorResponse(w, http.StatusInternalServerError, map[string]interface{}{
+			"status":  "error",
+			"message": "Failed to update checklist: " + err.Error(),
+		})
+		return
+	}
+
+	utils.JSONResponse(w, http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "Checklist updated successfully",
+	})
+}
+
+func (p *PermitController) SignAsIssuer(w http.ResponseWriter, r *http.Request) {
+	p.sign(w, r, p.PermitService.SignAsIssuer, "Permit issued successfully")
+}
+
+func (p *PermitController) SignAsReceiver(w http.ResponseWriter, r *http.Request) {
+	p.sign(w, r, p.PermitService.SignAsReceiver, "Permit accepted and active")
+}
+
+func (p *PermitController) SuspendPermit(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	permitID, err := utils.ParseInt64Param(r)
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": "Invalid permit ID: " + err.Error(),
+		})
+		return
+	}
+
+	actorID, err := principal.UserIDFromRequest(r)
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusUnauthorized, map[string]interface{}{
+			"status":  "error",
+			"message": "Unauthorized: " + err.Error(),
+		})
+		return
+	}
+
+	var suspendPermitRequest struct {
+		Reason string `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&suspendPermitRequest); err != nil {
+		utils.JSONErrorResponse(w, http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": "Invalid request body: " + err.Error(),
+		})
+		return
+	}
+
+	if err := p.PermitService.SuspendPermit(ctx, permitID, actorID, suspendPermitRequest.Reason); err != nil {
+		utils.JSONErrorResponse(w, http.StatusInternalServerError, map[string]interface{}{
+			"status":  "error",
+			"message": "Failed to suspend permit: " + err.Error(),
+		})
+		return
+	}
+
+	utils.JSONResponse(w, http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "Permit suspended successfully",
+	})
+}
+
+func (p *PermitController) ResumePermit(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	permitID, err := utils.ParseInt64Param(r)
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": "Invalid permit ID: " + err.Error(),
+		})
+		return
+	}
+
+	actorID, err := principal.UserIDFromRequest(r)
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusUnauthorized, map[string]interface{}{
+			"status":  "error",
+			"message": "Unauthorized: " + err.Error(),
+		})
+		return
+	}
+
+	if err := p.PermitService.ResumePermit(ctx, permitID, actorID); err != nil {
+		utils.JSONErrorResponse(w, http.StatusInternalServerError, map[string]interface{}{
+			"status":  "error",
+			"message": "Failed to resume permit: " + err.Error(),
+		})
+		return
+	}
+
+	utils.JSONResponse(w, http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "Permit resumed successfully",
+	})
+}
+
+func (p *PermitController) ClosePermit(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	permitID, err := utils.ParseInt64Param(r)
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": "Invalid permit ID: " + err.Error(),
+		})
+		return
+	}
+
+	actorID, err := principal.UserIDFromRequest(r)
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusUnauthorized, map[string]interface{}{
+			"status":  "error",
+			"message": "Unauthorized: " + err.Error(),
+		})
+		return
+	}
+
+	var closePermitRequest struct {
+		Notes string `json:"notes"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&closePermitRequest); err != nil {
+		utils.JSONErrorResponse(w, http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": "Invalid request body: " + err.Error(),
+		})
+		return
+	}
+
+	if err := p.PermitService.ClosePermit(ctx, permitID, actorID, closePermitRequest.Notes); err != nil {
+		utils.JSONErrorResponse(w, http.StatusInternalServerError, map[string]interface{}{
+			"status":  "error",
+			"message": "Failed to close permit: " + err.Error(),
+		})
+		return
+	}
+
+	utils.JSONResponse(w, http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "Permit closed successfully",
+	})
+}
+
+// sign handles both issuer and receiver signatures, which share the same payload
+func (p *PermitController) sign(w http.ResponseWriter, r *http.Request, signFn func(ctx context.Context, id, userID int64, signature string) error, successMessage string) {
+
+	ctx := r.Context()
+
+	permitID, err := utils.ParseInt64Param(r)
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": "Invalid permit ID: " + err.Error(),
+		})
+		return
+	}
+
+	actorID, err := principal.UserIDFromRequest(r)
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusUnauthorized, map[string]interface{}{
+			"status":  "error",
+			"message": "Unauthorized: " + err.Error(),
+		})
+		return
+	}
+
+	var signRequest struct {
+		Signature string `json:"signature"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&signRequest); err != nil {
+		utils.JSONErrorResponse(w, http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": "Invalid request body: " + err.Error(),
+		})
+		return
+	}
+
+	if err := signFn(ctx, permitID, actorID, signRequest.Signature); err != nil {
+		utils.JSONErrorResponse(w, http.StatusInternalServerError, map[string]interface{}{
+			"status":  "error",
+			"message": "Failed to sign permit: " + err.Error(),
+		})
+		return
+	}
+
+	utils.JSONResponse(w, http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": successMessage,
+	})
+}
@@ -0,0 +1,251 @@
+package permit
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	models "github.com/BerkatPS/internal"
+)
+
+const selectPermitColumns = `SELECT id, project_id, task_id, type, description, status, valid_from, valid_until,
+	issuer_id, issuer_signature, issued_at, receiver_id, receiver_signature, accepted_at,
+	suspension_reason, suspended_by, suspended_at, closed_by, closed_at, closure_notes FROM work_permits`
+
+type PermitRepository interface {
+	// CreatePermit stores the permit together with its hazard checklist
+	CreatePermit(ctx context.Context, permit *models.WorkPermit) error
+	FindPermitByID(ctx context.Context, id int64) (*models.WorkPermit, error)
+	FindPermitsByProjectID(ctx context.Context, projectID int64, status string) ([]models.WorkPermit, error)
+	// UpdatePermitState persists status, signature, suspension and closure fields
+	UpdatePermitState(ctx context.Context, permit *models.WorkPermit) error
+	FindChecklistByPermitID(ctx context.Context, permitID int64) ([]models.PermitChecklistItem, error)
+	FindChecklistItemByID(ctx context.Context, id int64) (*models.PermitChecklistItem, error)
+	UpdateChecklistItem(ctx context.Context, item *models.PermitChecklistItem) error
+	// CountValidPermits counts active permits of a type covering the task (or its whole project) at the given time
+	CountValidPermits(ctx context.Context, projectID, taskID int64, permitType string, at time.Time) (int, error)
+	FindTaskByID(ctx context.Context, taskID int64) (*models.Task, error)
+	// FindTasksWithoutValidPermit lists in-progress tasks that require a permit but have no valid one, with the latest matching permit if any
+	FindTasksWithoutValidPermit(ctx context.Context, projectID int64, at time.Time) ([]TaskPermitFlag, error)
+}
+
+type permitRepository struct {
+	db *sql.DB
+}
+
+func NewPermitRepository(db *sql.DB) PermitRepository {
+	return &permitRepository{db}
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanPermit(row rowScanner, permit *models.WorkPermit) error {
+	return row.Scan(&permit.ID, &permit.ProjectID, &permit.TaskID, &permit.Type, &permit.Description, &permit.Status, &permit.ValidFrom, &permit.ValidUntil,
+		&permit.IssuerID, &permit.IssuerSignature, &permit.IssuedAt, &permit.ReceiverID, &permit.ReceiverSignature, &permit.AcceptedAt,
+		&permit.SuspensionReason, &permit.SuspendedBy, &permit.SuspendedAt, &permit.ClosedBy, &permit.ClosedAt, &permit.ClosureNotes)
+}
+
+func (p *permitRepository) CreatePermit(ctx context.Context, permit *models.WorkPermit) error {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Signature, suspension and closure fields start out empty; unset timestamps are stored as the zero time
+	query := `INSERT INTO work_permits (project_id, task_id, type, description, status, valid_from, valid_until,
+		issuer_id, issuer_signature, issued_at, receiver_id, receiver_signature, accepted_at,
+		suspension_reason, suspended_by, suspended_at, closed_by, closed_at, closure_notes)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, '', $9, $10, '', $9, '', 0, $9, 0, $9, '') RETURNING id`
+
+	var zero time.Time
+	if err := tx.QueryRowContext(ctx, query, permit.ProjectID, permit.TaskID, permit.Type, permit.Description, permit.Status, permit.ValidFrom, permit.ValidUntil,
+		permit.IssuerID, zero, permit.ReceiverID).Scan(&permit.ID); err != nil {
+		return err
+	}
+
+	itemQuery := "INSERT INTO permit_checklist_items (permit_id, hazard, control, checked, checked_by, checked_at) VALUES ($1, $2, $3, false, 0, $4) RETURNING id"
+	for i := range permit.Checklist {
+		item := &permit.Checklist[i]
+		item.PermitID = permit.ID
+		if err := tx.QueryRowContext(ctx, itemQuery, item.PermitID, item.Hazard, item.Control, zero).Scan(&item.ID); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (p *permitRepository) FindPermitByID(ctx context.Context, id int64) (*models.WorkPermit, error) {
+	query := selectPermitColumns + " WHERE id = $1"
+
+	var permit models.WorkPermit
+	if err := scanPermit(p.db.QueryRowContext(ctx, query, id), &permit); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("permit not found")
+		}
+		return nil, err
+	}
+	return &permit, nil
+}
+
+func (p *permitRepository) FindPermitsByProjectID(ctx context.Context, projectID int64, status string) ([]models.WorkPermit, error) {
+	query := selectPermitColumns + " WHERE project_id = $1 AND ($2 = '' OR status = $2) ORDER BY valid_from DESC"
+
+	rows, err := p.db.QueryContext(ctx, query, projectID, status)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var permits []models.WorkPermit
+	for rows.Next() {
+		var permit models.WorkPermit
+		if err := scanPermit(rows, &permit); err != nil {
+			return nil, err
+		}
+		permits = append(permits, permit)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return permits, nil
+}
+
+func (p *permitRepository) UpdatePermitState(ctx context.Context, permit *models.WorkPermit) error {
+	query := `UPDATE work_permits SET status = $1, issuer_signature = $2, issued_at = $3, receiver_signature = $4, accepted_at = $5,
+		suspension_reason = $6, suspended_by = $7, suspended_at = $8, closed_by = $9, closed_at = $10, closure_notes = $11 WHERE id = $12`
+
+	_, err := p.db.ExecContext(ctx, query, permit.Status, permit.IssuerSignature, permit.IssuedAt, permit.ReceiverSignature, permit.AcceptedAt,
+		permit.SuspensionReason, permit.SuspendedBy, permit.SuspendedAt, permit.ClosedBy, permit.ClosedAt, permit.ClosureNotes, permit.ID)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (p *permitRepository) FindChecklistByPermitID(ctx context.Context, permitID int64) ([]models.PermitChecklistItem, error) {
+	query := "SELECT id, permit_id, hazard, control, checked, checked_by, checked_at FROM permit_checklist_items WHERE permit_id = $1 ORDER BY id"
+
+	rows, err := p.db.QueryContext(ctx, query, permitID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []models.PermitChecklistItem
+	for rows.Next() {
+		var item models.PermitChecklistItem
+		if err := rows.Scan(&item.ID, &item.PermitID, &item.Hazard, &item.Control, &item.Checked, &item.CheckedBy, &item.CheckedAt); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+func (p *permitRepository) FindChecklistItemByID(ctx context.Context, id int64) (*models.PermitChecklistItem, error) {
+	query := "SELECT id, permit_id, hazard, control, checked, checked_by, checked_at FROM permit_checklist_items WHERE id = $1"
+
+	var item models.PermitChecklistItem
+	err := p.db.QueryRowContext(ctx, query, id).Scan(&item.ID, &item.PermitID, &item.Hazard, &item.Control, &item.Checked, &item.CheckedBy, &item.CheckedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("checklist item not found")
+		}
+		return nil, err
+	}
+	return &item, nil
+}
+
+func (p *permitRepository) UpdateChecklistItem(ctx context.Context, item *models.PermitChecklistItem) error {
+	query := "UPDATE permit_checklist_items SET checked = $1, checked_by = $2, checked_at = $3 WHERE id = $4"
+
+	_, err := p.db.ExecContext(ctx, query, item.Checked, item.CheckedBy, item.CheckedAt, item.ID)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (p *permitRepository) CountValidPermits(ctx context.Context, projectID, taskID int64, permitType string, at time.Time) (int, error) {
+	query := `
+		SELECT COUNT(*)
+		FROM work_permits
+		WHERE project_id = $1 AND (task_id = $2 OR task_id = 0) AND type = $3
+			AND status = 'ACTIVE' AND valid_from <= $4 AND valid_until >= $4
+	`
+
+	var count int
+	if err := p.db.QueryRowContext(ctx, query, projectID, taskID, permitType, at).Scan(&count); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+func (p *permitRepository) FindTaskByID(ctx context.Context, taskID int64) (*models.Task, error) {
+	query := "SELECT id, project_id, name, status, COALESCE(required_permit_type, '') FROM tasks WHERE id = $1"
+
+	var task models.Task
+	err := p.db.QueryRowContext(ctx, query, taskID).Scan(&task.ID, &task.ProjectID, &task.Name, &task.Status, &task.RequiredPermitType)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("task not found")
+		}
+		return nil, err
+	}
+	return &task, nil
+}
+
+func (p *permitRepository) FindTasksWithoutValidPermit(ctx context.Context, projectID int64, at time.Time) ([]TaskPermitFlag, error) {
+	query := `
+		SELECT t.id, t.project_id, t.name, t.required_permit_type, latest.id, latest.status, latest.valid_from, latest.valid_until
+		FROM tasks t
+		LEFT JOIN LATERAL (
+			SELECT wp.id, wp.status, wp.valid_from, wp.valid_until
+			FROM work_permits wp
+			WHERE wp.project_id = t.project_id AND (wp.task_id = t.id OR wp.task_id = 0) AND wp.type = t.required_permit_type
+			ORDER BY wp.valid_until DESC
+			LIMIT 1
+		) latest ON true
+		WHERE t.status = 'IN_PROGRESS' AND t.required_permit_type <> '' AND ($1 = 0 OR t.project_id = $1)
+			AND NOT EXISTS (
+				SELECT 1 FROM work_permits wp
+				WHERE wp.project_id = t.project_id AND (wp.task_id = t.id OR wp.task_id = 0) AND wp.type = t.required_permit_type
+					AND wp.status = 'ACTIVE' AND wp.valid_from <= $2 AND wp.valid_until >= $2
+			)
+		ORDER BY t.project_id, t.id
+	`
+
+	rows, err := p.db.QueryContext(ctx, query, projectID, at)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var flags []TaskPermitFlag
+	for rows.Next() {
+		var flag TaskPermitFlag
+		var permitID sql.NullInt64
+		var permitStatus sql.NullString
+		var validFrom, validUntil sql.NullTime
+		if err := rows.Scan(&flag.TaskID, &flag.ProjectID, &flag.TaskName, &flag.RequiredPermitType, &permitID, &permitStatus, &validFrom, &validUntil); err != nil {
+			return nil, err
+		}
+		flag.LatestPermitID = permitID.Int64
+		flag.LatestPermitStatus = permitStatus.String
+		flag.LatestValidFrom = validFrom.Time
+		flag.LatestValidUntil = validUntil.Time
+		flags = append(flags, flag)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return flags, nil
+}
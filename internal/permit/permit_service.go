@@ -0,0 +1,408 @@
+package permit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	models "github.com/BerkatPS/internal"
+)
+
+const (
+	TypeHotWork         = "HOT_WORK"
+	TypeConfinedSpace   = "CONFINED_SPACE"
+	TypeWorkingAtHeight = "WORKING_AT_HEIGHT"
+	TypeExcavation      = "EXCAVATION"
+	TypeElectrical      = "ELECTRICAL"
+	TypeGeneral         = "GENERAL"
+
+	StatusDraft     = "DRAFT"
+	StatusIssued    = "ISSUED"
+	StatusActive    = "ACTIVE"
+	StatusSuspended = "SUSPENDED"
+	StatusClosed    = "CLOSED"
+
+	FlagMissing     = "MISSING"
+	FlagExpired     = "EXPIRED"
+	FlagNotYetValid = "NOT_YET_VALID"
+	FlagSuspended   = "SUSPENDED"
+	FlagNotActive   = "NOT_ACTIVE"
+)
+
+// ErrPermitRequired is returned when a task needs an active permit of a mandatory type before work can start
+var ErrPermitRequired = errors.New("active work permit required")
+
+var validTypes = map[string]bool{
+	TypeHotWork:         true,
+	TypeConfinedSpace:   true,
+	TypeWorkingAtHeight: true,
+	TypeExcavation:      true,
+	TypeElectrical:      true,
+	TypeGeneral:         true,
+}
+
+// NormalizeType returns the canonical spelling of a permit type, so "hot work" and "hot-work" become HOT_WORK.
+// It fails when the type is not one of the known permit types.
+func NormalizeType(permitType string) (string, error) {
+	normalized := strings.ToUpper(strings.TrimSpace(permitType))
+	normalized = strings.NewReplacer(" ", "_", "-", "_").Replace(normalized)
+	if !validTypes[normalized] {
+		return "", fmt.Errorf("invalid permit type %q", permitType)
+	}
+	return normalized, nil
+}
+
+// MandatoryTypes are permit types without which a task may not be put in progress
+var MandatoryTypes = map[string]bool{
+	TypeHotWork:         true,
+	TypeConfinedSpace:   true,
+	TypeWorkingAtHeight: true,
+}
+
+// TaskPermitFlag reports an in-progress task that is working without a valid required permit
+type TaskPermitFlag struct {
+	TaskID             int64     `json:"task_id"`
+	ProjectID          int64     `json:"project_id"`
+	TaskName           string    `json:"task_name"`
+	RequiredPermitType string    `json:"required_permit_type"`
+	Mandatory          bool      `json:"mandatory"`
+	Reason             string    `json:"reason"`
+	LatestPermitID     int64     `json:"latest_permit_id"`
+	LatestPermitStatus string    `json:"latest_permit_status"`
+	LatestValidFrom    time.Time `json:"latest_valid_from"`
+	LatestValidUntil   time.Time `json:"latest_valid_until"`
+}
+
+type PermitService interface {
+	CreatePermit(ctx context.Context, permit *models.WorkPermit) error
+	FindPermitByID(ctx context.Context, id int64) (*models.WorkPermit, error)
+	FindPermitsByProjectID(ctx context.Context, projectID int64, status string) ([]models.WorkPermit, error)
+	// CheckHazard ticks a hazard checklist item off, or clears it, while the permit is still a draft
+	CheckHazard(ctx context.Context, permitID, itemID, actorID int64, checked bool) error
+	// SignAsIssuer issues a draft permit once every hazard has been checked
+	SignAsIssuer(ctx context.Context, id, userID int64, signature string) error
+	// SignAsReceiver accepts an issued permit and makes it active
+	SignAsReceiver(ctx context.Context, id, userID int64, signature string) error
+	SuspendPermit(ctx context.Context, id, actorID int64, reason string) error
+	ResumePermit(ctx context.Context, id, actorID int64) error
+	ClosePermit(ctx context.Context, id, actorID int64, notes string) error
+	// CheckTaskPermit returns ErrPermitRequired when the task needs a mandatory permit that is not active right now
+	CheckTaskPermit(ctx context.Context, taskID int64) error
+	// FindFlaggedTasks lists in-progress tasks whose required permit is missing, expired or not active. projectID 0 means every project.
+	FindFlaggedTasks(ctx context.Context, projectID int64) ([]TaskPermitFlag, error)
+}
+
+type permitService struct {
+	PermitRepo PermitRepository
+}
+
+func NewPermitService(permitRepo PermitRepository) PermitService {
+	return &permitService{permitRepo}
+}
+
+func (p *permitService) CreatePermit(ctx context.Context, permit *models.WorkPermit) error {
+	if permit.ProjectID <= 0 {
+		return fmt.Errorf("invalid project ID")
+	}
+
+	if !validTypes[permit.Type] {
+		return fmt.Errorf("invalid permit type %q", permit.Type)
+	}
+
+	if permit.Description == "" {
+		return fmt.Errorf("description cannot be empty")
+	}
+
+	if permit.ValidFrom.IsZero() || permit.ValidUntil.IsZero() || !permit.ValidUntil.After(permit.ValidFrom) {
+		return fmt.Errorf("invalid validity window")
+	}
+
+	if permit.IssuerID <= 0 || permit.ReceiverID <= 0 {
+		return fmt.Errorf("issuer and receiver are required")
+	}
+
+	if permit.IssuerID == permit.ReceiverID {
+		return fmt.Errorf("issuer and receiver must be different people")
+	}
+
+	if len(permit.Checklist) == 0 {
+		return fmt.Errorf("hazard checklist cannot be empty")
+	}
+
+	for _, item := range permit.Checklist {
+		if item.Hazard == "" || item.Control == "" {
+			return fmt.Errorf("every checklist item needs a hazard and a control")
+		}
+	}
+
+	if permit.TaskID > 0 {
+		task, err := p.PermitRepo.FindTaskByID(ctx, permit.TaskID)
+		if err != nil {
+			return fmt.Errorf("failed to retrieve task: %v", err)
+		}
+		if task.ProjectID != permit.ProjectID {
+			return fmt.Errorf("task %d does not belong to project %d", permit.TaskID, permit.ProjectID)
+		}
+	}
+
+	permit.Status = StatusDraft
+
+	if err := p.PermitRepo.CreatePermit(ctx, permit); err != nil {
+		return fmt.Errorf("failed to create permit: %v", err)
+	}
+	return nil
+}
+
+func (p *permitService) FindPermitByID(ctx context.Context, id int64) (*models.WorkPermit, error) {
+	if id <= 0 {
+		return nil, fmt.Errorf("invalid permit ID")
+	}
+
+	permit, err := p.PermitRepo.FindPermitByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve permit: %v", err)
+	}
+
+	permit.Checklist, err = p.PermitRepo.FindChecklistByPermitID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve checklist: %v", err)
+	}
+	return permit, nil
+}
+
+func (p *permitService) FindPermitsByProjectID(ctx context.Context, projectID int64, status string) ([]models.WorkPermit, error) {
+	if projectID <= 0 {
+		return nil, fmt.Errorf("invalid project ID")
+	}
+
+	permits, err := p.PermitRepo.FindPermitsByProjectID(ctx, projectID, status)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve permits: %v", err)
+	}
+	return permits, nil
+}
+
+func (p *permitService) CheckHazard(ctx context.Context, permitID, itemID, actorID int64, checked bool) error {
+	if actorID <= 0 {
+		return fmt.Errorf("invalid actor ID")
+	}
+
+	permit, err := p.loadPermit(ctx, permitID, StatusDraft)
+	if err != nil {
+		return err
+	}
+
+	item, err := p.PermitRepo.FindChecklistItemByID(ctx, itemID)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve checklist item: %v", err)
+	}
+
+	if item.PermitID != permit.ID {
+		return fmt.Errorf("checklist item %d does not belong to permit %d", itemID, permitID)
+	}
+
+	item.Checked = checked
+	item.CheckedBy = 0
+	item.CheckedAt = time.Time{}
+	if checked {
+		item.CheckedBy = actorID
+		item.CheckedAt = time.Now()
+	}
+
+	if err := p.PermitRepo.UpdateChecklistItem(ctx, item); err != nil {
+		return fmt.Errorf("failed to update checklist item: %v", err)
+	}
+	return nil
+}
+
+func (p *permitService) SignAsIssuer(ctx context.Context, id, userID int64, signature string) error {
+	if signature == "" {
+		return fmt.Errorf("signature cannot be empty")
+	}
+
+	permit, err := p.loadPermit(ctx, id, StatusDraft)
+	if err != nil {
+		return err
+	}
+
+	if permit.IssuerID != userID {
+		return fmt.Errorf("only the designated issuer can sign this permit")
+	}
+
+	checklist, err := p.PermitRepo.FindChecklistByPermitID(ctx, id)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve checklist: %v", err)
+	}
+
+	for _, item := range checklist {
+		if !item.Checked {
+			return fmt.Errorf("hazard %q has not been checked", item.Hazard)
+		}
+	}
+
+	permit.Status = StatusIssued
+	permit.IssuerSignature = signature
+	permit.IssuedAt = time.Now()
+	return p.savePermit(ctx, permit)
+}
+
+func (p *permitService) SignAsReceiver(ctx context.Context, id, userID int64, signature string) error {
+	if signature == "" {
+		return fmt.Errorf("signature cannot be empty")
+	}
+
+	permit, err := p.loadPermit(ctx, id, StatusIssued)
+	if err != nil {
+		return err
+	}
+
+	if permit.ReceiverID != userID {
+		return fmt.Errorf("only the designated receiver can sign this permit")
+	}
+
+	if time.Now().After(permit.ValidUntil) {
+		return fmt.Errorf("permit expired at %s", permit.ValidUntil.Format(time.RFC3339))
+	}
+
+	permit.Status = StatusActive
+	permit.ReceiverSignature = signature
+	permit.AcceptedAt = time.Now()
+	return p.savePermit(ctx, permit)
+}
+
+func (p *permitService) SuspendPermit(ctx context.Context, id, actorID int64, reason string) error {
+	if actorID <= 0 {
+		return fmt.Errorf("invalid actor ID")
+	}
+
+	if reason == "" {
+		return fmt.Errorf("suspension reason cannot be empty")
+	}
+
+	permit, err := p.loadPermit(ctx, id, StatusActive)
+	if err != nil {
+		return err
+	}
+
+	permit.Status = StatusSuspended
+	permit.SuspensionReason = reason
+	permit.SuspendedBy = actorID
+	permit.SuspendedAt = time.Now()
+	return p.savePermit(ctx, permit)
+}
+
+func (p *permitService) ResumePermit(ctx context.Context, id, actorID int64) error {
+	permit, err := p.loadPermit(ctx, id, StatusSuspended)
+	if err != nil {
+		return err
+	}
+
+	if permit.IssuerID != actorID {
+		return fmt.Errorf("only the issuer can lift a suspension")
+	}
+
+	if time.Now().After(permit.ValidUntil) {
+		return fmt.Errorf("permit expired at %s", permit.ValidUntil.Format(time.RFC3339))
+	}
+
+	permit.Status = StatusActive
+	permit.SuspensionReason = ""
+	return p.savePermit(ctx, permit)
+}
+
+func (p *permitService) ClosePermit(ctx context.Context, id, actorID int64, notes string) error {
+	if actorID <= 0 {
+		return fmt.Errorf("invalid actor ID")
+	}
+
+	permit, err := p.loadPermit(ctx, id, StatusIssued, StatusActive, StatusSuspended)
+	if err != nil {
+		return err
+	}
+
+	permit.Status = StatusClosed
+	permit.ClosedBy = actorID
+	permit.ClosedAt = time.Now()
+	permit.ClosureNotes = notes
+	return p.savePermit(ctx, permit)
+}
+
+func (p *permitService) CheckTaskPermit(ctx context.Context, taskID int64) error {
+	task, err := p.PermitRepo.FindTaskByID(ctx, taskID)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve task: %v", err)
+	}
+
+	if !MandatoryTypes[task.RequiredPermitType] {
+		return nil
+	}
+
+	count, err := p.PermitRepo.CountValidPermits(ctx, task.ProjectID, task.ID, task.RequiredPermitType, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to check permits: %v", err)
+	}
+
+	if count == 0 {
+		return fmt.Errorf("%w: task %d needs an active %s permit", ErrPermitRequired, task.ID, task.RequiredPermitType)
+	}
+	return nil
+}
+
+func (p *permitService) FindFlaggedTasks(ctx context.Context, projectID int64) ([]TaskPermitFlag, error) {
+	if projectID < 0 {
+		return nil, fmt.Errorf("invalid project ID")
+	}
+
+	now := time.Now()
+	flags, err := p.PermitRepo.FindTasksWithoutValidPermit(ctx, projectID, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve flagged tasks: %v", err)
+	}
+
+	for i := range flags {
+		flag := &flags[i]
+		flag.Mandatory = MandatoryTypes[flag.RequiredPermitType]
+
+		switch {
+		case flag.LatestPermitID == 0 || flag.LatestPermitStatus == StatusClosed:
+			flag.Reason = FlagMissing
+		case flag.LatestPermitStatus == StatusSuspended:
+			flag.Reason = FlagSuspended
+		case now.After(flag.LatestValidUntil):
+			flag.Reason = FlagExpired
+		case now.Before(flag.LatestValidFrom):
+			flag.Reason = FlagNotYetValid
+		default:
+			flag.Reason = FlagNotActive
+		}
+	}
+	return flags, nil
+}
+
+// loadPermit fetches a permit and checks that it is in one of the expected statuses
+func (p *permitService) loadPermit(ctx context.Context, id int64, expected ...string) (*models.WorkPermit, error) {
+	if id <= 0 {
+		return nil, fmt.Errorf("invalid permit ID")
+	}
+
+	permit, err := p.PermitRepo.FindPermitByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve permit: %v", err)
+	}
+
+	for _, status := range expected {
+		if permit.Status == status {
+			return permit, nil
+		}
+	}
+	return nil, fmt.Errorf("permit is %s, expected %v", permit.Status, expected)
+}
+
+func (p *permitService) savePermit(ctx context.Context, permit *models.WorkPermit) error {
+	if err := p.PermitRepo.UpdatePermitState(ctx, permit); err != nil {
+		return fmt.Errorf("failed to update permit: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,20 @@
+package permit
+
+import (
+	"net/http"
+
+	"github.com/BerkatPS/pkg/rbac"
+)
+
+func RegisterRoutes(router *http.ServeMux, handler *PermitController, authz *rbac.Enforcer) {
+	router.HandleFunc("POST /permits", authz.RequireProject(rbac.PermitManage, rbac.BodyProject("project_id"), handler.CreatePermit))
+	router.HandleFunc("GET /permits/{id}", authz.RequireProject(rbac.PermitRead, authz.PathResource(rbac.ResourceWorkPermit, "id"), handler.FindPermitByID))
+	router.HandleFunc("GET /permits/project/{id}", authz.RequireProject(rbac.PermitRead, rbac.PathProject("id"), handler.FindPermitsByProject))
+	router.HandleFunc("GET /permits/flagged-tasks", authz.RequireProject(rbac.PermitRead, rbac.QueryProject("project_id"), handler.FindFlaggedTasks))
+	router.HandleFunc("PUT /permits/{id}/checklist/{item_id}", authz.RequireProject(rbac.PermitManage, authz.PathResource(rbac.ResourceWorkPermit, "id"), handler.CheckHazard))
+	router.HandleFunc("PUT /permits/{id}/sign/issuer", authz.RequireProject(rbac.PermitManage, authz.PathResource(rbac.ResourceWorkPermit, "id"), handler.SignAsIssuer))
+	router.HandleFunc("PUT /permits/{id}/sign/receiver", authz.RequireProject(rbac.PermitAccept, authz.PathResource(rbac.ResourceWorkPermit, "id"), handler.SignAsReceiver))
+	router.HandleFunc("PUT /permits/{id}/suspend", authz.RequireProject(rbac.PermitManage, authz.PathResource(rbac.ResourceWorkPermit, "id"), handler.SuspendPermit))
+	router.HandleFunc("PUT /permits/{id}/resume", authz.RequireProject(rbac.PermitManage, authz.PathResource(rbac.ResourceWorkPermit, "id"), handler.ResumePermit))
+	router.HandleFunc("PUT /permits/{id}/close", authz.RequireProject(rbac.PermitManage, authz.PathResource(rbac.ResourceWorkPermit, "id"), handler.ClosePermit))
+}
@@ -3,8 +3,11 @@ package presence
 import (
 	"encoding/json"
 	models "github.com/BerkatPS/internal"
+	"github.com/BerkatPS/pkg/export"
+	"github.com/BerkatPS/pkg/principal"
 	"github.com/BerkatPS/pkg/utils"
 	"net/http"
+	"time"
 )
 
 type PresenceController struct {
@@ -17,7 +20,7 @@ func NewPresenceController(presenceService PresenceService) *PresenceController
 
 func (p *PresenceController) FindAll(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
-	presences, err := p.presenceService.FindAll(ctx)
+	presences, err := p.presenceService.FindAll(ctx, principal.VisibleProjectIDs(ctx))
 	if err != nil {
 		utils.JSONErrorResponse(w, http.StatusInternalServerError, map[string]interface{}{
 			"status":  "error",
@@ -73,7 +76,7 @@ func (p *PresenceController) FindPresenceByUserID(w http.ResponseWriter, r *http
 		return
 	}
 
-	presence, err := p.presenceService.FindPresenceByUserID(ctx, userID)
+	presence, err := p.presenceService.FindPresenceByUserID(ctx, userID, principal.VisibleProjectIDs(ctx))
 	if err != nil {
 		utils.JSONErrorResponse(w, http.StatusInternalServerError, map[string]interface{}{
 			"status":  "error",
@@ -141,3 +144,47 @@ func (p *PresenceController) UpdatePresence(w http.ResponseWriter, r *http.Reque
 		"data":    presence,
 	})
 }
+
+var presenceExportHeaders = []string{"ID", "Date", "User ID", "User", "Project ID", "Project", "Status", "Comments"}
+
+// ExportPresences downloads attendance as ?format=csv or xlsx, filtered by ?project_id=, ?user_id=,
+// ?status=, ?start_date= and ?end_date=
+func (p *PresenceController) ExportPresences(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	filter, format, err := parsePresenceExport(r)
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	export.Stream(w, format, "Attendance", export.FileName("attendance", time.Now()), presenceExportHeaders,
+		func(emit func(values ...interface{}) error) error {
+			return p.presenceService.ExportPresences(ctx, filter, func(pr models.Presence) error {
+				return emit(pr.ID, pr.Date, pr.UserID, pr.User.Username, pr.ProjectID, pr.Project.Name, pr.Status, pr.Comments)
+			})
+		})
+}
+
+func parsePresenceExport(r *http.Request) (PresenceFilter, string, error) {
+	var filter PresenceFilter
+
+	format, err := export.ParseFormat(r)
+	if err != nil {
+		return filter, "", err
+	}
+	if filter.ProjectID, err = utils.ParseInt64Query(r, "project_id"); err != nil {
+		return filter, "", err
+	}
+	if filter.UserID, err = utils.ParseInt64Query(r, "user_id"); err != nil {
+		return filter, "", err
+	}
+	filter.Status = r.URL.Query().Get("status")
+	if filter.From, filter.To, err = export.ParseDateRange(r); err != nil {
+		return filter, "", err
+	}
+	return filter, format, nil
+}
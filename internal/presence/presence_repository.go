@@ -5,15 +5,21 @@ import (
 	"database/sql"
 	"fmt"
 	models "github.com/BerkatPS/internal"
+	"github.com/lib/pq"
+	"time"
 )
 
 type PresenceRepository interface {
-	FindAll(ctx context.Context) ([]models.Presence, error)
+	// FindAll and FindPresenceByUserID keep to the presences of projectIDs; nil matches every project
+	FindAll(ctx context.Context, projectIDs []int64) ([]models.Presence, error)
 	FindPresenceByID(ctx context.Context, id int64) (*models.Presence, error)
-	FindPresenceByUserID(ctx context.Context, userID int64) (*models.Presence, error)
+	FindPresenceByUserID(ctx context.Context, userID int64, projectIDs []int64) (*models.Presence, error)
 	CreatePresence(ctx context.Context, presence *models.Presence) error
 	FindPresenceByUserIDAndDate(ctx context.Context, userID int64, date string) (*models.Presence, error)
 	UpdatePresence(ctx context.Context, presence *models.Presence) error
+	// StreamPresences hands every presence matching the filter to fn, by date and user, without collecting
+	// them. User and Project carry the username and project name.
+	StreamPresences(ctx context.Context, filter PresenceFilter, fn func(models.Presence) error) error
 }
 
 type presenceRepository struct {
@@ -40,10 +46,10 @@ func (p *presenceRepository) FindPresenceByUserIDAndDate(ctx context.Context, us
 	return &presence, nil
 }
 
-func (p *presenceRepository) FindAll(ctx context.Context) ([]models.Presence, error) {
-	query := "SELECT id, user_id, status FROM presences"
+func (p *presenceRepository) FindAll(ctx context.Context, projectIDs []int64) ([]models.Presence, error) {
+	query := "SELECT id, user_id, status FROM presences WHERE $1::bigint[] IS NULL OR project_id = ANY($1)"
 
-	rows, err := p.db.QueryContext(ctx, query)
+	rows, err := p.db.QueryContext(ctx, query, pq.Array(projectIDs))
 	if err != nil {
 		return nil, err
 	}
@@ -76,11 +82,11 @@ func (p *presenceRepository) FindPresenceByID(ctx context.Context, id int64) (*m
 	return &presence, nil
 }
 
-func (p *presenceRepository) FindPresenceByUserID(ctx context.Context, userID int64) (*models.Presence, error) {
-	query := "SELECT id, user_id, status, comments, date FROM presences WHERE user_id = $1"
+func (p *presenceRepository) FindPresenceByUserID(ctx context.Context, userID int64, projectIDs []int64) (*models.Presence, error) {
+	query := "SELECT id, user_id, status, comments, date FROM presences WHERE user_id = $1 AND ($2::bigint[] IS NULL OR project_id = ANY($2))"
 
 	var presence models.Presence
-	err := p.db.QueryRowContext(ctx, query, userID).Scan(&presence.ID, &presence.UserID, &presence.Status, &presence.Comments, &presence.Date)
+	err := p.db.QueryRowContext(ctx, query, userID, pq.Array(projectIDs)).Scan(&presence.ID, &presence.UserID, &presence.Status, &presence.Comments, &presence.Date)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, fmt.Errorf("presence not found for user ID %d", userID)
@@ -93,9 +99,9 @@ func (p *presenceRepository) FindPresenceByUserID(ctx context.Context, userID in
 }
 
 func (p *presenceRepository) CreatePresence(ctx context.Context, presence *models.Presence) error {
-	query := "INSERT INTO presences (user_id, status, comments, date) VALUES ($1, $2, $3, $4)"
+	query := "INSERT INTO presences (user_id, project_id, status, comments, date) VALUES ($1, $2, $3, $4, $5)"
 
-	_, err := p.db.ExecContext(ctx, query, presence.UserID, presence.Status, presence.Comments, presence.Date)
+	_, err := p.db.ExecContext(ctx, query, presence.UserID, presence.ProjectID, presence.Status, presence.Comments, presence.Date)
 	return err
 }
 
@@ -105,3 +111,46 @@ func (p *presenceRepository) UpdatePresence(ctx context.Context, presence *model
 	_, err := p.db.ExecContext(ctx, query, presence.Status, presence.ID)
 	return err
 }
+
+func (p *presenceRepository) StreamPresences(ctx context.Context, filter PresenceFilter, fn func(models.Presence) error) error {
+	query := `SELECT pr.id, pr.user_id, COALESCE(pr.project_id, 0), pr.status, COALESCE(pr.comments, ''), pr.date,
+		COALESCE(u.username, ''), COALESCE(p.name, '')
+		FROM presences pr
+		LEFT JOIN users u ON u.id = pr.user_id
+		LEFT JOIN projects p ON p.id = pr.project_id
+		WHERE ($1 = 0 OR pr.project_id = $1)
+			AND ($2 = 0 OR pr.user_id = $2)
+			AND ($3 = '' OR pr.status = $3)
+			AND ($4::timestamp IS NULL OR pr.date >= $4)
+			AND ($5::timestamp IS NULL OR pr.date < $5)
+		ORDER BY pr.date, pr.user_id, pr.id`
+
+	rows, err := p.db.QueryContext(ctx, query, filter.ProjectID, filter.UserID, filter.Status, nullTime(filter.From), nullTime(filter.To))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		presence := models.Presence{User: &models.User{}, Project: &models.Project{}}
+		if err := rows.Scan(&presence.ID, &presence.UserID, &presence.ProjectID, &presence.Status, &presence.Comments, &presence.Date,
+			&presence.User.Username, &presence.Project.Name); err != nil {
+			return err
+		}
+		presence.User.ID = presence.UserID
+		presence.Project.ID = presence.ProjectID
+		if err := fn(presence); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// nullTime passes an unset bound as NULL so the filter ignores it
+func nullTime(t time.Time) interface{} {
+	if t.IsZero() {
+		return nil
+	}
+	return t
+}
@@ -9,11 +9,23 @@ import (
 )
 
 type PresenceService interface {
-	FindAll(ctx context.Context) ([]models.Presence, error)
+	// FindAll and FindPresenceByUserID keep to the presences of projectIDs; nil matches every project
+	FindAll(ctx context.Context, projectIDs []int64) ([]models.Presence, error)
 	FindPresenceByID(ctx context.Context, id int64) (*models.Presence, error)
-	FindPresenceByUserID(ctx context.Context, userID int64) (*models.Presence, error)
+	FindPresenceByUserID(ctx context.Context, userID int64, projectIDs []int64) (*models.Presence, error)
 	CreatePresence(ctx context.Context, presence *models.Presence) error
 	UpdatePresence(ctx context.Context, presence *models.Presence) error
+	// ExportPresences streams the presences matching the filter to fn, for CSV and XLSX exports
+	ExportPresences(ctx context.Context, filter PresenceFilter, fn func(models.Presence) error) error
+}
+
+// PresenceFilter narrows a presence export. Zero fields are not applied; From is inclusive and To exclusive.
+type PresenceFilter struct {
+	ProjectID int64
+	UserID    int64
+	Status    string
+	From      time.Time
+	To        time.Time
 }
 
 type presenceService struct {
@@ -24,8 +36,8 @@ func NewPresenceService(presenceRepository PresenceRepository) PresenceService {
 	return &presenceService{presenceRepository}
 }
 
-func (p *presenceService) FindAll(ctx context.Context) ([]models.Presence, error) {
-	return p.presenceRepository.FindAll(ctx)
+func (p *presenceService) FindAll(ctx context.Context, projectIDs []int64) ([]models.Presence, error) {
+	return p.presenceRepository.FindAll(ctx, projectIDs)
 }
 
 func (p *presenceService) FindPresenceByID(ctx context.Context, id int64) (*models.Presence, error) {
@@ -35,18 +47,21 @@ func (p *presenceService) FindPresenceByID(ctx context.Context, id int64) (*mode
 	return p.presenceRepository.FindPresenceByID(ctx, id)
 }
 
-func (p *presenceService) FindPresenceByUserID(ctx context.Context, userID int64) (*models.Presence, error) {
+func (p *presenceService) FindPresenceByUserID(ctx context.Context, userID int64, projectIDs []int64) (*models.Presence, error) {
 	if userID <= 0 {
 		return nil, errors.New("invalid user ID")
 	}
 
-	return p.presenceRepository.FindPresenceByUserID(ctx, userID)
+	return p.presenceRepository.FindPresenceByUserID(ctx, userID, projectIDs)
 }
 
 func (p *presenceService) CreatePresence(ctx context.Context, presence *models.Presence) error {
 	if presence.UserID <= 0 {
 		return errors.New("invalid user ID")
 	}
+	if presence.ProjectID <= 0 {
+		return errors.New("invalid project ID")
+	}
 	if presence.Comments == "" {
 		return errors.New("comments are required")
 	}
@@ -79,3 +94,13 @@ func (p *presenceService) UpdatePresence(ctx context.Context, presence *models.P
 
 	return p.presenceRepository.UpdatePresence(ctx, presence)
 }
+
+func (p *presenceService) ExportPresences(ctx context.Context, filter PresenceFilter, fn func(models.Presence) error) error {
+	if filter.ProjectID < 0 || filter.UserID < 0 {
+		return errors.New("invalid project or user ID")
+	}
+	if err := p.presenceRepository.StreamPresences(ctx, filter, fn); err != nil {
+		return fmt.Errorf("failed to export presences: %v", err)
+	}
+	return nil
+}
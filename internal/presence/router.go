@@ -1,11 +1,16 @@
 package presence
 
-import "net/http"
+import (
+	"net/http"
 
-func RegisterRoutes(router *http.ServeMux, handler *PresenceController) {
-	router.HandleFunc("GET /presences", handler.FindAll)
-	router.HandleFunc("GET /presences/:id", handler.FindPresenceByID)
-	router.HandleFunc("GET /presences/user/:id", handler.FindPresenceByUserID)
-	router.HandleFunc("POST /presences", handler.CreatePresence)
-	router.HandleFunc("PUT /presences/:id", handler.UpdatePresence)
+	"github.com/BerkatPS/pkg/rbac"
+)
+
+func RegisterRoutes(router *http.ServeMux, handler *PresenceController, authz *rbac.Enforcer) {
+	router.HandleFunc("GET /presences", authz.Require(rbac.PresenceRead, handler.FindAll))
+	router.HandleFunc("GET /presences/export", authz.RequireProject(rbac.PresenceRead, rbac.QueryProject("project_id"), handler.ExportPresences))
+	router.HandleFunc("GET /presences/{id}", authz.RequireProject(rbac.PresenceRead, authz.PathResource(rbac.ResourcePresence, "id"), handler.FindPresenceByID))
+	router.HandleFunc("GET /presences/user/{id}", authz.Require(rbac.PresenceRead, handler.FindPresenceByUserID))
+	router.HandleFunc("POST /presences", authz.RequireProject(rbac.PresenceCreate, rbac.BodyProject("project_id"), handler.CreatePresence))
+	router.HandleFunc("PUT /presences/{id}", authz.RequireProject(rbac.PresenceUpdate, authz.PathResource(rbac.ResourcePresence, "id"), handler.UpdatePresence))
 }
@@ -2,11 +2,23 @@ package project
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+
 	models "github.com/BerkatPS/internal"
+	"github.com/BerkatPS/pkg/principal"
 	"github.com/BerkatPS/pkg/utils"
-	"net/http"
 )
 
+// MaxDocumentSize caps a single document upload
+const MaxDocumentSize = 200 << 20
+
 type ProjectController struct {
 	projectService ProjectService
 }
@@ -27,7 +39,7 @@ func (pc *ProjectController) FindProjectsByStatus(w http.ResponseWriter, r *http
 		return
 	}
 
-	projects, err := pc.projectService.FindProjectsByStatus(ctx, status)
+	projects, err := pc.projectService.FindProjectsByStatus(ctx, status, principal.VisibleProjectIDs(ctx))
 	if err != nil {
 		utils.JSONErrorResponse(w, http.StatusInternalServerError, map[string]interface{}{
 			"status":  "error",
@@ -186,7 +198,11 @@ func (pc *ProjectController) UpdateProjectTeamRole(w http.ResponseWriter, r *htt
 	}
 
 	if err := pc.projectService.UpdateProjectTeamRole(ctx, id, updateProjectTeamRoleRequest.UserID, updateProjectTeamRoleRequest.Role); err != nil {
-		utils.JSONErrorResponse(w, http.StatusInternalServerError, map[string]interface{}{
+		status := http.StatusInternalServerError
+		if errors.Is(err, ErrRoleNotAssignable) || errors.Is(err, ErrNotProjectMember) {
+			status = http.StatusForbidden
+		}
+		utils.JSONErrorResponse(w, status, map[string]interface{}{
 			"status":  "error",
 			"message": "Failed to update project team role: " + err.Error(),
 		})
@@ -312,9 +328,20 @@ func (pc *ProjectController) UpdateProjectBudget(w http.ResponseWriter, r *http.
 	})
 }
 
+// DeleteProjectDocument archives a revision of a project document; ?revision_id= picks one, by default the current revision.
+// Stored content and history are kept.
 func (pc *ProjectController) DeleteProjectDocument(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
+	userID, err := principal.UserIDFromRequest(r)
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusUnauthorized, map[string]interface{}{
+			"status":  "error",
+			"message": "Unauthorized: " + err.Error(),
+		})
+		return
+	}
+
 	id, err := utils.ParseInt64Param(r)
 	if err != nil {
 		utils.JSONErrorResponse(w, http.StatusBadRequest, map[string]interface{}{
@@ -324,8 +351,29 @@ func (pc *ProjectController) DeleteProjectDocument(w http.ResponseWriter, r *htt
 		return
 	}
 
-	if err := pc.projectService.DeleteProjectDocument(ctx, id); err != nil {
-		utils.JSONErrorResponse(w, http.StatusInternalServerError, map[string]interface{}{
+	documentID, err := utils.ParseInt64PathValue(r, "document_id")
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": "Invalid document ID: " + err.Error(),
+		})
+		return
+	}
+
+	var revisionID int64
+	if raw := r.URL.Query().Get("revision_id"); raw != "" {
+		if revisionID, err = strconv.ParseInt(raw, 10, 64); err != nil {
+			utils.JSONErrorResponse(w, http.StatusBadRequest, map[string]interface{}{
+				"status":  "error",
+				"message": "Invalid revision ID: " + err.Error(),
+			})
+			return
+		}
+	}
+
+	revision, err := pc.projectService.DeleteProjectDocument(ctx, id, documentID, revisionID, userID)
+	if err != nil {
+		utils.JSONErrorResponse(w, documentErrorStatus(err), map[string]interface{}{
 			"status":  "error",
 			"message": "Failed to delete project document: " + err.Error(),
 		})
@@ -334,13 +382,25 @@ func (pc *ProjectController) DeleteProjectDocument(w http.ResponseWriter, r *htt
 
 	utils.JSONResponse(w, http.StatusOK, map[string]interface{}{
 		"status":  "success",
-		"message": "Project document deleted successfully",
+		"message": "Project document revision archived successfully",
+		"data":    revision,
 	})
 }
 
+// UploadProjectDocument accepts a multipart/form-data upload and streams the "file" part into storage.
+// Optional "name", "type", "label" and "notes" fields must come before the file part.
 func (pc *ProjectController) UploadProjectDocument(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
+	userID, err := principal.UserIDFromRequest(r)
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusUnauthorized, map[string]interface{}{
+			"status":  "error",
+			"message": "Unauthorized: " + err.Error(),
+		})
+		return
+	}
+
 	id, err := utils.ParseInt64Param(r)
 	if err != nil {
 		utils.JSONErrorResponse(w, http.StatusBadRequest, map[string]interface{}{
@@ -350,37 +410,359 @@ func (pc *ProjectController) UploadProjectDocument(w http.ResponseWriter, r *htt
 		return
 	}
 
-	var uploadProjectDocumentRequest struct {
-		Document *models.Document `json:"document"`
+	document := &models.Document{}
+	revision := &models.DocumentRevision{UploadedBy: userID}
+	fields := map[string]*string{
+		"name":  &document.Name,
+		"type":  &document.Type,
+		"label": &revision.Label,
+		"notes": &revision.Notes,
+	}
+
+	err = readMultipartFile(w, r, fields, func(fileName string, content io.Reader) error {
+		revision.FileName = fileName
+		return pc.projectService.UploadProjectDocument(ctx, id, document, revision, content)
+	})
+	if err != nil {
+		utils.JSONErrorResponse(w, documentErrorStatus(err), map[string]interface{}{
+			"status":  "error",
+			"message": "Failed to upload project document: " + err.Error(),
+		})
+		return
+	}
+
+	utils.JSONResponse(w, http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "Project document uploaded successfully",
+		"data":    document,
+	})
+}
+
+// UploadDocumentRevision uploads a new revision that supersedes the document's current one.
+// Optional "label" (defaults to the next letter) and "notes" fields must come before the file part.
+func (pc *ProjectController) UploadDocumentRevision(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userID, err := principal.UserIDFromRequest(r)
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusUnauthorized, map[string]interface{}{
+			"status":  "error",
+			"message": "Unauthorized: " + err.Error(),
+		})
+		return
+	}
+
+	id, err := utils.ParseInt64Param(r)
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": "Invalid document ID: " + err.Error(),
+		})
+		return
+	}
+
+	revision := &models.DocumentRevision{UploadedBy: userID}
+	fields := map[string]*string{
+		"label": &revision.Label,
+		"notes": &revision.Notes,
+	}
+
+	err = readMultipartFile(w, r, fields, func(fileName string, content io.Reader) error {
+		revision.FileName = fileName
+		return pc.projectService.UploadDocumentRevision(ctx, id, revision, content)
+	})
+	if err != nil {
+		utils.JSONErrorResponse(w, documentErrorStatus(err), map[string]interface{}{
+			"status":  "error",
+			"message": "Failed to upload document revision: " + err.Error(),
+		})
+		return
 	}
 
-	if err := json.NewDecoder(r.Body).Decode(&uploadProjectDocumentRequest); err != nil {
+	utils.JSONResponse(w, http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "Document revision uploaded successfully",
+		"data":    revision,
+	})
+}
+
+// FindDocumentRevisions returns a document with its full revision history
+func (pc *ProjectController) FindDocumentRevisions(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userID, err := principal.UserIDFromRequest(r)
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusUnauthorized, map[string]interface{}{
+			"status":  "error",
+			"message": "Unauthorized: " + err.Error(),
+		})
+		return
+	}
+
+	id, err := utils.ParseInt64Param(r)
+	if err != nil {
 		utils.JSONErrorResponse(w, http.StatusBadRequest, map[string]interface{}{
 			"status":  "error",
-			"message": "Invalid request payload: " + err.Error(),
+			"message": "Invalid document ID: " + err.Error(),
 		})
 		return
 	}
 
-	if err := pc.projectService.UploadProjectDocument(ctx, id, uploadProjectDocumentRequest.Document); err != nil {
-		utils.JSONErrorResponse(w, http.StatusInternalServerError, map[string]interface{}{
+	document, err := pc.projectService.FindDocumentRevisions(ctx, id, userID)
+	if err != nil {
+		utils.JSONErrorResponse(w, documentErrorStatus(err), map[string]interface{}{
 			"status":  "error",
-			"message": "Failed to upload project document: " + err.Error(),
+			"message": "Failed to retrieve document revisions: " + err.Error(),
 		})
 		return
 	}
 
 	utils.JSONResponse(w, http.StatusOK, map[string]interface{}{
 		"status":  "success",
-		"message": "Project document uploaded successfully",
+		"message": "Document revisions found successfully",
+		"data":    document,
+	})
+}
+
+func (pc *ProjectController) FindDocumentRevision(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userID, documentID, revisionID, ok := parseRevisionRequest(w, r)
+	if !ok {
+		return
+	}
+
+	revision, err := pc.projectService.FindDocumentRevision(ctx, documentID, revisionID, userID)
+	if err != nil {
+		utils.JSONErrorResponse(w, documentErrorStatus(err), map[string]interface{}{
+			"status":  "error",
+			"message": "Failed to retrieve document revision: " + err.Error(),
+		})
+		return
+	}
+
+	utils.JSONResponse(w, http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "Document revision found successfully",
+		"data":    revision,
 	})
 }
 
-// FindAll handles the request to retrieve all projects
+// DiffDocumentRevisions compares the metadata of two revisions given as ?from= and ?to= revision IDs
+func (pc *ProjectController) DiffDocumentRevisions(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userID, err := principal.UserIDFromRequest(r)
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusUnauthorized, map[string]interface{}{
+			"status":  "error",
+			"message": "Unauthorized: " + err.Error(),
+		})
+		return
+	}
+
+	id, err := utils.ParseInt64Param(r)
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": "Invalid document ID: " + err.Error(),
+		})
+		return
+	}
+
+	fromID, fromErr := strconv.ParseInt(r.URL.Query().Get("from"), 10, 64)
+	toID, toErr := strconv.ParseInt(r.URL.Query().Get("to"), 10, 64)
+	if fromErr != nil || toErr != nil {
+		utils.JSONErrorResponse(w, http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": "Query parameters 'from' and 'to' must be revision IDs",
+		})
+		return
+	}
+
+	diff, err := pc.projectService.DiffDocumentRevisions(ctx, id, fromID, toID, userID)
+	if err != nil {
+		utils.JSONErrorResponse(w, documentErrorStatus(err), map[string]interface{}{
+			"status":  "error",
+			"message": "Failed to compare document revisions: " + err.Error(),
+		})
+		return
+	}
+
+	utils.JSONResponse(w, http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "Document revisions compared successfully",
+		"data":    diff,
+	})
+}
+
+// DownloadDocument streams the current revision of a stored document to a member of its project
+func (pc *ProjectController) DownloadDocument(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userID, err := principal.UserIDFromRequest(r)
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusUnauthorized, map[string]interface{}{
+			"status":  "error",
+			"message": "Unauthorized: " + err.Error(),
+		})
+		return
+	}
+
+	id, err := utils.ParseInt64Param(r)
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": "Invalid document ID: " + err.Error(),
+		})
+		return
+	}
+
+	document, content, err := pc.projectService.OpenProjectDocument(ctx, id, userID)
+	if err != nil {
+		utils.JSONErrorResponse(w, documentErrorStatus(err), map[string]interface{}{
+			"status":  "error",
+			"message": "Failed to download document: " + err.Error(),
+		})
+		return
+	}
+	defer content.Close()
+
+	serveDocumentContent(w, content, document.FileName, document.MimeType, document.SHA256, document.Size)
+}
+
+// DownloadDocumentRevision streams any revision of a document, including superseded and archived ones
+func (pc *ProjectController) DownloadDocumentRevision(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userID, documentID, revisionID, ok := parseRevisionRequest(w, r)
+	if !ok {
+		return
+	}
+
+	revision, content, err := pc.projectService.OpenDocumentRevision(ctx, documentID, revisionID, userID)
+	if err != nil {
+		utils.JSONErrorResponse(w, documentErrorStatus(err), map[string]interface{}{
+			"status":  "error",
+			"message": "Failed to download document revision: " + err.Error(),
+		})
+		return
+	}
+	defer content.Close()
+
+	serveDocumentContent(w, content, revision.FileName, revision.MimeType, revision.SHA256, revision.Size)
+}
+
+func serveDocumentContent(w http.ResponseWriter, content io.Reader, fileName, mimeType, sha256 string, size int64) {
+	w.Header().Set("Content-Type", mimeType)
+	w.Header().Set("Content-Length", strconv.FormatInt(size, 10))
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": fileName}))
+	w.Header().Set("ETag", `"`+sha256+`"`)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusOK)
+
+	io.Copy(w, content)
+}
+
+// parseRevisionRequest reads the caller and the {id}/{revision_id} path values, writing the error response itself
+func parseRevisionRequest(w http.ResponseWriter, r *http.Request) (int64, int64, int64, bool) {
+	userID, err := principal.UserIDFromRequest(r)
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusUnauthorized, map[string]interface{}{
+			"status":  "error",
+			"message": "Unauthorized: " + err.Error(),
+		})
+		return 0, 0, 0, false
+	}
+
+	documentID, err := utils.ParseInt64Param(r)
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": "Invalid document ID: " + err.Error(),
+		})
+		return 0, 0, 0, false
+	}
+
+	revisionID, err := utils.ParseInt64PathValue(r, "revision_id")
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": "Invalid revision ID: " + err.Error(),
+		})
+		return 0, 0, 0, false
+	}
+
+	return userID, documentID, revisionID, true
+}
+
+var (
+	errInvalidUpload = errors.New("invalid multipart upload")
+	errMissingFile   = errors.New("missing required form field 'file'")
+)
+
+// readMultipartFile walks a multipart/form-data body without buffering it, copying the small text fields
+// into fields and handing the "file" part to upload as a stream
+func readMultipartFile(w http.ResponseWriter, r *http.Request, fields map[string]*string, upload func(fileName string, content io.Reader) error) error {
+	r.Body = http.MaxBytesReader(w, r.Body, MaxDocumentSize)
+
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return fmt.Errorf("%w: %w", errInvalidUpload, err)
+	}
+
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return errMissingFile
+		}
+		if err != nil {
+			return fmt.Errorf("%w: %w", errInvalidUpload, err)
+		}
+
+		if part.FormName() == "file" {
+			fileName := ""
+			if name := part.FileName(); name != "" {
+				fileName = filepath.Base(name)
+			}
+			err := upload(fileName, part)
+			part.Close()
+			return err
+		}
+
+		if target, ok := fields[part.FormName()]; ok {
+			value, err := io.ReadAll(io.LimitReader(part, 4096))
+			if err != nil {
+				part.Close()
+				return fmt.Errorf("%w: %w", errInvalidUpload, err)
+			}
+			*target = strings.TrimSpace(string(value))
+		}
+		part.Close()
+	}
+}
+
+// documentErrorStatus maps document failures to their HTTP status codes
+func documentErrorStatus(err error) int {
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.As(err, &tooLarge):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, errInvalidUpload), errors.Is(err, errMissingFile):
+		return http.StatusBadRequest
+	case errors.Is(err, ErrNotProjectMember):
+		return http.StatusForbidden
+	case errors.Is(err, ErrDuplicateRevisionLabel), errors.Is(err, ErrRevisionArchived):
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
+// FindAll handles the request to retrieve the projects the caller belongs to, or all of them for admins
 func (pc *ProjectController) FindAll(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
-	projects, err := pc.projectService.FindAll(ctx)
+	projects, err := pc.projectService.FindAll(ctx, principal.VisibleProjectIDs(ctx))
 	if err != nil {
 		utils.JSONErrorResponse(w, http.StatusInternalServerError, map[string]interface{}{
 			"status":  "error",
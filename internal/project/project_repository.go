@@ -4,17 +4,21 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"time"
+
 	models "github.com/BerkatPS/internal"
+	"github.com/lib/pq"
 )
 
 type ProjectRepository interface {
-	FindAll(ctx context.Context) ([]models.Project, error)
+	// FindAll lists the projects among projectIDs, or every project when projectIDs is nil
+	FindAll(ctx context.Context, projectIDs []int64) ([]models.Project, error)
 	FindProjectByID(ctx context.Context, id int64) (*models.Project, error)
 	CreateProject(ctx context.Context, project *models.Project) error
 	UpdateProject(ctx context.Context, project *models.Project) error
 	DeleteProject(ctx context.Context, id int64) error
 	// FindProjectsByStatus allows filtering projects by their status (e.g., ongoing, completed, delayed)
-	FindProjectsByStatus(ctx context.Context, status string) ([]models.Project, error)
+	FindProjectsByStatus(ctx context.Context, status string, projectIDs []int64) ([]models.Project, error)
 	// UpdateProjectStatus updates the status of a project, which is crucial for real-time monitoring
 	UpdateProjectStatus(ctx context.Context, id int64, status string) error
 	// AddTeamMemberToProject adds a new team member to an existing project to improve team collaboration
@@ -29,10 +33,21 @@ type ProjectRepository interface {
 	FindExpensesByProject(ctx context.Context, projectId int64) ([]models.Expense, error)
 	// UpdateProjectBudget allows updating the overall budget for a project, useful for real-time adjustments
 	UpdateProjectBudget(ctx context.Context, projectId int64, newBudget float64) error
-	// DeleteProjectDocument deletes a document from a project's records
-	DeleteProjectDocument(ctx context.Context, documentId int64) error
-	// UploadProjectDocument uploads a document related to a project, storing it for easy access
-	UploadProjectDocument(ctx context.Context, projectId int64, document *models.Document) error
+	// UploadProjectDocument records a stored document related to a project together with its first revision
+	UploadProjectDocument(ctx context.Context, projectId int64, document *models.Document, revision *models.DocumentRevision) error
+	// CreateDocumentRevision adds a revision, supersedes the current one and moves the document's current pointer
+	CreateDocumentRevision(ctx context.Context, documentId int64, revision *models.DocumentRevision) error
+	FindDocumentRevisions(ctx context.Context, documentId int64) ([]models.DocumentRevision, error)
+	FindDocumentRevisionByID(ctx context.Context, revisionId int64) (*models.DocumentRevision, error)
+	// ArchiveDocumentRevision archives a revision (the current one when revisionId is 0). Archiving the current
+	// revision makes the latest remaining revision current again, or archives the document when none is left.
+	ArchiveDocumentRevision(ctx context.Context, documentId int64, revisionId int64, userId int64, archivedAt time.Time) (*models.DocumentRevision, error)
+	// SetDocumentURL points a document at the endpoint that serves its content
+	SetDocumentURL(ctx context.Context, documentId int64, url string) error
+	// FindDocumentByID finds a document's metadata
+	FindDocumentByID(ctx context.Context, documentId int64) (*models.Document, error)
+	// IsProjectMember reports whether the user manages the project or is on its team
+	IsProjectMember(ctx context.Context, projectId int64, userId int64) (bool, error)
 }
 
 type projectRepository struct {
@@ -77,26 +92,309 @@ func (p *projectRepository) UpdateProjectBudget(ctx context.Context, projectId i
 	return nil
 }
 
-func (p *projectRepository) DeleteProjectDocument(ctx context.Context, documentId int64) error {
-	query := "DELETE FROM documents WHERE id = $1"
+func (p *projectRepository) UploadProjectDocument(ctx context.Context, projectId int64, document *models.Document, revision *models.DocumentRevision) error {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO documents (project_id, name, type, url, file_name, size, mime_type, sha256, storage_key, uploaded_by, upload_date, revision, status)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+		RETURNING id
+	`
 
-	_, err := p.db.ExecContext(ctx, query, documentId)
+	err = tx.QueryRowContext(ctx, query, projectId, document.Name, document.Type, document.URL, document.FileName, document.Size,
+		document.MimeType, document.SHA256, document.StorageKey, document.UploadedBy, document.UploadDate, revision.Label, document.Status).Scan(&document.ID)
 	if err != nil {
 		return err
 	}
-	return nil
+
+	revision.DocumentID = document.ID
+	revision.Number = 1
+	if err := insertRevision(ctx, tx, revision); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE documents SET current_revision_id = $1 WHERE id = $2", revision.ID, document.ID); err != nil {
+		return err
+	}
+	document.CurrentRevisionID = revision.ID
+	document.Revision = revision.Label
+
+	return tx.Commit()
 }
 
-func (p *projectRepository) UploadProjectDocument(ctx context.Context, projectId int64, document *models.Document) error {
-	query := "INSERT INTO documents (project_id, name, type, url) VALUES ($1, $2, $3, $4)"
+func (p *projectRepository) CreateDocumentRevision(ctx context.Context, documentId int64, revision *models.DocumentRevision) error {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
 
-	_, err := p.db.ExecContext(ctx, query, projectId, document.Name, document.Type, document.URL)
+	// Lock the document so concurrent uploads cannot claim the same revision number
+	document, err := scanDocument(tx.QueryRowContext(ctx, selectDocumentColumns+" WHERE id = $1 FOR UPDATE", documentId))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return errors.New("document not found")
+		}
+		return err
+	}
+
+	var count int
+	if err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM document_revisions WHERE document_id = $1", documentId).Scan(&count); err != nil {
+		return err
+	}
+
+	// Documents uploaded before revisions existed get their file recorded as the first revision
+	if count == 0 && document.StorageKey != "" {
+		legacy := &models.DocumentRevision{
+			DocumentID: documentId,
+			Number:     1,
+			Label:      RevisionLabel(1),
+			FileName:   document.FileName,
+			Size:       document.Size,
+			MimeType:   document.MimeType,
+			SHA256:     document.SHA256,
+			StorageKey: document.StorageKey,
+			Status:     RevisionStatusCurrent,
+			UploadedBy: document.UploadedBy,
+			UploadDate: document.UploadDate,
+		}
+		if err := insertRevision(ctx, tx, legacy); err != nil {
+			return err
+		}
+		document.CurrentRevisionID = legacy.ID
+		count = 1
+	}
+
+	revision.DocumentID = documentId
+	revision.Number = count + 1
+	if revision.Label == "" {
+		revision.Label = RevisionLabel(revision.Number)
+	}
+
+	var taken bool
+	takenQuery := "SELECT EXISTS (SELECT 1 FROM document_revisions WHERE document_id = $1 AND label = $2)"
+	if err := tx.QueryRowContext(ctx, takenQuery, documentId, revision.Label).Scan(&taken); err != nil {
+		return err
+	}
+	if taken {
+		return ErrDuplicateRevisionLabel
+	}
+
+	if err := insertRevision(ctx, tx, revision); err != nil {
+		return err
+	}
+
+	supersedeQuery := "UPDATE document_revisions SET status = $1, superseded_by = $2 WHERE document_id = $3 AND status = $4 AND id <> $2"
+	if _, err := tx.ExecContext(ctx, supersedeQuery, RevisionStatusSuperseded, revision.ID, documentId, RevisionStatusCurrent); err != nil {
+		return err
+	}
+
+	if err := setCurrentRevision(ctx, tx, documentId, revision); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (p *projectRepository) FindDocumentRevisions(ctx context.Context, documentId int64) ([]models.DocumentRevision, error) {
+	rows, err := p.db.QueryContext(ctx, selectRevisionColumns+" WHERE document_id = $1 ORDER BY number", documentId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var revisions []models.DocumentRevision
+	for rows.Next() {
+		revision, err := scanRevision(rows)
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, *revision)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return revisions, nil
+}
+
+func (p *projectRepository) FindDocumentRevisionByID(ctx context.Context, revisionId int64) (*models.DocumentRevision, error) {
+	revision, err := scanRevision(p.db.QueryRowContext(ctx, selectRevisionColumns+" WHERE id = $1", revisionId))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("revision not found")
+		}
+		return nil, err
+	}
+	return revision, nil
+}
+
+func (p *projectRepository) ArchiveDocumentRevision(ctx context.Context, documentId int64, revisionId int64, userId int64, archivedAt time.Time) (*models.DocumentRevision, error) {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	document, err := scanDocument(tx.QueryRowContext(ctx, selectDocumentColumns+" WHERE id = $1 FOR UPDATE", documentId))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("document not found")
+		}
+		return nil, err
+	}
+
+	if revisionId == 0 {
+		revisionId = document.CurrentRevisionID
+	}
+
+	revision, err := scanRevision(tx.QueryRowContext(ctx, selectRevisionColumns+" WHERE id = $1 AND document_id = $2", revisionId, documentId))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("revision not found")
+		}
+		return nil, err
+	}
+	if revision.Status == RevisionStatusArchived {
+		return nil, ErrRevisionArchived
+	}
+	wasCurrent := revision.Status == RevisionStatusCurrent
+
+	revision.Status = RevisionStatusArchived
+	revision.ArchivedBy = userId
+	revision.ArchivedAt = archivedAt
+	archiveQuery := "UPDATE document_revisions SET status = $1, archived_by = $2, archived_at = $3 WHERE id = $4"
+	if _, err := tx.ExecContext(ctx, archiveQuery, revision.Status, userId, archivedAt, revision.ID); err != nil {
+		return nil, err
+	}
+
+	if wasCurrent {
+		latestQuery := selectRevisionColumns + " WHERE document_id = $1 AND status <> $2 ORDER BY number DESC LIMIT 1"
+		latest, err := scanRevision(tx.QueryRowContext(ctx, latestQuery, documentId, RevisionStatusArchived))
+		switch {
+		case err == sql.ErrNoRows:
+			if _, err := tx.ExecContext(ctx, "UPDATE documents SET status = $1 WHERE id = $2", DocumentStatusArchived, documentId); err != nil {
+				return nil, err
+			}
+		case err != nil:
+			return nil, err
+		default:
+			restoreQuery := "UPDATE document_revisions SET status = $1, superseded_by = 0 WHERE id = $2"
+			if _, err := tx.ExecContext(ctx, restoreQuery, RevisionStatusCurrent, latest.ID); err != nil {
+				return nil, err
+			}
+			if err := setCurrentRevision(ctx, tx, documentId, latest); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return revision, nil
+}
+
+const selectDocumentColumns = `
+	SELECT id, project_id, name, type, url, file_name, size, mime_type, sha256, storage_key, uploaded_by, upload_date,
+		current_revision_id, revision, status
+	FROM documents`
+
+const selectRevisionColumns = `
+	SELECT id, document_id, number, label, file_name, size, mime_type, sha256, storage_key, notes, status, superseded_by,
+		uploaded_by, upload_date, archived_by, archived_at
+	FROM document_revisions`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanDocument(row rowScanner) (*models.Document, error) {
+	var document models.Document
+	err := row.Scan(&document.ID, &document.ProjectID, &document.Name, &document.Type, &document.URL, &document.FileName, &document.Size,
+		&document.MimeType, &document.SHA256, &document.StorageKey, &document.UploadedBy, &document.UploadDate,
+		&document.CurrentRevisionID, &document.Revision, &document.Status)
+	if err != nil {
+		return nil, err
+	}
+	return &document, nil
+}
+
+func scanRevision(row rowScanner) (*models.DocumentRevision, error) {
+	var revision models.DocumentRevision
+	err := row.Scan(&revision.ID, &revision.DocumentID, &revision.Number, &revision.Label, &revision.FileName, &revision.Size,
+		&revision.MimeType, &revision.SHA256, &revision.StorageKey, &revision.Notes, &revision.Status, &revision.SupersededBy,
+		&revision.UploadedBy, &revision.UploadDate, &revision.ArchivedBy, &revision.ArchivedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &revision, nil
+}
+
+func insertRevision(ctx context.Context, tx *sql.Tx, revision *models.DocumentRevision) error {
+	query := `
+		INSERT INTO document_revisions (document_id, number, label, file_name, size, mime_type, sha256, storage_key, notes, status,
+			superseded_by, uploaded_by, upload_date, archived_by, archived_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
+		RETURNING id
+	`
+
+	return tx.QueryRowContext(ctx, query, revision.DocumentID, revision.Number, revision.Label, revision.FileName, revision.Size,
+		revision.MimeType, revision.SHA256, revision.StorageKey, revision.Notes, revision.Status, revision.SupersededBy,
+		revision.UploadedBy, revision.UploadDate, revision.ArchivedBy, revision.ArchivedAt).Scan(&revision.ID)
+}
+
+// setCurrentRevision points the document at the revision and mirrors its file fields onto the document
+func setCurrentRevision(ctx context.Context, tx *sql.Tx, documentId int64, revision *models.DocumentRevision) error {
+	query := `
+		UPDATE documents
+		SET current_revision_id = $1, revision = $2, file_name = $3, size = $4, mime_type = $5, sha256 = $6, storage_key = $7,
+			uploaded_by = $8, upload_date = $9, status = $10
+		WHERE id = $11
+	`
+
+	_, err := tx.ExecContext(ctx, query, revision.ID, revision.Label, revision.FileName, revision.Size, revision.MimeType, revision.SHA256,
+		revision.StorageKey, revision.UploadedBy, revision.UploadDate, DocumentStatusActive, documentId)
+	return err
+}
+
+func (p *projectRepository) SetDocumentURL(ctx context.Context, documentId int64, url string) error {
+	query := "UPDATE documents SET url = $1 WHERE id = $2"
+
+	_, err := p.db.ExecContext(ctx, query, url, documentId)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
+func (p *projectRepository) FindDocumentByID(ctx context.Context, documentId int64) (*models.Document, error) {
+	document, err := scanDocument(p.db.QueryRowContext(ctx, selectDocumentColumns+" WHERE id = $1", documentId))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("document not found")
+		}
+		return nil, err
+	}
+	return document, nil
+}
+
+func (p *projectRepository) IsProjectMember(ctx context.Context, projectId int64, userId int64) (bool, error) {
+	query := `
+		SELECT EXISTS (SELECT 1 FROM project_team WHERE project_id = $1 AND user_id = $2)
+			OR EXISTS (SELECT 1 FROM projects WHERE id = $1 AND manager_id = $2)
+	`
+
+	var member bool
+	if err := p.db.QueryRowContext(ctx, query, projectId, userId).Scan(&member); err != nil {
+		return false, err
+	}
+	return member, nil
+}
+
 func (p *projectRepository) TrackProjectExpenses(ctx context.Context, expense *models.Expense) error {
 	query := "INSERT INTO expenses (project_id, amount, description, date) VALUES ($1, $2, $3, $4)"
 
@@ -149,10 +447,10 @@ func (p *projectRepository) UpdateProjectTeamRole(ctx context.Context, projectId
 	return nil
 }
 
-func (p *projectRepository) FindProjectsByStatus(ctx context.Context, status string) ([]models.Project, error) {
-	query := "SELECT id, name, description, budget, status FROM projects WHERE status = $1"
+func (p *projectRepository) FindProjectsByStatus(ctx context.Context, status string, projectIDs []int64) ([]models.Project, error) {
+	query := "SELECT id, name, description, budget, status FROM projects WHERE status = $1 AND ($2::bigint[] IS NULL OR id = ANY($2))"
 
-	rows, err := p.db.QueryContext(ctx, query, status)
+	rows, err := p.db.QueryContext(ctx, query, status, pq.Array(projectIDs))
 	if err != nil {
 		return nil, err
 	}
@@ -176,10 +474,10 @@ func (p *projectRepository) FindProjectsByStatus(ctx context.Context, status str
 
 
 // FindAll retrieves all projects from the database
-func (p *projectRepository) FindAll(ctx context.Context) ([]models.Project, error) {
-	query := "SELECT id, name, description, budget, status FROM projects"
+func (p *projectRepository) FindAll(ctx context.Context, projectIDs []int64) ([]models.Project, error) {
+	query := "SELECT id, name, description, budget, status FROM projects WHERE $1::bigint[] IS NULL OR id = ANY($1)"
 
-	rows, err := p.db.QueryContext(ctx, query)
+	rows, err := p.db.QueryContext(ctx, query, pq.Array(projectIDs))
 	if err != nil {
 		return nil, err
 	}
@@ -2,18 +2,56 @@ package project
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
 	models "github.com/BerkatPS/internal"
+	"github.com/BerkatPS/internal/events"
+	"github.com/BerkatPS/pkg/principal"
+	"github.com/BerkatPS/pkg/rbac"
+	"github.com/BerkatPS/pkg/storage"
+	"github.com/BerkatPS/pkg/utils"
+)
+
+// ErrNotProjectMember is returned when the caller neither manages the project nor is on its team
+var ErrNotProjectMember = errors.New("user is not a member of this project")
+
+// ErrRoleNotAssignable is returned when a team role is admin or grants more than the caller has on the project
+var ErrRoleNotAssignable = errors.New("not allowed to assign this team role")
+
+// ErrDuplicateRevisionLabel is returned when a document already has a revision with the requested label
+var ErrDuplicateRevisionLabel = errors.New("document already has a revision with this label")
+
+// ErrRevisionArchived is returned when archiving a revision that is already archived
+var ErrRevisionArchived = errors.New("revision is already archived")
+
+const (
+	DocumentStatusActive   = "ACTIVE"
+	DocumentStatusArchived = "ARCHIVED"
+
+	RevisionStatusCurrent    = "CURRENT"
+	RevisionStatusSuperseded = "SUPERSEDED"
+	RevisionStatusArchived   = "ARCHIVED"
 )
 
 type ProjectService interface {
-	FindAll(ctx context.Context) ([]models.Project, error)
+	// FindAll lists the projects among projectIDs, or every project when projectIDs is nil
+	FindAll(ctx context.Context, projectIDs []int64) ([]models.Project, error)
 	FindProjectByID(ctx context.Context, id int64) (*models.Project, error)
 	CreateProject(ctx context.Context, project *models.Project) error
 	UpdateProject(ctx context.Context, project *models.Project) error
 	DeleteProject(ctx context.Context, id int64) error
 	// FindProjectsByStatus allows filtering projects by their status (e.g., ongoing, completed, delayed)
-	FindProjectsByStatus(ctx context.Context, status string) ([]models.Project, error)
+	FindProjectsByStatus(ctx context.Context, status string, projectIDs []int64) ([]models.Project, error)
 	// UpdateProjectStatus updates the status of a project, which is crucial for real-time monitoring
 	UpdateProjectStatus(ctx context.Context, id int64, status string) error
 	// AddTeamMemberToProject adds a new team member to an existing project to improve team collaboration
@@ -28,19 +66,32 @@ type ProjectService interface {
 	FindExpensesByProject(ctx context.Context, projectId int64) ([]models.Expense, error)
 	// UpdateProjectBudget allows updating the overall budget for a project, useful for real-time adjustments
 	UpdateProjectBudget(ctx context.Context, projectId int64, newBudget float64) error
-	// DeleteProjectDocument deletes a document from a project's records
-	DeleteProjectDocument(ctx context.Context, documentId int64) error
-	// UploadProjectDocument uploads a document related to a project, storing it for easy access
-	UploadProjectDocument(ctx context.Context, projectId int64, document *models.Document) error
+	// DeleteProjectDocument archives a revision of a project document (the current one when revisionId is 0); history is never deleted
+	DeleteProjectDocument(ctx context.Context, projectId int64, documentId int64, revisionId int64, userId int64) (*models.DocumentRevision, error)
+	// UploadProjectDocument streams a document's content into the blob store and records it as the document's first revision
+	UploadProjectDocument(ctx context.Context, projectId int64, document *models.Document, revision *models.DocumentRevision, content io.Reader) error
+	// UploadDocumentRevision stores a new revision that supersedes the document's current one
+	UploadDocumentRevision(ctx context.Context, documentId int64, revision *models.DocumentRevision, content io.Reader) error
+	// FindDocumentRevisions returns the document with its revisions in order
+	FindDocumentRevisions(ctx context.Context, documentId int64, userId int64) (*models.Document, error)
+	FindDocumentRevision(ctx context.Context, documentId int64, revisionId int64, userId int64) (*models.DocumentRevision, error)
+	// DiffDocumentRevisions compares the metadata of two revisions of the same document
+	DiffDocumentRevisions(ctx context.Context, documentId int64, fromId int64, toId int64, userId int64) (*models.DocumentRevisionDiff, error)
+	// OpenProjectDocument returns a document and the content of its current revision for a member of the document's project
+	OpenProjectDocument(ctx context.Context, documentId int64, userId int64) (*models.Document, io.ReadCloser, error)
+	// OpenDocumentRevision returns a revision and its content, including superseded and archived ones
+	OpenDocumentRevision(ctx context.Context, documentId int64, revisionId int64, userId int64) (*models.DocumentRevision, io.ReadCloser, error)
 }
 
 type projectService struct {
 	ProjectRepo ProjectRepository
+	Events      events.Publisher
+	Blobs       storage.BlobStore
 }
 
-// NewProjectService creates a new instance of ProjectService
-func NewProjectService(ProjectRepo ProjectRepository) ProjectService {
-	return &projectService{ProjectRepo}
+// NewProjectService creates a new instance of ProjectService; publisher may be nil to disable event streaming
+func NewProjectService(ProjectRepo ProjectRepository, publisher events.Publisher, blobs storage.BlobStore) ProjectService {
+	return &projectService{ProjectRepo, publisher, blobs}
 }
 
 func (p *projectService) UpdateProjectBudget(ctx context.Context, projectId int64, newBudget float64) error {
@@ -59,34 +110,345 @@ func (p *projectService) UpdateProjectBudget(ctx context.Context, projectId int6
 	return nil
 }
 
-func (p *projectService) DeleteProjectDocument(ctx context.Context, documentId int64) error {
+func (p *projectService) DeleteProjectDocument(ctx context.Context, projectId int64, documentId int64, revisionId int64, userId int64) (*models.DocumentRevision, error) {
 	if documentId <= 0 {
-		return errors.New("invalid document ID")
+		return nil, errors.New("invalid document ID")
 	}
 
-	if err := p.ProjectRepo.DeleteProjectDocument(ctx, documentId); err != nil {
-		return err
+	document, err := p.loadMemberDocument(ctx, documentId, userId)
+	if err != nil {
+		return nil, err
 	}
 
-	return nil
+	if document.ProjectID != projectId {
+		return nil, errors.New("document not found")
+	}
+
+	revision, err := p.ProjectRepo.ArchiveDocumentRevision(ctx, documentId, revisionId, userId, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	return revision, nil
 }
 
-func (p *projectService) UploadProjectDocument(ctx context.Context, projectId int64, document *models.Document) error {
+func (p *projectService) UploadProjectDocument(ctx context.Context, projectId int64, document *models.Document, revision *models.DocumentRevision, content io.Reader) error {
 	if projectId <= 0 {
 		return errors.New("invalid project ID")
 	}
 
-	if document.Name == "" || document.Type == "" || document.URL == "" {
+	if revision.FileName == "" {
+		return errors.New("missing document file")
+	}
+
+	if document.Name == "" {
+		document.Name = revision.FileName
+	}
+
+	if document.Type == "" {
 		return errors.New("missing required document fields")
 	}
 
-	if err := p.ProjectRepo.UploadProjectDocument(ctx, projectId, document); err != nil {
+	if err := p.requireMember(ctx, projectId, revision.UploadedBy); err != nil {
+		return err
+	}
+
+	if err := p.storeRevisionContent(ctx, projectId, revision, content); err != nil {
+		return err
+	}
+
+	if revision.Label == "" {
+		revision.Label = RevisionLabel(1)
+	}
+	revision.Status = RevisionStatusCurrent
+
+	document.ProjectID = projectId
+	document.FileName = revision.FileName
+	document.Size = revision.Size
+	document.SHA256 = revision.SHA256
+	document.MimeType = revision.MimeType
+	document.StorageKey = revision.StorageKey
+	document.UploadedBy = revision.UploadedBy
+	document.UploadDate = revision.UploadDate
+	document.Status = DocumentStatusActive
+
+	if err := p.ProjectRepo.UploadProjectDocument(ctx, projectId, document, revision); err != nil {
+		p.deleteBlob(ctx, revision.StorageKey)
+		return err
+	}
+
+	document.URL = fmt.Sprintf("/documents/%d/download", document.ID)
+	if err := p.ProjectRepo.SetDocumentURL(ctx, document.ID, document.URL); err != nil {
+		return err
+	}
+
+	document.Revisions = []models.DocumentRevision{*revision}
+	return nil
+}
+
+func (p *projectService) UploadDocumentRevision(ctx context.Context, documentId int64, revision *models.DocumentRevision, content io.Reader) error {
+	if documentId <= 0 {
+		return errors.New("invalid document ID")
+	}
+
+	if revision.FileName == "" {
+		return errors.New("missing document file")
+	}
+
+	document, err := p.loadMemberDocument(ctx, documentId, revision.UploadedBy)
+	if err != nil {
+		return err
+	}
+
+	if err := p.storeRevisionContent(ctx, document.ProjectID, revision, content); err != nil {
+		return err
+	}
+	revision.Status = RevisionStatusCurrent
+
+	if err := p.ProjectRepo.CreateDocumentRevision(ctx, documentId, revision); err != nil {
+		p.deleteBlob(ctx, revision.StorageKey)
 		return err
 	}
 
 	return nil
 }
 
+func (p *projectService) FindDocumentRevisions(ctx context.Context, documentId int64, userId int64) (*models.Document, error) {
+	if documentId <= 0 {
+		return nil, errors.New("invalid document ID")
+	}
+
+	document, err := p.loadMemberDocument(ctx, documentId, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	revisions, err := p.ProjectRepo.FindDocumentRevisions(ctx, documentId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve revisions: %v", err)
+	}
+	document.Revisions = revisions
+
+	return document, nil
+}
+
+func (p *projectService) FindDocumentRevision(ctx context.Context, documentId int64, revisionId int64, userId int64) (*models.DocumentRevision, error) {
+	if documentId <= 0 || revisionId <= 0 {
+		return nil, errors.New("invalid document or revision ID")
+	}
+
+	if _, err := p.loadMemberDocument(ctx, documentId, userId); err != nil {
+		return nil, err
+	}
+
+	revision, err := p.ProjectRepo.FindDocumentRevisionByID(ctx, revisionId)
+	if err != nil {
+		return nil, err
+	}
+
+	if revision.DocumentID != documentId {
+		return nil, errors.New("revision not found")
+	}
+
+	return revision, nil
+}
+
+func (p *projectService) DiffDocumentRevisions(ctx context.Context, documentId int64, fromId int64, toId int64, userId int64) (*models.DocumentRevisionDiff, error) {
+	from, err := p.FindDocumentRevision(ctx, documentId, fromId, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	to, err := p.FindDocumentRevision(ctx, documentId, toId, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := &models.DocumentRevisionDiff{
+		DocumentID:     documentId,
+		From:           *from,
+		To:             *to,
+		ContentChanged: from.SHA256 != to.SHA256,
+		Changes:        []models.RevisionFieldChange{},
+	}
+
+	fields := []struct {
+		name     string
+		from, to interface{}
+	}{
+		{"label", from.Label, to.Label},
+		{"file_name", from.FileName, to.FileName},
+		{"size", from.Size, to.Size},
+		{"mime_type", from.MimeType, to.MimeType},
+		{"sha256", from.SHA256, to.SHA256},
+		{"notes", from.Notes, to.Notes},
+		{"status", from.Status, to.Status},
+		{"uploaded_by", from.UploadedBy, to.UploadedBy},
+		{"upload_date", from.UploadDate, to.UploadDate},
+	}
+	for _, field := range fields {
+		if field.from != field.to {
+			diff.Changes = append(diff.Changes, models.RevisionFieldChange{Field: field.name, From: field.from, To: field.to})
+		}
+	}
+
+	return diff, nil
+}
+
+func (p *projectService) OpenProjectDocument(ctx context.Context, documentId int64, userId int64) (*models.Document, io.ReadCloser, error) {
+	if documentId <= 0 {
+		return nil, nil, errors.New("invalid document ID")
+	}
+
+	document, err := p.loadMemberDocument(ctx, documentId, userId)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if document.StorageKey == "" {
+		return nil, nil, errors.New("document has no stored content")
+	}
+
+	content, err := p.Blobs.Open(ctx, document.StorageKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open document: %v", err)
+	}
+
+	return document, content, nil
+}
+
+func (p *projectService) OpenDocumentRevision(ctx context.Context, documentId int64, revisionId int64, userId int64) (*models.DocumentRevision, io.ReadCloser, error) {
+	revision, err := p.FindDocumentRevision(ctx, documentId, revisionId, userId)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	content, err := p.Blobs.Open(ctx, revision.StorageKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open revision: %v", err)
+	}
+
+	return revision, content, nil
+}
+
+// RevisionLabel returns the default label of the n-th revision: A, B, ... Z, AA, AB, ...
+func RevisionLabel(n int) string {
+	label := ""
+	for n > 0 {
+		n--
+		label = string(rune('A'+n%26)) + label
+		n /= 26
+	}
+	return label
+}
+
+// loadMemberDocument fetches a document and checks the user belongs to its project
+func (p *projectService) loadMemberDocument(ctx context.Context, documentId int64, userId int64) (*models.Document, error) {
+	document, err := p.ProjectRepo.FindDocumentByID(ctx, documentId)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := p.requireMember(ctx, document.ProjectID, userId); err != nil {
+		return nil, err
+	}
+
+	return document, nil
+}
+
+// storeRevisionContent streams content into the blob store and fills in the revision's storage key, size, SHA-256 and MIME type
+func (p *projectService) storeRevisionContent(ctx context.Context, projectId int64, revision *models.DocumentRevision, content io.Reader) error {
+	token, err := utils.GenerateSecureToken(16)
+	if err != nil {
+		return fmt.Errorf("failed to generate storage key: %v", err)
+	}
+	key := fmt.Sprintf("projects/%d/documents/%s%s", projectId, token, strings.ToLower(filepath.Ext(revision.FileName)))
+
+	// Sniff the MIME type from the first bytes while the content streams through the hash into the store
+	hash := sha256.New()
+	sniffer := &sniffWriter{}
+	size, err := p.Blobs.Put(ctx, key, io.TeeReader(content, io.MultiWriter(hash, sniffer)))
+	if err != nil {
+		return fmt.Errorf("failed to store document: %w", err)
+	}
+
+	if size == 0 {
+		p.deleteBlob(ctx, key)
+		return errors.New("document file is empty")
+	}
+
+	revision.StorageKey = key
+	revision.Size = size
+	revision.SHA256 = hex.EncodeToString(hash.Sum(nil))
+	revision.MimeType = detectMimeType(sniffer.buf, revision.FileName)
+	revision.UploadDate = time.Now()
+
+	return nil
+}
+
+// requireMember checks the caller may work on the project's documents. With a principal in ctx that is an admin,
+// which includes organization API keys, or a member as the principal's memberships say; otherwise the user is
+// looked up in the team.
+func (p *projectService) requireMember(ctx context.Context, projectId int64, userId int64) error {
+	if userId <= 0 {
+		return errors.New("invalid user ID")
+	}
+
+	if caller, ok := principal.FromContext(ctx); ok {
+		if !caller.IsAdmin() && !caller.IsMember(projectId) {
+			return ErrNotProjectMember
+		}
+		return nil
+	}
+
+	member, err := p.ProjectRepo.IsProjectMember(ctx, projectId, userId)
+	if err != nil {
+		return fmt.Errorf("failed to check project membership: %v", err)
+	}
+
+	if !member {
+		return ErrNotProjectMember
+	}
+	return nil
+}
+
+// deleteBlob removes content that never got a documents row; failures only leave an orphaned file
+func (p *projectService) deleteBlob(ctx context.Context, key string) {
+	if err := p.Blobs.Delete(ctx, key); err != nil {
+		log.Printf("failed to remove orphaned blob %s: %v", key, err)
+	}
+}
+
+// sniffWriter keeps the first 512 bytes written to it, which is all http.DetectContentType looks at
+type sniffWriter struct {
+	buf []byte
+}
+
+func (s *sniffWriter) Write(b []byte) (int, error) {
+	if remaining := 512 - len(s.buf); remaining > 0 {
+		if len(b) < remaining {
+			remaining = len(b)
+		}
+		s.buf = append(s.buf, b[:remaining]...)
+	}
+	return len(b), nil
+}
+
+// detectMimeType trusts the content first and falls back to the file extension when sniffing is inconclusive,
+// e.g. for DWG drawings or Office documents that sniff as generic binary or zip
+func detectMimeType(head []byte, fileName string) string {
+	detected := http.DetectContentType(head)
+	switch {
+	case strings.HasPrefix(detected, "application/octet-stream"),
+		strings.HasPrefix(detected, "application/zip"),
+		strings.HasPrefix(detected, "text/plain"):
+		if byExtension := mime.TypeByExtension(strings.ToLower(filepath.Ext(fileName))); byExtension != "" {
+			return byExtension
+		}
+	}
+	return detected
+}
+
 func (p *projectService) TrackProjectExpenses(ctx context.Context, expense *models.Expense) error {
 	if expense.ProjectID <= 0 {
 		return errors.New("invalid project ID")
@@ -104,6 +466,10 @@ func (p *projectService) TrackProjectExpenses(ctx context.Context, expense *mode
 		return err
 	}
 
+	if p.Events != nil {
+		p.Events.Publish(expense.ProjectID, events.EventExpenseCreated, expense)
+	}
+
 	return nil
 }
 
@@ -182,6 +548,28 @@ func (p *projectService) UpdateProjectTeamRole(ctx context.Context, projectId in
 		return errors.New("missing required project fields")
 	}
 
+	// The team role decides what the member may do on the project, so it must be one the policy knows
+	role = rbac.NormalizeRole(role)
+	if !rbac.IsRole(role) {
+		return fmt.Errorf("unknown role %q", role)
+	}
+
+	// Admin passes every check, so as a team role it would hand out rights no project role has
+	caller, err := principal.Require(ctx)
+	if err != nil {
+		return err
+	}
+	assignerRole := rbac.RoleAdmin
+	if !caller.IsAdmin() {
+		var member bool
+		if assignerRole, member = caller.ProjectRole(projectId); !member {
+			return ErrNotProjectMember
+		}
+	}
+	if !rbac.CanAssignTeamRole(assignerRole, role) {
+		return fmt.Errorf("%w: %s", ErrRoleNotAssignable, role)
+	}
+
 	if err := p.ProjectRepo.UpdateProjectTeamRole(ctx, projectId, userId, role); err != nil {
 		return err
 	}
@@ -189,12 +577,12 @@ func (p *projectService) UpdateProjectTeamRole(ctx context.Context, projectId in
 	return nil
 }
 
-func (p *projectService) FindProjectsByStatus(ctx context.Context, status string) ([]models.Project, error) {
+func (p *projectService) FindProjectsByStatus(ctx context.Context, status string, projectIDs []int64) ([]models.Project, error) {
 	if status == "" {
 		return nil, errors.New("missing required project fields")
 	}
 
-	projects, err := p.ProjectRepo.FindProjectsByStatus(ctx, status)
+	projects, err := p.ProjectRepo.FindProjectsByStatus(ctx, status, projectIDs)
 	if err != nil {
 		return nil, err
 	}
@@ -205,8 +593,8 @@ func (p *projectService) FindProjectsByStatus(ctx context.Context, status string
 
 
 // FindAll retrieves all projects from the repository
-func (p *projectService) FindAll(ctx context.Context) ([]models.Project, error) {
-	projects, err := p.ProjectRepo.FindAll(ctx)
+func (p *projectService) FindAll(ctx context.Context, projectIDs []int64) ([]models.Project, error) {
+	projects, err := p.ProjectRepo.FindAll(ctx, projectIDs)
 	if err != nil {
 		return nil, err
 	}
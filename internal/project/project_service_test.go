@@ -0,0 +1,124 @@
+package project
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/BerkatPS/pkg/principal"
+	"github.com/BerkatPS/pkg/rbac"
+)
+
+// teamRoleRepo records team role changes. The embedded ProjectRepository is nil, so any other method the
+// service calls panics and fails the test.
+type teamRoleRepo struct {
+	ProjectRepository
+
+	role string
+}
+
+func (f *teamRoleRepo) UpdateProjectTeamRole(ctx context.Context, projectId int64, userId int64, role string) error {
+	f.role = role
+	return nil
+}
+
+func TestUpdateProjectTeamRole(t *testing.T) {
+	admin := &principal.Principal{UserID: 1, Role: rbac.RoleAdmin}
+	manager := &principal.Principal{UserID: 2, Role: rbac.RoleWorker, Memberships: []principal.Membership{
+		{ProjectID: 1, Role: rbac.RoleProjectManager},
+		{ProjectID: 2, Role: rbac.RoleSiteEngineer},
+	}}
+
+	tests := []struct {
+		name     string
+		caller   *principal.Principal
+		project  int64
+		role     string
+		wantErr  error
+		wantRole string
+	}{
+		{name: "manager assigns engineer", caller: manager, project: 1, role: "Site Engineer", wantRole: rbac.RoleSiteEngineer},
+		{name: "manager assigns manager", caller: manager, project: 1, role: rbac.RoleProjectManager, wantRole: rbac.RoleProjectManager},
+		{name: "manager cannot assign admin", caller: manager, project: 1, role: "admin", wantErr: ErrRoleNotAssignable},
+		{name: "engineer cannot promote to manager", caller: manager, project: 2, role: rbac.RoleProjectManager, wantErr: ErrRoleNotAssignable},
+		{name: "non-member", caller: manager, project: 3, role: rbac.RoleWorker, wantErr: ErrNotProjectMember},
+		{name: "admin assigns manager", caller: admin, project: 3, role: rbac.RoleProjectManager, wantRole: rbac.RoleProjectManager},
+		{name: "admin cannot assign admin", caller: admin, project: 3, role: rbac.RoleAdmin, wantErr: ErrRoleNotAssignable},
+		{name: "no principal", project: 1, role: rbac.RoleWorker, wantErr: principal.ErrUnauthenticated},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &teamRoleRepo{}
+			service := &projectService{ProjectRepo: repo}
+			ctx := context.Background()
+			if tt.caller != nil {
+				ctx = principal.NewContext(ctx, tt.caller)
+			}
+
+			err := service.UpdateProjectTeamRole(ctx, tt.project, 5, tt.role)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("error = %v, want %v", err, tt.wantErr)
+				}
+				if repo.role != "" {
+					t.Errorf("role %q was stored", repo.role)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if repo.role != tt.wantRole {
+				t.Errorf("stored role = %q, want %q", repo.role, tt.wantRole)
+			}
+		})
+	}
+}
+
+// memberRepo answers team lookups from a set of project IDs
+type memberRepo struct {
+	ProjectRepository
+
+	projects map[int64]bool
+}
+
+func (f *memberRepo) IsProjectMember(ctx context.Context, projectId int64, userId int64) (bool, error) {
+	return f.projects[projectId], nil
+}
+
+func TestRequireMember(t *testing.T) {
+	admin := &principal.Principal{UserID: 1, Role: rbac.RoleAdmin}
+	organizationKey := &principal.Principal{UserID: 1, Role: rbac.RoleAdmin, APIKeyID: 4, Scopes: []string{rbac.ScopeProjectsRead}}
+	engineer := &principal.Principal{UserID: 2, Role: rbac.RoleSiteEngineer, Memberships: []principal.Membership{
+		{ProjectID: 1, Role: rbac.RoleSiteEngineer},
+	}}
+
+	tests := []struct {
+		name    string
+		caller  *principal.Principal
+		project int64
+		wantErr error
+	}{
+		{name: "admin off the team", caller: admin, project: 2},
+		{name: "organization key", caller: organizationKey, project: 2},
+		{name: "member", caller: engineer, project: 1},
+		{name: "non-member", caller: engineer, project: 2, wantErr: ErrNotProjectMember},
+		{name: "no principal, on the team", project: 1},
+		{name: "no principal, off the team", project: 2, wantErr: ErrNotProjectMember},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := &projectService{ProjectRepo: &memberRepo{projects: map[int64]bool{1: true}}}
+			ctx := context.Background()
+			if tt.caller != nil {
+				ctx = principal.NewContext(ctx, tt.caller)
+			}
+
+			if err := service.requireMember(ctx, tt.project, 2); !errors.Is(err, tt.wantErr) {
+				t.Errorf("error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -1,21 +1,31 @@
 package project
 
-import "net/http"
+import (
+	"net/http"
 
-func RegisterRoutes(router *http.ServeMux, handler *ProjectController) {
-	router.HandleFunc("GET /projects", handler.FindAll)
-	router.HandleFunc("GET /projects/{id}", handler.FindProjectByID)
-	router.HandleFunc("POST /projects/add", handler.CreateProject)
-	router.HandleFunc("PUT /projects/{id}", handler.UpdateProject)
-	router.HandleFunc("DELETE /projects/{id}", handler.DeleteProject)
-    router.HandleFunc("GET /projects/status-code/{status}", handler.FindProjectsByStatus) 
-	router.HandleFunc("PUT /projects/{id}/status/{status}", handler.UpdateProjectStatus)
-	router.HandleFunc("POST /projects/{id}/team/{user_id}", handler.AddTeamMemberToProject)
-	router.HandleFunc("DELETE /projects/{id}/team/{user_id}", handler.RemoveTeamMemberFromProject)
-	router.HandleFunc("PUT /projects/{id}/team/{user_id}/role/{role}", handler.UpdateProjectTeamRole)
-	router.HandleFunc("POST /projects/{id}/expenses", handler.TrackProjectExpenses)
-	router.HandleFunc("GET /projects/{id}/expenses", handler.FindExpensesByProject)
-	router.HandleFunc("PUT /projects/{id}/budget/{new_budget}", handler.UpdateProjectBudget)
-	router.HandleFunc("DELETE /projects/{id}/documents/{document_id}", handler.DeleteProjectDocument)
-	router.HandleFunc("POST /projects/{id}/documents", handler.UploadProjectDocument)
+	"github.com/BerkatPS/pkg/rbac"
+)
+
+func RegisterRoutes(router *http.ServeMux, handler *ProjectController, authz *rbac.Enforcer) {
+	router.HandleFunc("GET /projects", authz.Require(rbac.ProjectRead, handler.FindAll))
+	router.HandleFunc("GET /projects/{id}", authz.RequireProject(rbac.ProjectRead, rbac.PathProject("id"), handler.FindProjectByID))
+	router.HandleFunc("POST /projects/add", authz.Require(rbac.ProjectCreate, handler.CreateProject))
+	router.HandleFunc("PUT /projects/{id}", authz.RequireProject(rbac.ProjectUpdate, rbac.PathProject("id"), handler.UpdateProject))
+	router.HandleFunc("DELETE /projects/{id}", authz.RequireProject(rbac.ProjectDelete, rbac.PathProject("id"), handler.DeleteProject))
+    router.HandleFunc("GET /projects/status-code/{status}", authz.Require(rbac.ProjectRead, handler.FindProjectsByStatus)) 
+	router.HandleFunc("PUT /projects/{id}/status/{status}", authz.RequireProject(rbac.ProjectUpdate, rbac.PathProject("id"), handler.UpdateProjectStatus))
+	router.HandleFunc("POST /projects/{id}/team/{user_id}", authz.RequireProject(rbac.ProjectManageTeam, rbac.PathProject("id"), handler.AddTeamMemberToProject))
+	router.HandleFunc("DELETE /projects/{id}/team/{user_id}", authz.RequireProject(rbac.ProjectManageTeam, rbac.PathProject("id"), handler.RemoveTeamMemberFromProject))
+	router.HandleFunc("PUT /projects/{id}/team/{user_id}/role/{role}", authz.RequireProject(rbac.ProjectManageTeam, rbac.PathProject("id"), handler.UpdateProjectTeamRole))
+	router.HandleFunc("POST /projects/{id}/expenses", authz.RequireProject(rbac.ExpenseCreate, rbac.PathProject("id"), handler.TrackProjectExpenses))
+	router.HandleFunc("GET /projects/{id}/expenses", authz.RequireProject(rbac.ExpenseRead, rbac.PathProject("id"), handler.FindExpensesByProject))
+	router.HandleFunc("PUT /projects/{id}/budget/{new_budget}", authz.RequireProject(rbac.ProjectUpdateBudget, rbac.PathProject("id"), handler.UpdateProjectBudget))
+	router.HandleFunc("DELETE /projects/{id}/documents/{document_id}", authz.RequireProject(rbac.ProjectManageDocuments, rbac.PathProject("id"), handler.DeleteProjectDocument))
+	router.HandleFunc("POST /projects/{id}/documents", authz.RequireProject(rbac.ProjectManageDocuments, rbac.PathProject("id"), handler.UploadProjectDocument))
+	router.HandleFunc("GET /documents/{id}/download", authz.RequireProject(rbac.ProjectRead, authz.PathResource(rbac.ResourceDocument, "id"), handler.DownloadDocument))
+	router.HandleFunc("POST /documents/{id}/revisions", authz.RequireProject(rbac.ProjectManageDocuments, authz.PathResource(rbac.ResourceDocument, "id"), handler.UploadDocumentRevision))
+	router.HandleFunc("GET /documents/{id}/revisions", authz.RequireProject(rbac.ProjectRead, authz.PathResource(rbac.ResourceDocument, "id"), handler.FindDocumentRevisions))
+	router.HandleFunc("GET /documents/{id}/revisions/diff", authz.RequireProject(rbac.ProjectRead, authz.PathResource(rbac.ResourceDocument, "id"), handler.DiffDocumentRevisions))
+	router.HandleFunc("GET /documents/{id}/revisions/{revision_id}", authz.RequireProject(rbac.ProjectRead, authz.PathResource(rbac.ResourceDocument, "id"), handler.FindDocumentRevision))
+	router.HandleFunc("GET /documents/{id}/revisions/{revision_id}/download", authz.RequireProject(rbac.ProjectRead, authz.PathResource(rbac.ResourceDocument, "id"), handler.DownloadDocumentRevision))
 }
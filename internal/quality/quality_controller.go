@@ -2,9 +2,16 @@ package quality
 
 import (
 	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
 	models "github.com/BerkatPS/internal"
+	"github.com/BerkatPS/internal/report"
+	"github.com/BerkatPS/pkg/principal"
 	"github.com/BerkatPS/pkg/utils"
-	"net/http"
 )
 
 type QualityController struct {
@@ -44,39 +51,41 @@ func (q *QualityController) FindQualityByTaskID(w http.ResponseWriter, r *http.R
 	})
 }
 
-// func (q *QualityController) FindQualityByDateRange(w http.ResponseWriter, r *http.Request) {
+// FindQualityByDateRange lists the checks dated between ?start_date= and ?end_date= (YYYY-MM-DD, both inclusive)
+func (q *QualityController) FindQualityByDateRange(w http.ResponseWriter, r *http.Request) {
 
-// 	ctx := r.Context()
+	ctx := r.Context()
 
-// 	var dateRange models.QualityCheck
-// 	if err := json.NewDecoder(r.Body).Decode(&dateRange); err != nil {
-// 		utils.JSONErrorResponse(w, http.StatusBadRequest, map[string]interface{}{
-// 			"status":  "error",
-// 			"message": "Invalid request body: " + err.Error(),
-// 		})
-// 		return
-// 	}
-// 	qualities, err := q.QualityService.FindQualityByDateRange(ctx, dateRange)
-// 	if err != nil {
-// 		utils.JSONErrorResponse(w, http.StatusInternalServerError, map[string]interface{}{
-// 			"status":  "error",
-// 			"message": "Failed to retrieve quality: " + err.Error(),
-// 		})
-// 		return
-// 	}
+	startDate, endDate, err := parseDateRange(r.URL.Query().Get("start_date"), r.URL.Query().Get("end_date"))
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": "Invalid date range: " + err.Error(),
+		})
+		return
+	}
 
-// 	utils.JSONResponse(w, http.StatusOK, map[string]interface{}{
-// 		"status":  "success",
-// 		"message": "Quality found successfully",
-// 		"data":    qualities,
-// 	})
-// }
+	qualities, err := q.QualityService.FindQualityByDateRange(ctx, startDate, endDate.AddDate(0, 0, 1), principal.VisibleProjectIDs(ctx))
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusInternalServerError, map[string]interface{}{
+			"status":  "error",
+			"message": "Failed to retrieve quality: " + err.Error(),
+		})
+		return
+	}
+
+	utils.JSONResponse(w, http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "Quality found successfully",
+		"data":    qualities,
+	})
+}
 
 func (q *QualityController) FindQualityIssues(w http.ResponseWriter, r *http.Request) {
 
 	ctx := r.Context()
 
-	qualities, err := q.QualityService.FindQualityIssues(ctx)
+	qualities, err := q.QualityService.FindQualityIssues(ctx, principal.VisibleProjectIDs(ctx))
 	if err != nil {
 		utils.JSONErrorResponse(w, http.StatusInternalServerError, map[string]interface{}{
 			"status":  "error",
@@ -96,7 +105,7 @@ func (q *QualityController) FindNonCompliantQualityChecks(w http.ResponseWriter,
 
 	ctx := r.Context()
 
-	qualities, err := q.QualityService.FindNonCompliantQualityChecks(ctx)
+	qualities, err := q.QualityService.FindNonCompliantQualityChecks(ctx, principal.VisibleProjectIDs(ctx))
 	if err != nil {
 		utils.JSONErrorResponse(w, http.StatusInternalServerError, map[string]interface{}{
 			"status":  "error",
@@ -125,7 +134,7 @@ func (q *QualityController) FindQualityChecksByInspector(w http.ResponseWriter,
 		return
 	}
 
-	qualities, err := q.QualityService.FindQualityChecksByInspector(ctx, inspectorID)
+	qualities, err := q.QualityService.FindQualityChecksByInspector(ctx, inspectorID, principal.VisibleProjectIDs(ctx))
 	if err != nil {
 		utils.JSONErrorResponse(w, http.StatusInternalServerError, map[string]interface{}{
 			"status":  "error",
@@ -314,3 +323,173 @@ func (q *QualityController) UpdateQuality(w http.ResponseWriter, r *http.Request
 		"data":    quality,
 	})
 }
+
+// GenerateQualityReport snapshots the checks of a project and/or inspector over a date range into a stored report
+func (q *QualityController) GenerateQualityReport(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	userID, err := principal.UserIDFromRequest(r)
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusUnauthorized, map[string]interface{}{
+			"status":  "error",
+			"message": "Unauthorized: " + err.Error(),
+		})
+		return
+	}
+
+	var generateRequest struct {
+		ProjectID   int64   `json:"project_id"`
+		InspectorID int64   `json:"inspector_id"`
+		StartDate   string  `json:"start_date"`
+		EndDate     string  `json:"end_date"`
+		Comments    string  `json:"comments"`
+		DocumentIDs []int64 `json:"document_ids"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&generateRequest); err != nil {
+		utils.JSONErrorResponse(w, http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": "Invalid request body: " + err.Error(),
+		})
+		return
+	}
+
+	startDate, endDate, err := parseDateRange(generateRequest.StartDate, generateRequest.EndDate)
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": "Invalid date range: " + err.Error(),
+		})
+		return
+	}
+
+	qualityReport := &models.QualityReport{
+		ProjectID:     generateRequest.ProjectID,
+		InspectorID:   generateRequest.InspectorID,
+		GeneratedByID: userID,
+		StartDate:     startDate,
+		EndDate:       endDate,
+		Comments:      generateRequest.Comments,
+	}
+	for _, documentID := range generateRequest.DocumentIDs {
+		qualityReport.AttachedDocuments = append(qualityReport.AttachedDocuments, models.Document{ID: documentID})
+	}
+
+	if err := q.QualityService.GenerateQualityReport(ctx, qualityReport); err != nil {
+		utils.JSONErrorResponse(w, http.StatusInternalServerError, map[string]interface{}{
+			"status":  "error",
+			"message": "Failed to generate quality report: " + err.Error(),
+		})
+		return
+	}
+
+	utils.JSONResponse(w, http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "Quality report generated successfully",
+		"data":    qualityReport,
+	})
+}
+
+// FindQualityReportByID returns a quality report as JSON, or as a PDF when the path ends in .pdf
+func (q *QualityController) FindQualityReportByID(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	rawID := r.PathValue("id")
+	asPDF := strings.HasSuffix(rawID, ".pdf")
+
+	reportID, err := strconv.ParseInt(strings.TrimSuffix(rawID, ".pdf"), 10, 64)
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": "Invalid quality report ID: " + err.Error(),
+		})
+		return
+	}
+
+	if asPDF {
+		content, qualityReport, err := q.QualityService.RenderQualityReportPDF(ctx, reportID)
+		if err != nil {
+			utils.JSONErrorResponse(w, http.StatusInternalServerError, map[string]interface{}{
+				"status":  "error",
+				"message": "Failed to render quality report: " + err.Error(),
+			})
+			return
+		}
+
+		report.ServePDF(w, content, fmt.Sprintf("quality-report-%d-%s-%s.pdf", qualityReport.ID,
+			qualityReport.StartDate.Format("2006-01-02"), qualityReport.EndDate.Format("2006-01-02")))
+		return
+	}
+
+	qualityReport, err := q.QualityService.FindQualityReportByID(ctx, reportID)
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusInternalServerError, map[string]interface{}{
+			"status":  "error",
+			"message": "Failed to retrieve quality report: " + err.Error(),
+		})
+		return
+	}
+
+	utils.JSONResponse(w, http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "Quality report found successfully",
+		"data":    qualityReport,
+	})
+}
+
+// FindQualityReports lists quality reports newest first, optionally filtered by ?project_id= and ?inspector_id=
+func (q *QualityController) FindQualityReports(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	var filters [2]int64
+	for i, name := range []string{"project_id", "inspector_id"} {
+		raw := r.URL.Query().Get(name)
+		if raw == "" {
+			continue
+		}
+		value, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			utils.JSONErrorResponse(w, http.StatusBadRequest, map[string]interface{}{
+				"status":  "error",
+				"message": "Invalid " + name + ": " + err.Error(),
+			})
+			return
+		}
+		filters[i] = value
+	}
+
+	reports, err := q.QualityService.FindQualityReports(ctx, filters[0], filters[1])
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusInternalServerError, map[string]interface{}{
+			"status":  "error",
+			"message": "Failed to retrieve quality reports: " + err.Error(),
+		})
+		return
+	}
+
+	utils.JSONResponse(w, http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "Quality reports found successfully",
+		"data":    reports,
+	})
+}
+
+// parseDateRange reads two YYYY-MM-DD dates in local time
+func parseDateRange(start, end string) (time.Time, time.Time, error) {
+	startDate, err := time.ParseInLocation("2006-01-02", start, time.Local)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("start_date must be YYYY-MM-DD")
+	}
+
+	endDate, err := time.ParseInLocation("2006-01-02", end, time.Local)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("end_date must be YYYY-MM-DD")
+	}
+
+	if endDate.Before(startDate) {
+		return time.Time{}, time.Time{}, fmt.Errorf("end_date is before start_date")
+	}
+	return startDate, endDate, nil
+}
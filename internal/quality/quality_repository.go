@@ -7,6 +7,7 @@ import (
 	"time"
 
 	models "github.com/BerkatPS/internal"
+	"github.com/lib/pq"
 )
 
 type QualityRepository interface {
@@ -15,12 +16,25 @@ type QualityRepository interface {
 	UpdateQuality(ctx context.Context, quality *models.QualityCheck) error
 	ShowQualityPerProject(ctx context.Context, projectID int64) ([]models.QualityCheck, error)
 	FindQualityByTaskID(ctx context.Context, taskID int64) ([]models.QualityCheck, error)
-	FindQualityByDateRange(ctx context.Context, startDate, endDate time.Time) ([]models.QualityCheck, error)
-	FindQualityIssues(ctx context.Context) ([]models.QualityCheck, error)
+	// The listings below take projectIDs to keep to the checks of those projects; nil matches every project.
+	FindQualityByDateRange(ctx context.Context, startDate, endDate time.Time, projectIDs []int64) ([]models.QualityCheck, error)
+	FindQualityIssues(ctx context.Context, projectIDs []int64) ([]models.QualityCheck, error)
 	UpdateQualityStatus(ctx context.Context, id int64, status string) error
-	FindQualityChecksByInspector(ctx context.Context, inspectorID int64) ([]models.QualityCheck, error)
-	FindNonCompliantQualityChecks(ctx context.Context) ([]models.QualityCheck, error)
-
+	FindQualityChecksByInspector(ctx context.Context, inspectorID int64, projectIDs []int64) ([]models.QualityCheck, error)
+	FindNonCompliantQualityChecks(ctx context.Context, projectIDs []int64) ([]models.QualityCheck, error)
+	// FindChecksForReport returns the checks dated from (inclusive) to (exclusive). A zero projectID or inspectorID matches any.
+	FindChecksForReport(ctx context.Context, projectID, inspectorID int64, from, to time.Time) ([]models.QualityCheck, error)
+	// FindDocumentProject returns the project of a document and its current revision
+	FindDocumentProject(ctx context.Context, documentID int64) (int64, int64, error)
+	// CreateQualityReport stores the report with a snapshot of its checks and its attached documents
+	CreateQualityReport(ctx context.Context, report *models.QualityReport) error
+	FindQualityReportByID(ctx context.Context, id int64) (*models.QualityReport, error)
+	// FindQualityReports lists reports newest first. A zero projectID or inspectorID matches any.
+	FindQualityReports(ctx context.Context, projectID, inspectorID int64) ([]models.QualityReport, error)
+	// FindQualityReportChecks returns the checks as they were when the report was generated
+	FindQualityReportChecks(ctx context.Context, reportID int64) ([]models.QualityCheck, error)
+	// FindQualityReportDocuments returns the attached documents at their pinned revisions
+	FindQualityReportDocuments(ctx context.Context, reportID int64) ([]models.Document, error)
 }
 
 type qualityRepository struct {
@@ -31,10 +45,10 @@ func NewQualityRepository(db *sql.DB) QualityRepository {
 	return &qualityRepository{db}
 }
 
-func (q *qualityRepository) FindQualityChecksByInspector(ctx context.Context, inspectorID int64) ([]models.QualityCheck, error) {
-	query := "SELECT * FROM quality_checks WHERE inspector_id = $1"
+func (q *qualityRepository) FindQualityChecksByInspector(ctx context.Context, inspectorID int64, projectIDs []int64) ([]models.QualityCheck, error) {
+	query := "SELECT * FROM quality_checks WHERE inspector_id = $1 AND ($2::bigint[] IS NULL OR project_id = ANY($2))"
 
-	rows, err := q.db.QueryContext(ctx, query, inspectorID)
+	rows, err := q.db.QueryContext(ctx, query, inspectorID, pq.Array(projectIDs))
 	if err != nil {
 		return nil, err
 	}
@@ -52,10 +66,10 @@ func (q *qualityRepository) FindQualityChecksByInspector(ctx context.Context, in
 	return qualitys, nil
 }
 
-func (q *qualityRepository) FindNonCompliantQualityChecks(ctx context.Context) ([]models.QualityCheck, error) {
-	query := "SELECT * FROM quality_checks WHERE status = 'NON_COMPLIANT'"
+func (q *qualityRepository) FindNonCompliantQualityChecks(ctx context.Context, projectIDs []int64) ([]models.QualityCheck, error) {
+	query := "SELECT * FROM quality_checks WHERE status = 'NON_COMPLIANT' AND ($1::bigint[] IS NULL OR project_id = ANY($1))"
 
-	rows, err := q.db.QueryContext(ctx, query)
+	rows, err := q.db.QueryContext(ctx, query, pq.Array(projectIDs))
 	if err != nil {
 		return nil, err
 	}
@@ -94,31 +108,23 @@ func (q *qualityRepository) FindQualityByTaskID(ctx context.Context, taskID int6
 	return qualitys, nil
 }
 
-func (q *qualityRepository) FindQualityByDateRange(ctx context.Context, startDate, endDate time.Time) ([]models.QualityCheck, error) {
-	query := "SELECT * FROM quality_checks WHERE date BETWEEN $1 AND $2"
+func (q *qualityRepository) FindQualityByDateRange(ctx context.Context, startDate, endDate time.Time, projectIDs []int64) ([]models.QualityCheck, error) {
+	query := `SELECT id, project_id, inspector_id, date, status, comments FROM quality_checks
+		WHERE date >= $1 AND date < $2 AND ($3::bigint[] IS NULL OR project_id = ANY($3))
+		ORDER BY date, id`
 
-	rows, err := q.db.QueryContext(ctx, query, startDate, endDate)
+	rows, err := q.db.QueryContext(ctx, query, startDate, endDate, pq.Array(projectIDs))
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-
-	var qualitys []models.QualityCheck
-	for rows.Next() {
-		var quality models.QualityCheck
-		if err := rows.Scan(&quality.ID, &quality.ProjectID, &quality.InspectorID, &quality.Date, &quality.Comments, &quality.Status); err != nil {
-			return nil, err
-		}
-		qualitys = append(qualitys, quality)
-	}
-
-	return qualitys, nil
+	return scanChecks(rows)
 }
 
-func (q *qualityRepository) FindQualityIssues(ctx context.Context) ([]models.QualityCheck, error) {
-	query := "SELECT * FROM quality_checks WHERE status = 'NON_COMPLIANT'"
+func (q *qualityRepository) FindQualityIssues(ctx context.Context, projectIDs []int64) ([]models.QualityCheck, error) {
+	query := "SELECT * FROM quality_checks WHERE status = 'NON_COMPLIANT' AND ($1::bigint[] IS NULL OR project_id = ANY($1))"
 
-	rows, err := q.db.QueryContext(ctx, query)
+	rows, err := q.db.QueryContext(ctx, query, pq.Array(projectIDs))
 	if err != nil {
 		return nil, err
 	}
@@ -183,9 +189,9 @@ func (q *qualityRepository) FindQualityByID(ctx context.Context, id int64) (*mod
 
 func (q *qualityRepository) CreateQuality(ctx context.Context, quality *models.QualityCheck) error {
 
-	query := "INSERT INTO quality_checks (project_id, inspector_id, date, comments, status) VALUES ($1, $2, $3, $4, $5)"
+	query := "INSERT INTO quality_checks (project_id, inspector_id, date, comments, status) VALUES ($1, $2, $3, $4, $5) RETURNING id"
 
-	_, err := q.db.ExecContext(ctx, query, quality.ProjectID, quality.InspectorID, quality.Date, quality.Comments, quality.Status)
+	err := q.db.QueryRowContext(ctx, query, quality.ProjectID, quality.InspectorID, quality.Date, quality.Comments, quality.Status).Scan(&quality.ID)
 	if err != nil {
 		return err
 	}
@@ -223,3 +229,201 @@ func (q *qualityRepository) ShowQualityPerProject(ctx context.Context, projectID
 
 	return qualitys, nil
 }
+
+const selectQualityReportColumns = `SELECT r.id, r.project_id, r.inspector_id, r.generated_by_id, r.start_date, r.end_date, r.generated_date,
+	r.overall_status, r.total_checks, r.passed_checks, r.failed_checks, r.comments,
+	COALESCE(p.name, ''), COALESCE(i.username, ''), COALESCE(g.username, '')
+	FROM quality_reports r
+	LEFT JOIN projects p ON p.id = r.project_id
+	LEFT JOIN users i ON i.id = r.inspector_id
+	LEFT JOIN users g ON g.id = r.generated_by_id`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanQualityReport(row rowScanner, report *models.QualityReport) error {
+	var projectName, inspector, generatedBy string
+	if err := row.Scan(&report.ID, &report.ProjectID, &report.InspectorID, &report.GeneratedByID, &report.StartDate, &report.EndDate,
+		&report.GeneratedDate, &report.OverallStatus, &report.TotalChecks, &report.PassedChecks, &report.FailedChecks, &report.Comments,
+		&projectName, &inspector, &generatedBy); err != nil {
+		return err
+	}
+
+	if report.ProjectID > 0 {
+		report.Project = &models.Project{ID: report.ProjectID, Name: projectName}
+	}
+	if report.InspectorID > 0 {
+		report.Inspector = &models.User{ID: report.InspectorID, Username: inspector}
+	}
+	report.GeneratedBy = &models.User{ID: report.GeneratedByID, Username: generatedBy}
+	return nil
+}
+
+func (q *qualityRepository) FindChecksForReport(ctx context.Context, projectID, inspectorID int64, from, to time.Time) ([]models.QualityCheck, error) {
+	query := `SELECT id, project_id, inspector_id, date, status, comments FROM quality_checks
+		WHERE ($1 = 0 OR project_id = $1) AND ($2 = 0 OR inspector_id = $2) AND date >= $3 AND date < $4
+		ORDER BY date, id`
+
+	rows, err := q.db.QueryContext(ctx, query, projectID, inspectorID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanChecks(rows)
+}
+
+// scanChecks reads rows of id, project_id, inspector_id, date, status and comments
+func scanChecks(rows *sql.Rows) ([]models.QualityCheck, error) {
+	var checks []models.QualityCheck
+	for rows.Next() {
+		var check models.QualityCheck
+		if err := rows.Scan(&check.ID, &check.ProjectID, &check.InspectorID, &check.Date, &check.Status, &check.Comments); err != nil {
+			return nil, err
+		}
+		checks = append(checks, check)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return checks, nil
+}
+
+func (q *qualityRepository) FindDocumentProject(ctx context.Context, documentID int64) (int64, int64, error) {
+	query := "SELECT project_id, current_revision_id FROM documents WHERE id = $1"
+
+	var projectID, revisionID int64
+	if err := q.db.QueryRowContext(ctx, query, documentID).Scan(&projectID, &revisionID); err != nil {
+		if err == sql.ErrNoRows {
+			return 0, 0, errors.New("document not found")
+		}
+		return 0, 0, err
+	}
+	return projectID, revisionID, nil
+}
+
+func (q *qualityRepository) CreateQualityReport(ctx context.Context, report *models.QualityReport) error {
+	tx, err := q.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `INSERT INTO quality_reports (project_id, inspector_id, generated_by_id, start_date, end_date, generated_date,
+		overall_status, total_checks, passed_checks, failed_checks, comments)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11) RETURNING id`
+	if err := tx.QueryRowContext(ctx, query, report.ProjectID, report.InspectorID, report.GeneratedByID, report.StartDate, report.EndDate,
+		report.GeneratedDate, report.OverallStatus, report.TotalChecks, report.PassedChecks, report.FailedChecks, report.Comments).Scan(&report.ID); err != nil {
+		return err
+	}
+
+	checkQuery := `INSERT INTO quality_report_checks (quality_report_id, quality_check_id, project_id, inspector_id, date, status, comments)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`
+	for _, check := range report.QualityChecks {
+		if _, err := tx.ExecContext(ctx, checkQuery, report.ID, check.ID, check.ProjectID, check.InspectorID, check.Date, check.Status, check.Comments); err != nil {
+			return err
+		}
+	}
+
+	documentQuery := "INSERT INTO quality_report_documents (quality_report_id, document_id, revision_id) VALUES ($1, $2, $3)"
+	for _, document := range report.AttachedDocuments {
+		if _, err := tx.ExecContext(ctx, documentQuery, report.ID, document.ID, document.CurrentRevisionID); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (q *qualityRepository) FindQualityReportByID(ctx context.Context, id int64) (*models.QualityReport, error) {
+	var report models.QualityReport
+	if err := scanQualityReport(q.db.QueryRowContext(ctx, selectQualityReportColumns+" WHERE r.id = $1", id), &report); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("quality report not found")
+		}
+		return nil, err
+	}
+	return &report, nil
+}
+
+func (q *qualityRepository) FindQualityReports(ctx context.Context, projectID, inspectorID int64) ([]models.QualityReport, error) {
+	query := selectQualityReportColumns + `
+		WHERE ($1 = 0 OR r.project_id = $1) AND ($2 = 0 OR r.inspector_id = $2)
+		ORDER BY r.generated_date DESC, r.id DESC`
+
+	rows, err := q.db.QueryContext(ctx, query, projectID, inspectorID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var reports []models.QualityReport
+	for rows.Next() {
+		var report models.QualityReport
+		if err := scanQualityReport(rows, &report); err != nil {
+			return nil, err
+		}
+		reports = append(reports, report)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return reports, nil
+}
+
+func (q *qualityRepository) FindQualityReportChecks(ctx context.Context, reportID int64) ([]models.QualityCheck, error) {
+	query := `SELECT c.quality_check_id, c.project_id, c.inspector_id, c.date, c.status, c.comments, COALESCE(u.username, '')
+		FROM quality_report_checks c LEFT JOIN users u ON u.id = c.inspector_id
+		WHERE c.quality_report_id = $1 ORDER BY c.date, c.quality_check_id`
+
+	rows, err := q.db.QueryContext(ctx, query, reportID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var checks []models.QualityCheck
+	for rows.Next() {
+		var check models.QualityCheck
+		var inspector string
+		if err := rows.Scan(&check.ID, &check.ProjectID, &check.InspectorID, &check.Date, &check.Status, &check.Comments, &inspector); err != nil {
+			return nil, err
+		}
+		check.Inspector = &models.User{ID: check.InspectorID, Username: inspector}
+		checks = append(checks, check)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return checks, nil
+}
+
+func (q *qualityRepository) FindQualityReportDocuments(ctx context.Context, reportID int64) ([]models.Document, error) {
+	// Legacy documents have no revisions; their own file fields are the pinned content
+	query := `SELECT d.id, d.project_id, d.name, d.type, COALESCE(r.file_name, d.file_name, ''), COALESCE(r.mime_type, d.mime_type, ''),
+		COALESCE(r.storage_key, d.storage_key, ''), COALESCE(r.upload_date, d.upload_date), a.revision_id, COALESCE(r.label, '')
+		FROM quality_report_documents a
+		JOIN documents d ON d.id = a.document_id
+		LEFT JOIN document_revisions r ON r.id = a.revision_id AND a.revision_id > 0
+		WHERE a.quality_report_id = $1 ORDER BY a.id`
+
+	rows, err := q.db.QueryContext(ctx, query, reportID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var documents []models.Document
+	for rows.Next() {
+		var document models.Document
+		if err := rows.Scan(&document.ID, &document.ProjectID, &document.Name, &document.Type, &document.FileName, &document.MimeType,
+			&document.StorageKey, &document.UploadDate, &document.CurrentRevisionID, &document.Revision); err != nil {
+			return nil, err
+		}
+		documents = append(documents, document)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return documents, nil
+}
@@ -3,29 +3,61 @@ package quality
 import (
 	"context"
 	"fmt"
+	"strings"
 	"time"
 	models "github.com/BerkatPS/internal"
+	"github.com/BerkatPS/internal/events"
 )
 
+// Overall status of a quality report
+const (
+	OverallPassed = "Passed" // Every included check passed
+	OverallFailed = "Failed" // Every included check failed
+	OverallMixed  = "Mixed"
+)
+
+// Check statuses counted as a pass or a failure; anything else (e.g. PENDING) is neither and makes a report Mixed
+var (
+	passingStatuses = map[string]bool{"PASSED": true, "PASS": true, "COMPLIANT": true, "APPROVED": true}
+	failingStatuses = map[string]bool{"FAILED": true, "FAIL": true, "NON_COMPLIANT": true, "REJECTED": true}
+)
+
+// QualityReportRenderer renders quality reports as PDF
+type QualityReportRenderer interface {
+	RenderQualityReportPDF(ctx context.Context, qualityReport *models.QualityReport) ([]byte, error)
+}
+
 type QualityService interface {
 	FindQualityByID(ctx context.Context, id int64) (*models.QualityCheck, error)
 	CreateQuality(ctx context.Context, quality *models.QualityCheck) error
 	UpdateQuality(ctx context.Context, quality *models.QualityCheck) error
 	ShowQualityPerProject(ctx context.Context, projectID int64) ([]models.QualityCheck, error)
 	FindQualityByTaskID(ctx context.Context, taskID int64) ([]models.QualityCheck, error)
-	FindQualityByDateRange(ctx context.Context, startDate, endDate time.Time) ([]models.QualityCheck, error)
-	FindQualityIssues(ctx context.Context) ([]models.QualityCheck, error)
+	// The listings below take projectIDs to keep to the checks of those projects; nil matches every project.
+	FindQualityByDateRange(ctx context.Context, startDate, endDate time.Time, projectIDs []int64) ([]models.QualityCheck, error)
+	FindQualityIssues(ctx context.Context, projectIDs []int64) ([]models.QualityCheck, error)
 	UpdateQualityStatus(ctx context.Context, id int64, status string) error
-	FindQualityChecksByInspector(ctx context.Context, inspectorID int64) ([]models.QualityCheck, error)
-	FindNonCompliantQualityChecks(ctx context.Context) ([]models.QualityCheck, error)
+	FindQualityChecksByInspector(ctx context.Context, inspectorID int64, projectIDs []int64) ([]models.QualityCheck, error)
+	FindNonCompliantQualityChecks(ctx context.Context, projectIDs []int64) ([]models.QualityCheck, error)
+	// GenerateQualityReport snapshots the checks of a project, an inspector or both between StartDate and EndDate
+	// (both inclusive days) and stores the report with its overall status
+	GenerateQualityReport(ctx context.Context, report *models.QualityReport) error
+	// FindQualityReportByID returns the report with its checks as they were when it was generated
+	FindQualityReportByID(ctx context.Context, id int64) (*models.QualityReport, error)
+	FindQualityReports(ctx context.Context, projectID, inspectorID int64) ([]models.QualityReport, error)
+	RenderQualityReportPDF(ctx context.Context, id int64) ([]byte, *models.QualityReport, error)
 }
 
 type qualityService struct {
 	QualityRepo QualityRepository
+	Events      events.Publisher
+	Renderer    QualityReportRenderer
 }
 
-func NewQualityService(qualityRepo QualityRepository) QualityService {
-	return &qualityService{qualityRepo}
+// NewQualityService creates a QualityService. publisher may be nil, in which case results are not streamed;
+// renderer may be nil, in which case quality reports cannot be rendered as PDF.
+func NewQualityService(qualityRepo QualityRepository, publisher events.Publisher, renderer QualityReportRenderer) QualityService {
+	return &qualityService{qualityRepo, publisher, renderer}
 }
 
 func (q *qualityService) FindQualityByTaskID(ctx context.Context, taskID int64) ([]models.QualityCheck, error) {
@@ -39,12 +71,12 @@ func (q *qualityService) FindQualityByTaskID(ctx context.Context, taskID int64)
 	return qualities, nil
 }
 
-func (q *qualityService) FindQualityByDateRange(ctx context.Context, startDate, endDate time.Time) ([]models.QualityCheck, error) {
+func (q *qualityService) FindQualityByDateRange(ctx context.Context, startDate, endDate time.Time, projectIDs []int64) ([]models.QualityCheck, error) {
 	if startDate.IsZero() || endDate.IsZero() {
 		return nil, fmt.Errorf("invalid date range")
 	}
 
-	qualities, err := q.QualityRepo.FindQualityByDateRange(ctx, startDate, endDate)
+	qualities, err := q.QualityRepo.FindQualityByDateRange(ctx, startDate, endDate, projectIDs)
 	if err != nil {
 		return nil, fmt.Errorf("failed to retrieve quality: %v", err)
 	}
@@ -52,8 +84,8 @@ func (q *qualityService) FindQualityByDateRange(ctx context.Context, startDate,
 	return qualities, nil
 }
 
-func (q *qualityService) FindQualityIssues(ctx context.Context) ([]models.QualityCheck, error) {
-	qualities, err := q.QualityRepo.FindQualityIssues(ctx)
+func (q *qualityService) FindQualityIssues(ctx context.Context, projectIDs []int64) ([]models.QualityCheck, error) {
+	qualities, err := q.QualityRepo.FindQualityIssues(ctx, projectIDs)
 	if err != nil {
 		return nil, fmt.Errorf("failed to retrieve quality: %v", err)
 	}
@@ -77,12 +109,12 @@ func (q *qualityService) UpdateQualityStatus(ctx context.Context, id int64, stat
 	return nil
 }
 
-func (q *qualityService) FindQualityChecksByInspector(ctx context.Context, inspectorID int64) ([]models.QualityCheck, error) {
+func (q *qualityService) FindQualityChecksByInspector(ctx context.Context, inspectorID int64, projectIDs []int64) ([]models.QualityCheck, error) {
 	if inspectorID <= 0 {
 		return nil, fmt.Errorf("invalid inspector ID")
 	}
 
-	qualities, err := q.QualityRepo.FindQualityChecksByInspector(ctx, inspectorID)
+	qualities, err := q.QualityRepo.FindQualityChecksByInspector(ctx, inspectorID, projectIDs)
 	if err != nil {
 		return nil, fmt.Errorf("failed to retrieve quality: %v", err)
 	}
@@ -90,8 +122,8 @@ func (q *qualityService) FindQualityChecksByInspector(ctx context.Context, inspe
 
 }
 
-func (q *qualityService) FindNonCompliantQualityChecks(ctx context.Context) ([]models.QualityCheck, error) {
-	qualities, err := q.QualityRepo.FindNonCompliantQualityChecks(ctx)
+func (q *qualityService) FindNonCompliantQualityChecks(ctx context.Context, projectIDs []int64) ([]models.QualityCheck, error) {
+	qualities, err := q.QualityRepo.FindNonCompliantQualityChecks(ctx, projectIDs)
 	if err != nil {
 		return nil, fmt.Errorf("failed to retrieve quality: %v", err)
 	}
@@ -147,6 +179,10 @@ func (q *qualityService) CreateQuality(ctx context.Context, quality *models.Qual
 	if err != nil {
 		return fmt.Errorf("failed to create quality: %v", err)
 	}
+
+	if q.Events != nil {
+		q.Events.Publish(quality.ProjectID, events.EventQualityCheckRecorded, quality)
+	}
 	return nil
 }
 
@@ -180,6 +216,138 @@ func (q *qualityService) UpdateQuality(ctx context.Context, quality *models.Qual
 	if err != nil {
 		return fmt.Errorf("failed to update quality: %v", err)
 	}
+
+	if q.Events != nil {
+		q.Events.Publish(quality.ProjectID, events.EventQualityCheckRecorded, quality)
+	}
 	return nil
 }
 
+func (q *qualityService) GenerateQualityReport(ctx context.Context, report *models.QualityReport) error {
+	if report.ProjectID < 0 || report.InspectorID < 0 || (report.ProjectID == 0 && report.InspectorID == 0) {
+		return fmt.Errorf("a project ID, an inspector ID or both are required")
+	}
+
+	if report.GeneratedByID <= 0 {
+		return fmt.Errorf("invalid user ID")
+	}
+
+	if report.StartDate.IsZero() || report.EndDate.IsZero() || report.EndDate.Before(report.StartDate) {
+		return fmt.Errorf("invalid date range")
+	}
+
+	report.StartDate = startOfDay(report.StartDate)
+	report.EndDate = startOfDay(report.EndDate)
+
+	checks, err := q.QualityRepo.FindChecksForReport(ctx, report.ProjectID, report.InspectorID, report.StartDate, report.EndDate.AddDate(0, 0, 1))
+	if err != nil {
+		return fmt.Errorf("failed to retrieve quality checks: %v", err)
+	}
+
+	if len(checks) == 0 {
+		return fmt.Errorf("no quality checks found in the date range")
+	}
+
+	// Attachments are pinned to the revision current now; an inspector-only report may attach any project's documents
+	seen := make(map[int64]bool)
+	documents := make([]models.Document, 0, len(report.AttachedDocuments))
+	for _, document := range report.AttachedDocuments {
+		if seen[document.ID] {
+			continue
+		}
+		seen[document.ID] = true
+
+		projectID, revisionID, err := q.QualityRepo.FindDocumentProject(ctx, document.ID)
+		if err != nil {
+			return fmt.Errorf("failed to retrieve document %d: %v", document.ID, err)
+		}
+		if report.ProjectID > 0 && projectID != report.ProjectID {
+			return fmt.Errorf("document %d does not belong to project %d", document.ID, report.ProjectID)
+		}
+		documents = append(documents, models.Document{ID: document.ID, ProjectID: projectID, CurrentRevisionID: revisionID})
+	}
+
+	report.QualityChecks = checks
+	report.AttachedDocuments = documents
+	report.Comments = strings.TrimSpace(report.Comments)
+	report.GeneratedDate = time.Now()
+	report.TotalChecks, report.PassedChecks, report.FailedChecks = len(checks), 0, 0
+	for _, check := range checks {
+		status := strings.ToUpper(check.Status)
+		switch {
+		case passingStatuses[status]:
+			report.PassedChecks++
+		case failingStatuses[status]:
+			report.FailedChecks++
+		}
+	}
+
+	switch report.TotalChecks {
+	case report.PassedChecks:
+		report.OverallStatus = OverallPassed
+	case report.FailedChecks:
+		report.OverallStatus = OverallFailed
+	default:
+		report.OverallStatus = OverallMixed
+	}
+
+	if err := q.QualityRepo.CreateQualityReport(ctx, report); err != nil {
+		return fmt.Errorf("failed to create quality report: %v", err)
+	}
+	return nil
+}
+
+func (q *qualityService) FindQualityReportByID(ctx context.Context, id int64) (*models.QualityReport, error) {
+	if id <= 0 {
+		return nil, fmt.Errorf("invalid quality report ID")
+	}
+
+	report, err := q.QualityRepo.FindQualityReportByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve quality report: %v", err)
+	}
+
+	report.QualityChecks, err = q.QualityRepo.FindQualityReportChecks(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve quality report checks: %v", err)
+	}
+
+	report.AttachedDocuments, err = q.QualityRepo.FindQualityReportDocuments(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve quality report documents: %v", err)
+	}
+	return report, nil
+}
+
+func (q *qualityService) FindQualityReports(ctx context.Context, projectID, inspectorID int64) ([]models.QualityReport, error) {
+	if projectID < 0 || inspectorID < 0 {
+		return nil, fmt.Errorf("invalid project or inspector ID")
+	}
+
+	reports, err := q.QualityRepo.FindQualityReports(ctx, projectID, inspectorID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve quality reports: %v", err)
+	}
+	return reports, nil
+}
+
+func (q *qualityService) RenderQualityReportPDF(ctx context.Context, id int64) ([]byte, *models.QualityReport, error) {
+	if q.Renderer == nil {
+		return nil, nil, fmt.Errorf("PDF rendering is not configured")
+	}
+
+	report, err := q.FindQualityReportByID(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	content, err := q.Renderer.RenderQualityReportPDF(ctx, report)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to render quality report: %v", err)
+	}
+	return content, report, nil
+}
+
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
@@ -1,15 +1,24 @@
 package quality
 
-import "net/http"
+import (
+	"net/http"
 
-func RegisterRoutes(router *http.ServeMux, handler *QualityController) {
-	router.HandleFunc("GET /quality/{id}", handler.FindQualityByID)
-	router.HandleFunc("GET /quality", handler.ShowQualityPerProject)
-	router.HandleFunc("POST /quality/add", handler.CreateQuality)
-	router.HandleFunc("PUT /quality/{id}", handler.UpdateQuality)
-	router.HandleFunc("GET /quality/issues", handler.FindQualityIssues)
-	router.HandleFunc("GET /quality/non-compliant-check", handler.FindNonCompliantQualityChecks)
-	router.HandleFunc("GET /quality/inspector/{inspectorID}", handler.FindQualityChecksByInspector)
-	router.HandleFunc("GET /quality/task/{taskID}", handler.FindQualityByTaskID)
-	
+	"github.com/BerkatPS/pkg/rbac"
+)
+
+func RegisterRoutes(router *http.ServeMux, handler *QualityController, authz *rbac.Enforcer) {
+	router.HandleFunc("GET /quality/{id}", authz.RequireProject(rbac.QualityRead, authz.PathResource(rbac.ResourceQualityCheck, "id"), handler.FindQualityByID))
+	router.HandleFunc("GET /quality/project/{id}", authz.RequireProject(rbac.QualityRead, rbac.PathProject("id"), handler.ShowQualityPerProject))
+	router.HandleFunc("POST /quality/add", authz.RequireProject(rbac.QualityCreate, rbac.BodyProject("project_id"), handler.CreateQuality))
+	router.HandleFunc("PUT /quality/{id}", authz.RequireProject(rbac.QualityUpdate, authz.BodyResource(rbac.ResourceQualityCheck, "id"),
+		// An update may move it to another project, where the permission is needed too
+		authz.RequireProject(rbac.QualityUpdate, rbac.BodyProject("project_id"), handler.UpdateQuality)))
+	router.HandleFunc("GET /quality/issues", authz.Require(rbac.QualityRead, handler.FindQualityIssues))
+	router.HandleFunc("GET /quality/non-compliant-check", authz.Require(rbac.QualityRead, handler.FindNonCompliantQualityChecks))
+	router.HandleFunc("GET /quality/inspector/{inspectorID}", authz.Require(rbac.QualityRead, handler.FindQualityChecksByInspector))
+	router.HandleFunc("GET /quality/task/{taskID}", authz.RequireProject(rbac.QualityRead, authz.PathResource(rbac.ResourceTask, "taskID"), handler.FindQualityByTaskID))
+	router.HandleFunc("GET /quality/date-range", authz.Require(rbac.QualityRead, handler.FindQualityByDateRange))
+	router.HandleFunc("POST /quality/reports", authz.RequireProject(rbac.QualityReport, rbac.BodyProject("project_id"), handler.GenerateQualityReport))
+	router.HandleFunc("GET /quality/reports", authz.RequireProject(rbac.QualityRead, rbac.QueryProject("project_id"), handler.FindQualityReports))
+	router.HandleFunc("GET /quality/reports/{id}", authz.RequireProject(rbac.QualityRead, authz.PathResource(rbac.ResourceQualityReport, "id"), handler.FindQualityReportByID))
 }
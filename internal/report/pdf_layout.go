@@ -0,0 +1,285 @@
+package report
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/BerkatPS/pkg/pdf"
+)
+
+const (
+	pdfMargin      = 40.0
+	pdfHeaderSize  = 56.0 // Height reserved at the top of every page for the logo and project name
+	pdfFooterSize  = 30.0
+	pdfBodySize    = 9.0
+	pdfLineHeight  = 12.0
+	pdfCellPadding = 4.0
+	pdfMaxPhotos   = 12
+	pdfPhotoHeight = 200.0
+)
+
+var pdfContentWidth = pdf.PageWidth - 2*pdfMargin
+
+// pdfColumn is one column of a table; Width is its share of the content width
+type pdfColumn struct {
+	Title string
+	Width float64
+	Right bool // Right-align, for amounts
+}
+
+// pdfPhoto is a picture with its caption, ready to be placed in the photo section
+type pdfPhoto struct {
+	Image   *pdf.Image
+	Caption string
+}
+
+// pdfLayout flows headings, paragraphs, tables and photos down the pages of a document, starting a new page
+// with the project header whenever the current one is full
+type pdfLayout struct {
+	doc         *pdf.Document
+	page        *pdf.Page
+	y           float64
+	projectName string
+	title       string
+	logo        *pdf.Image
+}
+
+func newPDFLayout(doc *pdf.Document, projectName, title string, logo *pdf.Image) *pdfLayout {
+	l := &pdfLayout{doc: doc, projectName: projectName, title: title, logo: logo}
+	l.newPage()
+	return l
+}
+
+func (l *pdfLayout) newPage() {
+	l.page = l.doc.AddPage()
+
+	textX := pdfMargin
+	if l.logo != nil {
+		w, h := fitBox(l.logo, 120, pdfHeaderSize-16)
+		l.page.Image(l.logo, pdfMargin, pdfMargin, w, h)
+		textX += w + 12
+	}
+	l.page.Text(textX, pdfMargin+16, pdf.HelveticaBold, 14, truncate(pdf.HelveticaBold, 14, l.projectName, pdf.PageWidth-pdfMargin-textX))
+	l.page.Text(textX, pdfMargin+32, pdf.Helvetica, 10, truncate(pdf.Helvetica, 10, l.title, pdf.PageWidth-pdfMargin-textX))
+	l.page.Line(pdfMargin, pdfMargin+pdfHeaderSize-6, pdf.PageWidth-pdfMargin, pdfMargin+pdfHeaderSize-6, 0.8, 0.3)
+
+	l.y = pdfMargin + pdfHeaderSize + 6
+}
+
+// ensure starts a new page unless height more points fit on the current one
+func (l *pdfLayout) ensure(height float64) {
+	if l.y+height > pdf.PageHeight-pdfMargin-pdfFooterSize {
+		l.newPage()
+	}
+}
+
+func (l *pdfLayout) heading(text string) {
+	l.ensure(3 * pdfLineHeight)
+	l.y += 8
+	l.page.Text(pdfMargin, l.y+10, pdf.HelveticaBold, 11, text)
+	l.y += 16
+}
+
+func (l *pdfLayout) paragraph(text string) {
+	for _, line := range wrapText(pdf.Helvetica, pdfBodySize, text, pdfContentWidth) {
+		l.ensure(pdfLineHeight)
+		l.page.Text(pdfMargin, l.y+pdfBodySize, pdf.Helvetica, pdfBodySize, line)
+		l.y += pdfLineHeight
+	}
+	l.y += 4
+}
+
+// keyValues prints label: value pairs, one per line
+func (l *pdfLayout) keyValues(pairs [][2]string) {
+	labelWidth := 110.0
+	for _, pair := range pairs {
+		lines := wrapText(pdf.Helvetica, pdfBodySize, pair[1], pdfContentWidth-labelWidth)
+		l.ensure(float64(len(lines)) * pdfLineHeight)
+		l.page.Text(pdfMargin, l.y+pdfBodySize, pdf.HelveticaBold, pdfBodySize, pair[0])
+		for _, line := range lines {
+			l.page.Text(pdfMargin+labelWidth, l.y+pdfBodySize, pdf.Helvetica, pdfBodySize, line)
+			l.y += pdfLineHeight
+		}
+	}
+	l.y += 4
+}
+
+// table draws rows under a shaded header row, wrapping long cells and repeating the header on every page
+func (l *pdfLayout) table(columns []pdfColumn, rows [][]string) {
+	if len(rows) == 0 {
+		l.paragraph("None recorded.")
+		return
+	}
+
+	widths := make([]float64, len(columns))
+	for i, column := range columns {
+		widths[i] = column.Width * pdfContentWidth
+	}
+
+	header := func() {
+		height := pdfLineHeight + 2*pdfCellPadding
+		l.page.FillRect(pdfMargin, l.y, pdfContentWidth, height, 0.88)
+		x := pdfMargin
+		for i, column := range columns {
+			l.cell(x, widths[i], pdf.HelveticaBold, column.Title, column.Right)
+			x += widths[i]
+		}
+		l.y += height
+	}
+
+	l.ensure(2 * (pdfLineHeight + 2*pdfCellPadding))
+	header()
+
+	for _, row := range rows {
+		cells := make([][]string, len(columns))
+		lineCount := 1
+		for i := range columns {
+			var value string
+			if i < len(row) {
+				value = row[i]
+			}
+			cells[i] = wrapText(pdf.Helvetica, pdfBodySize, value, widths[i]-2*pdfCellPadding)
+			if len(cells[i]) > lineCount {
+				lineCount = len(cells[i])
+			}
+		}
+		height := float64(lineCount)*pdfLineHeight + 2*pdfCellPadding
+
+		if l.y+height > pdf.PageHeight-pdfMargin-pdfFooterSize {
+			l.newPage()
+			header()
+		}
+
+		x := pdfMargin
+		for i, column := range columns {
+			for j, line := range cells[i] {
+				lineX := x + pdfCellPadding
+				if column.Right {
+					lineX = x + widths[i] - pdfCellPadding - pdf.TextWidth(pdf.Helvetica, pdfBodySize, line)
+				}
+				l.page.Text(lineX, l.y+pdfCellPadding+pdfBodySize+float64(j)*pdfLineHeight, pdf.Helvetica, pdfBodySize, line)
+			}
+			x += widths[i]
+		}
+		l.y += height
+		l.page.Line(pdfMargin, l.y, pdfMargin+pdfContentWidth, l.y, 0.4, 0.75)
+	}
+	l.y += 8
+}
+
+func (l *pdfLayout) cell(x, width float64, font pdf.Font, text string, right bool) {
+	text = truncate(font, pdfBodySize, text, width-2*pdfCellPadding)
+	textX := x + pdfCellPadding
+	if right {
+		textX = x + width - pdfCellPadding - pdf.TextWidth(font, pdfBodySize, text)
+	}
+	l.page.Text(textX, l.y+pdfCellPadding+pdfBodySize, font, pdfBodySize, text)
+}
+
+// photos lays pictures out two per row, scaled to keep their aspect ratio
+func (l *pdfLayout) photos(photos []pdfPhoto) {
+	gap := 12.0
+	slotWidth := (pdfContentWidth - gap) / 2
+
+	for i := 0; i < len(photos); i += 2 {
+		row := photos[i:min(i+2, len(photos))]
+
+		rowHeight := 0.0
+		for _, photo := range row {
+			_, h := fitBox(photo.Image, slotWidth, pdfPhotoHeight)
+			rowHeight = max(rowHeight, h)
+		}
+		l.ensure(rowHeight + 2*pdfLineHeight)
+
+		for j, photo := range row {
+			x := pdfMargin + float64(j)*(slotWidth+gap)
+			w, h := fitBox(photo.Image, slotWidth, pdfPhotoHeight)
+			l.page.Image(photo.Image, x, l.y, w, h)
+			l.page.StrokeRect(x, l.y, w, h, 0.5, 0.6)
+			caption := truncate(pdf.Helvetica, 8, photo.Caption, slotWidth)
+			l.page.Text(x, l.y+rowHeight+10, pdf.Helvetica, 8, caption)
+		}
+		l.y += rowHeight + 2*pdfLineHeight
+	}
+}
+
+// signOff leaves room for the people who sign the printed report
+func (l *pdfLayout) signOff(preparedBy string) {
+	l.ensure(70)
+	l.y += 24
+	half := (pdfContentWidth - 40) / 2
+	for i, label := range []string{"Prepared by: " + preparedBy, "Approved by:"} {
+		x := pdfMargin + float64(i)*(half+40)
+		l.page.Line(x, l.y+24, x+half, l.y+24, 0.6, 0)
+		l.page.Text(x, l.y+36, pdf.Helvetica, pdfBodySize, label)
+		l.page.Text(x, l.y+48, pdf.Helvetica, pdfBodySize, "Signature / date")
+	}
+	l.y += 56
+}
+
+// finish numbers every page once the total is known
+func (l *pdfLayout) finish(generatedAt time.Time) {
+	pages := l.doc.Pages()
+	footerY := pdf.PageHeight - pdfMargin + 8
+	for i, page := range pages {
+		page.Line(pdfMargin, footerY-14, pdf.PageWidth-pdfMargin, footerY-14, 0.4, 0.75)
+		page.Text(pdfMargin, footerY, pdf.Helvetica, 8, "Generated "+generatedAt.Format("2006-01-02 15:04"))
+		number := fmt.Sprintf("Page %d of %d", i+1, len(pages))
+		page.Text(pdf.PageWidth-pdfMargin-pdf.TextWidth(pdf.Helvetica, 8, number), footerY, pdf.Helvetica, 8, number)
+	}
+}
+
+// fitBox scales an image to fit within maxWidth x maxHeight without distorting it
+func fitBox(img *pdf.Image, maxWidth, maxHeight float64) (float64, float64) {
+	if img.Width == 0 || img.Height == 0 {
+		return maxWidth, maxHeight
+	}
+	scale := min(maxWidth/float64(img.Width), maxHeight/float64(img.Height))
+	return float64(img.Width) * scale, float64(img.Height) * scale
+}
+
+// wrapText breaks text into lines no wider than width, splitting words that are too long on their own
+func wrapText(font pdf.Font, size float64, text string, width float64) []string {
+	var lines []string
+	for _, paragraph := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		line := ""
+		for _, word := range strings.Fields(paragraph) {
+			for pdf.TextWidth(font, size, word) > width {
+				if line != "" {
+					lines = append(lines, line)
+					line = ""
+				}
+				cut := len(word) - 1
+				for cut > 1 && pdf.TextWidth(font, size, word[:cut]) > width {
+					cut--
+				}
+				lines = append(lines, word[:cut])
+				word = word[cut:]
+			}
+
+			candidate := word
+			if line != "" {
+				candidate = line + " " + word
+			}
+			if pdf.TextWidth(font, size, candidate) > width {
+				lines = append(lines, line)
+				candidate = word
+			}
+			line = candidate
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// truncate shortens text with an ellipsis so it fits in width
+func truncate(font pdf.Font, size float64, text string, width float64) string {
+	if pdf.TextWidth(font, size, text) <= width {
+		return text
+	}
+	for len(text) > 0 && pdf.TextWidth(font, size, text+"...") > width {
+		text = text[:len(text)-1]
+	}
+	return text + "..."
+}
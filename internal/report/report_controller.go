@@ -0,0 +1,544 @@
+package report
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	models "github.com/BerkatPS/internal"
+	"github.com/BerkatPS/pkg/principal"
+	"github.com/BerkatPS/pkg/utils"
+)
+
+type ReportController struct {
+	ReportService ReportService
+}
+
+func NewReportController(reportService ReportService) *ReportController {
+	return &ReportController{reportService}
+}
+
+// CompileDailyReport creates or recompiles a project's daily report. date is YYYY-MM-DD and defaults to today.
+func (c *ReportController) CompileDailyReport(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	userID, err := principal.UserIDFromRequest(r)
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusUnauthorized, map[string]interface{}{
+			"status":  "error",
+			"message": "Unauthorized: " + err.Error(),
+		})
+		return
+	}
+
+	var compileRequest struct {
+		ProjectID int64  `json:"project_id"`
+		Date      string `json:"date"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&compileRequest); err != nil {
+		utils.JSONErrorResponse(w, http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": "Invalid request body: " + err.Error(),
+		})
+		return
+	}
+
+	day := time.Now()
+	if compileRequest.Date != "" {
+		day, err = time.ParseInLocation("2006-01-02", compileRequest.Date, time.Local)
+		if err != nil {
+			utils.JSONErrorResponse(w, http.StatusBadRequest, map[string]interface{}{
+				"status":  "error",
+				"message": "Invalid date, expected YYYY-MM-DD: " + err.Error(),
+			})
+			return
+		}
+	}
+
+	report, err := c.ReportService.CompileDailyReport(ctx, compileRequest.ProjectID, day, userID)
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusInternalServerError, map[string]interface{}{
+			"status":  "error",
+			"message": "Failed to compile daily report: " + err.Error(),
+		})
+		return
+	}
+
+	utils.JSONResponse(w, http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "Daily report compiled successfully",
+		"data":    report,
+	})
+}
+
+// FindReportByID returns a report as JSON, or as a PDF when the path ends in .pdf (GET /reports/12.pdf).
+// .html and .md render it through a report template.
+func (c *ReportController) FindReportByID(w http.ResponseWriter, r *http.Request) {
+
+	if strings.HasSuffix(r.PathValue("id"), ".pdf") {
+		c.RenderReportPDF(w, r)
+		return
+	}
+	if strings.HasSuffix(r.PathValue("id"), ".html") || strings.HasSuffix(r.PathValue("id"), ".md") {
+		c.RenderReportTemplate(w, r)
+		return
+	}
+
+	ctx := r.Context()
+
+	reportID, err := utils.ParseInt64Param(r)
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": "Invalid report ID: " + err.Error(),
+		})
+		return
+	}
+
+	report, err := c.ReportService.FindReportByID(ctx, reportID)
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusInternalServerError, map[string]interface{}{
+			"status":  "error",
+			"message": "Failed to retrieve report: " + err.Error(),
+		})
+		return
+	}
+
+	utils.JSONResponse(w, http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "Report found successfully",
+		"data":    report,
+	})
+}
+
+// FindReportsByProject lists a project's reports, newest day first, optionally filtered by ?type=
+func (c *ReportController) FindReportsByProject(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	projectID, err := utils.ParseInt64Param(r)
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": "Invalid project ID: " + err.Error(),
+		})
+		return
+	}
+
+	reports, err := c.ReportService.FindReportsByProjectID(ctx, projectID, r.URL.Query().Get("type"))
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusInternalServerError, map[string]interface{}{
+			"status":  "error",
+			"message": "Failed to retrieve reports: " + err.Error(),
+		})
+		return
+	}
+
+	utils.JSONResponse(w, http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "Reports found successfully",
+		"data":    reports,
+	})
+}
+
+// UpdateDailyReport edits the weather and notes the site team writes on a daily report
+func (c *ReportController) UpdateDailyReport(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	userID, err := principal.UserIDFromRequest(r)
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusUnauthorized, map[string]interface{}{
+			"status":  "error",
+			"message": "Unauthorized: " + err.Error(),
+		})
+		return
+	}
+
+	reportID, err := utils.ParseInt64Param(r)
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": "Invalid report ID: " + err.Error(),
+		})
+		return
+	}
+
+	var updateRequest struct {
+		Weather string `json:"weather"`
+		Notes   string `json:"notes"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&updateRequest); err != nil {
+		utils.JSONErrorResponse(w, http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": "Invalid request body: " + err.Error(),
+		})
+		return
+	}
+
+	report, err := c.ReportService.UpdateDailyReport(ctx, reportID, userID, updateRequest.Weather, updateRequest.Notes)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, ErrNotDailyReport) {
+			status = http.StatusConflict
+		}
+		utils.JSONErrorResponse(w, status, map[string]interface{}{
+			"status":  "error",
+			"message": "Failed to update report: " + err.Error(),
+		})
+		return
+	}
+
+	utils.JSONResponse(w, http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "Report updated successfully",
+		"data":    report,
+	})
+}
+
+func (c *ReportController) RenderReportPDF(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	reportID, err := strconv.ParseInt(strings.TrimSuffix(r.PathValue("id"), ".pdf"), 10, 64)
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": "Invalid report ID: " + err.Error(),
+		})
+		return
+	}
+
+	content, report, err := c.ReportService.RenderReportPDF(ctx, reportID)
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusInternalServerError, map[string]interface{}{
+			"status":  "error",
+			"message": "Failed to render report: " + err.Error(),
+		})
+		return
+	}
+
+	fileName := fmt.Sprintf("report-%d-%s.pdf", report.ID, report.Type)
+	if report.Type == TypeDaily || report.Type == TypeWeekly {
+		fileName = fmt.Sprintf("%s-report-%d-%s.pdf", report.Type, report.ProjectID, report.ReportDate.Format("2006-01-02"))
+	}
+	ServePDF(w, content, fileName)
+}
+
+// ServePDF sends a rendered PDF for the browser to display, saving it under fileName if downloaded
+func ServePDF(w http.ResponseWriter, content []byte, fileName string) {
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("inline", map[string]string{"filename": fileName}))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusOK)
+
+	w.Write(content)
+}
+
+// RenderReportTemplate renders a report through a template: GET /reports/12.html uses an HTML template and
+// GET /reports/12.md a Markdown one. ?template_id= picks the template, otherwise the default one applies.
+func (c *ReportController) RenderReportTemplate(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	rawID, format, contentType := r.PathValue("id"), TemplateFormatHTML, "text/html; charset=utf-8"
+	if strings.HasSuffix(rawID, ".md") {
+		format, contentType = TemplateFormatMarkdown, "text/markdown; charset=utf-8"
+	}
+
+	reportID, err := strconv.ParseInt(strings.TrimSuffix(strings.TrimSuffix(rawID, ".html"), ".md"), 10, 64)
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": "Invalid report ID: " + err.Error(),
+		})
+		return
+	}
+
+	templateID, err := utils.ParseInt64Query(r, "template_id")
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": "Invalid template ID: " + err.Error(),
+		})
+		return
+	}
+
+	content, _, err := c.ReportService.RenderReportTemplate(ctx, reportID, templateID, format)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, ErrNoTemplate) {
+			status = http.StatusNotFound
+		}
+		utils.JSONErrorResponse(w, status, map[string]interface{}{
+			"status":  "error",
+			"message": "Failed to render report: " + err.Error(),
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	// Templates are written by admins, but a rendered report is still not allowed to run scripts
+	w.Header().Set("Content-Security-Policy", "sandbox")
+	w.WriteHeader(http.StatusOK)
+
+	w.Write(content)
+}
+
+type templateRequest struct {
+	ProjectID  int64  `json:"project_id"`
+	Name       string `json:"name"`
+	Format     string `json:"format"`
+	ReportType string `json:"report_type"`
+	Body       string `json:"body"`
+	IsDefault  bool   `json:"is_default"`
+}
+
+func (t templateRequest) template() *models.ReportTemplate {
+	return &models.ReportTemplate{
+		ProjectID:  t.ProjectID,
+		Name:       t.Name,
+		Format:     t.Format,
+		ReportType: t.ReportType,
+		Body:       t.Body,
+		IsDefault:  t.IsDefault,
+	}
+}
+
+// decodeTemplateRequest reads a template upload, refusing bodies well past MaxTemplateSize before decoding them
+func decodeTemplateRequest(w http.ResponseWriter, r *http.Request) (templateRequest, bool) {
+	var templateRequest templateRequest
+	r.Body = http.MaxBytesReader(w, r.Body, 2*MaxTemplateSize)
+	if err := json.NewDecoder(r.Body).Decode(&templateRequest); err != nil {
+		status := http.StatusBadRequest
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			status = http.StatusRequestEntityTooLarge
+		}
+		utils.JSONErrorResponse(w, status, map[string]interface{}{
+			"status":  "error",
+			"message": "Invalid request body: " + err.Error(),
+		})
+		return templateRequest, false
+	}
+	return templateRequest, true
+}
+
+func templateErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrNotAdmin):
+		return http.StatusForbidden
+	case errors.Is(err, ErrInvalidTemplate):
+		return http.StatusUnprocessableEntity
+	case strings.Contains(err.Error(), "not found"):
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
+
+// CreateTemplate uploads an HTML or Markdown report template. Only admins may upload; project_id 0 makes it
+// an organization template.
+func (c *ReportController) CreateTemplate(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	userID, err := principal.UserIDFromRequest(r)
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusUnauthorized, map[string]interface{}{
+			"status":  "error",
+			"message": "Unauthorized: " + err.Error(),
+		})
+		return
+	}
+
+	templateRequest, ok := decodeTemplateRequest(w, r)
+	if !ok {
+		return
+	}
+
+	template := templateRequest.template()
+	if err := c.ReportService.CreateTemplate(ctx, template, userID); err != nil {
+		utils.JSONErrorResponse(w, templateErrorStatus(err), map[string]interface{}{
+			"status":  "error",
+			"message": "Failed to create report template: " + err.Error(),
+		})
+		return
+	}
+
+	utils.JSONResponse(w, http.StatusCreated, map[string]interface{}{
+		"status":  "success",
+		"message": "Report template created successfully",
+		"data":    template,
+	})
+}
+
+// ValidateTemplate checks a template against sample report data without storing it
+func (c *ReportController) ValidateTemplate(w http.ResponseWriter, r *http.Request) {
+
+	templateRequest, ok := decodeTemplateRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if err := c.ReportService.ValidateTemplate(templateRequest.template()); err != nil {
+		utils.JSONErrorResponse(w, templateErrorStatus(err), map[string]interface{}{
+			"status":  "error",
+			"message": "Report template is invalid: " + err.Error(),
+		})
+		return
+	}
+
+	utils.JSONResponse(w, http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "Report template is valid",
+	})
+}
+
+// FindTemplates lists the organization templates and, with ?project_id=, the project's own
+func (c *ReportController) FindTemplates(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	projectID, err := utils.ParseInt64Query(r, "project_id")
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": "Invalid project ID: " + err.Error(),
+		})
+		return
+	}
+
+	templates, err := c.ReportService.FindTemplates(ctx, projectID)
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusInternalServerError, map[string]interface{}{
+			"status":  "error",
+			"message": "Failed to retrieve report templates: " + err.Error(),
+		})
+		return
+	}
+
+	utils.JSONResponse(w, http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "Report templates found successfully",
+		"data":    templates,
+	})
+}
+
+func (c *ReportController) FindTemplateByID(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	templateID, err := utils.ParseInt64Param(r)
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": "Invalid template ID: " + err.Error(),
+		})
+		return
+	}
+
+	template, err := c.ReportService.FindTemplateByID(ctx, templateID)
+	if err != nil {
+		utils.JSONErrorResponse(w, templateErrorStatus(err), map[string]interface{}{
+			"status":  "error",
+			"message": "Failed to retrieve report template: " + err.Error(),
+		})
+		return
+	}
+
+	utils.JSONResponse(w, http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "Report template found successfully",
+		"data":    template,
+	})
+}
+
+func (c *ReportController) UpdateTemplate(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	userID, err := principal.UserIDFromRequest(r)
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusUnauthorized, map[string]interface{}{
+			"status":  "error",
+			"message": "Unauthorized: " + err.Error(),
+		})
+		return
+	}
+
+	templateID, err := utils.ParseInt64Param(r)
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": "Invalid template ID: " + err.Error(),
+		})
+		return
+	}
+
+	templateRequest, ok := decodeTemplateRequest(w, r)
+	if !ok {
+		return
+	}
+
+	template := templateRequest.template()
+	template.ID = templateID
+	updated, err := c.ReportService.UpdateTemplate(ctx, template, userID)
+	if err != nil {
+		utils.JSONErrorResponse(w, templateErrorStatus(err), map[string]interface{}{
+			"status":  "error",
+			"message": "Failed to update report template: " + err.Error(),
+		})
+		return
+	}
+
+	utils.JSONResponse(w, http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "Report template updated successfully",
+		"data":    updated,
+	})
+}
+
+func (c *ReportController) DeleteTemplate(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	userID, err := principal.UserIDFromRequest(r)
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusUnauthorized, map[string]interface{}{
+			"status":  "error",
+			"message": "Unauthorized: " + err.Error(),
+		})
+		return
+	}
+
+	templateID, err := utils.ParseInt64Param(r)
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": "Invalid template ID: " + err.Error(),
+		})
+		return
+	}
+
+	if err := c.ReportService.DeleteTemplate(ctx, templateID, userID); err != nil {
+		utils.JSONErrorResponse(w, templateErrorStatus(err), map[string]interface{}{
+			"status":  "error",
+			"message": "Failed to delete report template: " + err.Error(),
+		})
+		return
+	}
+
+	utils.JSONResponse(w, http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "Report template deleted successfully",
+	})
+}
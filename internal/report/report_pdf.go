@@ -0,0 +1,360 @@
+package report
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	models "github.com/BerkatPS/internal"
+	"github.com/BerkatPS/pkg/pdf"
+)
+
+// pdfAssets is what a rendered report needs besides the report itself
+type pdfAssets struct {
+	ProjectName string
+	PreparedBy  string
+	Logo        []byte // JPEG or PNG; the header has no logo when empty
+	Photos      []pdfPhotoFile
+}
+
+// pdfPhotoFile is a JPEG or PNG picture printed in the report's photo section
+type pdfPhotoFile struct {
+	Caption string
+	Content []byte
+}
+
+// renderReportPDF renders a stored report. Daily and weekly reports get a section per kind of site record;
+// reports of other types print their content as text.
+func renderReportPDF(report *models.Report, assets pdfAssets) ([]byte, error) {
+	title := fmt.Sprintf("%s report", report.Type)
+	switch report.Type {
+	case TypeDaily:
+		title = "Daily site report - " + report.ReportDate.Format("Monday 2 January 2006")
+	case TypeWeekly:
+		title = "Weekly site report - " + report.ReportDate.Format("2 Jan") + " to " + report.ReportDate.AddDate(0, 0, 6).Format("2 Jan 2006")
+	}
+
+	doc, layout := startPDF(title, assets)
+
+	layout.heading("Summary")
+	summary := [][2]string{
+		{"Report", fmt.Sprintf("#%d (%s)", report.ID, report.Type)},
+		{"Prepared by", assets.PreparedBy},
+		{"Created", report.CreationDate.Format("2006-01-02 15:04")},
+	}
+	if !report.UpdatedAt.IsZero() && report.UpdatedAt.After(report.CreationDate) {
+		summary = append(summary, [2]string{"Last updated", report.UpdatedAt.Format("2006-01-02 15:04")})
+	}
+
+	if daily := report.Daily; daily != nil {
+		summary = append(summary,
+			[2]string{"Date", daily.Date.Format("2006-01-02")},
+			[2]string{"Weather", orDash(daily.Weather)},
+			[2]string{"Compiled", daily.CompiledAt.Format("2006-01-02 15:04")},
+		)
+		layout.keyValues(summary)
+		renderDaily(layout, daily)
+	} else if weekly := report.Weekly; weekly != nil {
+		summary = append(summary,
+			[2]string{"Period", weekly.StartDate.Format("2006-01-02") + " to " + weekly.EndDate.Format("2006-01-02")},
+			[2]string{"Person-days", strconv.Itoa(weekly.AttendanceTotal)},
+			[2]string{"Expenses", fmt.Sprintf("%s (%d entries)", formatAmount(weekly.ExpenseTotal), weekly.ExpenseCount)},
+			[2]string{"Compiled", weekly.CompiledAt.Format("2006-01-02 15:04")},
+		)
+		layout.keyValues(summary)
+		renderWeekly(layout, weekly)
+	} else {
+		layout.keyValues(summary)
+		layout.heading("Content")
+		layout.paragraph(report.Content)
+	}
+
+	renderPhotos(doc, layout, assets.Photos)
+	layout.signOff(assets.PreparedBy)
+	return finishPDF(doc, layout)
+}
+
+// renderQualityReportPDF renders a quality report with its checks
+func renderQualityReportPDF(qualityReport *models.QualityReport, assets pdfAssets) ([]byte, error) {
+	title := fmt.Sprintf("Quality report - %s to %s", qualityReport.StartDate.Format("2006-01-02"), qualityReport.EndDate.Format("2006-01-02"))
+	doc, layout := startPDF(title, assets)
+
+	inspector := "All inspectors"
+	if qualityReport.Inspector != nil && qualityReport.Inspector.Username != "" {
+		inspector = qualityReport.Inspector.Username
+	} else if qualityReport.InspectorID > 0 {
+		inspector = fmt.Sprintf("User #%d", qualityReport.InspectorID)
+	}
+
+	layout.heading("Summary")
+	layout.keyValues([][2]string{
+		{"Report", fmt.Sprintf("#%d", qualityReport.ID)},
+		{"Period", qualityReport.StartDate.Format("2006-01-02") + " to " + qualityReport.EndDate.Format("2006-01-02")},
+		{"Inspector", inspector},
+		{"Overall status", orDash(qualityReport.OverallStatus)},
+		{"Checks", strconv.Itoa(len(qualityReport.QualityChecks))},
+		{"Generated", qualityReport.GeneratedDate.Format("2006-01-02 15:04")},
+	})
+
+	if qualityReport.Comments != "" {
+		layout.heading("Comments")
+		layout.paragraph(qualityReport.Comments)
+	}
+
+	counts := make(map[string]int)
+	for _, check := range qualityReport.QualityChecks {
+		counts[check.Status]++
+	}
+	layout.heading("Results by status")
+	layout.table([]pdfColumn{{Title: "Status", Width: 0.7}, {Title: "Checks", Width: 0.3, Right: true}}, countRows(counts))
+
+	layout.heading("Quality checks")
+	rows := make([][]string, 0, len(qualityReport.QualityChecks))
+	for _, check := range qualityReport.QualityChecks {
+		rows = append(rows, []string{
+			check.Date.Format("2006-01-02"),
+			strconv.FormatInt(check.ID, 10),
+			userLabel(check.Inspector, check.InspectorID),
+			check.Status,
+			check.Comments,
+		})
+	}
+	layout.table([]pdfColumn{
+		{Title: "Date", Width: 0.14},
+		{Title: "Check", Width: 0.1},
+		{Title: "Inspector", Width: 0.18},
+		{Title: "Status", Width: 0.16},
+		{Title: "Comments", Width: 0.42},
+	}, rows)
+
+	renderPhotos(doc, layout, assets.Photos)
+	layout.signOff(assets.PreparedBy)
+	return finishPDF(doc, layout)
+}
+
+func renderDaily(layout *pdfLayout, daily *models.DailySiteReport) {
+	if daily.Notes != "" {
+		layout.heading("Notes")
+		layout.paragraph(daily.Notes)
+	}
+
+	layout.heading(fmt.Sprintf("Attendance (%d)", daily.Attendance.Total))
+	attendance := make([][]string, 0, len(daily.Attendance.Entries))
+	for _, presence := range daily.Attendance.Entries {
+		attendance = append(attendance, []string{
+			userLabel(presence.User, presence.UserID),
+			presence.Status,
+			presence.Date.Format("15:04"),
+			presence.Comments,
+		})
+	}
+	layout.table([]pdfColumn{
+		{Title: "Worker", Width: 0.25},
+		{Title: "Status", Width: 0.15},
+		{Title: "Time", Width: 0.1},
+		{Title: "Comments", Width: 0.5},
+	}, attendance)
+
+	taskColumns := []pdfColumn{
+		{Title: "Task", Width: 0.4},
+		{Title: "Status", Width: 0.16},
+		{Title: "Planned", Width: 0.26},
+		{Title: "At", Width: 0.18},
+	}
+	taskRows := func(tasks []models.Task, at func(models.Task) time.Time) [][]string {
+		rows := make([][]string, 0, len(tasks))
+		for _, task := range tasks {
+			rows = append(rows, []string{
+				task.Name,
+				task.Status,
+				task.StartDate.Format("2006-01-02") + " - " + task.EndDate.Format("2006-01-02"),
+				at(task).Format("15:04"),
+			})
+		}
+		return rows
+	}
+	layout.heading(fmt.Sprintf("Tasks started (%d)", len(daily.TasksStarted)))
+	layout.table(taskColumns, taskRows(daily.TasksStarted, func(task models.Task) time.Time { return task.StartedAt }))
+	layout.heading(fmt.Sprintf("Tasks completed (%d)", len(daily.TasksCompleted)))
+	layout.table(taskColumns, taskRows(daily.TasksCompleted, func(task models.Task) time.Time { return task.CompletedAt }))
+
+	layout.heading(fmt.Sprintf("Expenses (%d)", len(daily.Expenses)))
+	expenses := make([][]string, 0, len(daily.Expenses)+1)
+	for _, expense := range daily.Expenses {
+		expenses = append(expenses, []string{expense.Date.Format("15:04"), expense.Description, formatAmount(expense.Amount)})
+	}
+	if len(expenses) > 0 {
+		expenses = append(expenses, []string{"", "Total", formatAmount(daily.ExpenseTotal)})
+	}
+	layout.table([]pdfColumn{
+		{Title: "Time", Width: 0.12},
+		{Title: "Description", Width: 0.63},
+		{Title: "Amount", Width: 0.25, Right: true},
+	}, expenses)
+
+	layout.heading(fmt.Sprintf("Quality checks (%d)", len(daily.QualityChecks)))
+	checks := make([][]string, 0, len(daily.QualityChecks))
+	for _, check := range daily.QualityChecks {
+		checks = append(checks, []string{strconv.FormatInt(check.ID, 10), userLabel(check.Inspector, check.InspectorID), check.Status, check.Comments})
+	}
+	layout.table([]pdfColumn{
+		{Title: "Check", Width: 0.1},
+		{Title: "Inspector", Width: 0.2},
+		{Title: "Status", Width: 0.18},
+		{Title: "Comments", Width: 0.52},
+	}, checks)
+
+	layout.heading(fmt.Sprintf("Safety incidents (%d)", len(daily.SafetyIncidents)))
+	incidents := make([][]string, 0, len(daily.SafetyIncidents))
+	for _, incident := range daily.SafetyIncidents {
+		incidents = append(incidents, []string{incident.Date.Format("15:04"), incident.Type, incident.Severity, incident.Status, incident.Description})
+	}
+	layout.table([]pdfColumn{
+		{Title: "Time", Width: 0.1},
+		{Title: "Type", Width: 0.16},
+		{Title: "Severity", Width: 0.13},
+		{Title: "Status", Width: 0.15},
+		{Title: "Description", Width: 0.46},
+	}, incidents)
+}
+
+func renderWeekly(layout *pdfLayout, weekly *models.WeeklySiteReport) {
+	layout.heading("Day by day")
+	days := make([][]string, 0, len(weekly.Days))
+	for _, day := range weekly.Days {
+		days = append(days, []string{
+			day.Date.Format("Mon 2 Jan"),
+			strconv.Itoa(day.Attendance),
+			strconv.Itoa(day.TasksStarted),
+			strconv.Itoa(day.TasksCompleted),
+			formatAmount(day.ExpenseTotal),
+			strconv.Itoa(day.QualityChecks),
+			strconv.Itoa(day.SafetyIncidents),
+		})
+	}
+	layout.table([]pdfColumn{
+		{Title: "Day", Width: 0.16},
+		{Title: "Attendance", Width: 0.13, Right: true},
+		{Title: "Started", Width: 0.11, Right: true},
+		{Title: "Completed", Width: 0.13, Right: true},
+		{Title: "Expenses", Width: 0.19, Right: true},
+		{Title: "Checks", Width: 0.12, Right: true},
+		{Title: "Incidents", Width: 0.16, Right: true},
+	}, days)
+
+	layout.heading("Attendance by status")
+	layout.table([]pdfColumn{{Title: "Status", Width: 0.7}, {Title: "Person-days", Width: 0.3, Right: true}}, countRows(weekly.AttendanceByStatus))
+
+	taskRows := func(tasks []models.Task, at func(models.Task) time.Time) [][]string {
+		rows := make([][]string, 0, len(tasks))
+		for _, task := range tasks {
+			rows = append(rows, []string{task.Name, task.Status, at(task).Format("Mon 2 Jan 15:04")})
+		}
+		return rows
+	}
+	taskColumns := []pdfColumn{{Title: "Task", Width: 0.5}, {Title: "Status", Width: 0.2}, {Title: "At", Width: 0.3}}
+	layout.heading(fmt.Sprintf("Tasks started (%d)", len(weekly.TasksStarted)))
+	layout.table(taskColumns, taskRows(weekly.TasksStarted, func(task models.Task) time.Time { return task.StartedAt }))
+	layout.heading(fmt.Sprintf("Tasks completed (%d)", len(weekly.TasksCompleted)))
+	layout.table(taskColumns, taskRows(weekly.TasksCompleted, func(task models.Task) time.Time { return task.CompletedAt }))
+
+	layout.heading("Quality checks by status")
+	layout.table([]pdfColumn{{Title: "Status", Width: 0.7}, {Title: "Checks", Width: 0.3, Right: true}}, countRows(weekly.QualitySummary))
+
+	layout.heading(fmt.Sprintf("Safety incidents (%d)", len(weekly.SafetyIncidents)))
+	incidents := make([][]string, 0, len(weekly.SafetyIncidents))
+	for _, incident := range weekly.SafetyIncidents {
+		incidents = append(incidents, []string{incident.Date.Format("Mon 2 Jan"), incident.Type, incident.Severity, incident.Status, incident.Description})
+	}
+	layout.table([]pdfColumn{
+		{Title: "Day", Width: 0.14},
+		{Title: "Type", Width: 0.15},
+		{Title: "Severity", Width: 0.13},
+		{Title: "Status", Width: 0.14},
+		{Title: "Description", Width: 0.44},
+	}, incidents)
+}
+
+func startPDF(title string, assets pdfAssets) (*pdf.Document, *pdfLayout) {
+	doc := pdf.New()
+	doc.Title = assets.ProjectName + " - " + title
+	doc.Author = assets.PreparedBy
+
+	// A logo that cannot be decoded is left out rather than failing the whole report
+	var logo *pdf.Image
+	if len(assets.Logo) > 0 {
+		logo, _ = doc.AddImage(assets.Logo)
+	}
+	return doc, newPDFLayout(doc, assets.ProjectName, title, logo)
+}
+
+func renderPhotos(doc *pdf.Document, layout *pdfLayout, photos []pdfPhotoFile) {
+	var images []pdfPhoto
+	for _, photo := range photos {
+		img, err := doc.AddImage(photo.Content)
+		if err != nil {
+			continue
+		}
+		images = append(images, pdfPhoto{Image: img, Caption: photo.Caption})
+	}
+	if len(images) == 0 {
+		return
+	}
+
+	layout.heading(fmt.Sprintf("Photos (%d)", len(images)))
+	layout.photos(images)
+}
+
+func finishPDF(doc *pdf.Document, layout *pdfLayout) ([]byte, error) {
+	layout.finish(time.Now())
+
+	var out bytes.Buffer
+	if _, err := doc.WriteTo(&out); err != nil {
+		return nil, fmt.Errorf("failed to write PDF: %v", err)
+	}
+	return out.Bytes(), nil
+}
+
+func countRows(counts map[string]int) [][]string {
+	statuses := make([]string, 0, len(counts))
+	for status := range counts {
+		statuses = append(statuses, status)
+	}
+	sort.Strings(statuses)
+
+	rows := make([][]string, 0, len(statuses))
+	for _, status := range statuses {
+		rows = append(rows, []string{status, strconv.Itoa(counts[status])})
+	}
+	return rows
+}
+
+func userLabel(user *models.User, userID int64) string {
+	if user != nil && user.Username != "" {
+		return user.Username
+	}
+	return fmt.Sprintf("User #%d", userID)
+}
+
+// formatAmount prints an amount with thousands separators and two decimals, e.g. 1,250,000.00
+func formatAmount(amount float64) string {
+	sign := ""
+	if amount < 0 {
+		sign, amount = "-", -amount
+	}
+	whole := strconv.FormatFloat(amount, 'f', 2, 64)
+	integer, fraction := whole[:len(whole)-3], whole[len(whole)-3:]
+	for i := len(integer) - 3; i > 0; i -= 3 {
+		integer = integer[:i] + "," + integer[i:]
+	}
+	return sign + integer + fraction
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
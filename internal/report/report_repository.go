@@ -0,0 +1,512 @@
+package report
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	models "github.com/BerkatPS/internal"
+)
+
+const selectReportColumns = "SELECT id, project_id, type, content, created_by, creation_date, report_date, updated_by, updated_at FROM reports"
+
+const selectTemplateColumns = `SELECT id, project_id, name, format, report_type, body, is_default, created_by, created_at, updated_by, updated_at
+	FROM report_templates`
+
+type ReportRepository interface {
+	CreateReport(ctx context.Context, report *models.Report) error
+	FindReportByID(ctx context.Context, id int64) (*models.Report, error)
+	// FindReportByDate returns the project's report of the given type for the day, or nil if there is none
+	FindReportByDate(ctx context.Context, projectID int64, reportType string, day time.Time) (*models.Report, error)
+	FindReportsByProjectID(ctx context.Context, projectID int64, reportType string) ([]models.Report, error)
+	UpdateReportContent(ctx context.Context, report *models.Report) error
+	FindProjectName(ctx context.Context, projectID int64) (string, error)
+	// FindActiveProjectIDs returns the projects that are not completed, cancelled or archived
+	FindActiveProjectIDs(ctx context.Context) ([]int64, error)
+	FindUsername(ctx context.Context, userID int64) (string, error)
+	// FindProjectLogo returns the project's most recent image document of type "logo", or nil if it has none
+	FindProjectLogo(ctx context.Context, projectID int64) (*models.Document, error)
+	// FindProjectPhotos returns up to limit active JPEG and PNG documents uploaded to the project in the range, oldest first
+	FindProjectPhotos(ctx context.Context, projectID int64, from, to time.Time, limit int) ([]models.Document, error)
+
+	// The queries below return what was recorded on a project between from (inclusive) and to (exclusive)
+	FindPresences(ctx context.Context, projectID int64, from, to time.Time) ([]models.Presence, error)
+	FindTasksStarted(ctx context.Context, projectID int64, from, to time.Time) ([]models.Task, error)
+	FindTasksCompleted(ctx context.Context, projectID int64, from, to time.Time) ([]models.Task, error)
+	FindExpenses(ctx context.Context, projectID int64, from, to time.Time) ([]models.Expense, error)
+	FindQualityChecks(ctx context.Context, projectID int64, from, to time.Time) ([]models.QualityCheck, error)
+	FindSafetyIncidents(ctx context.Context, projectID int64, from, to time.Time) ([]models.SafetyIncident, error)
+
+	// FindTemplateProject returns the project details printed by report templates
+	FindTemplateProject(ctx context.Context, projectID int64) (models.TemplateProject, error)
+	FindUserRole(ctx context.Context, userID int64) (string, error)
+	// CreateTemplate stores a template. A default template replaces the previous default of its project and report type.
+	CreateTemplate(ctx context.Context, template *models.ReportTemplate) error
+	// UpdateTemplate saves the editable fields of a template, with the same default handling as CreateTemplate
+	UpdateTemplate(ctx context.Context, template *models.ReportTemplate) error
+	DeleteTemplate(ctx context.Context, id int64) error
+	FindTemplateByID(ctx context.Context, id int64) (*models.ReportTemplate, error)
+	// FindTemplates lists the organization's templates and, when projectID is set, the project's own
+	FindTemplates(ctx context.Context, projectID int64) ([]models.ReportTemplate, error)
+	// FindDefaultTemplate returns the default template of the given format for a report, preferring the project's
+	// over the organization's and one made for the report type over one for any type. It returns nil if there is none.
+	FindDefaultTemplate(ctx context.Context, projectID int64, reportType, format string) (*models.ReportTemplate, error)
+}
+
+type reportRepository struct {
+	db *sql.DB
+}
+
+func NewReportRepository(db *sql.DB) ReportRepository {
+	return &reportRepository{db}
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanTemplate(row rowScanner, template *models.ReportTemplate) error {
+	return row.Scan(&template.ID, &template.ProjectID, &template.Name, &template.Format, &template.ReportType, &template.Body, &template.IsDefault,
+		&template.CreatedBy, &template.CreatedAt, &template.UpdatedBy, &template.UpdatedAt)
+}
+
+func scanReport(row rowScanner, report *models.Report) error {
+	return row.Scan(&report.ID, &report.ProjectID, &report.Type, &report.Content, &report.CreatedBy, &report.CreationDate,
+		&report.ReportDate, &report.UpdatedBy, &report.UpdatedAt)
+}
+
+func (r *reportRepository) CreateReport(ctx context.Context, report *models.Report) error {
+	query := `INSERT INTO reports (project_id, type, content, created_by, creation_date, report_date, updated_by, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id`
+
+	return r.db.QueryRowContext(ctx, query, report.ProjectID, report.Type, report.Content, report.CreatedBy, report.CreationDate,
+		report.ReportDate, report.UpdatedBy, report.UpdatedAt).Scan(&report.ID)
+}
+
+func (r *reportRepository) FindReportByID(ctx context.Context, id int64) (*models.Report, error) {
+	var report models.Report
+	if err := scanReport(r.db.QueryRowContext(ctx, selectReportColumns+" WHERE id = $1", id), &report); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("report not found")
+		}
+		return nil, err
+	}
+	return &report, nil
+}
+
+func (r *reportRepository) FindReportByDate(ctx context.Context, projectID int64, reportType string, day time.Time) (*models.Report, error) {
+	query := selectReportColumns + " WHERE project_id = $1 AND type = $2 AND report_date = $3 ORDER BY id LIMIT 1"
+
+	var report models.Report
+	if err := scanReport(r.db.QueryRowContext(ctx, query, projectID, reportType, day), &report); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &report, nil
+}
+
+func (r *reportRepository) FindReportsByProjectID(ctx context.Context, projectID int64, reportType string) ([]models.Report, error) {
+	query := selectReportColumns + " WHERE project_id = $1 AND ($2 = '' OR type = $2) ORDER BY report_date DESC, id DESC"
+
+	rows, err := r.db.QueryContext(ctx, query, projectID, reportType)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var reports []models.Report
+	for rows.Next() {
+		var report models.Report
+		if err := scanReport(rows, &report); err != nil {
+			return nil, err
+		}
+		reports = append(reports, report)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return reports, nil
+}
+
+func (r *reportRepository) UpdateReportContent(ctx context.Context, report *models.Report) error {
+	query := "UPDATE reports SET content = $1, updated_by = $2, updated_at = $3 WHERE id = $4"
+
+	result, err := r.db.ExecContext(ctx, query, report.Content, report.UpdatedBy, report.UpdatedAt, report.ID)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return errors.New("report not found")
+	}
+	return nil
+}
+
+func (r *reportRepository) FindProjectName(ctx context.Context, projectID int64) (string, error) {
+	var name string
+	if err := r.db.QueryRowContext(ctx, "SELECT name FROM projects WHERE id = $1", projectID).Scan(&name); err != nil {
+		if err == sql.ErrNoRows {
+			return "", errors.New("project not found")
+		}
+		return "", err
+	}
+	return name, nil
+}
+
+func (r *reportRepository) FindActiveProjectIDs(ctx context.Context) ([]int64, error) {
+	query := "SELECT id FROM projects WHERE UPPER(COALESCE(status, '')) NOT IN ('COMPLETED', 'CANCELLED', 'ARCHIVED', 'CLOSED') ORDER BY id"
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+func (r *reportRepository) FindUsername(ctx context.Context, userID int64) (string, error) {
+	var username string
+	if err := r.db.QueryRowContext(ctx, "SELECT username FROM users WHERE id = $1", userID).Scan(&username); err != nil {
+		if err == sql.ErrNoRows {
+			return "", errors.New("user not found")
+		}
+		return "", err
+	}
+	return username, nil
+}
+
+// selectImageDocumentColumns tolerates documents uploaded before file metadata was recorded
+const selectImageDocumentColumns = `SELECT id, project_id, name, type, COALESCE(file_name, ''), COALESCE(mime_type, ''),
+	COALESCE(storage_key, ''), upload_date FROM documents
+	WHERE project_id = $1 AND COALESCE(status, '') <> 'ARCHIVED' AND COALESCE(storage_key, '') <> ''
+	AND mime_type IN ('image/jpeg', 'image/png')`
+
+func (r *reportRepository) FindProjectLogo(ctx context.Context, projectID int64) (*models.Document, error) {
+	query := selectImageDocumentColumns + " AND LOWER(type) = 'logo' ORDER BY upload_date DESC, id DESC LIMIT 1"
+
+	documents, err := r.queryImageDocuments(ctx, query, projectID)
+	if err != nil || len(documents) == 0 {
+		return nil, err
+	}
+	return &documents[0], nil
+}
+
+func (r *reportRepository) FindProjectPhotos(ctx context.Context, projectID int64, from, to time.Time, limit int) ([]models.Document, error) {
+	query := selectImageDocumentColumns + ` AND LOWER(type) <> 'logo' AND upload_date >= $2 AND upload_date < $3
+		ORDER BY upload_date, id LIMIT $4`
+
+	return r.queryImageDocuments(ctx, query, projectID, from, to, limit)
+}
+
+func (r *reportRepository) queryImageDocuments(ctx context.Context, query string, args ...interface{}) ([]models.Document, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var documents []models.Document
+	for rows.Next() {
+		var document models.Document
+		if err := rows.Scan(&document.ID, &document.ProjectID, &document.Name, &document.Type, &document.FileName, &document.MimeType,
+			&document.StorageKey, &document.UploadDate); err != nil {
+			return nil, err
+		}
+		documents = append(documents, document)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return documents, nil
+}
+
+func (r *reportRepository) FindPresences(ctx context.Context, projectID int64, from, to time.Time) ([]models.Presence, error) {
+	query := `SELECT p.id, p.user_id, p.project_id, p.status, p.comments, p.date, COALESCE(u.username, '')
+		FROM presences p LEFT JOIN users u ON u.id = p.user_id
+		WHERE p.project_id = $1 AND p.date >= $2 AND p.date < $3 ORDER BY p.date, p.id`
+
+	rows, err := r.db.QueryContext(ctx, query, projectID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var presences []models.Presence
+	for rows.Next() {
+		var presence models.Presence
+		var username string
+		if err := rows.Scan(&presence.ID, &presence.UserID, &presence.ProjectID, &presence.Status, &presence.Comments, &presence.Date, &username); err != nil {
+			return nil, err
+		}
+		presence.User = &models.User{ID: presence.UserID, Username: username}
+		presences = append(presences, presence)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return presences, nil
+}
+
+func (r *reportRepository) FindTasksStarted(ctx context.Context, projectID int64, from, to time.Time) ([]models.Task, error) {
+	return r.queryTasks(ctx, "started_at", projectID, from, to)
+}
+
+func (r *reportRepository) FindTasksCompleted(ctx context.Context, projectID int64, from, to time.Time) ([]models.Task, error) {
+	return r.queryTasks(ctx, "completed_at", projectID, from, to)
+}
+
+// queryTasks lists the project's tasks whose timestamp column falls in the range. column is never user input.
+func (r *reportRepository) queryTasks(ctx context.Context, column string, projectID int64, from, to time.Time) ([]models.Task, error) {
+	query := `SELECT id, project_id, name, description, status, start_date, end_date, COALESCE(assigned_to_id, 0),
+		COALESCE(started_at, '0001-01-01'::timestamp), COALESCE(completed_at, '0001-01-01'::timestamp)
+		FROM tasks WHERE project_id = $1 AND ` + column + ` >= $2 AND ` + column + ` < $3 ORDER BY ` + column + `, id`
+
+	rows, err := r.db.QueryContext(ctx, query, projectID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tasks []models.Task
+	for rows.Next() {
+		var task models.Task
+		if err := rows.Scan(&task.ID, &task.ProjectID, &task.Name, &task.Description, &task.Status, &task.StartDate, &task.EndDate,
+			&task.AssignedToID, &task.StartedAt, &task.CompletedAt); err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, task)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return tasks, nil
+}
+
+func (r *reportRepository) FindExpenses(ctx context.Context, projectID int64, from, to time.Time) ([]models.Expense, error) {
+	query := `SELECT id, project_id, description, amount, date, COALESCE(approved_by, 0) FROM expenses
+		WHERE project_id = $1 AND date >= $2 AND date < $3 ORDER BY date, id`
+
+	rows, err := r.db.QueryContext(ctx, query, projectID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var expenses []models.Expense
+	for rows.Next() {
+		var expense models.Expense
+		if err := rows.Scan(&expense.ID, &expense.ProjectID, &expense.Description, &expense.Amount, &expense.Date, &expense.ApprovedBy); err != nil {
+			return nil, err
+		}
+		expenses = append(expenses, expense)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return expenses, nil
+}
+
+func (r *reportRepository) FindQualityChecks(ctx context.Context, projectID int64, from, to time.Time) ([]models.QualityCheck, error) {
+	query := `SELECT id, project_id, inspector_id, date, status, comments FROM quality_checks
+		WHERE project_id = $1 AND date >= $2 AND date < $3 ORDER BY date, id`
+
+	rows, err := r.db.QueryContext(ctx, query, projectID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var checks []models.QualityCheck
+	for rows.Next() {
+		var check models.QualityCheck
+		if err := rows.Scan(&check.ID, &check.ProjectID, &check.InspectorID, &check.Date, &check.Status, &check.Comments); err != nil {
+			return nil, err
+		}
+		checks = append(checks, check)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return checks, nil
+}
+
+func (r *reportRepository) FindSafetyIncidents(ctx context.Context, projectID int64, from, to time.Time) ([]models.SafetyIncident, error) {
+	query := `SELECT id, project_id, reporter_id, anonymous, date, description, severity, type, status FROM safety_incidents
+		WHERE project_id = $1 AND date >= $2 AND date < $3 ORDER BY date, id`
+
+	rows, err := r.db.QueryContext(ctx, query, projectID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var incidents []models.SafetyIncident
+	for rows.Next() {
+		var incident models.SafetyIncident
+		if err := rows.Scan(&incident.ID, &incident.ProjectID, &incident.ReporterID, &incident.Anonymous, &incident.Date,
+			&incident.Description, &incident.Severity, &incident.Type, &incident.Status); err != nil {
+			return nil, err
+		}
+		incidents = append(incidents, incident)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return incidents, nil
+}
+
+func (r *reportRepository) FindTemplateProject(ctx context.Context, projectID int64) (models.TemplateProject, error) {
+	query := `SELECT p.id, p.name, COALESCE(p.description, ''), COALESCE(p.status, ''), COALESCE(p.budget, 0), COALESCE(u.username, '')
+		FROM projects p LEFT JOIN users u ON u.id = p.manager_id WHERE p.id = $1`
+
+	var project models.TemplateProject
+	err := r.db.QueryRowContext(ctx, query, projectID).Scan(&project.ID, &project.Name, &project.Description, &project.Status, &project.Budget, &project.Manager)
+	if err == sql.ErrNoRows {
+		return project, errors.New("project not found")
+	}
+	return project, err
+}
+
+func (r *reportRepository) FindUserRole(ctx context.Context, userID int64) (string, error) {
+	var role string
+	if err := r.db.QueryRowContext(ctx, "SELECT COALESCE(role, '') FROM users WHERE id = $1", userID).Scan(&role); err != nil {
+		if err == sql.ErrNoRows {
+			return "", errors.New("user not found")
+		}
+		return "", err
+	}
+	return role, nil
+}
+
+func (r *reportRepository) CreateTemplate(ctx context.Context, template *models.ReportTemplate) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := clearDefaultTemplate(ctx, tx, template); err != nil {
+		return err
+	}
+
+	query := `INSERT INTO report_templates (project_id, name, format, report_type, body, is_default, created_by, created_at, updated_by, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $7, $8) RETURNING id`
+	if err := tx.QueryRowContext(ctx, query, template.ProjectID, template.Name, template.Format, template.ReportType, template.Body, template.IsDefault,
+		template.CreatedBy, template.CreatedAt).Scan(&template.ID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *reportRepository) UpdateTemplate(ctx context.Context, template *models.ReportTemplate) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := clearDefaultTemplate(ctx, tx, template); err != nil {
+		return err
+	}
+
+	query := `UPDATE report_templates SET name = $1, format = $2, report_type = $3, body = $4, is_default = $5, updated_by = $6, updated_at = $7
+		WHERE id = $8`
+	result, err := tx.ExecContext(ctx, query, template.Name, template.Format, template.ReportType, template.Body, template.IsDefault,
+		template.UpdatedBy, template.UpdatedAt, template.ID)
+	if err != nil {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return errors.New("report template not found")
+	}
+
+	return tx.Commit()
+}
+
+// clearDefaultTemplate unsets the current default of the template's project, report type and format when the template
+// becomes the default, so there is never more than one to choose from
+func clearDefaultTemplate(ctx context.Context, tx *sql.Tx, template *models.ReportTemplate) error {
+	if !template.IsDefault {
+		return nil
+	}
+	query := `UPDATE report_templates SET is_default = FALSE
+		WHERE project_id = $1 AND report_type = $2 AND format = $3 AND is_default AND id <> $4`
+	_, err := tx.ExecContext(ctx, query, template.ProjectID, template.ReportType, template.Format, template.ID)
+	return err
+}
+
+func (r *reportRepository) DeleteTemplate(ctx context.Context, id int64) error {
+	result, err := r.db.ExecContext(ctx, "DELETE FROM report_templates WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return errors.New("report template not found")
+	}
+	return nil
+}
+
+func (r *reportRepository) FindTemplateByID(ctx context.Context, id int64) (*models.ReportTemplate, error) {
+	var template models.ReportTemplate
+	if err := scanTemplate(r.db.QueryRowContext(ctx, selectTemplateColumns+" WHERE id = $1", id), &template); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("report template not found")
+		}
+		return nil, err
+	}
+	return &template, nil
+}
+
+func (r *reportRepository) FindTemplates(ctx context.Context, projectID int64) ([]models.ReportTemplate, error) {
+	query := selectTemplateColumns + " WHERE project_id = 0 OR project_id = $1 ORDER BY project_id DESC, name, id"
+
+	rows, err := r.db.QueryContext(ctx, query, projectID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var templates []models.ReportTemplate
+	for rows.Next() {
+		var template models.ReportTemplate
+		if err := scanTemplate(rows, &template); err != nil {
+			return nil, err
+		}
+		templates = append(templates, template)
+	}
+	return templates, rows.Err()
+}
+
+func (r *reportRepository) FindDefaultTemplate(ctx context.Context, projectID int64, reportType, format string) (*models.ReportTemplate, error) {
+	query := selectTemplateColumns + `
+		WHERE is_default AND format = $1 AND (project_id = $2 OR project_id = 0) AND (report_type = $3 OR report_type = '')
+		ORDER BY project_id DESC, report_type DESC LIMIT 1`
+
+	var template models.ReportTemplate
+	if err := scanTemplate(r.db.QueryRowContext(ctx, query, format, projectID, reportType), &template); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &template, nil
+}
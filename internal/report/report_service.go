@@ -0,0 +1,566 @@
+package report
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"time"
+
+	models "github.com/BerkatPS/internal"
+	"github.com/BerkatPS/pkg/storage"
+)
+
+const (
+	TypeDaily  = "daily"
+	TypeWeekly = "weekly"
+)
+
+// MaxPDFImageSize is the largest logo or photo embedded in a PDF; bigger files are left out
+const MaxPDFImageSize = 20 << 20
+
+// ErrNotDailyReport is returned when a daily-report operation is applied to a report of another type
+var ErrNotDailyReport = errors.New("report is not a daily report")
+
+type ReportService interface {
+	// CompileDailyReport gathers what was recorded on the project that day into its daily report. The first call
+	// creates the report; later calls recompile the figures and keep the weather and notes written by the site team.
+	CompileDailyReport(ctx context.Context, projectID int64, day time.Time, actorID int64) (*models.Report, error)
+	// FindReportByID returns the report, with daily reports decoded into Daily
+	FindReportByID(ctx context.Context, id int64) (*models.Report, error)
+	FindReportsByProjectID(ctx context.Context, projectID int64, reportType string) ([]models.Report, error)
+	// UpdateDailyReport replaces the weather and notes of a daily report
+	UpdateDailyReport(ctx context.Context, id, actorID int64, weather, notes string) (*models.Report, error)
+	// CompileWeeklyReport summarises the seven days from weekStart into the project's weekly report, replacing
+	// the figures of an earlier compilation of the same week. actorID is 0 when the scheduler compiles it.
+	CompileWeeklyReport(ctx context.Context, projectID int64, weekStart time.Time, actorID int64) (*models.Report, error)
+	// CompileWeeklyReports compiles the week's report for every active project and returns how many were compiled
+	CompileWeeklyReports(ctx context.Context, weekStart time.Time) (int, error)
+	// RenderReportPDF renders a stored report with the project's logo and the photos uploaded during the period it covers
+	RenderReportPDF(ctx context.Context, id int64) ([]byte, *models.Report, error)
+	// RenderQualityReportPDF renders a quality report with the project's logo and its attached photos,
+	// or the photos uploaded during its period when none are attached
+	RenderQualityReportPDF(ctx context.Context, qualityReport *models.QualityReport) ([]byte, error)
+
+	// CreateTemplate validates and stores a report template uploaded by an admin. ProjectID 0 makes it available
+	// to every project.
+	CreateTemplate(ctx context.Context, template *models.ReportTemplate, actorID int64) error
+	// UpdateTemplate replaces the name, format, report type, body and default flag of a template
+	UpdateTemplate(ctx context.Context, template *models.ReportTemplate, actorID int64) (*models.ReportTemplate, error)
+	DeleteTemplate(ctx context.Context, id, actorID int64) error
+	FindTemplateByID(ctx context.Context, id int64) (*models.ReportTemplate, error)
+	// FindTemplates returns the organization-wide templates and, when projectID is set, the project's own
+	FindTemplates(ctx context.Context, projectID int64) ([]models.ReportTemplate, error)
+	// ValidateTemplate checks a template the way an upload would without storing it
+	ValidateTemplate(template *models.ReportTemplate) error
+	// RenderReportTemplate renders a stored report through templateID, or through the default template of the
+	// report's project and type when templateID is 0. format is the template format the caller asked for.
+	RenderReportTemplate(ctx context.Context, reportID, templateID int64, format string) ([]byte, *models.Report, error)
+}
+
+type reportService struct {
+	ReportRepo ReportRepository
+	Blobs      storage.BlobStore
+}
+
+// NewReportService creates a ReportService. blobs holds the logo and photo documents printed in PDFs.
+func NewReportService(reportRepo ReportRepository, blobs storage.BlobStore) ReportService {
+	return &reportService{reportRepo, blobs}
+}
+
+func (s *reportService) CompileDailyReport(ctx context.Context, projectID int64, day time.Time, actorID int64) (*models.Report, error) {
+	if projectID <= 0 {
+		return nil, fmt.Errorf("invalid project ID")
+	}
+
+	if actorID <= 0 {
+		return nil, fmt.Errorf("invalid user ID")
+	}
+
+	if _, err := s.ReportRepo.FindProjectName(ctx, projectID); err != nil {
+		return nil, fmt.Errorf("failed to retrieve project: %v", err)
+	}
+
+	day = time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, day.Location())
+	if day.After(time.Now()) {
+		return nil, fmt.Errorf("cannot compile a daily report for a future date")
+	}
+
+	existing, err := s.ReportRepo.FindReportByDate(ctx, projectID, TypeDaily, day)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve existing report: %v", err)
+	}
+
+	daily, err := s.compileDaily(ctx, projectID, day)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if existing == nil {
+		report := &models.Report{
+			ProjectID:    projectID,
+			Type:         TypeDaily,
+			CreatedBy:    actorID,
+			CreationDate: now,
+			ReportDate:   day,
+			UpdatedBy:    actorID,
+			UpdatedAt:    now,
+			Daily:        daily,
+		}
+		if err := encodeDaily(report); err != nil {
+			return nil, err
+		}
+		if err := s.ReportRepo.CreateReport(ctx, report); err != nil {
+			return nil, fmt.Errorf("failed to create report: %v", err)
+		}
+		return report, nil
+	}
+
+	if err := decodeDaily(existing); err != nil {
+		return nil, err
+	}
+	daily.Weather = existing.Daily.Weather
+	daily.Notes = existing.Daily.Notes
+
+	existing.Daily = daily
+	existing.UpdatedBy = actorID
+	existing.UpdatedAt = now
+	if err := s.saveDaily(ctx, existing); err != nil {
+		return nil, err
+	}
+	return existing, nil
+}
+
+func (s *reportService) CompileWeeklyReport(ctx context.Context, projectID int64, weekStart time.Time, actorID int64) (*models.Report, error) {
+	if projectID <= 0 {
+		return nil, fmt.Errorf("invalid project ID")
+	}
+
+	if actorID < 0 {
+		return nil, fmt.Errorf("invalid user ID")
+	}
+
+	if _, err := s.ReportRepo.FindProjectName(ctx, projectID); err != nil {
+		return nil, fmt.Errorf("failed to retrieve project: %v", err)
+	}
+
+	weekStart = time.Date(weekStart.Year(), weekStart.Month(), weekStart.Day(), 0, 0, 0, 0, weekStart.Location())
+	if weekStart.After(time.Now()) {
+		return nil, fmt.Errorf("cannot compile a weekly report for a future week")
+	}
+
+	weekly, err := s.compileWeekly(ctx, projectID, weekStart)
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := s.ReportRepo.FindReportByDate(ctx, projectID, TypeWeekly, weekStart)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve existing report: %v", err)
+	}
+
+	now := time.Now()
+	report := existing
+	if report == nil {
+		report = &models.Report{
+			ProjectID:    projectID,
+			Type:         TypeWeekly,
+			CreatedBy:    actorID,
+			CreationDate: now,
+			ReportDate:   weekStart,
+		}
+	}
+	report.Weekly = weekly
+	report.UpdatedBy = actorID
+	report.UpdatedAt = now
+
+	content, err := json.Marshal(weekly)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode weekly report: %v", err)
+	}
+	report.Content = string(content)
+
+	if existing == nil {
+		err = s.ReportRepo.CreateReport(ctx, report)
+	} else {
+		err = s.ReportRepo.UpdateReportContent(ctx, report)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to save report: %v", err)
+	}
+	return report, nil
+}
+
+func (s *reportService) CompileWeeklyReports(ctx context.Context, weekStart time.Time) (int, error) {
+	projectIDs, err := s.ReportRepo.FindActiveProjectIDs(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to retrieve projects: %v", err)
+	}
+
+	// One project's failure should not cost every other project its report
+	var failed []string
+	compiled := 0
+	for _, projectID := range projectIDs {
+		if _, err := s.CompileWeeklyReport(ctx, projectID, weekStart, 0); err != nil {
+			failed = append(failed, fmt.Sprintf("project %d: %v", projectID, err))
+			continue
+		}
+		compiled++
+	}
+
+	if len(failed) > 0 {
+		return compiled, fmt.Errorf("failed to compile %d weekly reports: %s", len(failed), strings.Join(failed, "; "))
+	}
+	return compiled, nil
+}
+
+func (s *reportService) FindReportByID(ctx context.Context, id int64) (*models.Report, error) {
+	if id <= 0 {
+		return nil, fmt.Errorf("invalid report ID")
+	}
+
+	report, err := s.ReportRepo.FindReportByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve report: %v", err)
+	}
+
+	switch report.Type {
+	case TypeDaily:
+		if err := decodeDaily(report); err != nil {
+			return nil, err
+		}
+	case TypeWeekly:
+		var weekly models.WeeklySiteReport
+		if err := json.Unmarshal([]byte(report.Content), &weekly); err != nil {
+			return nil, fmt.Errorf("failed to decode weekly report: %v", err)
+		}
+		report.Weekly = &weekly
+	}
+	return report, nil
+}
+
+func (s *reportService) FindReportsByProjectID(ctx context.Context, projectID int64, reportType string) ([]models.Report, error) {
+	if projectID <= 0 {
+		return nil, fmt.Errorf("invalid project ID")
+	}
+
+	reports, err := s.ReportRepo.FindReportsByProjectID(ctx, projectID, reportType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve reports: %v", err)
+	}
+	return reports, nil
+}
+
+func (s *reportService) UpdateDailyReport(ctx context.Context, id, actorID int64, weather, notes string) (*models.Report, error) {
+	if actorID <= 0 {
+		return nil, fmt.Errorf("invalid user ID")
+	}
+
+	report, err := s.FindReportByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if report.Type != TypeDaily {
+		return nil, ErrNotDailyReport
+	}
+
+	report.Daily.Weather = strings.TrimSpace(weather)
+	report.Daily.Notes = strings.TrimSpace(notes)
+	report.UpdatedBy = actorID
+	report.UpdatedAt = time.Now()
+	if err := s.saveDaily(ctx, report); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+func (s *reportService) RenderReportPDF(ctx context.Context, id int64) ([]byte, *models.Report, error) {
+	report, err := s.FindReportByID(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	day := report.ReportDate
+	if day.IsZero() {
+		day = report.CreationDate
+	}
+	day = time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, day.Location())
+
+	days := 1
+	if report.Type == TypeWeekly {
+		days = 7
+	}
+
+	photos, err := s.ReportRepo.FindProjectPhotos(ctx, report.ProjectID, day, day.AddDate(0, 0, days), pdfMaxPhotos)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to retrieve photos: %v", err)
+	}
+
+	assets, err := s.loadPDFAssets(ctx, report.ProjectID, report.CreatedBy, photos)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	content, err := renderReportPDF(report, assets)
+	if err != nil {
+		return nil, nil, err
+	}
+	return content, report, nil
+}
+
+func (s *reportService) RenderQualityReportPDF(ctx context.Context, qualityReport *models.QualityReport) ([]byte, error) {
+	var photos []models.Document
+	for _, document := range qualityReport.AttachedDocuments {
+		if document.MimeType == "image/jpeg" || document.MimeType == "image/png" {
+			photos = append(photos, document)
+		}
+	}
+
+	if len(photos) == 0 {
+		var err error
+		photos, err = s.ReportRepo.FindProjectPhotos(ctx, qualityReport.ProjectID, qualityReport.StartDate,
+			qualityReport.EndDate.AddDate(0, 0, 1), pdfMaxPhotos)
+		if err != nil {
+			return nil, fmt.Errorf("failed to retrieve photos: %v", err)
+		}
+	}
+	if len(photos) > pdfMaxPhotos {
+		photos = photos[:pdfMaxPhotos]
+	}
+
+	assets, err := s.loadPDFAssets(ctx, qualityReport.ProjectID, qualityReport.GeneratedByID, photos)
+	if err != nil {
+		return nil, err
+	}
+	return renderQualityReportPDF(qualityReport, assets)
+}
+
+// loadPDFAssets gathers the project name, author, logo and photo contents. A logo or photo that cannot be read
+// is logged and left out so one bad file does not stop the report.
+func (s *reportService) loadPDFAssets(ctx context.Context, projectID, authorID int64, photos []models.Document) (pdfAssets, error) {
+	var assets pdfAssets
+
+	// Reports of an inspector's checks may span every project
+	assets.ProjectName = "All projects"
+	if projectID > 0 {
+		var err error
+		if assets.ProjectName, err = s.ReportRepo.FindProjectName(ctx, projectID); err != nil {
+			return assets, fmt.Errorf("failed to retrieve project: %v", err)
+		}
+	}
+
+	// Reports compiled by the scheduler have no author
+	assets.PreparedBy = "System"
+	if authorID > 0 {
+		assets.PreparedBy = fmt.Sprintf("User #%d", authorID)
+		if username, err := s.ReportRepo.FindUsername(ctx, authorID); err == nil {
+			assets.PreparedBy = username
+		}
+	}
+
+	logo, err := s.ReportRepo.FindProjectLogo(ctx, projectID)
+	if err != nil {
+		return assets, fmt.Errorf("failed to retrieve project logo: %v", err)
+	}
+	if logo != nil {
+		assets.Logo = s.readImage(ctx, logo)
+	}
+
+	for i := range photos {
+		if content := s.readImage(ctx, &photos[i]); content != nil {
+			caption := photos[i].Name
+			if caption == "" {
+				caption = photos[i].FileName
+			}
+			caption += " (" + photos[i].UploadDate.Format("2006-01-02 15:04") + ")"
+			assets.Photos = append(assets.Photos, pdfPhotoFile{Caption: caption, Content: content})
+		}
+	}
+	return assets, nil
+}
+
+func (s *reportService) readImage(ctx context.Context, document *models.Document) []byte {
+	if s.Blobs == nil || document.StorageKey == "" {
+		return nil
+	}
+
+	file, err := s.Blobs.Open(ctx, document.StorageKey)
+	if err != nil {
+		log.Printf("failed to open document %d for PDF: %v", document.ID, err)
+		return nil
+	}
+	defer file.Close()
+
+	content, err := io.ReadAll(io.LimitReader(file, MaxPDFImageSize+1))
+	if err != nil {
+		log.Printf("failed to read document %d for PDF: %v", document.ID, err)
+		return nil
+	}
+	if len(content) > MaxPDFImageSize {
+		log.Printf("document %d is too large to embed in a PDF", document.ID)
+		return nil
+	}
+	return content
+}
+
+// compileDaily collects the attendance, task progress, expenses, quality checks and safety incidents of one day
+func (s *reportService) compileDaily(ctx context.Context, projectID int64, day time.Time) (*models.DailySiteReport, error) {
+	from, to := day, day.AddDate(0, 0, 1)
+	daily := &models.DailySiteReport{
+		Date:           day,
+		Attendance:     models.DailyAttendance{ByStatus: make(map[string]int)},
+		QualitySummary: make(map[string]int),
+		CompiledAt:     time.Now(),
+	}
+
+	var err error
+	if daily.Attendance.Entries, err = s.ReportRepo.FindPresences(ctx, projectID, from, to); err != nil {
+		return nil, fmt.Errorf("failed to retrieve attendance: %v", err)
+	}
+	for _, presence := range daily.Attendance.Entries {
+		daily.Attendance.ByStatus[presence.Status]++
+	}
+	daily.Attendance.Total = len(daily.Attendance.Entries)
+
+	if daily.TasksStarted, err = s.ReportRepo.FindTasksStarted(ctx, projectID, from, to); err != nil {
+		return nil, fmt.Errorf("failed to retrieve started tasks: %v", err)
+	}
+
+	if daily.TasksCompleted, err = s.ReportRepo.FindTasksCompleted(ctx, projectID, from, to); err != nil {
+		return nil, fmt.Errorf("failed to retrieve completed tasks: %v", err)
+	}
+
+	if daily.Expenses, err = s.ReportRepo.FindExpenses(ctx, projectID, from, to); err != nil {
+		return nil, fmt.Errorf("failed to retrieve expenses: %v", err)
+	}
+	for _, expense := range daily.Expenses {
+		daily.ExpenseTotal += expense.Amount
+	}
+
+	if daily.QualityChecks, err = s.ReportRepo.FindQualityChecks(ctx, projectID, from, to); err != nil {
+		return nil, fmt.Errorf("failed to retrieve quality checks: %v", err)
+	}
+	for _, check := range daily.QualityChecks {
+		daily.QualitySummary[check.Status]++
+	}
+
+	if daily.SafetyIncidents, err = s.ReportRepo.FindSafetyIncidents(ctx, projectID, from, to); err != nil {
+		return nil, fmt.Errorf("failed to retrieve safety incidents: %v", err)
+	}
+	return daily, nil
+}
+
+// compileWeekly gathers seven days of site records once and totals them per day and for the week
+func (s *reportService) compileWeekly(ctx context.Context, projectID int64, weekStart time.Time) (*models.WeeklySiteReport, error) {
+	from, to := weekStart, weekStart.AddDate(0, 0, 7)
+	weekly := &models.WeeklySiteReport{
+		StartDate:          from,
+		EndDate:            to.AddDate(0, 0, -1),
+		AttendanceByStatus: make(map[string]int),
+		QualitySummary:     make(map[string]int),
+		CompiledAt:         time.Now(),
+	}
+
+	days := make([]models.WeeklyDaySummary, 7)
+	for i := range days {
+		days[i].Date = from.AddDate(0, 0, i)
+	}
+	// dayOf finds a record's day; records outside the week cannot occur but are ignored rather than trusted
+	dayOf := func(t time.Time) *models.WeeklyDaySummary {
+		for i := len(days) - 1; i >= 0; i-- {
+			if !t.Before(days[i].Date) {
+				if t.Before(to) {
+					return &days[i]
+				}
+				break
+			}
+		}
+		return &models.WeeklyDaySummary{}
+	}
+
+	presences, err := s.ReportRepo.FindPresences(ctx, projectID, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve attendance: %v", err)
+	}
+	for _, presence := range presences {
+		weekly.AttendanceByStatus[presence.Status]++
+		dayOf(presence.Date).Attendance++
+	}
+	weekly.AttendanceTotal = len(presences)
+
+	if weekly.TasksStarted, err = s.ReportRepo.FindTasksStarted(ctx, projectID, from, to); err != nil {
+		return nil, fmt.Errorf("failed to retrieve started tasks: %v", err)
+	}
+	for _, task := range weekly.TasksStarted {
+		dayOf(task.StartedAt).TasksStarted++
+	}
+
+	if weekly.TasksCompleted, err = s.ReportRepo.FindTasksCompleted(ctx, projectID, from, to); err != nil {
+		return nil, fmt.Errorf("failed to retrieve completed tasks: %v", err)
+	}
+	for _, task := range weekly.TasksCompleted {
+		dayOf(task.CompletedAt).TasksCompleted++
+	}
+
+	expenses, err := s.ReportRepo.FindExpenses(ctx, projectID, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve expenses: %v", err)
+	}
+	for _, expense := range expenses {
+		weekly.ExpenseTotal += expense.Amount
+		dayOf(expense.Date).ExpenseTotal += expense.Amount
+	}
+	weekly.ExpenseCount = len(expenses)
+
+	checks, err := s.ReportRepo.FindQualityChecks(ctx, projectID, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve quality checks: %v", err)
+	}
+	for _, check := range checks {
+		weekly.QualitySummary[check.Status]++
+		dayOf(check.Date).QualityChecks++
+	}
+
+	if weekly.SafetyIncidents, err = s.ReportRepo.FindSafetyIncidents(ctx, projectID, from, to); err != nil {
+		return nil, fmt.Errorf("failed to retrieve safety incidents: %v", err)
+	}
+	for _, incident := range weekly.SafetyIncidents {
+		dayOf(incident.Date).SafetyIncidents++
+	}
+
+	weekly.Days = days
+	return weekly, nil
+}
+
+func (s *reportService) saveDaily(ctx context.Context, report *models.Report) error {
+	if err := encodeDaily(report); err != nil {
+		return err
+	}
+	if err := s.ReportRepo.UpdateReportContent(ctx, report); err != nil {
+		return fmt.Errorf("failed to update report: %v", err)
+	}
+	return nil
+}
+
+func encodeDaily(report *models.Report) error {
+	content, err := json.Marshal(report.Daily)
+	if err != nil {
+		return fmt.Errorf("failed to encode daily report: %v", err)
+	}
+	report.Content = string(content)
+	return nil
+}
+
+func decodeDaily(report *models.Report) error {
+	var daily models.DailySiteReport
+	if err := json.Unmarshal([]byte(report.Content), &daily); err != nil {
+		return fmt.Errorf("failed to decode daily report: %v", err)
+	}
+	report.Daily = &daily
+	return nil
+}
@@ -0,0 +1,414 @@
+package report
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	models "github.com/BerkatPS/internal"
+)
+
+const (
+	TemplateFormatHTML     = "html"
+	TemplateFormatMarkdown = "markdown"
+
+	// MaxTemplateSize is the largest template body accepted on upload
+	MaxTemplateSize = 256 << 10
+
+	// A template that writes more than this, or still writes after running this long, is stopped and the render fails
+	maxRenderedSize = 8 << 20
+	renderTimeout   = 5 * time.Second
+
+	// adminRole is the user role allowed to manage report templates
+	adminRole = "admin"
+)
+
+var (
+	// ErrInvalidTemplate is returned when an uploaded template does not parse or fails on sample report data
+	ErrInvalidTemplate = errors.New("invalid report template")
+	// ErrNoTemplate is returned when a report is rendered without a template and no default applies
+	ErrNoTemplate = errors.New("no report template")
+	// ErrNotAdmin is returned when someone other than an admin manages report templates
+	ErrNotAdmin = errors.New("only admins can manage report templates")
+
+	errRenderTooLarge = errors.New("rendered report is too large")
+)
+
+// templateFuncs are the helpers available to every report template
+var templateFuncs = map[string]interface{}{
+	"date": func(t time.Time) string {
+		if t.IsZero() {
+			return ""
+		}
+		return t.Format("2006-01-02")
+	},
+	"datetime": func(t time.Time) string {
+		if t.IsZero() {
+			return ""
+		}
+		return t.Format("2006-01-02 15:04")
+	},
+	"formatTime": func(layout string, t time.Time) string {
+		if t.IsZero() {
+			return ""
+		}
+		return t.Format(layout)
+	},
+	"money": formatAmount,
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+	"join":  strings.Join,
+	"default": func(fallback, value string) string {
+		if value == "" {
+			return fallback
+		}
+		return value
+	},
+	"add": func(a, b int) int { return a + b },
+	"percent": func(part, total int) string {
+		if total == 0 {
+			return "0%"
+		}
+		return fmt.Sprintf("%.0f%%", float64(part)*100/float64(total))
+	},
+}
+
+type templateExecutor interface {
+	Execute(w io.Writer, data interface{}) error
+}
+
+// parseTemplate compiles a template body. HTML templates escape what they print; Markdown templates print as is.
+func parseTemplate(format, body string) (templateExecutor, error) {
+	var executor templateExecutor
+	var err error
+	switch format {
+	case TemplateFormatHTML:
+		executor, err = htmltemplate.New("report").Funcs(htmltemplate.FuncMap(templateFuncs)).Option("missingkey=error").Parse(body)
+	case TemplateFormatMarkdown:
+		executor, err = texttemplate.New("report").Funcs(texttemplate.FuncMap(templateFuncs)).Option("missingkey=error").Parse(body)
+	default:
+		return nil, fmt.Errorf("format must be %s or %s", TemplateFormatHTML, TemplateFormatMarkdown)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := checkTemplateWork(executor); err != nil {
+		return nil, err
+	}
+	return executor, nil
+}
+
+// limitedBuffer fails writes once the rendered output passes maxRenderedSize or the render runs past its
+// deadline, which stops the template
+type limitedBuffer struct {
+	bytes.Buffer
+	deadline time.Time
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if b.Len()+len(p) > maxRenderedSize {
+		return 0, errRenderTooLarge
+	}
+	if time.Now().After(b.deadline) {
+		return 0, fmt.Errorf("template did not finish within %s", renderTimeout)
+	}
+	return b.Buffer.Write(p)
+}
+
+// executeTemplate renders data through a parsed template. parseTemplate has already bounded the work a template
+// can do, so it runs on the caller's goroutine and nothing is left running once it returns; a panic becomes an
+// error instead of taking the request, or the server, down with it.
+func executeTemplate(executor templateExecutor, data *models.ReportTemplateData) (content []byte, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			content, err = nil, fmt.Errorf("template panicked: %v", p)
+		}
+	}()
+
+	out := limitedBuffer{deadline: time.Now().Add(renderTimeout)}
+	if err := executor.Execute(&out, data); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// validateTemplate checks the fields of a template and runs it against a full and an empty sample report,
+// so a template that only works on some data is refused before it is ever used
+func validateTemplate(template *models.ReportTemplate) error {
+	template.Name = strings.TrimSpace(template.Name)
+	template.Format = strings.ToLower(strings.TrimSpace(template.Format))
+	template.ReportType = strings.ToLower(strings.TrimSpace(template.ReportType))
+
+	if template.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidTemplate)
+	}
+	if template.ProjectID < 0 {
+		return fmt.Errorf("%w: invalid project ID", ErrInvalidTemplate)
+	}
+	if template.ReportType != "" && template.ReportType != TypeDaily && template.ReportType != TypeWeekly {
+		return fmt.Errorf("%w: report_type must be %s, %s or empty", ErrInvalidTemplate, TypeDaily, TypeWeekly)
+	}
+	if strings.TrimSpace(template.Body) == "" {
+		return fmt.Errorf("%w: body is required", ErrInvalidTemplate)
+	}
+	if len(template.Body) > MaxTemplateSize {
+		return fmt.Errorf("%w: body is larger than %d bytes", ErrInvalidTemplate, MaxTemplateSize)
+	}
+
+	executor, err := parseTemplate(template.Format, template.Body)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidTemplate, err)
+	}
+
+	for _, sample := range []*models.ReportTemplateData{sampleTemplateData(), emptyTemplateData()} {
+		if _, err := executeTemplate(executor, sample); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidTemplate, err)
+		}
+	}
+	return nil
+}
+
+// templateData maps a compiled daily or weekly report onto the data model templates are written against
+func templateData(report *models.Report, project models.TemplateProject) *models.ReportTemplateData {
+	data := emptyTemplateData()
+	data.Project = project
+	data.Report.ID = report.ID
+	data.Report.Type = report.Type
+
+	switch {
+	case report.Daily != nil:
+		daily := report.Daily
+		data.Report.StartDate, data.Report.EndDate = daily.Date, daily.Date
+		data.Report.Weather, data.Report.Notes = daily.Weather, daily.Notes
+		data.Report.CompiledAt = daily.CompiledAt
+		data.Attendance.Total = daily.Attendance.Total
+		copyCounts(data.Attendance.ByStatus, daily.Attendance.ByStatus)
+		data.Tasks.Started = orEmpty(daily.TasksStarted)
+		data.Tasks.Completed = orEmpty(daily.TasksCompleted)
+		data.Expenses = models.TemplateExpenses{Count: len(daily.Expenses), Total: daily.ExpenseTotal, Items: orEmpty(daily.Expenses)}
+		data.Quality.Total = len(daily.QualityChecks)
+		data.Quality.Checks = orEmpty(daily.QualityChecks)
+		copyCounts(data.Quality.Summary, daily.QualitySummary)
+		data.Incidents = orEmpty(daily.SafetyIncidents)
+	case report.Weekly != nil:
+		weekly := report.Weekly
+		data.Report.StartDate, data.Report.EndDate = weekly.StartDate, weekly.EndDate
+		data.Report.CompiledAt = weekly.CompiledAt
+		data.Attendance.Total = weekly.AttendanceTotal
+		copyCounts(data.Attendance.ByStatus, weekly.AttendanceByStatus)
+		data.Tasks.Started = orEmpty(weekly.TasksStarted)
+		data.Tasks.Completed = orEmpty(weekly.TasksCompleted)
+		data.Expenses.Count, data.Expenses.Total = weekly.ExpenseCount, weekly.ExpenseTotal
+		for _, count := range weekly.QualitySummary {
+			data.Quality.Total += count
+		}
+		copyCounts(data.Quality.Summary, weekly.QualitySummary)
+		data.Incidents = orEmpty(weekly.SafetyIncidents)
+		data.Days = orEmpty(weekly.Days)
+	}
+	return data
+}
+
+// emptyTemplateData has every collection allocated, so templates can range over and index maps without nil checks
+func emptyTemplateData() *models.ReportTemplateData {
+	return &models.ReportTemplateData{
+		Attendance:  models.TemplateAttendance{ByStatus: map[string]int{}},
+		Tasks:       models.TemplateTasks{Started: []models.Task{}, Completed: []models.Task{}},
+		Expenses:    models.TemplateExpenses{Items: []models.Expense{}},
+		Quality:     models.TemplateQuality{Summary: map[string]int{}, Checks: []models.QualityCheck{}},
+		Incidents:   []models.SafetyIncident{},
+		Days:        []models.WeeklyDaySummary{},
+		GeneratedAt: time.Now(),
+	}
+}
+
+// sampleTemplateData fills every part of the data model, for validating uploaded templates
+func sampleTemplateData() *models.ReportTemplateData {
+	day := time.Date(2024, 3, 4, 0, 0, 0, 0, time.Local)
+	data := emptyTemplateData()
+	data.Report = models.TemplateReport{ID: 1, Type: TypeDaily, StartDate: day, EndDate: day, Weather: "Sunny", Notes: "Slab poured", CompiledAt: day.Add(18 * time.Hour)}
+	data.Project = models.TemplateProject{ID: 1, Name: "Sample Project", Description: "Sample", Status: "IN_PROGRESS", Budget: 1000000, Manager: "manager"}
+	data.Attendance = models.TemplateAttendance{Total: 2, ByStatus: map[string]int{"PRESENT": 2}}
+	task := models.Task{ID: 1, ProjectID: 1, Name: "Pour slab", Status: "DONE", StartDate: day, EndDate: day, StartedAt: day, CompletedAt: day}
+	data.Tasks = models.TemplateTasks{Started: []models.Task{task}, Completed: []models.Task{task}}
+	data.Expenses = models.TemplateExpenses{Count: 1, Total: 2500, Items: []models.Expense{{ID: 1, ProjectID: 1, Description: "Concrete", Amount: 2500, Date: day}}}
+	data.Quality = models.TemplateQuality{Total: 1, Summary: map[string]int{"PASSED": 1},
+		Checks: []models.QualityCheck{{ID: 1, ProjectID: 1, Date: day, Status: "PASSED", Comments: "Level within tolerance"}}}
+	data.Incidents = []models.SafetyIncident{{ID: 1, ProjectID: 1, Date: day, Description: "Near miss at the hoist", Severity: "LOW", Type: "NEAR_MISS", Status: "OPEN"}}
+	data.Days = []models.WeeklyDaySummary{{Date: day, Attendance: 2, TasksStarted: 1, TasksCompleted: 1, ExpenseTotal: 2500, QualityChecks: 1, SafetyIncidents: 1}}
+	return data
+}
+
+func copyCounts(dst, src map[string]int) {
+	for key, count := range src {
+		dst[key] = count
+	}
+}
+
+func orEmpty[T any](items []T) []T {
+	if items == nil {
+		return []T{}
+	}
+	return items
+}
+
+func (s *reportService) CreateTemplate(ctx context.Context, template *models.ReportTemplate, actorID int64) error {
+	if err := s.requireAdmin(ctx, actorID); err != nil {
+		return err
+	}
+	if err := validateTemplate(template); err != nil {
+		return err
+	}
+	if err := s.checkTemplateProject(ctx, template.ProjectID); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	template.CreatedBy, template.CreatedAt = actorID, now
+	template.UpdatedBy, template.UpdatedAt = actorID, now
+	if err := s.ReportRepo.CreateTemplate(ctx, template); err != nil {
+		return fmt.Errorf("failed to create report template: %v", err)
+	}
+	return nil
+}
+
+func (s *reportService) UpdateTemplate(ctx context.Context, template *models.ReportTemplate, actorID int64) (*models.ReportTemplate, error) {
+	if err := s.requireAdmin(ctx, actorID); err != nil {
+		return nil, err
+	}
+
+	existing, err := s.FindTemplateByID(ctx, template.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	// A template stays in the scope it was uploaded to
+	existing.Name, existing.Format, existing.ReportType = template.Name, template.Format, template.ReportType
+	existing.Body, existing.IsDefault = template.Body, template.IsDefault
+	if err := validateTemplate(existing); err != nil {
+		return nil, err
+	}
+
+	existing.UpdatedBy, existing.UpdatedAt = actorID, time.Now()
+	if err := s.ReportRepo.UpdateTemplate(ctx, existing); err != nil {
+		return nil, fmt.Errorf("failed to update report template: %v", err)
+	}
+	return existing, nil
+}
+
+func (s *reportService) DeleteTemplate(ctx context.Context, id, actorID int64) error {
+	if err := s.requireAdmin(ctx, actorID); err != nil {
+		return err
+	}
+	if id <= 0 {
+		return fmt.Errorf("invalid template ID")
+	}
+	if err := s.ReportRepo.DeleteTemplate(ctx, id); err != nil {
+		return fmt.Errorf("failed to delete report template: %v", err)
+	}
+	return nil
+}
+
+func (s *reportService) FindTemplateByID(ctx context.Context, id int64) (*models.ReportTemplate, error) {
+	if id <= 0 {
+		return nil, fmt.Errorf("invalid template ID")
+	}
+	template, err := s.ReportRepo.FindTemplateByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve report template: %v", err)
+	}
+	return template, nil
+}
+
+func (s *reportService) FindTemplates(ctx context.Context, projectID int64) ([]models.ReportTemplate, error) {
+	if projectID < 0 {
+		return nil, fmt.Errorf("invalid project ID")
+	}
+	templates, err := s.ReportRepo.FindTemplates(ctx, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve report templates: %v", err)
+	}
+	return templates, nil
+}
+
+func (s *reportService) ValidateTemplate(template *models.ReportTemplate) error {
+	return validateTemplate(template)
+}
+
+func (s *reportService) RenderReportTemplate(ctx context.Context, reportID, templateID int64, format string) ([]byte, *models.Report, error) {
+	report, err := s.FindReportByID(ctx, reportID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var template *models.ReportTemplate
+	if templateID > 0 {
+		template, err = s.FindTemplateByID(ctx, templateID)
+		if err != nil {
+			return nil, nil, err
+		}
+		if template.ProjectID != 0 && template.ProjectID != report.ProjectID {
+			return nil, nil, fmt.Errorf("%w: template %d belongs to another project", ErrNoTemplate, templateID)
+		}
+		if template.ReportType != "" && template.ReportType != report.Type {
+			return nil, nil, fmt.Errorf("%w: template %d is for %s reports", ErrNoTemplate, templateID, template.ReportType)
+		}
+		if template.Format != format {
+			return nil, nil, fmt.Errorf("%w: template %d renders %s", ErrNoTemplate, templateID, template.Format)
+		}
+	} else {
+		template, err = s.ReportRepo.FindDefaultTemplate(ctx, report.ProjectID, report.Type, format)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to retrieve report template: %v", err)
+		}
+		if template == nil {
+			return nil, nil, fmt.Errorf("%w: no default %s template for %s reports", ErrNoTemplate, format, report.Type)
+		}
+	}
+
+	project, err := s.ReportRepo.FindTemplateProject(ctx, report.ProjectID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to retrieve project: %v", err)
+	}
+
+	executor, err := parseTemplate(template.Format, template.Body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse report template %d: %v", template.ID, err)
+	}
+	content, err := executeTemplate(executor, templateData(report, project))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to render report template %d: %v", template.ID, err)
+	}
+	return content, report, nil
+}
+
+func (s *reportService) requireAdmin(ctx context.Context, actorID int64) error {
+	if actorID <= 0 {
+		return fmt.Errorf("invalid user ID")
+	}
+	role, err := s.ReportRepo.FindUserRole(ctx, actorID)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve user: %v", err)
+	}
+	if !strings.EqualFold(role, adminRole) {
+		return ErrNotAdmin
+	}
+	return nil
+}
+
+// checkTemplateProject makes sure a project template points at an existing project; 0 is the organization
+func (s *reportService) checkTemplateProject(ctx context.Context, projectID int64) error {
+	if projectID == 0 {
+		return nil
+	}
+	if _, err := s.ReportRepo.FindProjectName(ctx, projectID); err != nil {
+		return fmt.Errorf("failed to retrieve project: %v", err)
+	}
+	return nil
+}
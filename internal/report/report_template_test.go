@@ -0,0 +1,78 @@
+package report
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	models "github.com/BerkatPS/internal"
+)
+
+func TestValidateTemplateBoundsWork(t *testing.T) {
+	// Each level calls the one below ten times, so level 7 would run ten million empty templates
+	var fanOut strings.Builder
+	fanOut.WriteString(`{{define "l0"}}{{end}}`)
+	for level := 1; level <= 7; level++ {
+		fanOut.WriteString(`{{define "l` + string(rune('0'+level)) + `"}}`)
+		fanOut.WriteString(strings.Repeat(`{{template "l`+string(rune('0'+level-1))+`"}}`, 10))
+		fanOut.WriteString(`{{end}}`)
+	}
+	fanOut.WriteString(`{{template "l7"}}`)
+
+	tests := []struct {
+		name    string
+		format  string
+		body    string
+		wantErr bool
+	}{
+		{name: "plain fields", format: TemplateFormatMarkdown, body: "# {{.Project.Name}}\n{{.Report.Notes}}"},
+		{name: "range over data", format: TemplateFormatHTML, body: `{{range .Tasks.Started}}<li>{{.Name}}</li>{{end}}`},
+		{name: "nested range over data", format: TemplateFormatMarkdown, body: `{{range $day := .Days}}{{range $.Incidents}}{{$day.Date}}{{.Type}}{{end}}{{end}}`},
+		{name: "range over map", format: TemplateFormatMarkdown, body: `{{range $status, $count := .Attendance.ByStatus}}{{$status}}: {{$count}}{{end}}`},
+		{name: "with over data", format: TemplateFormatMarkdown, body: `{{with .Expenses}}{{range .Items}}{{.Description}}{{end}}{{end}}`},
+		{name: "helper template", format: TemplateFormatHTML, body: `{{define "task"}}<li>{{.Name}}</li>{{end}}{{range .Tasks.Completed}}{{template "task" .}}{{end}}`},
+		{name: "range over integer", format: TemplateFormatMarkdown, body: `{{range 1000000000}}{{end}}`, wantErr: true},
+		{name: "range over variable holding integer", format: TemplateFormatMarkdown, body: `{{$n := 1000000000}}{{range $n}}{{end}}`, wantErr: true},
+		{name: "range over with-bound integer", format: TemplateFormatMarkdown, body: `{{with 1000000000}}{{range .}}{{end}}{{end}}`, wantErr: true},
+		{name: "range over helper result", format: TemplateFormatMarkdown, body: `{{range add 1000000 1000000}}{{end}}`, wantErr: true},
+		{name: "range in template called with integer", format: TemplateFormatHTML, body: `{{define "loop"}}{{range .}}{{end}}{{end}}{{template "loop" 1000000000}}`, wantErr: true},
+		{name: "recursive template", format: TemplateFormatMarkdown, body: `{{define "loop"}}{{template "loop" .}}{{end}}{{template "loop" .}}`, wantErr: true},
+		{name: "mutually recursive templates", format: TemplateFormatHTML, body: `{{define "a"}}{{template "b" .}}{{end}}{{define "b"}}{{template "a" .}}{{end}}{{template "a" .}}`, wantErr: true},
+		{name: "exponential template calls", format: TemplateFormatMarkdown, body: fanOut.String(), wantErr: true},
+		{name: "triple nested range", format: TemplateFormatMarkdown, body: `{{range .Days}}{{range $.Days}}{{range $.Days}}{{.Date}}{{end}}{{end}}{{end}}`},
+		{name: "range nested four deep", format: TemplateFormatMarkdown, body: `{{range .Days}}{{range $.Days}}{{range $.Days}}{{range $.Days}}{{end}}{{end}}{{end}}{{end}}`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			template := &models.ReportTemplate{Name: "Site diary", Format: tt.format, Body: tt.body}
+
+			start := time.Now()
+			err := validateTemplate(template)
+			if elapsed := time.Since(start); elapsed > time.Second {
+				t.Errorf("validation took %s", elapsed)
+			}
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidTemplate) {
+					t.Errorf("error = %v, want %v", err, ErrInvalidTemplate)
+				}
+			} else if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestExecuteTemplateRecoversPanic(t *testing.T) {
+	if _, err := executeTemplate(panickingExecutor{}, sampleTemplateData()); err == nil || !strings.Contains(err.Error(), "panicked") {
+		t.Errorf("error = %v, want a panic turned into an error", err)
+	}
+}
+
+type panickingExecutor struct{}
+
+func (panickingExecutor) Execute(w io.Writer, data interface{}) error {
+	panic("boom")
+}
@@ -0,0 +1,23 @@
+package report
+
+import (
+	"net/http"
+
+	"github.com/BerkatPS/pkg/rbac"
+)
+
+func RegisterRoutes(router *http.ServeMux, handler *ReportController, authz *rbac.Enforcer) {
+	router.HandleFunc("POST /reports/daily", authz.RequireProject(rbac.ReportCompile, rbac.BodyProject("project_id"), handler.CompileDailyReport))
+	router.HandleFunc("GET /reports/project/{id}", authz.RequireProject(rbac.ReportRead, rbac.PathProject("id"), handler.FindReportsByProject))
+	// Also serves the PDF and template renderings, such as /reports/12.pdf and /reports/12.html
+	router.HandleFunc("GET /reports/{id}", authz.RequireProject(rbac.ReportRead, authz.PathResource(rbac.ResourceReport, "id"), handler.FindReportByID))
+	router.HandleFunc("PUT /reports/{id}", authz.RequireProject(rbac.ReportCompile, authz.PathResource(rbac.ResourceReport, "id"), handler.UpdateDailyReport))
+
+	router.HandleFunc("POST /report-templates", authz.Require(rbac.ReportManageTemplates, handler.CreateTemplate))
+	router.HandleFunc("POST /report-templates/validate", authz.Require(rbac.ReportManageTemplates, handler.ValidateTemplate))
+	// Organization templates have no project and are readable by everyone who may read reports
+	router.HandleFunc("GET /report-templates", authz.RequireProjectOrOrganization(rbac.ReportRead, rbac.QueryProject("project_id"), handler.FindTemplates))
+	router.HandleFunc("GET /report-templates/{id}", authz.RequireProjectOrOrganization(rbac.ReportRead, authz.PathResource(rbac.ResourceReportTemplate, "id"), handler.FindTemplateByID))
+	router.HandleFunc("PUT /report-templates/{id}", authz.Require(rbac.ReportManageTemplates, handler.UpdateTemplate))
+	router.HandleFunc("DELETE /report-templates/{id}", authz.Require(rbac.ReportManageTemplates, handler.DeleteTemplate))
+}
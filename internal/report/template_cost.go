@@ -0,0 +1,198 @@
+package report
+
+import (
+	"fmt"
+	htmltemplate "html/template"
+	texttemplate "text/template"
+	"text/template/parse"
+)
+
+const (
+	// rangeItems is how many items a range is assumed to loop over when bounding the work of a template
+	rangeItems = 100
+	// maxTemplateWork bounds the nodes a template may execute, counting every {{template}} call and range
+	// iteration, so rendering always finishes without a timeout having to abandon it
+	maxTemplateWork = 10_000_000
+)
+
+// checkTemplateWork refuses templates whose rendering time is not bounded by the report data: templates that
+// call themselves, {{template}} calls fanning out into too much work, and ranges over anything but report data,
+// such as {{range 1000000000}}. None of these need to write anything, so the output limit would not stop them.
+func checkTemplateWork(executor templateExecutor) error {
+	var root *parse.Tree
+	var lookup func(name string) *parse.Tree
+	switch t := executor.(type) {
+	case *htmltemplate.Template:
+		root = t.Tree
+		lookup = func(name string) *parse.Tree {
+			if found := t.Lookup(name); found != nil {
+				return found.Tree
+			}
+			return nil
+		}
+	case *texttemplate.Template:
+		root = t.Tree
+		lookup = func(name string) *parse.Tree {
+			if found := t.Lookup(name); found != nil {
+				return found.Tree
+			}
+			return nil
+		}
+	default:
+		return nil
+	}
+	if root == nil {
+		return nil
+	}
+
+	estimator := &workEstimator{lookup: lookup, work: map[string]int64{}, calling: map[string]bool{}}
+	work, err := estimator.node(root.Root, newWorkScope(true))
+	if err != nil {
+		return err
+	}
+	if work > maxTemplateWork {
+		return fmt.Errorf("template does too much work")
+	}
+	return nil
+}
+
+// workScope tracks whether dot and each variable hold report data rather than values the template made up
+type workScope struct {
+	dot  bool
+	vars map[string]bool
+}
+
+func newWorkScope(data bool) *workScope {
+	return &workScope{dot: data, vars: map[string]bool{"$": data}}
+}
+
+// enter returns the scope of a block nested in s; the variables it declares end with it
+func (s *workScope) enter(dot bool) *workScope {
+	vars := make(map[string]bool, len(s.vars))
+	for name, data := range s.vars {
+		vars[name] = data
+	}
+	return &workScope{dot: dot, vars: vars}
+}
+
+// isData reports whether a pipeline only reads report data: a field of dot or of a variable holding data
+func (s *workScope) isData(pipe *parse.PipeNode) bool {
+	if pipe == nil || len(pipe.Cmds) != 1 || len(pipe.Cmds[0].Args) != 1 {
+		return false
+	}
+	switch arg := pipe.Cmds[0].Args[0].(type) {
+	case *parse.DotNode, *parse.FieldNode:
+		return s.dot
+	case *parse.VariableNode:
+		return s.vars[arg.Ident[0]]
+	}
+	return false
+}
+
+func (s *workScope) declare(pipe *parse.PipeNode) {
+	if pipe == nil {
+		return
+	}
+	data := s.isData(pipe)
+	for _, variable := range pipe.Decl {
+		s.vars[variable.Ident[0]] = data
+	}
+}
+
+type workEstimator struct {
+	lookup  func(name string) *parse.Tree
+	work    map[string]int64 // Work of each template by name and whether it is called with data
+	calling map[string]bool  // Templates being estimated, to catch recursion
+}
+
+func (e *workEstimator) node(node parse.Node, scope *workScope) (int64, error) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return 0, nil
+		}
+		var work int64
+		for _, child := range n.Nodes {
+			childWork, err := e.node(child, scope)
+			if err != nil {
+				return 0, err
+			}
+			work = addWork(work, childWork)
+		}
+		return work, nil
+	case *parse.ActionNode:
+		scope.declare(n.Pipe)
+		return 1, nil
+	case *parse.IfNode:
+		return e.branches(&n.BranchNode, scope, scope.dot, 1)
+	case *parse.WithNode:
+		return e.branches(&n.BranchNode, scope, scope.isData(n.Pipe), 1)
+	case *parse.RangeNode:
+		if !scope.isData(n.Pipe) {
+			return 0, fmt.Errorf("range may only loop over report data")
+		}
+		return e.branches(&n.BranchNode, scope, true, rangeItems)
+	case *parse.TemplateNode:
+		data := n.Pipe == nil || scope.isData(n.Pipe)
+		work, err := e.template(n.Name, data)
+		return addWork(1, work), err
+	}
+	return 1, nil
+}
+
+// branches estimates an if, with or range whose body runs times times with dot set to data
+func (e *workEstimator) branches(n *parse.BranchNode, scope *workScope, dot bool, times int64) (int64, error) {
+	body := scope.enter(dot)
+	for _, variable := range n.Pipe.Decl {
+		// Range variables hold items and indexes of report data; with and if ones what the pipeline gives
+		body.vars[variable.Ident[0]] = dot && (times > 1 || scope.isData(n.Pipe))
+	}
+	bodyWork, err := e.node(n.List, body)
+	if err != nil {
+		return 0, err
+	}
+	elseWork, err := e.node(n.ElseList, scope.enter(scope.dot))
+	if err != nil {
+		return 0, err
+	}
+	return addWork(1, addWork(mulWork(times, addWork(1, bodyWork)), elseWork)), nil
+}
+
+func (e *workEstimator) template(name string, data bool) (int64, error) {
+	key := fmt.Sprintf("%s/%t", name, data)
+	if work, ok := e.work[key]; ok {
+		return work, nil
+	}
+	if e.calling[name] {
+		return 0, fmt.Errorf("template %q calls itself", name)
+	}
+	tree := e.lookup(name)
+	if tree == nil {
+		// Executing fails on the missing template, which validation reports
+		return 0, nil
+	}
+
+	e.calling[name] = true
+	work, err := e.node(tree.Root, newWorkScope(data))
+	delete(e.calling, name)
+	if err != nil {
+		return 0, err
+	}
+	e.work[key] = work
+	return work, nil
+}
+
+// addWork and mulWork stop counting past maxTemplateWork, so fan-out cannot overflow
+func addWork(a, b int64) int64 {
+	if a+b > maxTemplateWork {
+		return maxTemplateWork + 1
+	}
+	return a + b
+}
+
+func mulWork(a, b int64) int64 {
+	if b != 0 && a > maxTemplateWork/b {
+		return maxTemplateWork + 1
+	}
+	return addWork(a*b, 0)
+}
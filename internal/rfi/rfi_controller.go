@@ -0,0 +1,426 @@
+package rfi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	models "github.com/BerkatPS/internal"
+	"github.com/BerkatPS/pkg/principal"
+	"github.com/BerkatPS/pkg/utils"
+)
+
+type RFIController struct {
+	RFIService RFIService
+}
+
+func NewRFIController(rfiService RFIService) *RFIController {
+	return &RFIController{rfiService}
+}
+
+// rfiRequest is the body accepted when creating or editing a draft RFI
+type rfiRequest struct {
+	ProjectID   int64     `json:"project_id"`
+	Subject     string    `json:"subject"`
+	Question    string    `json:"question"`
+	AddresseeID int64     `json:"addressee_id"`
+	RequiredBy  time.Time `json:"required_by"`
+	DocumentIDs []int64   `json:"document_ids"`
+}
+
+func (req rfiRequest) toRFI() *models.RFI {
+	rfi := &models.RFI{
+		ProjectID:   req.ProjectID,
+		Subject:     req.Subject,
+		Question:    req.Question,
+		AddresseeID: req.AddresseeID,
+		RequiredBy:  req.RequiredBy,
+	}
+	for _, documentID := range req.DocumentIDs {
+		rfi.Documents = append(rfi.Documents, models.RFIDocument{DocumentID: documentID})
+	}
+	return rfi
+}
+
+func (c *RFIController) CreateRFI(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	userID, err := principal.UserIDFromRequest(r)
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusUnauthorized, map[string]interface{}{
+			"status":  "error",
+			"message": "Unauthorized: " + err.Error(),
+		})
+		return
+	}
+
+	var createRFIRequest rfiRequest
+	if err := json.NewDecoder(r.Body).Decode(&createRFIRequest); err != nil {
+		utils.JSONErrorResponse(w, http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": "Invalid request body: " + err.Error(),
+		})
+		return
+	}
+
+	rfi := createRFIRequest.toRFI()
+	rfi.RaisedBy = userID
+
+	if err := c.RFIService.CreateRFI(ctx, rfi); err != nil {
+		utils.JSONErrorResponse(w, rfiErrorStatus(err), map[string]interface{}{
+			"status":  "error",
+			"message": "Failed to create RFI: " + err.Error(),
+		})
+		return
+	}
+
+	utils.JSONResponse(w, http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "RFI created successfully",
+		"data":    rfi,
+	})
+}
+
+func (c *RFIController) FindRFIByID(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	rfiID, err := utils.ParseInt64Param(r)
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": "Invalid RFI ID: " + err.Error(),
+		})
+		return
+	}
+
+	rfi, err := c.RFIService.FindRFIByID(ctx, rfiID)
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusInternalServerError, map[string]interface{}{
+			"status":  "error",
+			"message": "Failed to retrieve RFI: " + err.Error(),
+		})
+		return
+	}
+
+	utils.JSONResponse(w, http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "RFI found successfully",
+		"data":    rfi,
+	})
+}
+
+// FindRFIsByProject lists a project's RFIs in number order, optionally filtered by ?status=
+func (c *RFIController) FindRFIsByProject(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	projectID, err := utils.ParseInt64Param(r)
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": "Invalid project ID: " + err.Error(),
+		})
+		return
+	}
+
+	rfis, err := c.RFIService.FindRFIsByProjectID(ctx, projectID, r.URL.Query().Get("status"))
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusInternalServerError, map[string]interface{}{
+			"status":  "error",
+			"message": "Failed to retrieve RFIs: " + err.Error(),
+		})
+		return
+	}
+
+	utils.JSONResponse(w, http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "RFIs found successfully",
+		"data":    rfis,
+	})
+}
+
+func (c *RFIController) UpdateDraft(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	userID, err := principal.UserIDFromRequest(r)
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusUnauthorized, map[string]interface{}{
+			"status":  "error",
+			"message": "Unauthorized: " + err.Error(),
+		})
+		return
+	}
+
+	rfiID, err := utils.ParseInt64Param(r)
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": "Invalid RFI ID: " + err.Error(),
+		})
+		return
+	}
+
+	var updateRFIRequest rfiRequest
+	if err := json.NewDecoder(r.Body).Decode(&updateRFIRequest); err != nil {
+		utils.JSONErrorResponse(w, http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": "Invalid request body: " + err.Error(),
+		})
+		return
+	}
+
+	rfi := updateRFIRequest.toRFI()
+	rfi.ID = rfiID
+
+	if err := c.RFIService.UpdateDraft(ctx, rfi, userID); err != nil {
+		utils.JSONErrorResponse(w, rfiErrorStatus(err), map[string]interface{}{
+			"status":  "error",
+			"message": "Failed to update RFI: " + err.Error(),
+		})
+		return
+	}
+
+	utils.JSONResponse(w, http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "RFI updated successfully",
+		"data":    rfi,
+	})
+}
+
+func (c *RFIController) IssueRFI(w http.ResponseWriter, r *http.Request) {
+	c.transition(w, r, c.RFIService.IssueRFI, "RFI issued successfully")
+}
+
+func (c *RFIController) CloseRFI(w http.ResponseWriter, r *http.Request) {
+	c.transition(w, r, c.RFIService.CloseRFI, "RFI closed successfully")
+}
+
+func (c *RFIController) ReopenRFI(w http.ResponseWriter, r *http.Request) {
+	c.transition(w, r, c.RFIService.ReopenRFI, "RFI reopened successfully")
+}
+
+func (c *RFIController) VoidRFI(w http.ResponseWriter, r *http.Request) {
+	c.transition(w, r, c.RFIService.VoidRFI, "RFI voided successfully")
+}
+
+func (c *RFIController) RespondRFI(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	userID, err := principal.UserIDFromRequest(r)
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusUnauthorized, map[string]interface{}{
+			"status":  "error",
+			"message": "Unauthorized: " + err.Error(),
+		})
+		return
+	}
+
+	rfiID, err := utils.ParseInt64Param(r)
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": "Invalid RFI ID: " + err.Error(),
+		})
+		return
+	}
+
+	var respondRequest struct {
+		Response string `json:"response"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&respondRequest); err != nil {
+		utils.JSONErrorResponse(w, http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": "Invalid request body: " + err.Error(),
+		})
+		return
+	}
+
+	if err := c.RFIService.RespondRFI(ctx, rfiID, userID, respondRequest.Response); err != nil {
+		utils.JSONErrorResponse(w, rfiErrorStatus(err), map[string]interface{}{
+			"status":  "error",
+			"message": "Failed to respond to RFI: " + err.Error(),
+		})
+		return
+	}
+
+	utils.JSONResponse(w, http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "RFI answered successfully",
+	})
+}
+
+// LinkTasks links an answered RFI to the tasks its response unblocks
+func (c *RFIController) LinkTasks(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	userID, err := principal.UserIDFromRequest(r)
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusUnauthorized, map[string]interface{}{
+			"status":  "error",
+			"message": "Unauthorized: " + err.Error(),
+		})
+		return
+	}
+
+	rfiID, err := utils.ParseInt64Param(r)
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": "Invalid RFI ID: " + err.Error(),
+		})
+		return
+	}
+
+	var linkTasksRequest struct {
+		TaskIDs []int64 `json:"task_ids"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&linkTasksRequest); err != nil {
+		utils.JSONErrorResponse(w, http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": "Invalid request body: " + err.Error(),
+		})
+		return
+	}
+
+	tasks, err := c.RFIService.LinkTasks(ctx, rfiID, userID, linkTasksRequest.TaskIDs)
+	if err != nil {
+		utils.JSONErrorResponse(w, rfiErrorStatus(err), map[string]interface{}{
+			"status":  "error",
+			"message": "Failed to link tasks: " + err.Error(),
+		})
+		return
+	}
+
+	utils.JSONResponse(w, http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "Tasks linked successfully",
+		"data":    tasks,
+	})
+}
+
+// FindOverdueRFIs lists open RFIs past their required-by date, optionally for one ?project_id=
+func (c *RFIController) FindOverdueRFIs(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	projectID, ok := parseProjectQuery(w, r)
+	if !ok {
+		return
+	}
+
+	rfis, err := c.RFIService.FindOverdueRFIs(ctx, projectID)
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusInternalServerError, map[string]interface{}{
+			"status":  "error",
+			"message": "Failed to retrieve overdue RFIs: " + err.Error(),
+		})
+		return
+	}
+
+	utils.JSONResponse(w, http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "Overdue RFIs found successfully",
+		"data":    rfis,
+	})
+}
+
+// FindResponseTimes reports the average RFI response time per project, optionally for one ?project_id=
+func (c *RFIController) FindResponseTimes(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	projectID, ok := parseProjectQuery(w, r)
+	if !ok {
+		return
+	}
+
+	stats, err := c.RFIService.FindResponseTimes(ctx, projectID)
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusInternalServerError, map[string]interface{}{
+			"status":  "error",
+			"message": "Failed to retrieve RFI response times: " + err.Error(),
+		})
+		return
+	}
+
+	utils.JSONResponse(w, http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "RFI response times calculated successfully",
+		"data":    stats,
+	})
+}
+
+func (c *RFIController) transition(w http.ResponseWriter, r *http.Request, transitionFn func(ctx context.Context, id, actorID int64) error, successMessage string) {
+
+	ctx := r.Context()
+
+	userID, err := principal.UserIDFromRequest(r)
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusUnauthorized, map[string]interface{}{
+			"status":  "error",
+			"message": "Unauthorized: " + err.Error(),
+		})
+		return
+	}
+
+	rfiID, err := utils.ParseInt64Param(r)
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": "Invalid RFI ID: " + err.Error(),
+		})
+		return
+	}
+
+	if err := transitionFn(ctx, rfiID, userID); err != nil {
+		utils.JSONErrorResponse(w, rfiErrorStatus(err), map[string]interface{}{
+			"status":  "error",
+			"message": "Failed to update RFI: " + err.Error(),
+		})
+		return
+	}
+
+	utils.JSONResponse(w, http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": successMessage,
+	})
+}
+
+func parseProjectQuery(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	raw := r.URL.Query().Get("project_id")
+	if raw == "" {
+		return 0, true
+	}
+
+	projectID, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": "Invalid project ID: " + err.Error(),
+		})
+		return 0, false
+	}
+	return projectID, true
+}
+
+// rfiErrorStatus maps workflow failures to 409 Conflict, participant checks to 403 Forbidden and tasks of
+// other projects to 422 Unprocessable Entity
+func rfiErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrIllegalTransition):
+		return http.StatusConflict
+	case errors.Is(err, ErrNotParticipant):
+		return http.StatusForbidden
+	case errors.Is(err, ErrOtherProject):
+		return http.StatusUnprocessableEntity
+	}
+	return http.StatusInternalServerError
+}
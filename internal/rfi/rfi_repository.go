@@ -0,0 +1,303 @@
+package rfi
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	models "github.com/BerkatPS/internal"
+)
+
+const selectRFIColumns = `SELECT id, project_id, number, subject, question, raised_by, addressee_id, required_by, status,
+	issued_at, response, responded_by, responded_at, closed_at, created_at FROM rfis`
+
+type RFIRepository interface {
+	// CreateRFI numbers the RFI within its project and stores it with its document references
+	CreateRFI(ctx context.Context, rfi *models.RFI) error
+	FindRFIByID(ctx context.Context, id int64) (*models.RFI, error)
+	FindRFIsByProjectID(ctx context.Context, projectID int64, status string) ([]models.RFI, error)
+	// UpdateRFIDraft saves the editable fields of a draft and replaces its document references
+	UpdateRFIDraft(ctx context.Context, rfi *models.RFI) error
+	// UpdateRFIState persists status, issue, response and closure fields
+	UpdateRFIState(ctx context.Context, rfi *models.RFI) error
+	FindDocumentsByRFIID(ctx context.Context, rfiID int64) ([]models.RFIDocument, error)
+	FindTasksByRFIID(ctx context.Context, rfiID int64) ([]models.RFITask, error)
+	// FindDocumentProject returns the project of a document and its current revision
+	FindDocumentProject(ctx context.Context, documentID int64) (int64, int64, error)
+	FindTaskProjectID(ctx context.Context, taskID int64) (int64, error)
+	// LinkTasks records the tasks an RFI unblocks, skipping links that already exist
+	LinkTasks(ctx context.Context, links []models.RFITask) error
+	// FindOverdueRFIs lists open RFIs past their required-by date. projectID 0 means every project.
+	FindOverdueRFIs(ctx context.Context, projectID int64, at time.Time) ([]models.RFI, error)
+	// FindResponseTimes aggregates answered, open and overdue RFIs per project. projectID 0 means every project.
+	FindResponseTimes(ctx context.Context, projectID int64, at time.Time) ([]models.RFIResponseTime, error)
+}
+
+type rfiRepository struct {
+	db *sql.DB
+}
+
+func NewRFIRepository(db *sql.DB) RFIRepository {
+	return &rfiRepository{db}
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanRFI(row rowScanner, rfi *models.RFI) error {
+	return row.Scan(&rfi.ID, &rfi.ProjectID, &rfi.Number, &rfi.Subject, &rfi.Question, &rfi.RaisedBy, &rfi.AddresseeID, &rfi.RequiredBy, &rfi.Status,
+		&rfi.IssuedAt, &rfi.Response, &rfi.RespondedBy, &rfi.RespondedAt, &rfi.ClosedAt, &rfi.CreatedAt)
+}
+
+func (r *rfiRepository) CreateRFI(ctx context.Context, rfi *models.RFI) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Lock the project row so two RFIs raised at once cannot take the same number
+	var projectID int64
+	if err := tx.QueryRowContext(ctx, "SELECT id FROM projects WHERE id = $1 FOR UPDATE", rfi.ProjectID).Scan(&projectID); err != nil {
+		if err == sql.ErrNoRows {
+			return errors.New("project not found")
+		}
+		return err
+	}
+
+	if err := tx.QueryRowContext(ctx, "SELECT COALESCE(MAX(number), 0) + 1 FROM rfis WHERE project_id = $1", rfi.ProjectID).Scan(&rfi.Number); err != nil {
+		return err
+	}
+
+	// Issue, response and closure fields start out empty; unset timestamps are stored as the zero time
+	query := `INSERT INTO rfis (project_id, number, subject, question, raised_by, addressee_id, required_by, status,
+		issued_at, response, responded_by, responded_at, closed_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, '', 0, $9, $9, $10) RETURNING id`
+
+	var zero time.Time
+	if err := tx.QueryRowContext(ctx, query, rfi.ProjectID, rfi.Number, rfi.Subject, rfi.Question, rfi.RaisedBy, rfi.AddresseeID, rfi.RequiredBy, rfi.Status,
+		zero, rfi.CreatedAt).Scan(&rfi.ID); err != nil {
+		return err
+	}
+
+	if err := insertDocuments(ctx, tx, rfi); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *rfiRepository) FindRFIByID(ctx context.Context, id int64) (*models.RFI, error) {
+	var rfi models.RFI
+	if err := scanRFI(r.db.QueryRowContext(ctx, selectRFIColumns+" WHERE id = $1", id), &rfi); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("RFI not found")
+		}
+		return nil, err
+	}
+	return &rfi, nil
+}
+
+func (r *rfiRepository) FindRFIsByProjectID(ctx context.Context, projectID int64, status string) ([]models.RFI, error) {
+	query := selectRFIColumns + " WHERE project_id = $1 AND ($2 = '' OR status = $2) ORDER BY number"
+
+	return r.queryRFIs(ctx, query, projectID, status)
+}
+
+func (r *rfiRepository) UpdateRFIDraft(ctx context.Context, rfi *models.RFI) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := "UPDATE rfis SET subject = $1, question = $2, addressee_id = $3, required_by = $4 WHERE id = $5"
+	if _, err := tx.ExecContext(ctx, query, rfi.Subject, rfi.Question, rfi.AddresseeID, rfi.RequiredBy, rfi.ID); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM rfi_documents WHERE rfi_id = $1", rfi.ID); err != nil {
+		return err
+	}
+
+	if err := insertDocuments(ctx, tx, rfi); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *rfiRepository) UpdateRFIState(ctx context.Context, rfi *models.RFI) error {
+	query := `UPDATE rfis SET status = $1, issued_at = $2, response = $3, responded_by = $4, responded_at = $5, closed_at = $6
+		WHERE id = $7`
+
+	_, err := r.db.ExecContext(ctx, query, rfi.Status, rfi.IssuedAt, rfi.Response, rfi.RespondedBy, rfi.RespondedAt, rfi.ClosedAt, rfi.ID)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *rfiRepository) FindDocumentsByRFIID(ctx context.Context, rfiID int64) ([]models.RFIDocument, error) {
+	query := "SELECT id, rfi_id, document_id, revision_id FROM rfi_documents WHERE rfi_id = $1 ORDER BY id"
+
+	rows, err := r.db.QueryContext(ctx, query, rfiID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var documents []models.RFIDocument
+	for rows.Next() {
+		var document models.RFIDocument
+		if err := rows.Scan(&document.ID, &document.RFIID, &document.DocumentID, &document.RevisionID); err != nil {
+			return nil, err
+		}
+		documents = append(documents, document)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return documents, nil
+}
+
+func (r *rfiRepository) FindTasksByRFIID(ctx context.Context, rfiID int64) ([]models.RFITask, error) {
+	query := "SELECT id, rfi_id, task_id, linked_by, linked_at FROM rfi_tasks WHERE rfi_id = $1 ORDER BY id"
+
+	rows, err := r.db.QueryContext(ctx, query, rfiID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tasks []models.RFITask
+	for rows.Next() {
+		var task models.RFITask
+		if err := rows.Scan(&task.ID, &task.RFIID, &task.TaskID, &task.LinkedBy, &task.LinkedAt); err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, task)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return tasks, nil
+}
+
+func (r *rfiRepository) FindDocumentProject(ctx context.Context, documentID int64) (int64, int64, error) {
+	query := "SELECT project_id, current_revision_id FROM documents WHERE id = $1"
+
+	var projectID, revisionID int64
+	if err := r.db.QueryRowContext(ctx, query, documentID).Scan(&projectID, &revisionID); err != nil {
+		if err == sql.ErrNoRows {
+			return 0, 0, errors.New("document not found")
+		}
+		return 0, 0, err
+	}
+	return projectID, revisionID, nil
+}
+
+func (r *rfiRepository) FindTaskProjectID(ctx context.Context, taskID int64) (int64, error) {
+	var projectID int64
+	if err := r.db.QueryRowContext(ctx, "SELECT COALESCE(project_id, 0) FROM tasks WHERE id = $1", taskID).Scan(&projectID); err != nil {
+		if err == sql.ErrNoRows {
+			return 0, errors.New("task not found")
+		}
+		return 0, err
+	}
+	return projectID, nil
+}
+
+func (r *rfiRepository) LinkTasks(ctx context.Context, links []models.RFITask) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `INSERT INTO rfi_tasks (rfi_id, task_id, linked_by, linked_at)
+		SELECT $1, $2, $3, $4
+		WHERE NOT EXISTS (SELECT 1 FROM rfi_tasks WHERE rfi_id = $1 AND task_id = $2)`
+	for _, link := range links {
+		if _, err := tx.ExecContext(ctx, query, link.RFIID, link.TaskID, link.LinkedBy, link.LinkedAt); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (r *rfiRepository) FindOverdueRFIs(ctx context.Context, projectID int64, at time.Time) ([]models.RFI, error) {
+	query := selectRFIColumns + " WHERE status = $1 AND required_by < $2 AND ($3 = 0 OR project_id = $3) ORDER BY required_by"
+
+	return r.queryRFIs(ctx, query, StatusOpen, at, projectID)
+}
+
+func (r *rfiRepository) FindResponseTimes(ctx context.Context, projectID int64, at time.Time) ([]models.RFIResponseTime, error) {
+	query := `
+		SELECT p.id, p.name,
+			COUNT(*) FILTER (WHERE r.status IN ($1, $2)),
+			COALESCE(AVG(EXTRACT(EPOCH FROM (r.responded_at - r.issued_at)) / 3600) FILTER (WHERE r.status IN ($1, $2)), 0),
+			COUNT(*) FILTER (WHERE r.status = $3),
+			COUNT(*) FILTER (WHERE r.status = $3 AND r.required_by < $4)
+		FROM projects p
+		JOIN rfis r ON r.project_id = p.id
+		WHERE ($5 = 0 OR p.id = $5)
+		GROUP BY p.id, p.name
+		ORDER BY p.id
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, StatusAnswered, StatusClosed, StatusOpen, at, projectID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stats []models.RFIResponseTime
+	for rows.Next() {
+		var stat models.RFIResponseTime
+		if err := rows.Scan(&stat.ProjectID, &stat.ProjectName, &stat.AnsweredCount, &stat.AverageResponseHours, &stat.OpenCount, &stat.OverdueCount); err != nil {
+			return nil, err
+		}
+		stats = append(stats, stat)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+func (r *rfiRepository) queryRFIs(ctx context.Context, query string, args ...interface{}) ([]models.RFI, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rfis []models.RFI
+	for rows.Next() {
+		var rfi models.RFI
+		if err := scanRFI(rows, &rfi); err != nil {
+			return nil, err
+		}
+		rfis = append(rfis, rfi)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return rfis, nil
+}
+
+func insertDocuments(ctx context.Context, tx *sql.Tx, rfi *models.RFI) error {
+	query := "INSERT INTO rfi_documents (rfi_id, document_id, revision_id) VALUES ($1, $2, $3) RETURNING id"
+	for i := range rfi.Documents {
+		document := &rfi.Documents[i]
+		document.RFIID = rfi.ID
+		if err := tx.QueryRowContext(ctx, query, document.RFIID, document.DocumentID, document.RevisionID).Scan(&document.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
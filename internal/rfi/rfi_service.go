@@ -0,0 +1,351 @@
+package rfi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	models "github.com/BerkatPS/internal"
+)
+
+const (
+	StatusDraft    = "DRAFT"
+	StatusOpen     = "OPEN"
+	StatusAnswered = "ANSWERED"
+	StatusClosed   = "CLOSED"
+	StatusVoid     = "VOID"
+)
+
+// ErrIllegalTransition is returned when an RFI is not in a status that allows the requested step
+var ErrIllegalTransition = errors.New("illegal RFI status transition")
+
+// ErrNotParticipant is returned when someone other than the raiser or addressee attempts their step
+var ErrNotParticipant = errors.New("user is not allowed to perform this step on the RFI")
+
+// ErrOtherProject is returned when an RFI references a task that belongs to another project
+var ErrOtherProject = errors.New("task belongs to another project")
+
+type RFIService interface {
+	// CreateRFI stores a draft RFI; document references must belong to the RFI's project
+	CreateRFI(ctx context.Context, rfi *models.RFI) error
+	// FindRFIByID returns the RFI with its document references and unblocked tasks
+	FindRFIByID(ctx context.Context, id int64) (*models.RFI, error)
+	FindRFIsByProjectID(ctx context.Context, projectID int64, status string) ([]models.RFI, error)
+	// UpdateDraft changes the question, addressee, required-by date or documents of a draft, by its raiser
+	UpdateDraft(ctx context.Context, rfi *models.RFI, actorID int64) error
+	// IssueRFI sends a draft to its addressee: DRAFT -> OPEN
+	IssueRFI(ctx context.Context, id, actorID int64) error
+	// RespondRFI records the addressee's official response: OPEN -> ANSWERED
+	RespondRFI(ctx context.Context, id, actorID int64, response string) error
+	// CloseRFI accepts the response: ANSWERED -> CLOSED
+	CloseRFI(ctx context.Context, id, actorID int64) error
+	// ReopenRFI sends an insufficient response back to the addressee: ANSWERED -> OPEN
+	ReopenRFI(ctx context.Context, id, actorID int64) error
+	// VoidRFI withdraws an RFI that is no longer needed: DRAFT or OPEN -> VOID
+	VoidRFI(ctx context.Context, id, actorID int64) error
+	// LinkTasks records which tasks of the project an answered RFI unblocks
+	LinkTasks(ctx context.Context, id, actorID int64, taskIDs []int64) ([]models.RFITask, error)
+	FindOverdueRFIs(ctx context.Context, projectID int64) ([]models.RFI, error)
+	// FindResponseTimes returns the average response time per project. projectID 0 means every project.
+	FindResponseTimes(ctx context.Context, projectID int64) ([]models.RFIResponseTime, error)
+}
+
+type rfiService struct {
+	RFIRepo RFIRepository
+}
+
+func NewRFIService(rfiRepo RFIRepository) RFIService {
+	return &rfiService{rfiRepo}
+}
+
+func (s *rfiService) CreateRFI(ctx context.Context, rfi *models.RFI) error {
+	if rfi.ProjectID <= 0 {
+		return fmt.Errorf("invalid project ID")
+	}
+
+	if rfi.RaisedBy <= 0 {
+		return fmt.Errorf("invalid user ID")
+	}
+
+	if err := s.validateDraft(ctx, rfi); err != nil {
+		return err
+	}
+
+	rfi.Status = StatusDraft
+	rfi.CreatedAt = time.Now()
+
+	if err := s.RFIRepo.CreateRFI(ctx, rfi); err != nil {
+		return fmt.Errorf("failed to create RFI: %v", err)
+	}
+	return nil
+}
+
+func (s *rfiService) FindRFIByID(ctx context.Context, id int64) (*models.RFI, error) {
+	if id <= 0 {
+		return nil, fmt.Errorf("invalid RFI ID")
+	}
+
+	rfi, err := s.RFIRepo.FindRFIByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve RFI: %v", err)
+	}
+
+	rfi.Documents, err = s.RFIRepo.FindDocumentsByRFIID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve RFI documents: %v", err)
+	}
+
+	rfi.UnblockedTasks, err = s.RFIRepo.FindTasksByRFIID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve RFI tasks: %v", err)
+	}
+	return rfi, nil
+}
+
+func (s *rfiService) FindRFIsByProjectID(ctx context.Context, projectID int64, status string) ([]models.RFI, error) {
+	if projectID <= 0 {
+		return nil, fmt.Errorf("invalid project ID")
+	}
+
+	rfis, err := s.RFIRepo.FindRFIsByProjectID(ctx, projectID, status)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve RFIs: %v", err)
+	}
+	return rfis, nil
+}
+
+func (s *rfiService) UpdateDraft(ctx context.Context, rfi *models.RFI, actorID int64) error {
+	current, err := s.loadRFI(ctx, rfi.ID, StatusDraft)
+	if err != nil {
+		return err
+	}
+
+	if current.RaisedBy != actorID {
+		return fmt.Errorf("%w: only the raiser can edit a draft", ErrNotParticipant)
+	}
+
+	rfi.ProjectID = current.ProjectID
+	if err := s.validateDraft(ctx, rfi); err != nil {
+		return err
+	}
+
+	if err := s.RFIRepo.UpdateRFIDraft(ctx, rfi); err != nil {
+		return fmt.Errorf("failed to update RFI: %v", err)
+	}
+	return nil
+}
+
+func (s *rfiService) IssueRFI(ctx context.Context, id, actorID int64) error {
+	rfi, err := s.loadRFI(ctx, id, StatusDraft)
+	if err != nil {
+		return err
+	}
+
+	if rfi.RaisedBy != actorID {
+		return fmt.Errorf("%w: only the raiser can issue the RFI", ErrNotParticipant)
+	}
+
+	if !rfi.RequiredBy.After(time.Now()) {
+		return fmt.Errorf("required-by date has already passed")
+	}
+
+	rfi.Status = StatusOpen
+	rfi.IssuedAt = time.Now()
+	return s.saveRFI(ctx, rfi)
+}
+
+func (s *rfiService) RespondRFI(ctx context.Context, id, actorID int64, response string) error {
+	response = strings.TrimSpace(response)
+	if response == "" {
+		return fmt.Errorf("response cannot be empty")
+	}
+
+	rfi, err := s.loadRFI(ctx, id, StatusOpen)
+	if err != nil {
+		return err
+	}
+
+	if rfi.AddresseeID != actorID {
+		return fmt.Errorf("%w: only the addressee can respond", ErrNotParticipant)
+	}
+
+	rfi.Status = StatusAnswered
+	rfi.Response = response
+	rfi.RespondedBy = actorID
+	rfi.RespondedAt = time.Now()
+	return s.saveRFI(ctx, rfi)
+}
+
+func (s *rfiService) CloseRFI(ctx context.Context, id, actorID int64) error {
+	rfi, err := s.loadRFI(ctx, id, StatusAnswered)
+	if err != nil {
+		return err
+	}
+
+	if rfi.RaisedBy != actorID {
+		return fmt.Errorf("%w: only the raiser can close the RFI", ErrNotParticipant)
+	}
+
+	rfi.Status = StatusClosed
+	rfi.ClosedAt = time.Now()
+	return s.saveRFI(ctx, rfi)
+}
+
+func (s *rfiService) ReopenRFI(ctx context.Context, id, actorID int64) error {
+	rfi, err := s.loadRFI(ctx, id, StatusAnswered)
+	if err != nil {
+		return err
+	}
+
+	if rfi.RaisedBy != actorID {
+		return fmt.Errorf("%w: only the raiser can reopen the RFI", ErrNotParticipant)
+	}
+
+	// The previous response stays visible until the addressee answers again; response time counts to the final answer
+	rfi.Status = StatusOpen
+	rfi.RespondedAt = time.Time{}
+	return s.saveRFI(ctx, rfi)
+}
+
+func (s *rfiService) VoidRFI(ctx context.Context, id, actorID int64) error {
+	rfi, err := s.loadRFI(ctx, id, StatusDraft, StatusOpen)
+	if err != nil {
+		return err
+	}
+
+	if rfi.RaisedBy != actorID {
+		return fmt.Errorf("%w: only the raiser can void the RFI", ErrNotParticipant)
+	}
+
+	rfi.Status = StatusVoid
+	rfi.ClosedAt = time.Now()
+	return s.saveRFI(ctx, rfi)
+}
+
+func (s *rfiService) LinkTasks(ctx context.Context, id, actorID int64, taskIDs []int64) ([]models.RFITask, error) {
+	if actorID <= 0 {
+		return nil, fmt.Errorf("invalid user ID")
+	}
+
+	if len(taskIDs) == 0 {
+		return nil, fmt.Errorf("task IDs cannot be empty")
+	}
+
+	rfi, err := s.loadRFI(ctx, id, StatusAnswered, StatusClosed)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	links := make([]models.RFITask, 0, len(taskIDs))
+	for _, taskID := range taskIDs {
+		projectID, err := s.RFIRepo.FindTaskProjectID(ctx, taskID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to retrieve task %d: %v", taskID, err)
+		}
+		if projectID != rfi.ProjectID {
+			return nil, fmt.Errorf("%w: task %d is not on project %d", ErrOtherProject, taskID, rfi.ProjectID)
+		}
+		links = append(links, models.RFITask{RFIID: rfi.ID, TaskID: taskID, LinkedBy: actorID, LinkedAt: now})
+	}
+
+	if err := s.RFIRepo.LinkTasks(ctx, links); err != nil {
+		return nil, fmt.Errorf("failed to link tasks: %v", err)
+	}
+
+	tasks, err := s.RFIRepo.FindTasksByRFIID(ctx, rfi.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve RFI tasks: %v", err)
+	}
+	return tasks, nil
+}
+
+func (s *rfiService) FindOverdueRFIs(ctx context.Context, projectID int64) ([]models.RFI, error) {
+	if projectID < 0 {
+		return nil, fmt.Errorf("invalid project ID")
+	}
+
+	rfis, err := s.RFIRepo.FindOverdueRFIs(ctx, projectID, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve overdue RFIs: %v", err)
+	}
+	return rfis, nil
+}
+
+func (s *rfiService) FindResponseTimes(ctx context.Context, projectID int64) ([]models.RFIResponseTime, error) {
+	if projectID < 0 {
+		return nil, fmt.Errorf("invalid project ID")
+	}
+
+	stats, err := s.RFIRepo.FindResponseTimes(ctx, projectID, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve RFI response times: %v", err)
+	}
+	return stats, nil
+}
+
+// validateDraft checks the editable fields and pins every referenced document to its current revision
+func (s *rfiService) validateDraft(ctx context.Context, rfi *models.RFI) error {
+	rfi.Subject = strings.TrimSpace(rfi.Subject)
+	rfi.Question = strings.TrimSpace(rfi.Question)
+
+	if rfi.Subject == "" || rfi.Question == "" {
+		return fmt.Errorf("subject and question cannot be empty")
+	}
+
+	if rfi.AddresseeID <= 0 {
+		return fmt.Errorf("invalid addressee ID")
+	}
+
+	if rfi.RequiredBy.IsZero() {
+		return fmt.Errorf("required-by date cannot be empty")
+	}
+
+	seen := make(map[int64]bool)
+	documents := make([]models.RFIDocument, 0, len(rfi.Documents))
+	for _, document := range rfi.Documents {
+		if seen[document.DocumentID] {
+			continue
+		}
+		seen[document.DocumentID] = true
+
+		projectID, revisionID, err := s.RFIRepo.FindDocumentProject(ctx, document.DocumentID)
+		if err != nil {
+			return fmt.Errorf("failed to retrieve document %d: %v", document.DocumentID, err)
+		}
+		if projectID != rfi.ProjectID {
+			return fmt.Errorf("document %d does not belong to project %d", document.DocumentID, rfi.ProjectID)
+		}
+		documents = append(documents, models.RFIDocument{DocumentID: document.DocumentID, RevisionID: revisionID})
+	}
+	rfi.Documents = documents
+	return nil
+}
+
+// loadRFI fetches an RFI and checks that it is in one of the expected statuses
+func (s *rfiService) loadRFI(ctx context.Context, id int64, expected ...string) (*models.RFI, error) {
+	if id <= 0 {
+		return nil, fmt.Errorf("invalid RFI ID")
+	}
+
+	rfi, err := s.RFIRepo.FindRFIByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve RFI: %v", err)
+	}
+
+	for _, status := range expected {
+		if rfi.Status == status {
+			return rfi, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: RFI is %s, expected %v", ErrIllegalTransition, rfi.Status, expected)
+}
+
+func (s *rfiService) saveRFI(ctx context.Context, rfi *models.RFI) error {
+	if err := s.RFIRepo.UpdateRFIState(ctx, rfi); err != nil {
+		return fmt.Errorf("failed to update RFI: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,22 @@
+package rfi
+
+import (
+	"net/http"
+
+	"github.com/BerkatPS/pkg/rbac"
+)
+
+func RegisterRoutes(router *http.ServeMux, handler *RFIController, authz *rbac.Enforcer) {
+	router.HandleFunc("POST /rfis", authz.RequireProject(rbac.RFIManage, rbac.BodyProject("project_id"), handler.CreateRFI))
+	router.HandleFunc("GET /rfis/overdue", authz.RequireProject(rbac.RFIRead, rbac.QueryProject("project_id"), handler.FindOverdueRFIs))
+	router.HandleFunc("GET /rfis/response-times", authz.RequireProject(rbac.RFIRead, rbac.QueryProject("project_id"), handler.FindResponseTimes))
+	router.HandleFunc("GET /rfis/project/{id}", authz.RequireProject(rbac.RFIRead, rbac.PathProject("id"), handler.FindRFIsByProject))
+	router.HandleFunc("GET /rfis/{id}", authz.RequireProject(rbac.RFIRead, authz.PathResource(rbac.ResourceRFI, "id"), handler.FindRFIByID))
+	router.HandleFunc("PUT /rfis/{id}", authz.RequireProject(rbac.RFIManage, authz.PathResource(rbac.ResourceRFI, "id"), handler.UpdateDraft))
+	router.HandleFunc("PUT /rfis/{id}/issue", authz.RequireProject(rbac.RFIManage, authz.PathResource(rbac.ResourceRFI, "id"), handler.IssueRFI))
+	router.HandleFunc("PUT /rfis/{id}/respond", authz.RequireProject(rbac.RFIRespond, authz.PathResource(rbac.ResourceRFI, "id"), handler.RespondRFI))
+	router.HandleFunc("PUT /rfis/{id}/close", authz.RequireProject(rbac.RFIManage, authz.PathResource(rbac.ResourceRFI, "id"), handler.CloseRFI))
+	router.HandleFunc("PUT /rfis/{id}/reopen", authz.RequireProject(rbac.RFIManage, authz.PathResource(rbac.ResourceRFI, "id"), handler.ReopenRFI))
+	router.HandleFunc("PUT /rfis/{id}/void", authz.RequireProject(rbac.RFIManage, authz.PathResource(rbac.ResourceRFI, "id"), handler.VoidRFI))
+	router.HandleFunc("POST /rfis/{id}/tasks", authz.RequireProject(rbac.RFIManage, authz.PathResource(rbac.ResourceRFI, "id"), handler.LinkTasks))
+}
@@ -0,0 +1,25 @@
+package safety
+
+import (
+	"net/http"
+
+	"github.com/BerkatPS/pkg/rbac"
+)
+
+func RegisterRoutes(router *http.ServeMux, handler *SafetyController, authz *rbac.Enforcer) {
+	router.HandleFunc("POST /safety/incidents", authz.RequireProject(rbac.SafetyReport, rbac.BodyProject("project_id"), handler.ReportIncident))
+	// Anonymous reporters are not logged in; the routes are public, reports are limited per IP address and stay
+	// out of the KPIs until someone starts investigating them
+	router.HandleFunc("POST /safety/near-misses/anonymous", handler.ReportAnonymousNearMiss)
+	router.HandleFunc("POST /safety/near-misses/status", handler.FindAnonymousReport)
+	router.HandleFunc("GET /safety/incidents/{id}", authz.RequireProject(rbac.SafetyRead, authz.PathResource(rbac.ResourceSafetyIncident, "id"), handler.FindIncidentByID))
+	router.HandleFunc("PUT /safety/incidents/{id}", authz.RequireProject(rbac.SafetyInvestigate, authz.PathResource(rbac.ResourceSafetyIncident, "id"), handler.UpdateIncident))
+	router.HandleFunc("PUT /safety/incidents/{id}/investigate", authz.RequireProject(rbac.SafetyInvestigate, authz.PathResource(rbac.ResourceSafetyIncident, "id"), handler.StartInvestigation))
+	router.HandleFunc("PUT /safety/incidents/{id}/root-cause", authz.RequireProject(rbac.SafetyInvestigate, authz.PathResource(rbac.ResourceSafetyIncident, "id"), handler.RecordRootCause))
+	router.HandleFunc("POST /safety/incidents/{id}/corrective-actions", authz.RequireProject(rbac.SafetyInvestigate, authz.PathResource(rbac.ResourceSafetyIncident, "id"), handler.AddCorrectiveAction))
+	router.HandleFunc("PUT /safety/corrective-actions/{id}/complete", authz.RequireProject(rbac.SafetyCompleteAction, authz.PathResource(rbac.ResourceCorrectiveAction, "id"), handler.CompleteCorrectiveAction))
+	router.HandleFunc("PUT /safety/incidents/{id}/close", authz.RequireProject(rbac.SafetyInvestigate, authz.PathResource(rbac.ResourceSafetyIncident, "id"), handler.CloseIncident))
+	router.HandleFunc("GET /safety/project/{id}", authz.RequireProject(rbac.SafetyRead, rbac.PathProject("id"), handler.FindIncidentsByProject))
+	router.HandleFunc("GET /safety/kpi", authz.Require(rbac.SafetyPortfolio, handler.PortfolioKPI))
+	router.HandleFunc("GET /safety/kpi/project/{id}", authz.RequireProject(rbac.SafetyRead, rbac.PathProject("id"), handler.ProjectKPI))
+}
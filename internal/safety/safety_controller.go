@@ -0,0 +1,565 @@
+package safety
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	models "github.com/BerkatPS/internal"
+	"github.com/BerkatPS/pkg/principal"
+	"github.com/BerkatPS/pkg/utils"
+)
+
+type SafetyController struct {
+	SafetyService SafetyService
+}
+
+func NewSafetyController(safetyService SafetyService) *SafetyController {
+	return &SafetyController{safetyService}
+}
+
+func (s *SafetyController) FindIncidentByID(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	incidentID, err := utils.ParseInt64Param(r)
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": "Invalid incident ID: " + err.Error(),
+		})
+		return
+	}
+
+	incident, err := s.SafetyService.FindIncidentByID(ctx, incidentID)
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusInternalServerError, map[string]interface{}{
+			"status":  "error",
+			"message": "Failed to retrieve incident: " + err.Error(),
+		})
+		return
+	}
+
+	utils.JSONResponse(w, http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "Incident found successfully",
+		"data":    incident,
+	})
+}
+
+// FindIncidentsByProject lists a project's incidents, optionally filtered by ?severity= and ?status=
+func (s *SafetyController) FindIncidentsByProject(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	projectID, err := utils.ParseInt64Param(r)
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": "Invalid project ID: " + err.Error(),
+		})
+		return
+	}
+
+	severity := r.URL.Query().Get("severity")
+	status := r.URL.Query().Get("status")
+
+	incidents, err := s.SafetyService.FindIncidentsByProjectID(ctx, projectID, severity, status)
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusInternalServerError, map[string]interface{}{
+			"status":  "error",
+			"message": "Failed to retrieve incidents: " + err.Error(),
+		})
+		return
+	}
+
+	utils.JSONResponse(w, http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "Incidents found successfully",
+		"data":    incidents,
+	})
+}
+
+func (s *SafetyController) ReportIncident(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	var incident models.SafetyIncident
+	if err := json.NewDecoder(r.Body).Decode(&incident); err != nil {
+		utils.JSONErrorResponse(w, http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": "Invalid request body: " + err.Error(),
+		})
+		return
+	}
+
+	if err := s.SafetyService.ReportIncident(ctx, &incident); err != nil {
+		utils.JSONErrorResponse(w, http.StatusInternalServerError, map[string]interface{}{
+			"status":  "error",
+			"message": "Failed to report incident: " + err.Error(),
+		})
+		return
+	}
+
+	utils.JSONResponse(w, http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "Incident reported successfully",
+		"data":    incident,
+	})
+}
+
+// ReportAnonymousNearMiss accepts a near miss without any reporter identity and hands back a one-time tracking token
+func (s *SafetyController) ReportAnonymousNearMiss(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	var nearMissRequest struct {
+		ProjectID   int64     `json:"project_id"`
+		Description string    `json:"description"`
+		Severity    string    `json:"severity"`
+		Date        time.Time `json:"date"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&nearMissRequest); err != nil {
+		utils.JSONErrorResponse(w, http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": "Invalid request body: " + err.Error(),
+		})
+		return
+	}
+
+	incident := &models.SafetyIncident{
+		ProjectID:   nearMissRequest.ProjectID,
+		Description: nearMissRequest.Description,
+		Severity:    nearMissRequest.Severity,
+		Date:        nearMissRequest.Date,
+	}
+
+	token, err := s.SafetyService.ReportAnonymousNearMiss(ctx, incident, utils.ClientIP(r))
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, ErrTooManyAnonymousReports) {
+			status = http.StatusTooManyRequests
+		}
+		utils.JSONErrorResponse(w, status, map[string]interface{}{
+			"status":  "error",
+			"message": "Failed to report near miss: " + err.Error(),
+		})
+		return
+	}
+
+	utils.JSONResponse(w, http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "Near miss reported anonymously. Keep the tracking token, it is shown only once",
+		"data": map[string]interface{}{
+			"incident_id":    incident.ID,
+			"tracking_token": token,
+		},
+	})
+}
+
+// FindAnonymousReport looks up an anonymous report by tracking token. The token travels in the body so it never shows up in request logs.
+func (s *SafetyController) FindAnonymousReport(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	var trackingRequest struct {
+		Token string `json:"token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&trackingRequest); err != nil {
+		utils.JSONErrorResponse(w, http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": "Invalid request body: " + err.Error(),
+		})
+		return
+	}
+
+	incident, err := s.SafetyService.FindAnonymousReport(ctx, trackingRequest.Token)
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusNotFound, map[string]interface{}{
+			"status":  "error",
+			"message": "Failed to retrieve report: " + err.Error(),
+		})
+		return
+	}
+
+	utils.JSONResponse(w, http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "Report found successfully",
+		"data":    incident,
+	})
+}
+
+func (s *SafetyController) UpdateIncident(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	incidentID, err := utils.ParseInt64Param(r)
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": "Invalid incident ID: " + err.Error(),
+		})
+		return
+	}
+
+	var incident models.SafetyIncident
+	if err := json.NewDecoder(r.Body).Decode(&incident); err != nil {
+		utils.JSONErrorResponse(w, http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": "Invalid request body: " + err.Error(),
+		})
+		return
+	}
+	incident.ID = incidentID
+
+	if err := s.SafetyService.UpdateIncident(ctx, &incident); err != nil {
+		utils.JSONErrorResponse(w, http.StatusInternalServerError, map[string]interface{}{
+			"status":  "error",
+			"message": "Failed to update incident: " + err.Error(),
+		})
+		return
+	}
+
+	utils.JSONResponse(w, http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "Incident updated successfully",
+		"data":    incident,
+	})
+}
+
+func (s *SafetyController) StartInvestigation(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	incidentID, err := utils.ParseInt64Param(r)
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": "Invalid incident ID: " + err.Error(),
+		})
+		return
+	}
+
+	actorID, err := principal.UserIDFromRequest(r)
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusUnauthorized, map[string]interface{}{
+			"status":  "error",
+			"message": "Unauthorized: " + err.Error(),
+		})
+		return
+	}
+
+	var startInvestigationRequest struct {
+		Notes string `json:"notes"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&startInvestigationRequest); err != nil {
+		utils.JSONErrorResponse(w, http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": "Invalid request body: " + err.Error(),
+		})
+		return
+	}
+
+	if err := s.SafetyService.StartInvestigation(ctx, incidentID, actorID, startInvestigationRequest.Notes); err != nil {
+		utils.JSONErrorResponse(w, workflowErrorStatus(err), map[string]interface{}{
+			"status":  "error",
+			"message": "Failed to start investigation: " + err.Error(),
+		})
+		return
+	}
+
+	utils.JSONResponse(w, http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "Investigation started successfully",
+	})
+}
+
+func (s *SafetyController) RecordRootCause(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	incidentID, err := utils.ParseInt64Param(r)
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": "Invalid incident ID: " + err.Error(),
+		})
+		return
+	}
+
+	actorID, err := principal.UserIDFromRequest(r)
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusUnauthorized, map[string]interface{}{
+			"status":  "error",
+			"message": "Unauthorized: " + err.Error(),
+		})
+		return
+	}
+
+	var recordRootCauseRequest struct {
+		RootCause string `json:"root_cause"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&recordRootCauseRequest); err != nil {
+		utils.JSONErrorResponse(w, http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": "Invalid request body: " + err.Error(),
+		})
+		return
+	}
+
+	if err := s.SafetyService.RecordRootCause(ctx, incidentID, actorID, recordRootCauseRequest.RootCause); err != nil {
+		utils.JSONErrorResponse(w, workflowErrorStatus(err), map[string]interface{}{
+			"status":  "error",
+			"message": "Failed to record root cause: " + err.Error(),
+		})
+		return
+	}
+
+	utils.JSONResponse(w, http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "Root cause recorded successfully",
+	})
+}
+
+func (s *SafetyController) AddCorrectiveAction(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	incidentID, err := utils.ParseInt64Param(r)
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": "Invalid incident ID: " + err.Error(),
+		})
+		return
+	}
+
+	actorID, err := principal.UserIDFromRequest(r)
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusUnauthorized, map[string]interface{}{
+			"status":  "error",
+			"message": "Unauthorized: " + err.Error(),
+		})
+		return
+	}
+
+	var addCorrectiveActionRequest struct {
+		Description string    `json:"description"`
+		OwnerID     int64     `json:"owner_id"`
+		DueDate     time.Time `json:"due_date"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&addCorrectiveActionRequest); err != nil {
+		utils.JSONErrorResponse(w, http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": "Invalid request body: " + err.Error(),
+		})
+		return
+	}
+
+	action := &models.CorrectiveAction{
+		IncidentID:  incidentID,
+		Description: addCorrectiveActionRequest.Description,
+		OwnerID:     addCorrectiveActionRequest.OwnerID,
+		DueDate:     addCorrectiveActionRequest.DueDate,
+	}
+
+	if err := s.SafetyService.AddCorrectiveAction(ctx, action, actorID); err != nil {
+		utils.JSONErrorResponse(w, workflowErrorStatus(err), map[string]interface{}{
+			"status":  "error",
+			"message": "Failed to add corrective action: " + err.Error(),
+		})
+		return
+	}
+
+	utils.JSONResponse(w, http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "Corrective action added successfully",
+		"data":    action,
+	})
+}
+
+func (s *SafetyController) CompleteCorrectiveAction(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	actionID, err := utils.ParseInt64Param(r)
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": "Invalid corrective action ID: " + err.Error(),
+		})
+		return
+	}
+
+	actorID, err := principal.UserIDFromRequest(r)
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusUnauthorized, map[string]interface{}{
+			"status":  "error",
+			"message": "Unauthorized: " + err.Error(),
+		})
+		return
+	}
+
+	if err := s.SafetyService.CompleteCorrectiveAction(ctx, actionID, actorID); err != nil {
+		utils.JSONErrorResponse(w, http.StatusInternalServerError, map[string]interface{}{
+			"status":  "error",
+			"message": "Failed to complete corrective action: " + err.Error(),
+		})
+		return
+	}
+
+	utils.JSONResponse(w, http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "Corrective action completed successfully",
+	})
+}
+
+func (s *SafetyController) CloseIncident(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	incidentID, err := utils.ParseInt64Param(r)
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": "Invalid incident ID: " + err.Error(),
+		})
+		return
+	}
+
+	actorID, err := principal.UserIDFromRequest(r)
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusUnauthorized, map[string]interface{}{
+			"status":  "error",
+			"message": "Unauthorized: " + err.Error(),
+		})
+		return
+	}
+
+	var closeIncidentRequest struct {
+		Verification string `json:"verification"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&closeIncidentRequest); err != nil {
+		utils.JSONErrorResponse(w, http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": "Invalid request body: " + err.Error(),
+		})
+		return
+	}
+
+	if err := s.SafetyService.CloseIncident(ctx, incidentID, actorID, closeIncidentRequest.Verification); err != nil {
+		utils.JSONErrorResponse(w, workflowErrorStatus(err), map[string]interface{}{
+			"status":  "error",
+			"message": "Failed to close incident: " + err.Error(),
+		})
+		return
+	}
+
+	utils.JSONResponse(w, http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "Incident closed successfully",
+	})
+}
+
+// workflowErrorStatus maps illegal workflow jumps to 409 Conflict
+func workflowErrorStatus(err error) int {
+	if errors.Is(err, ErrIllegalTransition) {
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
+// ProjectKPI reports safety KPIs for one project over ?start=YYYY-MM-DD&end=YYYY-MM-DD, with optional ?shift_hours=
+func (s *SafetyController) ProjectKPI(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	projectID, err := utils.ParseInt64Param(r)
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": "Invalid project ID: " + err.Error(),
+		})
+		return
+	}
+
+	startDate, endDate, shiftHours, err := parseKPIQuery(r)
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": "Invalid query parameters: " + err.Error(),
+		})
+		return
+	}
+
+	kpi, err := s.SafetyService.ProjectKPI(ctx, projectID, startDate, endDate, shiftHours)
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusInternalServerError, map[string]interface{}{
+			"status":  "error",
+			"message": "Failed to compute safety KPI: " + err.Error(),
+		})
+		return
+	}
+
+	utils.JSONResponse(w, http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "Safety KPI computed successfully",
+		"data":    kpi,
+	})
+}
+
+// PortfolioKPI reports safety KPIs across every project over ?start=YYYY-MM-DD&end=YYYY-MM-DD, with optional ?shift_hours=
+func (s *SafetyController) PortfolioKPI(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	startDate, endDate, shiftHours, err := parseKPIQuery(r)
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": "Invalid query parameters: " + err.Error(),
+		})
+		return
+	}
+
+	kpi, err := s.SafetyService.PortfolioKPI(ctx, startDate, endDate, shiftHours)
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusInternalServerError, map[string]interface{}{
+			"status":  "error",
+			"message": "Failed to compute safety KPI: " + err.Error(),
+		})
+		return
+	}
+
+	utils.JSONResponse(w, http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "Safety KPI computed successfully",
+		"data":    kpi,
+	})
+}
+
+func parseKPIQuery(r *http.Request) (time.Time, time.Time, float64, error) {
+	query := r.URL.Query()
+
+	startDate, err := time.Parse("2006-01-02", query.Get("start"))
+	if err != nil {
+		return time.Time{}, time.Time{}, 0, fmt.Errorf("start: %v", err)
+	}
+
+	endDate, err := time.Parse("2006-01-02", query.Get("end"))
+	if err != nil {
+		return time.Time{}, time.Time{}, 0, fmt.Errorf("end: %v", err)
+	}
+
+	shiftHours := DefaultShiftHours
+	if raw := query.Get("shift_hours"); raw != "" {
+		shiftHours, err = strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return time.Time{}, time.Time{}, 0, fmt.Errorf("shift_hours: %v", err)
+		}
+	}
+	return startDate, endDate, shiftHours, nil
+}
@@ -0,0 +1,419 @@
+package safety
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	models "github.com/BerkatPS/internal"
+)
+
+const selectIncidentColumns = "SELECT id, project_id, reporter_id, anonymous, date, description, severity, type, status, root_cause, closure_verification, verified_by FROM safety_incidents"
+
+type SafetyRepository interface {
+	FindIncidentByID(ctx context.Context, id int64) (*models.SafetyIncident, error)
+	FindIncidentsByProjectID(ctx context.Context, projectID int64, severity, status string) ([]models.SafetyIncident, error)
+	CreateIncident(ctx context.Context, incident *models.SafetyIncident) error
+	// CreateAnonymousIncident stores an incident together with the hash of its reporter's tracking token
+	CreateAnonymousIncident(ctx context.Context, incident *models.SafetyIncident, tokenHash string) error
+	FindIncidentByTokenHash(ctx context.Context, tokenHash string) (*models.SafetyIncident, error)
+	// ReserveAnonymousReport counts an anonymous report against the hashed IP address unless it has sent limit
+	// reports since since, and reports whether the report may go ahead
+	ReserveAnonymousReport(ctx context.Context, ipHash string, since time.Time, limit int) (bool, error)
+	UpdateIncident(ctx context.Context, incident *models.SafetyIncident) error
+	// TransitionIncident moves an incident out of fromStatus and records the transition in the same transaction
+	TransitionIncident(ctx context.Context, incident *models.SafetyIncident, fromStatus string, transition *models.IncidentTransition) error
+	CreateTransition(ctx context.Context, transition *models.IncidentTransition) error
+	FindTransitionsByIncidentID(ctx context.Context, incidentID int64) ([]models.IncidentTransition, error)
+	CreateCorrectiveAction(ctx context.Context, action *models.CorrectiveAction) error
+	FindCorrectiveActionByID(ctx context.Context, id int64) (*models.CorrectiveAction, error)
+	FindCorrectiveActionsByIncidentID(ctx context.Context, incidentID int64) ([]models.CorrectiveAction, error)
+	CompleteCorrectiveAction(ctx context.Context, action *models.CorrectiveAction) error
+	// CountIncidents groups incidents in [startDate, endDate) by project, type, severity and whether they are anonymous
+	// reports nobody has triaged yet. projectID 0 means every project.
+	CountIncidents(ctx context.Context, projectID int64, startDate, endDate time.Time) ([]IncidentCount, error)
+	// CountPresenceDays counts attended worker-days per project in [startDate, endDate). projectID 0 means every project.
+	CountPresenceDays(ctx context.Context, projectID int64, startDate, endDate time.Time) (map[int64]int64, error)
+	// FindLastIncidentDates returns the latest non near-miss incident before endDate per project. projectID 0 means every project.
+	FindLastIncidentDates(ctx context.Context, projectID int64, endDate time.Time) (map[int64]time.Time, error)
+	FindProjectNames(ctx context.Context, projectID int64) (map[int64]string, error)
+}
+
+// IncidentCount is one row of the grouped incident counts used by the KPI engine
+type IncidentCount struct {
+	ProjectID  int64
+	Type       string
+	Severity   string
+	Unverified bool // Anonymous reports still waiting for an investigation
+	Count      int
+}
+
+type safetyRepository struct {
+	db *sql.DB
+}
+
+func NewSafetyRepository(db *sql.DB) SafetyRepository {
+	return &safetyRepository{db}
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanIncident(row rowScanner, incident *models.SafetyIncident) error {
+	return row.Scan(&incident.ID, &incident.ProjectID, &incident.ReporterID, &incident.Anonymous, &incident.Date, &incident.Description, &incident.Severity, &incident.Type, &incident.Status, &incident.RootCause, &incident.ClosureVerification, &incident.VerifiedBy)
+}
+
+func (s *safetyRepository) FindIncidentByID(ctx context.Context, id int64) (*models.SafetyIncident, error) {
+	query := selectIncidentColumns + " WHERE id = $1"
+
+	row := s.db.QueryRowContext(ctx, query, id)
+	var incident models.SafetyIncident
+	if err := scanIncident(row, &incident); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("incident not found")
+		}
+		return nil, err
+	}
+	return &incident, nil
+}
+
+// FindIncidentsByProjectID lists a project's incidents, newest first. Empty severity or status means no filter.
+func (s *safetyRepository) FindIncidentsByProjectID(ctx context.Context, projectID int64, severity, status string) ([]models.SafetyIncident, error) {
+	conditions := []string{"project_id = $1"}
+	args := []interface{}{projectID}
+
+	if severity != "" {
+		args = append(args, severity)
+		conditions = append(conditions, fmt.Sprintf("severity = $%d", len(args)))
+	}
+	if status != "" {
+		args = append(args, status)
+		conditions = append(conditions, fmt.Sprintf("status = $%d", len(args)))
+	}
+
+	query := selectIncidentColumns + " WHERE " + strings.Join(conditions, " AND ") + " ORDER BY date DESC"
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var incidents []models.SafetyIncident
+	for rows.Next() {
+		var incident models.SafetyIncident
+		if err := scanIncident(rows, &incident); err != nil {
+			return nil, err
+		}
+		incidents = append(incidents, incident)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return incidents, nil
+}
+
+const insertIncidentQuery = "INSERT INTO safety_incidents (project_id, reporter_id, anonymous, date, description, severity, type, status, root_cause, closure_verification, verified_by) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, '', '', 0) RETURNING id"
+
+func (s *safetyRepository) CreateIncident(ctx context.Context, incident *models.SafetyIncident) error {
+	return s.db.QueryRowContext(ctx, insertIncidentQuery, incident.ProjectID, incident.ReporterID, incident.Anonymous, incident.Date, incident.Description, incident.Severity, incident.Type, incident.Status).Scan(&incident.ID)
+}
+
+func (s *safetyRepository) CreateAnonymousIncident(ctx context.Context, incident *models.SafetyIncident, tokenHash string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := tx.QueryRowContext(ctx, insertIncidentQuery, incident.ProjectID, incident.ReporterID, incident.Anonymous, incident.Date, incident.Description, incident.Severity, incident.Type, incident.Status).Scan(&incident.ID); err != nil {
+		return err
+	}
+
+	tokenQuery := "INSERT INTO anonymous_report_tokens (incident_id, token_hash) VALUES ($1, $2)"
+	if _, err := tx.ExecContext(ctx, tokenQuery, incident.ID, tokenHash); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *safetyRepository) FindIncidentByTokenHash(ctx context.Context, tokenHash string) (*models.SafetyIncident, error) {
+	query := selectIncidentColumns + " WHERE id = (SELECT incident_id FROM anonymous_report_tokens WHERE token_hash = $1)"
+
+	row := s.db.QueryRowContext(ctx, query, tokenHash)
+	var incident models.SafetyIncident
+	if err := scanIncident(row, &incident); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("report not found")
+		}
+		return nil, err
+	}
+	return &incident, nil
+}
+
+// ReserveAnonymousReport holds an advisory lock on the address while it counts and records the report, so
+// parallel requests cannot all pass the count. Attempts are stored by the hour, so a reservation may count for up
+// to an hour longer than the window.
+func (s *safetyRepository) ReserveAnonymousReport(ctx context.Context, ipHash string, since time.Time, limit int) (bool, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock(hashtext('anonymous_report:' || $1))", ipHash); err != nil {
+		return false, err
+	}
+
+	var count int
+	countQuery := "SELECT COUNT(*) FROM anonymous_report_attempts WHERE ip_hash = $1 AND created_at >= $2"
+	if err := tx.QueryRowContext(ctx, countQuery, ipHash, since.Truncate(time.Hour)).Scan(&count); err != nil {
+		return false, err
+	}
+	if count >= limit {
+		return false, nil
+	}
+
+	insertQuery := "INSERT INTO anonymous_report_attempts (ip_hash, created_at) VALUES ($1, $2)"
+	if _, err := tx.ExecContext(ctx, insertQuery, ipHash, time.Now().Truncate(time.Hour)); err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
+
+func (s *safetyRepository) UpdateIncident(ctx context.Context, incident *models.SafetyIncident) error {
+	query := "UPDATE safety_incidents SET date = $1, description = $2, severity = $3, type = $4 WHERE id = $5"
+
+	_, err := s.db.ExecContext(ctx, query, incident.Date, incident.Description, incident.Severity, incident.Type, incident.ID)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *safetyRepository) TransitionIncident(ctx context.Context, incident *models.SafetyIncident, fromStatus string, transition *models.IncidentTransition) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := "UPDATE safety_incidents SET status = $1, root_cause = $2, closure_verification = $3, verified_by = $4 WHERE id = $5 AND status = $6"
+
+	result, err := tx.ExecContext(ctx, query, incident.Status, incident.RootCause, incident.ClosureVerification, incident.VerifiedBy, incident.ID, fromStatus)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return errors.New("incident status changed concurrently")
+	}
+
+	insertQuery := "INSERT INTO incident_transitions (incident_id, from_status, to_status, actor_id, notes, timestamp) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id"
+	if err := tx.QueryRowContext(ctx, insertQuery, transition.IncidentID, transition.FromStatus, transition.ToStatus, transition.ActorID, transition.Notes, transition.Timestamp).Scan(&transition.ID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *safetyRepository) CreateTransition(ctx context.Context, transition *models.IncidentTransition) error {
+	query := "INSERT INTO incident_transitions (incident_id, from_status, to_status, actor_id, notes, timestamp) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id"
+
+	return s.db.QueryRowContext(ctx, query, transition.IncidentID, transition.FromStatus, transition.ToStatus, transition.ActorID, transition.Notes, transition.Timestamp).Scan(&transition.ID)
+}
+
+func (s *safetyRepository) FindTransitionsByIncidentID(ctx context.Context, incidentID int64) ([]models.IncidentTransition, error) {
+	query := "SELECT id, incident_id, from_status, to_status, actor_id, notes, timestamp FROM incident_transitions WHERE incident_id = $1 ORDER BY timestamp ASC, id ASC"
+
+	rows, err := s.db.QueryContext(ctx, query, incidentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var transitions []models.IncidentTransition
+	for rows.Next() {
+		var transition models.IncidentTransition
+		if err := rows.Scan(&transition.ID, &transition.IncidentID, &transition.FromStatus, &transition.ToStatus, &transition.ActorID, &transition.Notes, &transition.Timestamp); err != nil {
+			return nil, err
+		}
+		transitions = append(transitions, transition)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return transitions, nil
+}
+
+func (s *safetyRepository) CreateCorrectiveAction(ctx context.Context, action *models.CorrectiveAction) error {
+	query := "INSERT INTO corrective_actions (incident_id, description, owner_id, due_date, status, completed_at, completed_by) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id"
+
+	return s.db.QueryRowContext(ctx, query, action.IncidentID, action.Description, action.OwnerID, action.DueDate, action.Status, action.CompletedAt, action.CompletedBy).Scan(&action.ID)
+}
+
+func (s *safetyRepository) FindCorrectiveActionByID(ctx context.Context, id int64) (*models.CorrectiveAction, error) {
+	query := "SELECT id, incident_id, description, owner_id, due_date, status, completed_at, completed_by FROM corrective_actions WHERE id = $1"
+
+	var action models.CorrectiveAction
+	err := s.db.QueryRowContext(ctx, query, id).Scan(&action.ID, &action.IncidentID, &action.Description, &action.OwnerID, &action.DueDate, &action.Status, &action.CompletedAt, &action.CompletedBy)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("corrective action not found")
+		}
+		return nil, err
+	}
+	return &action, nil
+}
+
+func (s *safetyRepository) FindCorrectiveActionsByIncidentID(ctx context.Context, incidentID int64) ([]models.CorrectiveAction, error) {
+	query := "SELECT id, incident_id, description, owner_id, due_date, status, completed_at, completed_by FROM corrective_actions WHERE incident_id = $1 ORDER BY due_date ASC"
+
+	rows, err := s.db.QueryContext(ctx, query, incidentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var actions []models.CorrectiveAction
+	for rows.Next() {
+		var action models.CorrectiveAction
+		if err := rows.Scan(&action.ID, &action.IncidentID, &action.Description, &action.OwnerID, &action.DueDate, &action.Status, &action.CompletedAt, &action.CompletedBy); err != nil {
+			return nil, err
+		}
+		actions = append(actions, action)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return actions, nil
+}
+
+func (s *safetyRepository) CompleteCorrectiveAction(ctx context.Context, action *models.CorrectiveAction) error {
+	query := "UPDATE corrective_actions SET status = $1, completed_at = $2, completed_by = $3 WHERE id = $4"
+
+	_, err := s.db.ExecContext(ctx, query, action.Status, action.CompletedAt, action.CompletedBy, action.ID)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *safetyRepository) CountIncidents(ctx context.Context, projectID int64, startDate, endDate time.Time) ([]IncidentCount, error) {
+	query := `
+		SELECT project_id, type, severity, anonymous AND status = 'REPORTED', COUNT(*)
+		FROM safety_incidents
+		WHERE date >= $1 AND date < $2 AND ($3 = 0 OR project_id = $3)
+		GROUP BY project_id, type, severity, anonymous AND status = 'REPORTED'
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, startDate, endDate, projectID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var counts []IncidentCount
+	for rows.Next() {
+		var count IncidentCount
+		if err := rows.Scan(&count.ProjectID, &count.Type, &count.Severity, &count.Unverified, &count.Count); err != nil {
+			return nil, err
+		}
+		counts = append(counts, count)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
+
+func (s *safetyRepository) CountPresenceDays(ctx context.Context, projectID int64, startDate, endDate time.Time) (map[int64]int64, error) {
+	query := `
+		SELECT project_id, COUNT(*)
+		FROM presences
+		WHERE date >= $1 AND date < $2 AND status <> 'ABSENT' AND ($3 = 0 OR project_id = $3)
+		GROUP BY project_id
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, startDate, endDate, projectID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	days := make(map[int64]int64)
+	for rows.Next() {
+		var id, count int64
+		if err := rows.Scan(&id, &count); err != nil {
+			return nil, err
+		}
+		days[id] = count
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return days, nil
+}
+
+func (s *safetyRepository) FindLastIncidentDates(ctx context.Context, projectID int64, endDate time.Time) (map[int64]time.Time, error) {
+	query := `
+		SELECT project_id, MAX(date)
+		FROM safety_incidents
+		WHERE date < $1 AND type <> 'NEAR_MISS' AND ($2 = 0 OR project_id = $2)
+		GROUP BY project_id
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, endDate, projectID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	dates := make(map[int64]time.Time)
+	for rows.Next() {
+		var id int64
+		var date time.Time
+		if err := rows.Scan(&id, &date); err != nil {
+			return nil, err
+		}
+		dates[id] = date
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return dates, nil
+}
+
+func (s *safetyRepository) FindProjectNames(ctx context.Context, projectID int64) (map[int64]string, error) {
+	query := "SELECT id, name FROM projects WHERE ($1 = 0 OR id = $1)"
+
+	rows, err := s.db.QueryContext(ctx, query, projectID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	names := make(map[int64]string)
+	for rows.Next() {
+		var id int64
+		var name string
+		if err := rows.Scan(&id, &name); err != nil {
+			return nil, err
+		}
+		names[id] = name
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return names, nil
+}
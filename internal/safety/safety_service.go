@@ -0,0 +1,621 @@
+package safety
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	models "github.com/BerkatPS/internal"
+	"github.com/BerkatPS/pkg/config"
+	"github.com/BerkatPS/pkg/utils"
+)
+
+const (
+	SeverityLow      = "LOW"
+	SeverityMedium   = "MEDIUM"
+	SeverityHigh     = "HIGH"
+	SeverityCritical = "CRITICAL"
+
+	StatusReported           = "REPORTED"
+	StatusUnderInvestigation = "UNDER_INVESTIGATION"
+	StatusCorrectiveAction   = "CORRECTIVE_ACTION"
+	StatusClosed             = "CLOSED"
+
+	ActionStatusOpen      = "OPEN"
+	ActionStatusCompleted = "COMPLETED"
+
+	TypeNearMiss   = "NEAR_MISS"
+	TypeFirstAid   = "FIRST_AID"
+	TypeRecordable = "RECORDABLE"
+	TypeLostTime   = "LOST_TIME"
+	TypeFatality   = "FATALITY"
+
+	// DefaultShiftHours converts one attended presence day into hours of labour exposure
+	DefaultShiftHours = 8.0
+
+	trirBaseHours  = 200000.0
+	ltifrBaseHours = 1000000.0
+)
+
+var (
+	// ErrIllegalTransition is returned when a workflow step is requested from the wrong status
+	ErrIllegalTransition = errors.New("illegal incident status transition")
+	// ErrTooManyAnonymousReports is returned once an IP address has sent its share of anonymous reports
+	ErrTooManyAnonymousReports = errors.New("too many anonymous reports from this address, try again later")
+)
+
+var validSeverities = map[string]bool{
+	SeverityLow:      true,
+	SeverityMedium:   true,
+	SeverityHigh:     true,
+	SeverityCritical: true,
+}
+
+var validTypes = map[string]bool{
+	TypeNearMiss:   true,
+	TypeFirstAid:   true,
+	TypeRecordable: true,
+	TypeLostTime:   true,
+	TypeFatality:   true,
+}
+
+// recordableTypes count towards TRIR, lostTimeTypes towards LTIFR
+var recordableTypes = map[string]bool{TypeRecordable: true, TypeLostTime: true, TypeFatality: true}
+var lostTimeTypes = map[string]bool{TypeLostTime: true, TypeFatality: true}
+
+var severityWeights = map[string]int{
+	SeverityLow:      1,
+	SeverityMedium:   3,
+	SeverityHigh:     5,
+	SeverityCritical: 10,
+}
+
+// allowedTransitions describes the investigation workflow: reported -> under investigation -> corrective action -> closed
+var allowedTransitions = map[string]string{
+	StatusReported:           StatusUnderInvestigation,
+	StatusUnderInvestigation: StatusCorrectiveAction,
+	StatusCorrectiveAction:   StatusClosed,
+}
+
+type SafetyService interface {
+	FindIncidentByID(ctx context.Context, id int64) (*models.SafetyIncident, error)
+	FindIncidentsByProjectID(ctx context.Context, projectID int64, severity, status string) ([]models.SafetyIncident, error)
+	ReportIncident(ctx context.Context, incident *models.SafetyIncident) error
+	UpdateIncident(ctx context.Context, incident *models.SafetyIncident) error
+	// ReportAnonymousNearMiss stores a near miss without any reporter identity and returns the tracking token, which is never stored in plain form.
+	// Each IP address may send a limited number of reports per window.
+	ReportAnonymousNearMiss(ctx context.Context, incident *models.SafetyIncident, ip string) (string, error)
+	// FindAnonymousReport lets the holder of a tracking token follow the status of their report
+	FindAnonymousReport(ctx context.Context, token string) (*models.SafetyIncident, error)
+	// StartInvestigation moves a reported incident under investigation
+	StartInvestigation(ctx context.Context, id, actorID int64, notes string) error
+	// RecordRootCause stores the root-cause analysis and opens the corrective action step
+	RecordRootCause(ctx context.Context, id, actorID int64, rootCause string) error
+	AddCorrectiveAction(ctx context.Context, action *models.CorrectiveAction, actorID int64) error
+	CompleteCorrectiveAction(ctx context.Context, actionID, actorID int64) error
+	// CloseIncident verifies the corrective actions and closes the incident
+	CloseIncident(ctx context.Context, id, actorID int64, verification string) error
+	// ProjectKPI computes TRIR, LTIFR, severity scores and days since the last incident for one project
+	ProjectKPI(ctx context.Context, projectID int64, startDate, endDate time.Time, shiftHours float64) (*models.SafetyKPI, error)
+	// PortfolioKPI computes the same figures across every project, with a per-project breakdown
+	PortfolioKPI(ctx context.Context, startDate, endDate time.Time, shiftHours float64) (*models.SafetyKPI, error)
+}
+
+type safetyService struct {
+	SafetyRepo   SafetyRepository
+	reportLimit  int
+	reportWindow time.Duration
+}
+
+func NewSafetyService(safetyRepo SafetyRepository, cfg *config.Config) SafetyService {
+	return &safetyService{
+		SafetyRepo:   safetyRepo,
+		reportLimit:  cfg.AnonymousReportLimit,
+		reportWindow: cfg.AnonymousReportWindow,
+	}
+}
+
+func (s *safetyService) FindIncidentByID(ctx context.Context, id int64) (*models.SafetyIncident, error) {
+	if id <= 0 {
+		return nil, fmt.Errorf("invalid incident ID")
+	}
+
+	incident, err := s.SafetyRepo.FindIncidentByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve incident: %v", err)
+	}
+
+	incident.CorrectiveActions, err = s.SafetyRepo.FindCorrectiveActionsByIncidentID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve corrective actions: %v", err)
+	}
+
+	incident.Transitions, err = s.SafetyRepo.FindTransitionsByIncidentID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve incident history: %v", err)
+	}
+	return incident, nil
+}
+
+func (s *safetyService) FindIncidentsByProjectID(ctx context.Context, projectID int64, severity, status string) ([]models.SafetyIncident, error) {
+	if projectID <= 0 {
+		return nil, fmt.Errorf("invalid project ID")
+	}
+
+	if severity != "" && !validSeverities[severity] {
+		return nil, fmt.Errorf("invalid severity %q", severity)
+	}
+
+	incidents, err := s.SafetyRepo.FindIncidentsByProjectID(ctx, projectID, severity, status)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve incidents: %v", err)
+	}
+	return incidents, nil
+}
+
+func (s *safetyService) ReportIncident(ctx context.Context, incident *models.SafetyIncident) error {
+	if incident.ProjectID <= 0 {
+		return fmt.Errorf("invalid project ID")
+	}
+
+	if incident.ReporterID <= 0 {
+		return fmt.Errorf("invalid reporter ID")
+	}
+
+	if incident.Description == "" {
+		return fmt.Errorf("description cannot be empty")
+	}
+
+	if !validSeverities[incident.Severity] {
+		return fmt.Errorf("invalid severity %q", incident.Severity)
+	}
+
+	if !validTypes[incident.Type] {
+		return fmt.Errorf("invalid incident type %q", incident.Type)
+	}
+
+	if incident.Date.IsZero() {
+		incident.Date = time.Now()
+	}
+	incident.Anonymous = false
+	incident.Status = StatusReported
+
+	if err := s.SafetyRepo.CreateIncident(ctx, incident); err != nil {
+		return fmt.Errorf("failed to report incident: %v", err)
+	}
+
+	transition := &models.IncidentTransition{
+		IncidentID: incident.ID,
+		ToStatus:   StatusReported,
+		ActorID:    incident.ReporterID,
+		Timestamp:  time.Now(),
+	}
+	if err := s.SafetyRepo.CreateTransition(ctx, transition); err != nil {
+		return fmt.Errorf("failed to record incident history: %v", err)
+	}
+	return nil
+}
+
+func (s *safetyService) ReportAnonymousNearMiss(ctx context.Context, incident *models.SafetyIncident, ip string) (string, error) {
+	if incident.ProjectID <= 0 {
+		return "", fmt.Errorf("invalid project ID")
+	}
+
+	if incident.Description == "" {
+		return "", fmt.Errorf("description cannot be empty")
+	}
+
+	if !validSeverities[incident.Severity] {
+		return "", fmt.Errorf("invalid severity %q", incident.Severity)
+	}
+
+	if incident.Date.IsZero() {
+		incident.Date = time.Now()
+	}
+
+	if ip != "" {
+		allowed, err := s.SafetyRepo.ReserveAnonymousReport(ctx, utils.HashToken(ip), time.Now().Add(-s.reportWindow), s.reportLimit)
+		if err != nil {
+			return "", fmt.Errorf("failed to check anonymous report limit: %v", err)
+		}
+		if !allowed {
+			return "", ErrTooManyAnonymousReports
+		}
+	}
+
+	// Whatever the client sent, nothing that could identify the reporter is kept
+	incident.ReporterID = 0
+	incident.Anonymous = true
+	incident.Type = TypeNearMiss
+	incident.Status = StatusReported
+
+	token, err := utils.GenerateSecureToken(32)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate tracking token: %v", err)
+	}
+
+	if err := s.SafetyRepo.CreateAnonymousIncident(ctx, incident, utils.HashToken(token)); err != nil {
+		return "", fmt.Errorf("failed to report near miss: %v", err)
+	}
+
+	transition := &models.IncidentTransition{
+		IncidentID: incident.ID,
+		ToStatus:   StatusReported,
+		Timestamp:  time.Now(),
+	}
+	if err := s.SafetyRepo.CreateTransition(ctx, transition); err != nil {
+		return "", fmt.Errorf("failed to record incident history: %v", err)
+	}
+	return token, nil
+}
+
+func (s *safetyService) FindAnonymousReport(ctx context.Context, token string) (*models.SafetyIncident, error) {
+	if token == "" {
+		return nil, fmt.Errorf("tracking token cannot be empty")
+	}
+
+	incident, err := s.SafetyRepo.FindIncidentByTokenHash(ctx, utils.HashToken(token))
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve report: %v", err)
+	}
+
+	transitions, err := s.SafetyRepo.FindTransitionsByIncidentID(ctx, incident.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve incident history: %v", err)
+	}
+
+	// Token holders see how their report progresses, not who handled it
+	for i := range transitions {
+		transitions[i].ActorID = 0
+		transitions[i].Notes = ""
+	}
+	incident.Transitions = transitions
+	incident.RootCause = ""
+	incident.ClosureVerification = ""
+	incident.VerifiedBy = 0
+	return incident, nil
+}
+
+func (s *safetyService) UpdateIncident(ctx context.Context, incident *models.SafetyIncident) error {
+	if incident.ID <= 0 {
+		return fmt.Errorf("invalid incident ID")
+	}
+
+	if incident.Description == "" {
+		return fmt.Errorf("description cannot be empty")
+	}
+
+	if !validSeverities[incident.Severity] {
+		return fmt.Errorf("invalid severity %q", incident.Severity)
+	}
+
+	if !validTypes[incident.Type] {
+		return fmt.Errorf("invalid incident type %q", incident.Type)
+	}
+
+	if incident.Date.IsZero() {
+		return fmt.Errorf("date cannot be empty")
+	}
+
+	existing, err := s.SafetyRepo.FindIncidentByID(ctx, incident.ID)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve incident: %v", err)
+	}
+
+	if existing.Status == StatusClosed {
+		return fmt.Errorf("closed incidents cannot be updated")
+	}
+
+	if err := s.SafetyRepo.UpdateIncident(ctx, incident); err != nil {
+		return fmt.Errorf("failed to update incident: %v", err)
+	}
+	return nil
+}
+
+func (s *safetyService) StartInvestigation(ctx context.Context, id, actorID int64, notes string) error {
+	incident, err := s.loadForTransition(ctx, id, actorID, StatusUnderInvestigation)
+	if err != nil {
+		return err
+	}
+
+	return s.transition(ctx, incident, actorID, notes)
+}
+
+func (s *safetyService) RecordRootCause(ctx context.Context, id, actorID int64, rootCause string) error {
+	if rootCause == "" {
+		return fmt.Errorf("root cause cannot be empty")
+	}
+
+	incident, err := s.loadForTransition(ctx, id, actorID, StatusCorrectiveAction)
+	if err != nil {
+		return err
+	}
+
+	incident.RootCause = rootCause
+	return s.transition(ctx, incident, actorID, rootCause)
+}
+
+func (s *safetyService) AddCorrectiveAction(ctx context.Context, action *models.CorrectiveAction, actorID int64) error {
+	if action.IncidentID <= 0 {
+		return fmt.Errorf("invalid incident ID")
+	}
+
+	if actorID <= 0 {
+		return fmt.Errorf("invalid actor ID")
+	}
+
+	if action.Description == "" {
+		return fmt.Errorf("description cannot be empty")
+	}
+
+	if action.OwnerID <= 0 {
+		return fmt.Errorf("invalid owner ID")
+	}
+
+	if action.DueDate.IsZero() {
+		return fmt.Errorf("due date cannot be empty")
+	}
+
+	incident, err := s.SafetyRepo.FindIncidentByID(ctx, action.IncidentID)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve incident: %v", err)
+	}
+
+	if incident.Status != StatusCorrectiveAction {
+		return fmt.Errorf("%w: corrective actions can only be assigned after the root cause is recorded", ErrIllegalTransition)
+	}
+
+	action.Status = ActionStatusOpen
+	action.CompletedAt = time.Time{}
+	action.CompletedBy = 0
+
+	if err := s.SafetyRepo.CreateCorrectiveAction(ctx, action); err != nil {
+		return fmt.Errorf("failed to create corrective action: %v", err)
+	}
+	return nil
+}
+
+func (s *safetyService) CompleteCorrectiveAction(ctx context.Context, actionID, actorID int64) error {
+	if actionID <= 0 {
+		return fmt.Errorf("invalid corrective action ID")
+	}
+
+	if actorID <= 0 {
+		return fmt.Errorf("invalid actor ID")
+	}
+
+	action, err := s.SafetyRepo.FindCorrectiveActionByID(ctx, actionID)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve corrective action: %v", err)
+	}
+
+	if action.Status == ActionStatusCompleted {
+		return fmt.Errorf("corrective action is already completed")
+	}
+
+	action.Status = ActionStatusCompleted
+	action.CompletedAt = time.Now()
+	action.CompletedBy = actorID
+
+	if err := s.SafetyRepo.CompleteCorrectiveAction(ctx, action); err != nil {
+		return fmt.Errorf("failed to complete corrective action: %v", err)
+	}
+	return nil
+}
+
+func (s *safetyService) CloseIncident(ctx context.Context, id, actorID int64, verification string) error {
+	if verification == "" {
+		return fmt.Errorf("closure verification cannot be empty")
+	}
+
+	incident, err := s.loadForTransition(ctx, id, actorID, StatusClosed)
+	if err != nil {
+		return err
+	}
+
+	actions, err := s.SafetyRepo.FindCorrectiveActionsByIncidentID(ctx, id)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve corrective actions: %v", err)
+	}
+
+	if len(actions) == 0 {
+		return fmt.Errorf("%w: cannot close an incident without any corrective action", ErrIllegalTransition)
+	}
+
+	for _, action := range actions {
+		if action.Status != ActionStatusCompleted {
+			return fmt.Errorf("%w: corrective action %d is not completed", ErrIllegalTransition, action.ID)
+		}
+	}
+
+	incident.ClosureVerification = verification
+	incident.VerifiedBy = actorID
+	return s.transition(ctx, incident, actorID, verification)
+}
+
+// loadForTransition fetches the incident and checks that moving it to the target status is a legal step
+func (s *safetyService) loadForTransition(ctx context.Context, id, actorID int64, target string) (*models.SafetyIncident, error) {
+	if id <= 0 {
+		return nil, fmt.Errorf("invalid incident ID")
+	}
+
+	if actorID <= 0 {
+		return nil, fmt.Errorf("invalid actor ID")
+	}
+
+	incident, err := s.SafetyRepo.FindIncidentByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve incident: %v", err)
+	}
+
+	if allowedTransitions[incident.Status] != target {
+		return nil, fmt.Errorf("%w: %s -> %s", ErrIllegalTransition, incident.Status, target)
+	}
+
+	return incident, nil
+}
+
+// transition moves the incident to the next workflow status and records who did it and when
+func (s *safetyService) transition(ctx context.Context, incident *models.SafetyIncident, actorID int64, notes string) error {
+	fromStatus := incident.Status
+	incident.Status = allowedTransitions[fromStatus]
+
+	transition := &models.IncidentTransition{
+		IncidentID: incident.ID,
+		FromStatus: fromStatus,
+		ToStatus:   incident.Status,
+		ActorID:    actorID,
+		Notes:      notes,
+		Timestamp:  time.Now(),
+	}
+
+	if err := s.SafetyRepo.TransitionIncident(ctx, incident, fromStatus, transition); err != nil {
+		return fmt.Errorf("failed to move incident to %s: %v", incident.Status, err)
+	}
+	return nil
+}
+
+func (s *safetyService) ProjectKPI(ctx context.Context, projectID int64, startDate, endDate time.Time, shiftHours float64) (*models.SafetyKPI, error) {
+	if projectID <= 0 {
+		return nil, fmt.Errorf("invalid project ID")
+	}
+
+	kpis, err := s.computeKPIs(ctx, projectID, startDate, endDate, shiftHours)
+	if err != nil {
+		return nil, err
+	}
+
+	kpi, ok := kpis[projectID]
+	if !ok {
+		return nil, fmt.Errorf("project not found")
+	}
+	return kpi, nil
+}
+
+func (s *safetyService) PortfolioKPI(ctx context.Context, startDate, endDate time.Time, shiftHours float64) (*models.SafetyKPI, error) {
+	kpis, err := s.computeKPIs(ctx, 0, startDate, endDate, shiftHours)
+	if err != nil {
+		return nil, err
+	}
+
+	portfolio := &models.SafetyKPI{StartDate: startDate, EndDate: endDate}
+	for _, kpi := range kpis {
+		portfolio.HoursWorked += kpi.HoursWorked
+		portfolio.TotalIncidents += kpi.TotalIncidents
+		portfolio.RecordableIncidents += kpi.RecordableIncidents
+		portfolio.LostTimeInjuries += kpi.LostTimeInjuries
+		portfolio.NearMisses += kpi.NearMisses
+		portfolio.UnverifiedReports += kpi.UnverifiedReports
+		portfolio.SeverityScore += kpi.SeverityScore
+		if kpi.LastIncidentDate.After(portfolio.LastIncidentDate) {
+			portfolio.LastIncidentDate = kpi.LastIncidentDate
+		}
+		portfolio.Projects = append(portfolio.Projects, *kpi)
+	}
+
+	sort.Slice(portfolio.Projects, func(i, j int) bool {
+		return portfolio.Projects[i].ProjectID < portfolio.Projects[j].ProjectID
+	})
+
+	applyRates(portfolio, endDate)
+	return portfolio, nil
+}
+
+// computeKPIs builds one KPI per project from incident counts and presence-based labour exposure.
+// The range is inclusive of both dates; projectID 0 means every project.
+func (s *safetyService) computeKPIs(ctx context.Context, projectID int64, startDate, endDate time.Time, shiftHours float64) (map[int64]*models.SafetyKPI, error) {
+	if startDate.IsZero() || endDate.IsZero() || endDate.Before(startDate) {
+		return nil, fmt.Errorf("invalid date range")
+	}
+
+	if shiftHours <= 0 {
+		shiftHours = DefaultShiftHours
+	}
+
+	rangeEnd := endDate.AddDate(0, 0, 1)
+
+	names, err := s.SafetyRepo.FindProjectNames(ctx, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve projects: %v", err)
+	}
+
+	kpis := make(map[int64]*models.SafetyKPI, len(names))
+	kpiFor := func(id int64) *models.SafetyKPI {
+		kpi, ok := kpis[id]
+		if !ok {
+			kpi = &models.SafetyKPI{ProjectID: id, ProjectName: names[id], StartDate: startDate, EndDate: endDate}
+			kpis[id] = kpi
+		}
+		return kpi
+	}
+	for id := range names {
+		kpiFor(id)
+	}
+
+	counts, err := s.SafetyRepo.CountIncidents(ctx, projectID, startDate, rangeEnd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count incidents: %v", err)
+	}
+
+	for _, count := range counts {
+		kpi := kpiFor(count.ProjectID)
+		// Anyone can send an anonymous report, so it only counts once someone has started investigating it
+		if count.Unverified {
+			kpi.UnverifiedReports += count.Count
+			continue
+		}
+		kpi.TotalIncidents += count.Count
+		kpi.SeverityScore += severityWeights[count.Severity] * count.Count
+		if recordableTypes[count.Type] {
+			kpi.RecordableIncidents += count.Count
+		}
+		if lostTimeTypes[count.Type] {
+			kpi.LostTimeInjuries += count.Count
+		}
+		if count.Type == TypeNearMiss {
+			kpi.NearMisses += count.Count
+		}
+	}
+
+	presenceDays, err := s.SafetyRepo.CountPresenceDays(ctx, projectID, startDate, rangeEnd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve labour exposure: %v", err)
+	}
+
+	for id, days := range presenceDays {
+		kpiFor(id).HoursWorked = float64(days) * shiftHours
+	}
+
+	lastDates, err := s.SafetyRepo.FindLastIncidentDates(ctx, projectID, rangeEnd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve last incident dates: %v", err)
+	}
+
+	for id, date := range lastDates {
+		kpiFor(id).LastIncidentDate = date
+	}
+
+	for _, kpi := range kpis {
+		applyRates(kpi, endDate)
+	}
+	return kpis, nil
+}
+
+// applyRates normalises the counts by hours worked and fills the days-since-last-incident counter as of asOf
+func applyRates(kpi *models.SafetyKPI, asOf time.Time) {
+	if kpi.HoursWorked > 0 {
+		kpi.TRIR = float64(kpi.RecordableIncidents) * trirBaseHours / kpi.HoursWorked
+		kpi.LTIFR = float64(kpi.LostTimeInjuries) * ltifrBaseHours / kpi.HoursWorked
+		kpi.SeverityRate = float64(kpi.SeverityScore) * trirBaseHours / kpi.HoursWorked
+	}
+
+	if !kpi.LastIncidentDate.IsZero() {
+		days := int(asOf.Sub(kpi.LastIncidentDate).Hours() / 24)
+		if days < 0 {
+			days = 0
+		}
+		kpi.DaysSinceLastIncident = &days
+	}
+}
@@ -0,0 +1,117 @@
+package safety
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	models "github.com/BerkatPS/internal"
+	"github.com/BerkatPS/pkg/utils"
+)
+
+// anonymousReportRepo keeps anonymous reports and the per-address counts in memory. The embedded SafetyRepository
+// is nil, so any other method the service calls panics and fails the test.
+type anonymousReportRepo struct {
+	SafetyRepository
+
+	reserved map[string]int
+	created  int
+}
+
+func (f *anonymousReportRepo) ReserveAnonymousReport(ctx context.Context, ipHash string, since time.Time, limit int) (bool, error) {
+	if f.reserved[ipHash] >= limit {
+		return false, nil
+	}
+	f.reserved[ipHash]++
+	return true, nil
+}
+
+func (f *anonymousReportRepo) CreateAnonymousIncident(ctx context.Context, incident *models.SafetyIncident, tokenHash string) error {
+	f.created++
+	incident.ID = int64(f.created)
+	return nil
+}
+
+func (f *anonymousReportRepo) CreateTransition(ctx context.Context, transition *models.IncidentTransition) error {
+	return nil
+}
+
+func TestReportAnonymousNearMissLimitsEachAddress(t *testing.T) {
+	repo := &anonymousReportRepo{reserved: map[string]int{}}
+	service := &safetyService{SafetyRepo: repo, reportLimit: 2, reportWindow: time.Hour}
+
+	tests := []struct {
+		ip      string
+		wantErr error
+	}{
+		{ip: "203.0.113.9"},
+		{ip: "203.0.113.9"},
+		{ip: "203.0.113.9", wantErr: ErrTooManyAnonymousReports},
+		{ip: "198.51.100.7"},
+	}
+
+	for i, tt := range tests {
+		incident := &models.SafetyIncident{ProjectID: 1, Description: "Unsecured load on the hoist", Severity: SeverityMedium}
+		_, err := service.ReportAnonymousNearMiss(context.Background(), incident, tt.ip)
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("report %d from %s: error = %v, want %v", i+1, tt.ip, err, tt.wantErr)
+		}
+	}
+
+	if repo.created != 3 {
+		t.Errorf("%d reports stored, want 3", repo.created)
+	}
+	if _, ok := repo.reserved["203.0.113.9"]; ok {
+		t.Errorf("address stored in plain form, want it hashed")
+	}
+	if repo.reserved[utils.HashToken("203.0.113.9")] != 2 {
+		t.Errorf("reservations = %v, want 2 for the limited address", repo.reserved)
+	}
+}
+
+// kpiRepo returns fixed incident counts and exposure for project 1. The embedded SafetyRepository is nil, so any
+// other method the service calls panics and fails the test.
+type kpiRepo struct {
+	SafetyRepository
+
+	counts []IncidentCount
+}
+
+func (f *kpiRepo) FindProjectNames(ctx context.Context, projectID int64) (map[int64]string, error) {
+	return map[int64]string{1: "Tower A"}, nil
+}
+
+func (f *kpiRepo) CountIncidents(ctx context.Context, projectID int64, startDate, endDate time.Time) ([]IncidentCount, error) {
+	return f.counts, nil
+}
+
+func (f *kpiRepo) CountPresenceDays(ctx context.Context, projectID int64, startDate, endDate time.Time) (map[int64]int64, error) {
+	return map[int64]int64{1: 25000}, nil
+}
+
+func (f *kpiRepo) FindLastIncidentDates(ctx context.Context, projectID int64, endDate time.Time) (map[int64]time.Time, error) {
+	return map[int64]time.Time{}, nil
+}
+
+func TestProjectKPILeavesOutUnverifiedReports(t *testing.T) {
+	repo := &kpiRepo{counts: []IncidentCount{
+		{ProjectID: 1, Type: TypeNearMiss, Severity: SeverityLow, Count: 2},
+		{ProjectID: 1, Type: TypeNearMiss, Severity: SeverityCritical, Unverified: true, Count: 40},
+		{ProjectID: 1, Type: TypeRecordable, Severity: SeverityHigh, Count: 1},
+	}}
+	service := &safetyService{SafetyRepo: repo}
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	kpi, err := service.ProjectKPI(context.Background(), 1, start, start.AddDate(0, 1, -1), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if kpi.TotalIncidents != 3 || kpi.NearMisses != 2 || kpi.UnverifiedReports != 40 {
+		t.Errorf("total %d, near misses %d, unverified %d, want 3, 2, 40", kpi.TotalIncidents, kpi.NearMisses, kpi.UnverifiedReports)
+	}
+	if want := severityWeights[SeverityLow]*2 + severityWeights[SeverityHigh]; kpi.SeverityScore != want {
+		t.Errorf("severity score = %d, want %d", kpi.SeverityScore, want)
+	}
+}
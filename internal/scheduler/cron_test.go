@@ -0,0 +1,110 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+func TestParseSchedule(t *testing.T) {
+	tests := []struct {
+		expression string
+		wantErr    bool
+	}{
+		{expression: "* * * * *"},
+		{expression: "0 2 * * *"},
+		{expression: "*/15 8-17 * * MON-FRI"},
+		{expression: "0,30 6 1,15 jan-mar sun"},
+		{expression: "5/10 * * * *"},
+		{expression: "0 0 * * 7"},
+		{expression: "  @Daily  "},
+		{expression: "@weekly"},
+		{expression: "", wantErr: true},
+		{expression: "* * * *", wantErr: true},
+		{expression: "* * * * * *", wantErr: true},
+		{expression: "60 * * * *", wantErr: true},
+		{expression: "* 24 * * *", wantErr: true},
+		{expression: "* * 0 * *", wantErr: true},
+		{expression: "* * * 13 *", wantErr: true},
+		{expression: "* * * * 8", wantErr: true},
+		{expression: "30-10 * * * *", wantErr: true},
+		{expression: "*/0 * * * *", wantErr: true},
+		{expression: "*/x * * * *", wantErr: true},
+		{expression: "* * * FOO *", wantErr: true},
+		{expression: "@every 5m", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			_, err := ParseSchedule(tt.expression)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseSchedule(%q) error = %v, want error %v", tt.expression, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestScheduleNext(t *testing.T) {
+	at := func(value, zone string) time.Time {
+		location := time.UTC
+		if zone != "" {
+			var err error
+			if location, err = time.LoadLocation(zone); err != nil {
+				t.Fatal(err)
+			}
+		}
+		parsed, err := time.ParseInLocation("2006-01-02 15:04", value, location)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return parsed
+	}
+
+	// 2024-01-01 is a Monday
+	tests := []struct {
+		name       string
+		expression string
+		zone       string
+		from       string
+		want       string
+	}{
+		{name: "next minute", expression: "* * * * *", from: "2024-01-01 10:00", want: "2024-01-01 10:01"},
+		{name: "later today", expression: "0 2 * * *", from: "2024-01-01 01:59", want: "2024-01-01 02:00"},
+		{name: "strictly after", expression: "0 2 * * *", from: "2024-01-01 02:00", want: "2024-01-02 02:00"},
+		{name: "step", expression: "*/15 * * * *", from: "2024-01-01 10:16", want: "2024-01-01 10:30"},
+		{name: "step with start", expression: "5/20 * * * *", from: "2024-01-01 10:26", want: "2024-01-01 10:45"},
+		{name: "hour rolls over", expression: "*/15 * * * *", from: "2024-01-01 10:50", want: "2024-01-01 11:00"},
+		{name: "weekday name", expression: "0 6 * * MON", from: "2024-01-01 07:00", want: "2024-01-08 06:00"},
+		{name: "weekday range skips weekend", expression: "0 9 * * MON-FRI", from: "2024-01-05 10:00", want: "2024-01-08 09:00"},
+		{name: "seven is sunday", expression: "0 0 * * 7", from: "2024-01-01 00:00", want: "2024-01-07 00:00"},
+		{name: "month name", expression: "0 0 1 MAR *", from: "2024-01-15 00:00", want: "2024-03-01 00:00"},
+		{name: "year rolls over", expression: "@yearly", from: "2024-06-01 00:00", want: "2025-01-01 00:00"},
+		{name: "leap day", expression: "0 0 29 2 *", from: "2024-03-01 00:00", want: "2028-02-29 00:00"},
+		{name: "day of month or weekday", expression: "0 0 15 * FRI", from: "2024-01-01 00:00", want: "2024-01-05 00:00"},
+		{name: "day of month with weekday star", expression: "0 0 15 * *", from: "2024-01-01 00:00", want: "2024-01-15 00:00"},
+		{name: "half-hour offset zone", expression: "0 11 * * *", zone: "Asia/Kolkata", from: "2024-01-01 09:40", want: "2024-01-01 11:00"},
+		{name: "half-hour offset zone next day", expression: "0 6 * * *", zone: "Asia/Kolkata", from: "2024-01-01 11:00", want: "2024-01-02 06:00"},
+		{name: "quarter-hour offset zone", expression: "30 8 * * *", zone: "Asia/Kathmandu", from: "2024-01-01 08:31", want: "2024-01-02 08:30"},
+		{name: "spring forward gap", expression: "30 2 * * *", zone: "America/New_York", from: "2024-03-10 00:00", want: "2024-03-11 02:30"},
+		{name: "never matches", expression: "0 0 31 2 *", from: "2024-01-01 00:00", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule, err := ParseSchedule(tt.expression)
+			if err != nil {
+				t.Fatalf("ParseSchedule(%q): %v", tt.expression, err)
+			}
+
+			got := schedule.Next(at(tt.from, tt.zone))
+
+			var want time.Time
+			if tt.want != "" {
+				want = at(tt.want, tt.zone)
+			}
+			if !got.Equal(want) {
+				t.Errorf("Next(%s) = %s, want %s", tt.from, got, want)
+			}
+		})
+	}
+}
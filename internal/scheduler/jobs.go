@@ -0,0 +1,109 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	models "github.com/BerkatPS/internal"
+	"github.com/BerkatPS/internal/events"
+)
+
+const (
+	JobArchiveTasks  = "archive-completed-tasks"
+	JobDetectOverdue = "detect-overdue"
+	JobWeeklyReports = "weekly-reports"
+)
+
+// TaskSweeper is the part of the task service the built-in jobs use
+type TaskSweeper interface {
+	ArchiveCompletedTasks(ctx context.Context) error
+	FindOverdueTasks(ctx context.Context, projectIDs []int64) ([]models.Task, error)
+}
+
+// RFISweeper finds RFIs past their required-by date. projectID 0 means every project.
+type RFISweeper interface {
+	FindOverdueRFIs(ctx context.Context, projectID int64) ([]models.RFI, error)
+}
+
+// WeeklyReportCompiler compiles the weekly site report of every active project
+type WeeklyReportCompiler interface {
+	CompileWeeklyReports(ctx context.Context, weekStart time.Time) (int, error)
+}
+
+// BuiltinJobs holds what the built-in jobs work on and the cron expression of each
+type BuiltinJobs struct {
+	Tasks     TaskSweeper
+	RFIs      RFISweeper
+	Reports   WeeklyReportCompiler
+	Publisher events.Publisher
+
+	ArchiveSchedule      string
+	OverdueSchedule      string
+	WeeklyReportSchedule string
+}
+
+// RegisterBuiltinJobs registers archiving, overdue detection and weekly report generation. A job with an
+// invalid expression is reported and left out while the others are still registered.
+func RegisterBuiltinJobs(s *Scheduler, jobs BuiltinJobs) error {
+	var failed []error
+
+	if err := s.Register(JobArchiveTasks, jobs.ArchiveSchedule, 0, jobs.archiveTasks); err != nil {
+		failed = append(failed, err)
+	}
+	if err := s.Register(JobDetectOverdue, jobs.OverdueSchedule, 0, jobs.detectOverdue); err != nil {
+		failed = append(failed, err)
+	}
+	if err := s.Register(JobWeeklyReports, jobs.WeeklyReportSchedule, 2*time.Hour, jobs.compileWeeklyReports); err != nil {
+		failed = append(failed, err)
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("%d built-in jobs not registered: %v", len(failed), failed)
+	}
+	return nil
+}
+
+func (j BuiltinJobs) archiveTasks(ctx context.Context) (string, error) {
+	if err := j.Tasks.ArchiveCompletedTasks(ctx); err != nil {
+		return "", err
+	}
+	return "completed tasks archived", nil
+}
+
+// detectOverdue publishes an overdue event to the project stream of every late task and RFI
+func (j BuiltinJobs) detectOverdue(ctx context.Context) (string, error) {
+	tasks, err := j.Tasks.FindOverdueTasks(ctx, nil)
+	if err != nil {
+		return "", err
+	}
+	for _, task := range tasks {
+		j.Publisher.Publish(task.ProjectID, events.EventTaskOverdue, task)
+	}
+
+	rfis, err := j.RFIs.FindOverdueRFIs(ctx, 0)
+	if err != nil {
+		return fmt.Sprintf("%d overdue tasks", len(tasks)), err
+	}
+	for _, rfi := range rfis {
+		j.Publisher.Publish(rfi.ProjectID, events.EventRFIOverdue, rfi)
+	}
+
+	return fmt.Sprintf("%d overdue tasks, %d overdue RFIs", len(tasks), len(rfis)), nil
+}
+
+// compileWeeklyReports covers the last full Monday to Sunday week
+func (j BuiltinJobs) compileWeeklyReports(ctx context.Context) (string, error) {
+	weekStart := previousWeekStart(time.Now())
+
+	compiled, err := j.Reports.CompileWeeklyReports(ctx, weekStart)
+	result := fmt.Sprintf("%d weekly reports compiled for the week of %s", compiled, weekStart.Format("2006-01-02"))
+	return result, err
+}
+
+// previousWeekStart returns midnight on the Monday of the week before t
+func previousWeekStart(t time.Time) time.Time {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	sinceMonday := (int(day.Weekday()) + 6) % 7
+	return day.AddDate(0, 0, -sinceMonday-7)
+}
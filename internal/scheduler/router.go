@@ -0,0 +1,14 @@
+package scheduler
+
+import (
+	"net/http"
+
+	"github.com/BerkatPS/pkg/rbac"
+)
+
+// RegisterRoutes exposes the scheduled jobs to admins; a manual run acts on every project
+func RegisterRoutes(router *http.ServeMux, handler *SchedulerController, authz *rbac.Enforcer) {
+	router.HandleFunc("GET /jobs", authz.Require(rbac.JobManage, handler.FindJobs))
+	router.HandleFunc("GET /jobs/{name}/runs", authz.Require(rbac.JobManage, handler.FindJobRuns))
+	router.HandleFunc("POST /jobs/{name}/run", authz.Require(rbac.JobManage, handler.RunJob))
+}
@@ -0,0 +1,384 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	models "github.com/BerkatPS/internal"
+)
+
+const (
+	StatusRunning   = "RUNNING"
+	StatusSucceeded = "SUCCEEDED"
+	StatusFailed    = "FAILED"
+
+	TriggerSchedule = "SCHEDULE"
+	TriggerManual   = "MANUAL"
+
+	// DefaultTimeout bounds a run when a job does not set its own. A RUNNING row older than the timeout plus
+	// leaseGrace is treated as abandoned by a replica that went away.
+	DefaultTimeout = 30 * time.Minute
+	leaseGrace     = 5 * time.Minute
+
+	// finishTimeout bounds recording the outcome of a run, which happens after the run context is done
+	finishTimeout = 10 * time.Second
+)
+
+var (
+	ErrUnknownJob = errors.New("unknown job")
+	ErrJobRunning = errors.New("job is already running")
+)
+
+// JobFunc does the work of a job and returns a short summary stored on the run
+type JobFunc func(ctx context.Context) (string, error)
+
+// Job is a registered background job
+type Job struct {
+	Name     string
+	Schedule *Schedule
+	Timeout  time.Duration
+	Run      JobFunc
+
+	next time.Time
+}
+
+// JobInfo describes a registered job and when it fires next on this instance
+type JobInfo struct {
+	Name     string         `json:"name"`
+	Schedule string         `json:"schedule"`
+	Timeout  string         `json:"timeout"`
+	NextRun  time.Time      `json:"next_run"`
+	Running  bool           `json:"running"` // Running on this instance
+	LastRun  *models.JobRun `json:"last_run,omitempty"`
+}
+
+// Scheduler runs registered jobs on their cron schedules. Every run is claimed in job_runs first, so with
+// several replicas each scheduled slot runs once and a job never overlaps itself.
+type Scheduler struct {
+	repo     SchedulerRepository
+	instance string
+	location *time.Location
+
+	mu      sync.Mutex
+	jobs    []*Job
+	running map[string]bool
+	wake    chan struct{}
+	started bool
+	wg      sync.WaitGroup
+	ctx     context.Context
+	cancel  context.CancelFunc
+}
+
+func NewScheduler(repo SchedulerRepository) *Scheduler {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "unknown"
+	}
+
+	return &Scheduler{
+		repo:     repo,
+		instance: fmt.Sprintf("%s-%d", host, os.Getpid()),
+		location: time.Local,
+		running:  make(map[string]bool),
+		wake:     make(chan struct{}, 1),
+	}
+}
+
+// Register adds a job firing on the cron expression. A zero timeout means DefaultTimeout.
+func (s *Scheduler) Register(name, expression string, timeout time.Duration, run JobFunc) error {
+	schedule, err := ParseSchedule(expression)
+	if err != nil {
+		return fmt.Errorf("failed to register job %s: %w", name, err)
+	}
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.findJob(name) != nil {
+		return fmt.Errorf("failed to register job %s: name already registered", name)
+	}
+
+	job := &Job{Name: name, Schedule: schedule, Timeout: timeout, Run: run}
+	if s.started {
+		job.next = schedule.Next(time.Now().In(s.location))
+		s.notify()
+	}
+	s.jobs = append(s.jobs, job)
+	return nil
+}
+
+// Start begins firing jobs in the background until ctx is cancelled or Stop is called
+func (s *Scheduler) Start(ctx context.Context) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.started {
+		return
+	}
+	s.started = true
+	s.ctx, s.cancel = context.WithCancel(ctx)
+
+	now := time.Now().In(s.location)
+	for _, job := range s.jobs {
+		job.next = job.Schedule.Next(now)
+	}
+
+	log.Printf("scheduler started on %s with %d jobs", s.instance, len(s.jobs))
+	go s.loop()
+}
+
+// Stop cancels running jobs and waits for them to record their outcome
+func (s *Scheduler) Stop() {
+	s.mu.Lock()
+	cancel := s.cancel
+	s.mu.Unlock()
+
+	if cancel != nil {
+		cancel()
+	}
+	s.wg.Wait()
+}
+
+func (s *Scheduler) loop() {
+	timer := time.NewTimer(time.Hour)
+	defer timer.Stop()
+
+	for {
+		s.mu.Lock()
+		var earliest time.Time
+		for _, job := range s.jobs {
+			if !job.next.IsZero() && (earliest.IsZero() || job.next.Before(earliest)) {
+				earliest = job.next
+			}
+		}
+		s.mu.Unlock()
+
+		// With nothing scheduled, wait for a registration
+		wait := time.Hour
+		if !earliest.IsZero() {
+			wait = time.Until(earliest)
+		}
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		timer.Reset(max(wait, 0))
+
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-s.wake:
+			continue
+		case <-timer.C:
+		}
+
+		now := time.Now().In(s.location)
+		s.mu.Lock()
+		for _, job := range s.jobs {
+			if job.next.IsZero() || job.next.After(now) {
+				continue
+			}
+			slot := job.next
+			// Slots missed while the process was busy or asleep are skipped rather than replayed
+			job.next = job.Schedule.Next(now)
+			s.dispatch(job, slot)
+		}
+		s.mu.Unlock()
+	}
+}
+
+// dispatch claims and runs a scheduled slot in the background. Callers hold s.mu.
+func (s *Scheduler) dispatch(job *Job, slot time.Time) {
+	if s.running[job.Name] {
+		log.Printf("scheduler: skipping %s at %s, previous run still in progress", job.Name, slot.Format(time.RFC3339))
+		return
+	}
+	s.running[job.Name] = true
+	s.wg.Add(1)
+
+	go func() {
+		defer s.release(job)
+
+		run, err := s.claim(s.ctx, job, slot, TriggerSchedule)
+		if err != nil {
+			log.Printf("failed to claim job %s: %v", job.Name, err)
+			return
+		}
+		if run == nil {
+			// Another replica took this slot
+			return
+		}
+		s.execute(s.ctx, job, run)
+	}()
+}
+
+// RunNow claims a manual run of a job and starts it in the background. The returned run is still RUNNING.
+func (s *Scheduler) RunNow(ctx context.Context, name string) (*models.JobRun, error) {
+	s.mu.Lock()
+	job := s.findJob(name)
+	if job == nil {
+		s.mu.Unlock()
+		return nil, ErrUnknownJob
+	}
+	if s.running[name] {
+		s.mu.Unlock()
+		return nil, ErrJobRunning
+	}
+	s.running[name] = true
+	s.wg.Add(1)
+	runCtx := s.ctx
+	s.mu.Unlock()
+
+	if runCtx == nil {
+		runCtx = context.Background()
+	}
+
+	run, err := s.claim(ctx, job, time.Now().Truncate(time.Second), TriggerManual)
+	if err != nil || run == nil {
+		s.release(job)
+		if err != nil {
+			return nil, fmt.Errorf("failed to claim job %s: %v", name, err)
+		}
+		return nil, ErrJobRunning
+	}
+
+	go func() {
+		defer s.release(job)
+		s.execute(runCtx, job, run)
+	}()
+	return run, nil
+}
+
+func (s *Scheduler) release(job *Job) {
+	s.mu.Lock()
+	delete(s.running, job.Name)
+	s.mu.Unlock()
+	s.wg.Done()
+}
+
+// claim records the run in job_runs. A nil run means the slot belongs to another replica or run.
+func (s *Scheduler) claim(ctx context.Context, job *Job, slot time.Time, trigger string) (*models.JobRun, error) {
+	now := time.Now()
+	run := &models.JobRun{
+		JobName:      job.Name,
+		ScheduledFor: slot.UTC(),
+		Trigger:      trigger,
+		Instance:     s.instance,
+		Status:       StatusRunning,
+		StartedAt:    now.UTC(),
+	}
+
+	claimed, err := s.repo.ClaimRun(ctx, run, now.Add(-job.Timeout-leaseGrace).UTC())
+	if err != nil || !claimed {
+		return nil, err
+	}
+	return run, nil
+}
+
+// execute runs a claimed job under its timeout and records the outcome
+func (s *Scheduler) execute(parent context.Context, job *Job, run *models.JobRun) {
+	ctx, cancel := context.WithTimeout(parent, job.Timeout)
+	defer cancel()
+
+	result, err := safeRun(ctx, job.Run)
+
+	run.FinishedAt = time.Now().UTC()
+	run.Result = result
+	run.Status = StatusSucceeded
+	if err != nil {
+		run.Status = StatusFailed
+		run.Error = err.Error()
+		log.Printf("job %s failed: %v", job.Name, err)
+	}
+
+	finishCtx, finishCancel := context.WithTimeout(context.Background(), finishTimeout)
+	defer finishCancel()
+	if err := s.repo.FinishRun(finishCtx, run); err != nil {
+		log.Printf("failed to record run %d of job %s: %v", run.ID, job.Name, err)
+	}
+}
+
+// safeRun keeps a panicking job from taking the server down
+func safeRun(ctx context.Context, run JobFunc) (result string, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("panic: %v", p)
+		}
+	}()
+	return run(ctx)
+}
+
+// Jobs lists the registered jobs with their next run on this instance and their latest recorded run
+func (s *Scheduler) Jobs(ctx context.Context) ([]JobInfo, error) {
+	latest, err := s.repo.FindLatestRuns(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find job runs: %v", err)
+	}
+
+	s.mu.Lock()
+	infos := make([]JobInfo, 0, len(s.jobs))
+	for _, job := range s.jobs {
+		info := JobInfo{
+			Name:     job.Name,
+			Schedule: job.Schedule.Expression,
+			Timeout:  job.Timeout.String(),
+			NextRun:  job.next,
+			Running:  s.running[job.Name],
+		}
+		if !s.started {
+			info.NextRun = job.Schedule.Next(time.Now().In(s.location))
+		}
+		if run, ok := latest[job.Name]; ok {
+			info.LastRun = &run
+		}
+		infos = append(infos, info)
+	}
+	s.mu.Unlock()
+
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	return infos, nil
+}
+
+// FindRuns returns the most recent runs of a job, newest first
+func (s *Scheduler) FindRuns(ctx context.Context, name string, limit int) ([]models.JobRun, error) {
+	s.mu.Lock()
+	job := s.findJob(name)
+	s.mu.Unlock()
+	if job == nil {
+		return nil, ErrUnknownJob
+	}
+
+	runs, err := s.repo.FindRuns(ctx, name, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find runs of job %s: %v", name, err)
+	}
+	return runs, nil
+}
+
+func (s *Scheduler) findJob(name string) *Job {
+	for _, job := range s.jobs {
+		if job.Name == name {
+			return job
+		}
+	}
+	return nil
+}
+
+// notify wakes the loop so it picks up a changed job list
+func (s *Scheduler) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,122 @@
+package scheduler
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/BerkatPS/pkg/principal"
+	"github.com/BerkatPS/pkg/utils"
+)
+
+const (
+	defaultRunLimit = 20
+	maxRunLimit     = 200
+)
+
+type SchedulerController struct {
+	Scheduler *Scheduler
+}
+
+func NewSchedulerController(scheduler *Scheduler) *SchedulerController {
+	return &SchedulerController{scheduler}
+}
+
+// FindJobs lists the registered jobs with their schedule, next run and latest recorded run
+func (c *SchedulerController) FindJobs(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	jobs, err := c.Scheduler.Jobs(ctx)
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusInternalServerError, map[string]interface{}{
+			"status":  "error",
+			"message": "Failed to retrieve jobs: " + err.Error(),
+		})
+		return
+	}
+
+	utils.JSONResponse(w, http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "Jobs found successfully",
+		"data":    jobs,
+	})
+}
+
+// FindJobRuns returns the run history of a job, newest first, limited by ?limit=
+func (c *SchedulerController) FindJobRuns(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	limit := defaultRunLimit
+	if value := r.URL.Query().Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 {
+			utils.JSONErrorResponse(w, http.StatusBadRequest, map[string]interface{}{
+				"status":  "error",
+				"message": "Invalid limit",
+			})
+			return
+		}
+		limit = min(parsed, maxRunLimit)
+	}
+
+	runs, err := c.Scheduler.FindRuns(ctx, r.PathValue("name"), limit)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, ErrUnknownJob) {
+			status = http.StatusNotFound
+		}
+		utils.JSONErrorResponse(w, status, map[string]interface{}{
+			"status":  "error",
+			"message": "Failed to retrieve job runs: " + err.Error(),
+		})
+		return
+	}
+
+	utils.JSONResponse(w, http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "Job runs found successfully",
+		"data":    runs,
+	})
+}
+
+// RunJob starts a job immediately, outside its schedule. The run continues in the background.
+func (c *SchedulerController) RunJob(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	userID, err := principal.UserIDFromRequest(r)
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusUnauthorized, map[string]interface{}{
+			"status":  "error",
+			"message": "Unauthorized: " + err.Error(),
+		})
+		return
+	}
+
+	name := r.PathValue("name")
+	run, err := c.Scheduler.RunNow(ctx, name)
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, ErrUnknownJob):
+			status = http.StatusNotFound
+		case errors.Is(err, ErrJobRunning):
+			status = http.StatusConflict
+		}
+		utils.JSONErrorResponse(w, status, map[string]interface{}{
+			"status":  "error",
+			"message": "Failed to start job: " + err.Error(),
+		})
+		return
+	}
+	log.Printf("job %s started manually by user %d as run %d", name, userID, run.ID)
+
+	utils.JSONResponse(w, http.StatusAccepted, map[string]interface{}{
+		"status":  "success",
+		"message": "Job started successfully",
+		"data":    run,
+	})
+}
@@ -0,0 +1,126 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	models "github.com/BerkatPS/internal"
+)
+
+const selectJobRunColumns = `SELECT id, job_name, scheduled_for, trigger, instance, status, result, error, started_at, finished_at FROM job_runs`
+
+type SchedulerRepository interface {
+	// ClaimRun records a RUNNING run unless the slot was already claimed or another run of the job is still
+	// within its lease. Runs older than staleBefore that never finished are marked FAILED first.
+	ClaimRun(ctx context.Context, run *models.JobRun, staleBefore time.Time) (bool, error)
+	// FinishRun stores the outcome of a claimed run
+	FinishRun(ctx context.Context, run *models.JobRun) error
+	FindRuns(ctx context.Context, jobName string, limit int) ([]models.JobRun, error)
+	// FindLatestRuns returns the most recent run of every job, keyed by job name
+	FindLatestRuns(ctx context.Context) (map[string]models.JobRun, error)
+}
+
+type schedulerRepository struct {
+	db *sql.DB
+}
+
+func NewSchedulerRepository(db *sql.DB) SchedulerRepository {
+	return &schedulerRepository{db}
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanJobRun(row rowScanner, run *models.JobRun) error {
+	return row.Scan(&run.ID, &run.JobName, &run.ScheduledFor, &run.Trigger, &run.Instance, &run.Status, &run.Result, &run.Error,
+		&run.StartedAt, &run.FinishedAt)
+}
+
+func (r *schedulerRepository) ClaimRun(ctx context.Context, run *models.JobRun, staleBefore time.Time) (bool, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	// Replicas racing for the same job queue up on this lock until the winner commits its claim
+	if _, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock(hashtext('job_runs:' || $1))", run.JobName); err != nil {
+		return false, err
+	}
+
+	// A replica that died mid-run never finishes its row; release the job once the lease has passed
+	query := `UPDATE job_runs SET status = $1, error = 'abandoned: no result reported before the lease expired', finished_at = $2
+		WHERE job_name = $3 AND status = $4 AND started_at < $5`
+	if _, err := tx.ExecContext(ctx, query, StatusFailed, run.StartedAt, run.JobName, StatusRunning, staleBefore); err != nil {
+		return false, err
+	}
+
+	var taken bool
+	query = `SELECT EXISTS (SELECT 1 FROM job_runs WHERE job_name = $1 AND (scheduled_for = $2 OR status = $3))`
+	if err := tx.QueryRowContext(ctx, query, run.JobName, run.ScheduledFor, StatusRunning).Scan(&taken); err != nil {
+		return false, err
+	}
+	if taken {
+		return false, nil
+	}
+
+	query = `INSERT INTO job_runs (job_name, scheduled_for, trigger, instance, status, result, error, started_at, finished_at)
+		VALUES ($1, $2, $3, $4, $5, '', '', $6, $7) RETURNING id`
+	var zero time.Time
+	if err := tx.QueryRowContext(ctx, query, run.JobName, run.ScheduledFor, run.Trigger, run.Instance, run.Status, run.StartedAt, zero).Scan(&run.ID); err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
+
+func (r *schedulerRepository) FinishRun(ctx context.Context, run *models.JobRun) error {
+	query := "UPDATE job_runs SET status = $1, result = $2, error = $3, finished_at = $4 WHERE id = $5"
+	_, err := r.db.ExecContext(ctx, query, run.Status, run.Result, run.Error, run.FinishedAt, run.ID)
+	return err
+}
+
+func (r *schedulerRepository) FindRuns(ctx context.Context, jobName string, limit int) ([]models.JobRun, error) {
+	query := selectJobRunColumns + " WHERE job_name = $1 ORDER BY started_at DESC, id DESC LIMIT $2"
+
+	rows, err := r.db.QueryContext(ctx, query, jobName, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var runs []models.JobRun
+	for rows.Next() {
+		var run models.JobRun
+		if err := scanJobRun(rows, &run); err != nil {
+			return nil, err
+		}
+		runs = append(runs, run)
+	}
+
+	return runs, rows.Err()
+}
+
+func (r *schedulerRepository) FindLatestRuns(ctx context.Context) (map[string]models.JobRun, error) {
+	query := `SELECT DISTINCT ON (job_name) id, job_name, scheduled_for, trigger, instance, status, result, error, started_at, finished_at
+		FROM job_runs ORDER BY job_name, started_at DESC, id DESC`
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	runs := make(map[string]models.JobRun)
+	for rows.Next() {
+		var run models.JobRun
+		if err := scanJobRun(rows, &run); err != nil {
+			return nil, err
+		}
+		runs[run.JobName] = run
+	}
+
+	return runs, rows.Err()
+}
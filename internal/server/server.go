@@ -3,62 +3,123 @@ package server
 import (
 	"database/sql"
 	"github.com/BerkatPS/internal/presence"
+	"log"
 	"net/http"
 
 	"github.com/BerkatPS/internal/auth"
+	"github.com/BerkatPS/internal/events"
 	"github.com/BerkatPS/internal/expense"
+	"github.com/BerkatPS/internal/message"
+	"github.com/BerkatPS/internal/permit"
 	"github.com/BerkatPS/internal/project"
 	"github.com/BerkatPS/internal/quality"
+	"github.com/BerkatPS/internal/report"
+	"github.com/BerkatPS/internal/rfi"
+	"github.com/BerkatPS/internal/safety"
+	"github.com/BerkatPS/internal/scheduler"
+	"github.com/BerkatPS/internal/submittal"
 	"github.com/BerkatPS/internal/task"
 	"github.com/BerkatPS/pkg/config"
+	"github.com/BerkatPS/pkg/mail"
 	"github.com/BerkatPS/pkg/middleware"
+	"github.com/BerkatPS/pkg/rbac"
+	"github.com/BerkatPS/pkg/storage"
+	"github.com/BerkatPS/pkg/utils"
 )
 
 type Server struct {
-	Router *http.ServeMux
-	db     *sql.DB
+	Router    *http.ServeMux
+	Handler   http.Handler         // Router behind the middleware chain; what the server listens with
+	Scheduler *scheduler.Scheduler // Background jobs; main starts it when enabled
+	db        *sql.DB
+	cfg       *config.Config
+
+	principals middleware.PrincipalLoader
+}
+
+// publicRoutes may be called without an access token
+var publicRoutes = []string{
+	"GET /hello",
+	"POST /register",
+	"POST /login",
+	"POST /refresh",
+	"POST /forgot-password",
+	"POST /reset-password",
+	"POST /safety/near-misses/anonymous",
+	"POST /safety/near-misses/status",
+}
+
+// apiKeyPaths are the only paths API keys may call. Every route below them checks a permission, which an API
+// key only passes when one of its scopes grants it.
+var apiKeyPaths = []string{
+	"/projects",
+	"/documents",
+	"/tasks",
+	"/expenses",
+	"/quality",
+	"/presences",
 }
 
-func NewServer(db *sql.DB) *Server {
+func NewServer(db *sql.DB, cfg *config.Config) *Server {
 	router := http.NewServeMux()
 	s := &Server{
 		Router: router,
 		db:     db,
+		cfg:    cfg,
 	}
 
-	s.applyMiddleware()
 	s.registerRoutes()
 
 	router.HandleFunc("/hello", func(w http.ResponseWriter, r *http.Request) {
 		w.Write([]byte("Hello World"))
 	})
 
+	s.applyMiddleware()
+
 	return s
 }
 
 func (s *Server) registerRoutes() {
+	// project event stream, shared by every service that publishes
+	eventBroker := events.NewBroker(events.DefaultHistorySize)
+
+	// uploaded document content
+	blobStore := storage.NewLocalStore(s.cfg.StorageDir)
+
 	// auth routes
 	authRepo := auth.NewAuthRepository(s.db)
-	authService := auth.NewAuthService(authRepo)
+	mailer, err := mail.NewSender(s.cfg.MailSender, s.cfg.MailDir)
+	if err != nil {
+		log.Printf("failed to set up mail, logging it instead: %v", err)
+		mailer = mail.NewLogSender()
+	}
+	authService := auth.NewAuthService(authRepo, mailer, s.cfg)
+	// access tokens are only accepted while their login session is open
+	utils.SetSessionChecker(authService)
+	s.principals = authService
+
+	// role-based permissions of the routes, taken from the caller's team role on the project a request works on
+	authz := rbac.NewEnforcer(rbac.NewScopeRepository(s.db))
+
 	authController := auth.NewAuthController(authService)
-	auth.RegisterRoutes(s.Router, authController)
+	auth.RegisterRoutes(s.Router, authController, authz)
 
 	// project routes
 	projectRepo := project.NewProjectRepository(s.db)
-	projectService := project.NewProjectService(projectRepo)
+	projectService := project.NewProjectService(projectRepo, eventBroker, blobStore)
 	projectController := project.NewProjectController(projectService)
-	project.RegisterRoutes(s.Router, projectController)
+	project.RegisterRoutes(s.Router, projectController, authz)
 	// expenses routes
 	expenseRepo := expense.NewExpenseRepository(s.db)
-	expenseService := expense.NewExpenseService(expenseRepo)
+	expenseService := expense.NewExpenseService(expenseRepo, eventBroker)
 	expenseController := expense.NewExpenseController(expenseService)
-	expense.RegisterRoutes(s.Router, expenseController)
+	expense.RegisterRoutes(s.Router, expenseController, authz)
 
 	//presence routes
 	presenceRepo := presence.NewPresenceRepository(s.db)
 	presenceService := presence.NewPresenceService(presenceRepo)
 	presenceController := presence.NewPresenceController(presenceService)
-	presence.RegisterRoutes(s.Router, presenceController)
+	presence.RegisterRoutes(s.Router, presenceController, authz)
 	// Document routes
 
 	// Project routes
@@ -66,36 +127,87 @@ func (s *Server) registerRoutes() {
 	// QualityCheck routes
 
 	// SafetyIncident routes
+	safetyRepo := safety.NewSafetyRepository(s.db)
+	safetyService := safety.NewSafetyService(safetyRepo, s.cfg)
+	safetyController := safety.NewSafetyController(safetyService)
+	safety.RegisterRoutes(s.Router, safetyController, authz)
 
 	// report routes
+	reportRepo := report.NewReportRepository(s.db)
+	reportService := report.NewReportService(reportRepo, blobStore)
+	reportController := report.NewReportController(reportService)
+	report.RegisterRoutes(s.Router, reportController, authz)
+
+	// permit routes
+	permitRepo := permit.NewPermitRepository(s.db)
+	permitService := permit.NewPermitService(permitRepo)
+	permitController := permit.NewPermitController(permitService)
+	permit.RegisterRoutes(s.Router, permitController, authz)
 
 	// Task Routes
 	taskRepo := task.NewTaskRepository(s.db)
-	taskService := task.NewTaskService(taskRepo)
+	taskService := task.NewTaskService(taskRepo, permitService, eventBroker)
 	taskController := task.NewTaskController(taskService)
-	task.RegisterRoutes(s.Router, taskController)
+	task.RegisterRoutes(s.Router, taskController, authz)
 
 	// Message Routes
+	messageRepo := message.NewMessageRepository(s.db)
+	messageService := message.NewMessageService(messageRepo, eventBroker)
+	messageController := message.NewMessageController(messageService)
+	message.RegisterRoutes(s.Router, messageController, authz)
 
 	// quality Routes
 	qualityRepo := quality.NewQualityRepository(s.db)
-	qualityService := quality.NewQualityService(qualityRepo)
+	qualityService := quality.NewQualityService(qualityRepo, eventBroker, reportService)
 	qualityController := quality.NewQualityController(qualityService)
-	quality.RegisterRoutes(s.Router, qualityController)
+	quality.RegisterRoutes(s.Router, qualityController, authz)
+
+	// RFI Routes
+	rfiRepo := rfi.NewRFIRepository(s.db)
+	rfiService := rfi.NewRFIService(rfiRepo)
+	rfiController := rfi.NewRFIController(rfiService)
+	rfi.RegisterRoutes(s.Router, rfiController, authz)
+
+	// Submittal Routes
+	submittalRepo := submittal.NewSubmittalRepository(s.db)
+	submittalService := submittal.NewSubmittalService(submittalRepo)
+	submittalController := submittal.NewSubmittalController(submittalService)
+	submittal.RegisterRoutes(s.Router, submittalController, authz)
+
+	// Event stream Routes
+	eventsController := events.NewEventsController(eventBroker)
+	events.RegisterRoutes(s.Router, eventsController, authz)
+
+	// Scheduled job Routes
+	s.Scheduler = scheduler.NewScheduler(scheduler.NewSchedulerRepository(s.db))
+	err = scheduler.RegisterBuiltinJobs(s.Scheduler, scheduler.BuiltinJobs{
+		Tasks:                taskService,
+		RFIs:                 rfiService,
+		Reports:              reportService,
+		Publisher:            eventBroker,
+		ArchiveSchedule:      s.cfg.ArchiveSchedule,
+		OverdueSchedule:      s.cfg.OverdueSchedule,
+		WeeklyReportSchedule: s.cfg.WeeklyReportSchedule,
+	})
+	if err != nil {
+		log.Printf("failed to register scheduled jobs: %v", err)
+	}
+	schedulerController := scheduler.NewSchedulerController(s.Scheduler)
+	scheduler.RegisterRoutes(s.Router, schedulerController, authz)
 
 }
 
 func (s *Server) applyMiddleware() {
 	// Apply middleware to all routes
-	s.Router.Handle("/", middleware.IPMiddleware(config.AllowedIPs)(
+	s.Handler = middleware.IPMiddleware(s.cfg.TrustedProxies)(
 		middleware.LoggingMiddleware(
 			middleware.RecoveryMiddleware(
 				middleware.CORSHandler(
-					middleware.AuthMiddleware(s.Router),
+					middleware.AuthMiddleware(s.principals, publicRoutes, apiKeyPaths)(s.Router),
 				),
 			),
 		),
-	))
+	)
 }
 
 // exampleHandler is an example of a simple route handler
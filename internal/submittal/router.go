@@ -0,0 +1,16 @@
+package submittal
+
+import (
+	"net/http"
+
+	"github.com/BerkatPS/pkg/rbac"
+)
+
+func RegisterRoutes(router *http.ServeMux, handler *SubmittalController, authz *rbac.Enforcer) {
+	router.HandleFunc("POST /submittals", authz.RequireProject(rbac.SubmittalSubmit, rbac.BodyProject("project_id"), handler.CreateSubmittal))
+	router.HandleFunc("GET /submittals/blocking-procurement", authz.RequireProject(rbac.SubmittalRead, rbac.QueryProject("project_id"), handler.FindBlockingProcurement))
+	router.HandleFunc("GET /submittals/project/{id}", authz.RequireProject(rbac.SubmittalRead, rbac.PathProject("id"), handler.FindSubmittalsByProject))
+	router.HandleFunc("GET /submittals/{id}", authz.RequireProject(rbac.SubmittalRead, authz.PathResource(rbac.ResourceSubmittal, "id"), handler.FindSubmittalByID))
+	router.HandleFunc("PUT /submittals/{id}/review", authz.RequireProject(rbac.SubmittalReview, authz.PathResource(rbac.ResourceSubmittal, "id"), handler.ReviewSubmittal))
+	router.HandleFunc("PUT /submittals/{id}/resubmit", authz.RequireProject(rbac.SubmittalSubmit, authz.PathResource(rbac.ResourceSubmittal, "id"), handler.ResubmitSubmittal))
+}
@@ -0,0 +1,298 @@
+package submittal
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	models "github.com/BerkatPS/internal"
+	"github.com/BerkatPS/pkg/principal"
+	"github.com/BerkatPS/pkg/utils"
+)
+
+type SubmittalController struct {
+	SubmittalService SubmittalService
+}
+
+func NewSubmittalController(submittalService SubmittalService) *SubmittalController {
+	return &SubmittalController{submittalService}
+}
+
+func (c *SubmittalController) CreateSubmittal(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	userID, err := principal.UserIDFromRequest(r)
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusUnauthorized, map[string]interface{}{
+			"status":  "error",
+			"message": "Unauthorized: " + err.Error(),
+		})
+		return
+	}
+
+	var createSubmittalRequest struct {
+		ProjectID            int64     `json:"project_id"`
+		Title                string    `json:"title"`
+		Type                 string    `json:"type"`
+		SpecSection          string    `json:"spec_section"`
+		Description          string    `json:"description"`
+		ContractorReviewerID int64     `json:"contractor_reviewer_id"`
+		ConsultantReviewerID int64     `json:"consultant_reviewer_id"`
+		OwnerReviewerID      int64     `json:"owner_reviewer_id"`
+		RequiredOnSite       time.Time `json:"required_on_site"`
+		LeadTimeDays         int       `json:"lead_time_days"`
+		DocumentIDs          []int64   `json:"document_ids"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&createSubmittalRequest); err != nil {
+		utils.JSONErrorResponse(w, http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": "Invalid request body: " + err.Error(),
+		})
+		return
+	}
+
+	submittal := &models.Submittal{
+		ProjectID:            createSubmittalRequest.ProjectID,
+		Title:                createSubmittalRequest.Title,
+		Type:                 createSubmittalRequest.Type,
+		SpecSection:          createSubmittalRequest.SpecSection,
+		Description:          createSubmittalRequest.Description,
+		SubmittedBy:          userID,
+		ContractorReviewerID: createSubmittalRequest.ContractorReviewerID,
+		ConsultantReviewerID: createSubmittalRequest.ConsultantReviewerID,
+		OwnerReviewerID:      createSubmittalRequest.OwnerReviewerID,
+		RequiredOnSite:       createSubmittalRequest.RequiredOnSite,
+		LeadTimeDays:         createSubmittalRequest.LeadTimeDays,
+		Documents:            toDocuments(createSubmittalRequest.DocumentIDs),
+	}
+
+	if err := c.SubmittalService.CreateSubmittal(ctx, submittal); err != nil {
+		utils.JSONErrorResponse(w, submittalErrorStatus(err), map[string]interface{}{
+			"status":  "error",
+			"message": "Failed to create submittal: " + err.Error(),
+		})
+		return
+	}
+
+	utils.JSONResponse(w, http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "Submittal created successfully",
+		"data":    submittal,
+	})
+}
+
+func (c *SubmittalController) FindSubmittalByID(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	submittalID, err := utils.ParseInt64Param(r)
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": "Invalid submittal ID: " + err.Error(),
+		})
+		return
+	}
+
+	submittal, err := c.SubmittalService.FindSubmittalByID(ctx, submittalID)
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusInternalServerError, map[string]interface{}{
+			"status":  "error",
+			"message": "Failed to retrieve submittal: " + err.Error(),
+		})
+		return
+	}
+
+	utils.JSONResponse(w, http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "Submittal found successfully",
+		"data":    submittal,
+	})
+}
+
+// FindSubmittalsByProject lists a project's submittal register in number order, optionally filtered by ?status=
+func (c *SubmittalController) FindSubmittalsByProject(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	projectID, err := utils.ParseInt64Param(r)
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": "Invalid project ID: " + err.Error(),
+		})
+		return
+	}
+
+	submittals, err := c.SubmittalService.FindSubmittalsByProjectID(ctx, projectID, r.URL.Query().Get("status"))
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusInternalServerError, map[string]interface{}{
+			"status":  "error",
+			"message": "Failed to retrieve submittals: " + err.Error(),
+		})
+		return
+	}
+
+	utils.JSONResponse(w, http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "Submittals found successfully",
+		"data":    submittals,
+	})
+}
+
+// ReviewSubmittal records the current reviewer's outcome: APPROVED, APPROVED_AS_NOTED or REVISE_AND_RESUBMIT
+func (c *SubmittalController) ReviewSubmittal(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	userID, err := principal.UserIDFromRequest(r)
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusUnauthorized, map[string]interface{}{
+			"status":  "error",
+			"message": "Unauthorized: " + err.Error(),
+		})
+		return
+	}
+
+	submittalID, err := utils.ParseInt64Param(r)
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": "Invalid submittal ID: " + err.Error(),
+		})
+		return
+	}
+
+	var reviewRequest struct {
+		Outcome  string `json:"outcome"`
+		Comments string `json:"comments"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&reviewRequest); err != nil {
+		utils.JSONErrorResponse(w, http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": "Invalid request body: " + err.Error(),
+		})
+		return
+	}
+
+	submittal, err := c.SubmittalService.ReviewSubmittal(ctx, submittalID, userID, reviewRequest.Outcome, reviewRequest.Comments)
+	if err != nil {
+		utils.JSONErrorResponse(w, submittalErrorStatus(err), map[string]interface{}{
+			"status":  "error",
+			"message": "Failed to review submittal: " + err.Error(),
+		})
+		return
+	}
+
+	utils.JSONResponse(w, http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "Submittal reviewed successfully",
+		"data":    submittal,
+	})
+}
+
+// ResubmitSubmittal starts the next review cycle of a submittal that was sent back, with revised documents
+func (c *SubmittalController) ResubmitSubmittal(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	userID, err := principal.UserIDFromRequest(r)
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusUnauthorized, map[string]interface{}{
+			"status":  "error",
+			"message": "Unauthorized: " + err.Error(),
+		})
+		return
+	}
+
+	submittalID, err := utils.ParseInt64Param(r)
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": "Invalid submittal ID: " + err.Error(),
+		})
+		return
+	}
+
+	var resubmitRequest struct {
+		DocumentIDs []int64 `json:"document_ids"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&resubmitRequest); err != nil {
+		utils.JSONErrorResponse(w, http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": "Invalid request body: " + err.Error(),
+		})
+		return
+	}
+
+	submittal, err := c.SubmittalService.ResubmitSubmittal(ctx, submittalID, userID, toDocuments(resubmitRequest.DocumentIDs))
+	if err != nil {
+		utils.JSONErrorResponse(w, submittalErrorStatus(err), map[string]interface{}{
+			"status":  "error",
+			"message": "Failed to resubmit submittal: " + err.Error(),
+		})
+		return
+	}
+
+	utils.JSONResponse(w, http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "Submittal resubmitted successfully",
+		"data":    submittal,
+	})
+}
+
+// FindBlockingProcurement lists submittals holding up procurement by order-by date, optionally for one ?project_id=
+func (c *SubmittalController) FindBlockingProcurement(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+
+	var projectID int64
+	if raw := r.URL.Query().Get("project_id"); raw != "" {
+		var err error
+		projectID, err = strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			utils.JSONErrorResponse(w, http.StatusBadRequest, map[string]interface{}{
+				"status":  "error",
+				"message": "Invalid project ID: " + err.Error(),
+			})
+			return
+		}
+	}
+
+	items, err := c.SubmittalService.FindBlockingProcurement(ctx, projectID)
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusInternalServerError, map[string]interface{}{
+			"status":  "error",
+			"message": "Failed to retrieve blocking submittals: " + err.Error(),
+		})
+		return
+	}
+
+	utils.JSONResponse(w, http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "Blocking submittals found successfully",
+		"data":    items,
+	})
+}
+
+func toDocuments(documentIDs []int64) []models.SubmittalDocument {
+	var documents []models.SubmittalDocument
+	for _, documentID := range documentIDs {
+		documents = append(documents, models.SubmittalDocument{DocumentID: documentID})
+	}
+	return documents
+}
+
+// submittalErrorStatus maps workflow failures to 409 Conflict and participant checks to 403 Forbidden
+func submittalErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrIllegalTransition):
+		return http.StatusConflict
+	case errors.Is(err, ErrNotParticipant):
+		return http.StatusForbidden
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,291 @@
+package submittal
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	models "github.com/BerkatPS/internal"
+)
+
+const selectSubmittalColumns = `SELECT id, project_id, number, title, type, spec_section, description, submitted_by,
+	contractor_reviewer_id, consultant_reviewer_id, owner_reviewer_id, required_on_site, lead_time_days, cycle, status, current_step,
+	created_at, approved_at FROM submittals`
+
+type SubmittalRepository interface {
+	// CreateSubmittal numbers the submittal within its project and stores it with the documents and review steps of its first cycle
+	CreateSubmittal(ctx context.Context, submittal *models.Submittal) error
+	FindSubmittalByID(ctx context.Context, id int64) (*models.Submittal, error)
+	FindSubmittalsByProjectID(ctx context.Context, projectID int64, status string) ([]models.Submittal, error)
+	FindDocumentsBySubmittalID(ctx context.Context, submittalID int64) ([]models.SubmittalDocument, error)
+	FindReviewsBySubmittalID(ctx context.Context, submittalID int64) ([]models.SubmittalReview, error)
+	// RecordReview saves a step's outcome together with the submittal's new state. skipped steps of the cycle are closed
+	// without review. It fails when the submittal has moved on since it was read.
+	RecordReview(ctx context.Context, submittal *models.Submittal, previousStep string, review *models.SubmittalReview, skipped []string) error
+	// StartCycle saves a resubmission: the submittal's new cycle with its documents and pending review steps
+	StartCycle(ctx context.Context, submittal *models.Submittal) error
+	// FindDocumentProject returns the project of a document and its current revision
+	FindDocumentProject(ctx context.Context, documentID int64) (int64, int64, error)
+	// FindBlockingSubmittals lists submittals that are not approved. projectID 0 means every project.
+	FindBlockingSubmittals(ctx context.Context, projectID int64) ([]models.Submittal, error)
+}
+
+type submittalRepository struct {
+	db *sql.DB
+}
+
+func NewSubmittalRepository(db *sql.DB) SubmittalRepository {
+	return &submittalRepository{db}
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanSubmittal(row rowScanner, submittal *models.Submittal) error {
+	return row.Scan(&submittal.ID, &submittal.ProjectID, &submittal.Number, &submittal.Title, &submittal.Type, &submittal.SpecSection,
+		&submittal.Description, &submittal.SubmittedBy, &submittal.ContractorReviewerID, &submittal.ConsultantReviewerID, &submittal.OwnerReviewerID,
+		&submittal.RequiredOnSite, &submittal.LeadTimeDays, &submittal.Cycle, &submittal.Status, &submittal.CurrentStep,
+		&submittal.CreatedAt, &submittal.ApprovedAt)
+}
+
+func (s *submittalRepository) CreateSubmittal(ctx context.Context, submittal *models.Submittal) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Lock the project row so two submittals registered at once cannot take the same number
+	var projectID int64
+	if err := tx.QueryRowContext(ctx, "SELECT id FROM projects WHERE id = $1 FOR UPDATE", submittal.ProjectID).Scan(&projectID); err != nil {
+		if err == sql.ErrNoRows {
+			return errors.New("project not found")
+		}
+		return err
+	}
+
+	if err := tx.QueryRowContext(ctx, "SELECT COALESCE(MAX(number), 0) + 1 FROM submittals WHERE project_id = $1", submittal.ProjectID).Scan(&submittal.Number); err != nil {
+		return err
+	}
+
+	query := `INSERT INTO submittals (project_id, number, title, type, spec_section, description, submitted_by,
+		contractor_reviewer_id, consultant_reviewer_id, owner_reviewer_id, required_on_site, lead_time_days, cycle, status, current_step,
+		created_at, approved_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17) RETURNING id`
+
+	if err := tx.QueryRowContext(ctx, query, submittal.ProjectID, submittal.Number, submittal.Title, submittal.Type, submittal.SpecSection,
+		submittal.Description, submittal.SubmittedBy, submittal.ContractorReviewerID, submittal.ConsultantReviewerID, submittal.OwnerReviewerID,
+		submittal.RequiredOnSite, submittal.LeadTimeDays, submittal.Cycle, submittal.Status, submittal.CurrentStep,
+		submittal.CreatedAt, submittal.ApprovedAt).Scan(&submittal.ID); err != nil {
+		return err
+	}
+
+	if err := insertCycle(ctx, tx, submittal); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *submittalRepository) FindSubmittalByID(ctx context.Context, id int64) (*models.Submittal, error) {
+	var submittal models.Submittal
+	if err := scanSubmittal(s.db.QueryRowContext(ctx, selectSubmittalColumns+" WHERE id = $1", id), &submittal); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("submittal not found")
+		}
+		return nil, err
+	}
+	return &submittal, nil
+}
+
+func (s *submittalRepository) FindSubmittalsByProjectID(ctx context.Context, projectID int64, status string) ([]models.Submittal, error) {
+	query := selectSubmittalColumns + " WHERE project_id = $1 AND ($2 = '' OR status = $2) ORDER BY number"
+
+	return s.querySubmittals(ctx, query, projectID, status)
+}
+
+func (s *submittalRepository) FindDocumentsBySubmittalID(ctx context.Context, submittalID int64) ([]models.SubmittalDocument, error) {
+	query := "SELECT id, submittal_id, cycle, document_id, revision_id FROM submittal_documents WHERE submittal_id = $1 ORDER BY cycle, id"
+
+	rows, err := s.db.QueryContext(ctx, query, submittalID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var documents []models.SubmittalDocument
+	for rows.Next() {
+		var document models.SubmittalDocument
+		if err := rows.Scan(&document.ID, &document.SubmittalID, &document.Cycle, &document.DocumentID, &document.RevisionID); err != nil {
+			return nil, err
+		}
+		documents = append(documents, document)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return documents, nil
+}
+
+func (s *submittalRepository) FindReviewsBySubmittalID(ctx context.Context, submittalID int64) ([]models.SubmittalReview, error) {
+	query := `SELECT id, submittal_id, cycle, step, sequence, reviewer_id, outcome, comments, reviewed_at
+		FROM submittal_reviews WHERE submittal_id = $1 ORDER BY cycle, sequence`
+
+	rows, err := s.db.QueryContext(ctx, query, submittalID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var reviews []models.SubmittalReview
+	for rows.Next() {
+		var review models.SubmittalReview
+		if err := rows.Scan(&review.ID, &review.SubmittalID, &review.Cycle, &review.Step, &review.Sequence, &review.ReviewerID,
+			&review.Outcome, &review.Comments, &review.ReviewedAt); err != nil {
+			return nil, err
+		}
+		reviews = append(reviews, review)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return reviews, nil
+}
+
+func (s *submittalRepository) RecordReview(ctx context.Context, submittal *models.Submittal, previousStep string, review *models.SubmittalReview, skipped []string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Only move on if nobody else reviewed this step in the meantime
+	stateQuery := `UPDATE submittals SET status = $1, current_step = $2, approved_at = $3
+		WHERE id = $4 AND status = $5 AND current_step = $6 AND cycle = $7`
+	result, err := tx.ExecContext(ctx, stateQuery, submittal.Status, submittal.CurrentStep, submittal.ApprovedAt,
+		submittal.ID, StatusUnderReview, previousStep, submittal.Cycle)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrIllegalTransition
+	}
+
+	reviewQuery := `UPDATE submittal_reviews SET outcome = $1, comments = $2, reviewed_at = $3
+		WHERE submittal_id = $4 AND cycle = $5 AND step = $6 RETURNING id, sequence`
+	if err := tx.QueryRowContext(ctx, reviewQuery, review.Outcome, review.Comments, review.ReviewedAt,
+		submittal.ID, submittal.Cycle, review.Step).Scan(&review.ID, &review.Sequence); err != nil {
+		return err
+	}
+
+	skipQuery := "UPDATE submittal_reviews SET outcome = $1 WHERE submittal_id = $2 AND cycle = $3 AND step = $4"
+	for _, step := range skipped {
+		if _, err := tx.ExecContext(ctx, skipQuery, OutcomeSkipped, submittal.ID, submittal.Cycle, step); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (s *submittalRepository) StartCycle(ctx context.Context, submittal *models.Submittal) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `UPDATE submittals SET cycle = $1, status = $2, current_step = $3
+		WHERE id = $4 AND status = $5 AND cycle = $6`
+	result, err := tx.ExecContext(ctx, query, submittal.Cycle, submittal.Status, submittal.CurrentStep,
+		submittal.ID, StatusReviseAndResubmit, submittal.Cycle-1)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrIllegalTransition
+	}
+
+	if err := insertCycle(ctx, tx, submittal); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *submittalRepository) FindDocumentProject(ctx context.Context, documentID int64) (int64, int64, error) {
+	query := "SELECT project_id, current_revision_id FROM documents WHERE id = $1"
+
+	var projectID, revisionID int64
+	if err := s.db.QueryRowContext(ctx, query, documentID).Scan(&projectID, &revisionID); err != nil {
+		if err == sql.ErrNoRows {
+			return 0, 0, errors.New("document not found")
+		}
+		return 0, 0, err
+	}
+	return projectID, revisionID, nil
+}
+
+func (s *submittalRepository) FindBlockingSubmittals(ctx context.Context, projectID int64) ([]models.Submittal, error) {
+	query := selectSubmittalColumns + " WHERE status IN ($1, $2) AND ($3 = 0 OR project_id = $3) ORDER BY required_on_site - make_interval(days => lead_time_days), id"
+
+	return s.querySubmittals(ctx, query, StatusUnderReview, StatusReviseAndResubmit, projectID)
+}
+
+func (s *submittalRepository) querySubmittals(ctx context.Context, query string, args ...interface{}) ([]models.Submittal, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var submittals []models.Submittal
+	for rows.Next() {
+		var submittal models.Submittal
+		if err := scanSubmittal(rows, &submittal); err != nil {
+			return nil, err
+		}
+		submittals = append(submittals, submittal)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return submittals, nil
+}
+
+// insertCycle stores the documents and pending review steps of the submittal's current cycle
+func insertCycle(ctx context.Context, tx *sql.Tx, submittal *models.Submittal) error {
+	documentQuery := "INSERT INTO submittal_documents (submittal_id, cycle, document_id, revision_id) VALUES ($1, $2, $3, $4) RETURNING id"
+	for i := range submittal.Documents {
+		document := &submittal.Documents[i]
+		document.SubmittalID = submittal.ID
+		document.Cycle = submittal.Cycle
+		if err := tx.QueryRowContext(ctx, documentQuery, document.SubmittalID, document.Cycle, document.DocumentID, document.RevisionID).Scan(&document.ID); err != nil {
+			return err
+		}
+	}
+
+	var zero time.Time
+	reviewQuery := `INSERT INTO submittal_reviews (submittal_id, cycle, step, sequence, reviewer_id, outcome, comments, reviewed_at)
+		VALUES ($1, $2, $3, $4, $5, $6, '', $7) RETURNING id`
+	for i := range submittal.Reviews {
+		review := &submittal.Reviews[i]
+		review.SubmittalID = submittal.ID
+		review.Cycle = submittal.Cycle
+		if err := tx.QueryRowContext(ctx, reviewQuery, review.SubmittalID, review.Cycle, review.Step, review.Sequence, review.ReviewerID,
+			review.Outcome, zero).Scan(&review.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,369 @@
+package submittal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	models "github.com/BerkatPS/internal"
+)
+
+const (
+	StatusUnderReview       = "UNDER_REVIEW"
+	StatusReviseAndResubmit = "REVISE_AND_RESUBMIT"
+	StatusApproved          = "APPROVED"
+	StatusApprovedAsNoted   = "APPROVED_AS_NOTED"
+)
+
+// Review steps, in the order a submittal passes through them
+const (
+	StepContractor = "CONTRACTOR"
+	StepConsultant = "CONSULTANT"
+	StepOwner      = "OWNER"
+)
+
+const (
+	OutcomePending           = "PENDING"
+	OutcomeApproved          = "APPROVED"
+	OutcomeApprovedAsNoted   = "APPROVED_AS_NOTED"
+	OutcomeReviseAndResubmit = "REVISE_AND_RESUBMIT"
+	OutcomeSkipped           = "SKIPPED" // Later steps of a cycle that was sent back before reaching them
+)
+
+var reviewSteps = []string{StepContractor, StepConsultant, StepOwner}
+
+// ErrIllegalTransition is returned when a submittal is not in a status that allows the requested step
+var ErrIllegalTransition = errors.New("illegal submittal status transition")
+
+// ErrNotParticipant is returned when someone other than the current reviewer or the submitter attempts their step
+var ErrNotParticipant = errors.New("user is not allowed to perform this step on the submittal")
+
+type SubmittalService interface {
+	// CreateSubmittal registers a submittal and sends its first cycle to the contractor's reviewer
+	CreateSubmittal(ctx context.Context, submittal *models.Submittal) error
+	// FindSubmittalByID returns the submittal with the documents and reviews of every cycle
+	FindSubmittalByID(ctx context.Context, id int64) (*models.Submittal, error)
+	FindSubmittalsByProjectID(ctx context.Context, projectID int64, status string) ([]models.Submittal, error)
+	// ReviewSubmittal records the current step's outcome. Approvals move the submittal to the next step or,
+	// after the owner, approve it; revise-and-resubmit sends it back to the submitter.
+	ReviewSubmittal(ctx context.Context, id, actorID int64, outcome, comments string) (*models.Submittal, error)
+	// ResubmitSubmittal starts a new cycle with revised documents: REVISE_AND_RESUBMIT -> UNDER_REVIEW
+	ResubmitSubmittal(ctx context.Context, id, actorID int64, documents []models.SubmittalDocument) (*models.Submittal, error)
+	// FindBlockingProcurement lists unapproved submittals by the date their material must be ordered. projectID 0 means every project.
+	FindBlockingProcurement(ctx context.Context, projectID int64) ([]models.SubmittalBlockingItem, error)
+}
+
+type submittalService struct {
+	SubmittalRepo SubmittalRepository
+}
+
+func NewSubmittalService(submittalRepo SubmittalRepository) SubmittalService {
+	return &submittalService{submittalRepo}
+}
+
+func (s *submittalService) CreateSubmittal(ctx context.Context, submittal *models.Submittal) error {
+	if submittal.ProjectID <= 0 {
+		return fmt.Errorf("invalid project ID")
+	}
+
+	if submittal.SubmittedBy <= 0 {
+		return fmt.Errorf("invalid user ID")
+	}
+
+	submittal.Title = strings.TrimSpace(submittal.Title)
+	submittal.Type = strings.TrimSpace(submittal.Type)
+	if submittal.Title == "" || submittal.Type == "" {
+		return fmt.Errorf("title and type cannot be empty")
+	}
+
+	if submittal.ContractorReviewerID <= 0 || submittal.ConsultantReviewerID <= 0 || submittal.OwnerReviewerID <= 0 {
+		return fmt.Errorf("contractor, consultant and owner reviewers are required")
+	}
+
+	if submittal.RequiredOnSite.IsZero() {
+		return fmt.Errorf("required-on-site date cannot be empty")
+	}
+
+	if submittal.LeadTimeDays < 0 {
+		return fmt.Errorf("lead time cannot be negative")
+	}
+
+	documents, err := s.pinDocuments(ctx, submittal.ProjectID, submittal.Documents)
+	if err != nil {
+		return err
+	}
+
+	submittal.Cycle = 1
+	submittal.Documents = documents
+	submittal.CreatedAt = time.Now()
+	submittal.ApprovedAt = time.Time{}
+	startCycle(submittal)
+
+	if err := s.SubmittalRepo.CreateSubmittal(ctx, submittal); err != nil {
+		return fmt.Errorf("failed to create submittal: %v", err)
+	}
+	return nil
+}
+
+func (s *submittalService) FindSubmittalByID(ctx context.Context, id int64) (*models.Submittal, error) {
+	if id <= 0 {
+		return nil, fmt.Errorf("invalid submittal ID")
+	}
+
+	submittal, err := s.SubmittalRepo.FindSubmittalByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve submittal: %v", err)
+	}
+
+	submittal.Documents, err = s.SubmittalRepo.FindDocumentsBySubmittalID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve submittal documents: %v", err)
+	}
+
+	submittal.Reviews, err = s.SubmittalRepo.FindReviewsBySubmittalID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve submittal reviews: %v", err)
+	}
+	return submittal, nil
+}
+
+func (s *submittalService) FindSubmittalsByProjectID(ctx context.Context, projectID int64, status string) ([]models.Submittal, error) {
+	if projectID <= 0 {
+		return nil, fmt.Errorf("invalid project ID")
+	}
+
+	submittals, err := s.SubmittalRepo.FindSubmittalsByProjectID(ctx, projectID, status)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve submittals: %v", err)
+	}
+	return submittals, nil
+}
+
+func (s *submittalService) ReviewSubmittal(ctx context.Context, id, actorID int64, outcome, comments string) (*models.Submittal, error) {
+	comments = strings.TrimSpace(comments)
+	switch outcome {
+	case OutcomeApproved:
+	case OutcomeApprovedAsNoted, OutcomeReviseAndResubmit:
+		if comments == "" {
+			return nil, fmt.Errorf("comments are required when the outcome is %s", outcome)
+		}
+	default:
+		return nil, fmt.Errorf("invalid review outcome %q", outcome)
+	}
+
+	submittal, err := s.loadSubmittal(ctx, id, StatusUnderReview)
+	if err != nil {
+		return nil, err
+	}
+
+	step := submittal.CurrentStep
+	if reviewerFor(submittal, step) != actorID {
+		return nil, fmt.Errorf("%w: only the %s reviewer can review this step", ErrNotParticipant, strings.ToLower(step))
+	}
+
+	reviews, err := s.SubmittalRepo.FindReviewsBySubmittalID(ctx, submittal.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve submittal reviews: %v", err)
+	}
+
+	review := &models.SubmittalReview{
+		SubmittalID: submittal.ID,
+		Cycle:       submittal.Cycle,
+		Step:        step,
+		ReviewerID:  actorID,
+		Outcome:     outcome,
+		Comments:    comments,
+		ReviewedAt:  time.Now(),
+	}
+
+	var skipped []string
+	next := nextStep(step)
+	switch {
+	case outcome == OutcomeReviseAndResubmit:
+		submittal.Status = StatusReviseAndResubmit
+		submittal.CurrentStep = ""
+		for remaining := next; remaining != ""; remaining = nextStep(remaining) {
+			skipped = append(skipped, remaining)
+		}
+	case next != "":
+		submittal.CurrentStep = next
+	default:
+		// The owner signed off; any step approving with notes carries over to the final status
+		submittal.Status = StatusApproved
+		if outcome == OutcomeApprovedAsNoted || notedInCycle(reviews, submittal.Cycle) {
+			submittal.Status = StatusApprovedAsNoted
+		}
+		submittal.CurrentStep = ""
+		submittal.ApprovedAt = review.ReviewedAt
+	}
+
+	if err := s.SubmittalRepo.RecordReview(ctx, submittal, step, review, skipped); err != nil {
+		if errors.Is(err, ErrIllegalTransition) {
+			return nil, fmt.Errorf("%w: submittal was reviewed by someone else in the meantime", ErrIllegalTransition)
+		}
+		return nil, fmt.Errorf("failed to record review: %v", err)
+	}
+
+	return s.FindSubmittalByID(ctx, submittal.ID)
+}
+
+func (s *submittalService) ResubmitSubmittal(ctx context.Context, id, actorID int64, documents []models.SubmittalDocument) (*models.Submittal, error) {
+	if len(documents) == 0 {
+		return nil, fmt.Errorf("a resubmission needs at least one document")
+	}
+
+	submittal, err := s.loadSubmittal(ctx, id, StatusReviseAndResubmit)
+	if err != nil {
+		return nil, err
+	}
+
+	if submittal.SubmittedBy != actorID {
+		return nil, fmt.Errorf("%w: only the submitter can resubmit", ErrNotParticipant)
+	}
+
+	submittal.Documents, err = s.pinDocuments(ctx, submittal.ProjectID, documents)
+	if err != nil {
+		return nil, err
+	}
+
+	submittal.Cycle++
+	startCycle(submittal)
+
+	if err := s.SubmittalRepo.StartCycle(ctx, submittal); err != nil {
+		if errors.Is(err, ErrIllegalTransition) {
+			return nil, fmt.Errorf("%w: submittal was resubmitted in the meantime", ErrIllegalTransition)
+		}
+		return nil, fmt.Errorf("failed to resubmit submittal: %v", err)
+	}
+
+	return s.FindSubmittalByID(ctx, submittal.ID)
+}
+
+func (s *submittalService) FindBlockingProcurement(ctx context.Context, projectID int64) ([]models.SubmittalBlockingItem, error) {
+	if projectID < 0 {
+		return nil, fmt.Errorf("invalid project ID")
+	}
+
+	submittals, err := s.SubmittalRepo.FindBlockingSubmittals(ctx, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve blocking submittals: %v", err)
+	}
+
+	now := time.Now()
+	items := make([]models.SubmittalBlockingItem, 0, len(submittals))
+	for i := range submittals {
+		submittal := &submittals[i]
+		orderBy := submittal.RequiredOnSite.AddDate(0, 0, -submittal.LeadTimeDays)
+
+		waitingOn := submittal.SubmittedBy
+		if submittal.Status == StatusUnderReview {
+			waitingOn = reviewerFor(submittal, submittal.CurrentStep)
+		}
+
+		items = append(items, models.SubmittalBlockingItem{
+			SubmittalID:    submittal.ID,
+			ProjectID:      submittal.ProjectID,
+			Number:         submittal.Number,
+			Title:          submittal.Title,
+			Type:           submittal.Type,
+			Status:         submittal.Status,
+			Cycle:          submittal.Cycle,
+			CurrentStep:    submittal.CurrentStep,
+			WaitingOn:      waitingOn,
+			RequiredOnSite: submittal.RequiredOnSite,
+			OrderBy:        orderBy,
+			DaysToOrderBy:  int(orderBy.Sub(now).Hours() / 24),
+			Late:           now.After(orderBy),
+		})
+	}
+	return items, nil
+}
+
+// pinDocuments checks that every referenced document belongs to the project and pins it to its current revision
+func (s *submittalService) pinDocuments(ctx context.Context, projectID int64, documents []models.SubmittalDocument) ([]models.SubmittalDocument, error) {
+	seen := make(map[int64]bool)
+	pinned := make([]models.SubmittalDocument, 0, len(documents))
+	for _, document := range documents {
+		if seen[document.DocumentID] {
+			continue
+		}
+		seen[document.DocumentID] = true
+
+		documentProjectID, revisionID, err := s.SubmittalRepo.FindDocumentProject(ctx, document.DocumentID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to retrieve document %d: %v", document.DocumentID, err)
+		}
+		if documentProjectID != projectID {
+			return nil, fmt.Errorf("document %d does not belong to project %d", document.DocumentID, projectID)
+		}
+		pinned = append(pinned, models.SubmittalDocument{DocumentID: document.DocumentID, RevisionID: revisionID})
+	}
+	return pinned, nil
+}
+
+// loadSubmittal fetches a submittal and checks that it is in one of the expected statuses
+func (s *submittalService) loadSubmittal(ctx context.Context, id int64, expected ...string) (*models.Submittal, error) {
+	if id <= 0 {
+		return nil, fmt.Errorf("invalid submittal ID")
+	}
+
+	submittal, err := s.SubmittalRepo.FindSubmittalByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve submittal: %v", err)
+	}
+
+	for _, status := range expected {
+		if submittal.Status == status {
+			return submittal, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: submittal is %s, expected %v", ErrIllegalTransition, submittal.Status, expected)
+}
+
+// startCycle puts the submittal back at the contractor step with a pending review for every step
+func startCycle(submittal *models.Submittal) {
+	submittal.Status = StatusUnderReview
+	submittal.CurrentStep = StepContractor
+	submittal.Reviews = make([]models.SubmittalReview, 0, len(reviewSteps))
+	for i, step := range reviewSteps {
+		submittal.Reviews = append(submittal.Reviews, models.SubmittalReview{
+			Step:       step,
+			Sequence:   i + 1,
+			ReviewerID: reviewerFor(submittal, step),
+			Outcome:    OutcomePending,
+		})
+	}
+}
+
+func reviewerFor(submittal *models.Submittal, step string) int64 {
+	switch step {
+	case StepContractor:
+		return submittal.ContractorReviewerID
+	case StepConsultant:
+		return submittal.ConsultantReviewerID
+	case StepOwner:
+		return submittal.OwnerReviewerID
+	}
+	return 0
+}
+
+// nextStep returns the step after step, or "" after the last one
+func nextStep(step string) string {
+	for i, s := range reviewSteps {
+		if s == step && i+1 < len(reviewSteps) {
+			return reviewSteps[i+1]
+		}
+	}
+	return ""
+}
+
+func notedInCycle(reviews []models.SubmittalReview, cycle int) bool {
+	for _, review := range reviews {
+		if review.Cycle == cycle && review.Outcome == OutcomeApprovedAsNoted {
+			return true
+		}
+	}
+	return false
+}
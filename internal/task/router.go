@@ -1,17 +1,24 @@
 package task
 
-import "net/http"
+import (
+	"net/http"
 
-func RegisterRoutes(router *http.ServeMux, handler *TaskController) {
-	router.HandleFunc("GET /tasks", handler.ShowAllTasks)
-	router.HandleFunc("GET /tasks/{id}", handler.FindTaskByID)
-	router.HandleFunc("POST /tasks/add", handler.CreateTask)
-	router.HandleFunc("PUT /tasks/{id}", handler.UpdateTask)
-	router.HandleFunc("DELETE /tasks/{id}", handler.DeleteTask)
-	router.HandleFunc("PUT /tasks/{id}/done", handler.TaskMarkAsDone)
-	router.HandleFunc("PUT /tasks/{id}/in-progress", handler.TaskMarkAsInProgress)
-	router.HandleFunc("GET /tasks/overdue", handler.FindOverdueTasks)
-	router.HandleFunc("GET /tasks/user/{user_id}", handler.FindTasksByAssignedUser)
-	router.HandleFunc("PUT /tasks/archive", handler.ArchiveCompletedTasks)
-	router.HandleFunc("GET /tasks/project/{project_id}", handler.FindTasksByProjectID)
+	"github.com/BerkatPS/pkg/rbac"
+)
+
+func RegisterRoutes(router *http.ServeMux, handler *TaskController, authz *rbac.Enforcer) {
+	router.HandleFunc("GET /tasks", authz.Require(rbac.TaskRead, handler.ShowAllTasks))
+	router.HandleFunc("GET /tasks/{id}", authz.RequireProject(rbac.TaskRead, authz.PathResource(rbac.ResourceTask, "id"), handler.FindTaskByID))
+	router.HandleFunc("POST /tasks/add", authz.RequireProject(rbac.TaskCreate, rbac.BodyProject("project_id"), handler.CreateTask))
+	router.HandleFunc("PUT /tasks/{id}", authz.RequireProject(rbac.TaskUpdate, authz.BodyResource(rbac.ResourceTask, "id"),
+		// An update may move it to another project, where the permission is needed too
+		authz.RequireProject(rbac.TaskUpdate, rbac.BodyProject("project_id"), handler.UpdateTask)))
+	router.HandleFunc("DELETE /tasks/{id}", authz.RequireProject(rbac.TaskDelete, authz.PathResource(rbac.ResourceTask, "id"), handler.DeleteTask))
+	router.HandleFunc("PUT /tasks/{id}/done", authz.RequireProject(rbac.TaskUpdateStatus, authz.PathResource(rbac.ResourceTask, "id"), handler.TaskMarkAsDone))
+	router.HandleFunc("PUT /tasks/{id}/in-progress", authz.RequireProject(rbac.TaskUpdateStatus, authz.PathResource(rbac.ResourceTask, "id"), handler.TaskMarkAsInProgress))
+	router.HandleFunc("GET /tasks/overdue", authz.Require(rbac.TaskRead, handler.FindOverdueTasks))
+	router.HandleFunc("GET /tasks/export", authz.RequireProject(rbac.TaskRead, rbac.QueryProject("project_id"), handler.ExportTasks))
+	router.HandleFunc("GET /tasks/user/{user_id}", authz.Require(rbac.TaskRead, handler.FindTasksByAssignedUser))
+	router.HandleFunc("PUT /tasks/archive", authz.Require(rbac.TaskArchive, handler.ArchiveCompletedTasks))
+	router.HandleFunc("GET /tasks/project/{project_id}", authz.RequireProject(rbac.TaskRead, rbac.PathProject("project_id"), handler.FindTasksByProjectID))
 }
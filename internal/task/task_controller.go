@@ -2,9 +2,15 @@ package task
 
 import (
 	"encoding/json"
+	"fmt"
 	models "github.com/BerkatPS/internal"
+	"github.com/BerkatPS/pkg/export"
+	"github.com/BerkatPS/pkg/principal"
 	"github.com/BerkatPS/pkg/utils"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type TaskController struct {
@@ -73,7 +79,7 @@ func (t *TaskController) TaskMarkAsInProgress(w http.ResponseWriter, r *http.Req
 func (t *TaskController) FindOverdueTasks(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
-	tasks, err := t.Service.FindOverdueTasks(ctx)
+	tasks, err := t.Service.FindOverdueTasks(ctx, principal.VisibleProjectIDs(ctx))
 	if err != nil {
 		utils.JSONErrorResponse(w, http.StatusInternalServerError, map[string]interface{}{
 			"status":  "error",
@@ -101,7 +107,7 @@ func (t *TaskController) FindTasksByAssignedUser(w http.ResponseWriter, r *http.
 		return
 	}
 
-	tasks, err := t.Service.FindTasksByAssignedUser(ctx, userID)
+	tasks, err := t.Service.FindTasksByAssignedUser(ctx, userID, principal.VisibleProjectIDs(ctx))
 	if err != nil {
 		utils.JSONErrorResponse(w, http.StatusInternalServerError, map[string]interface{}{
 			"status":  "error",
@@ -165,7 +171,7 @@ func (t *TaskController) TaskMarkAsDone(w http.ResponseWriter, r *http.Request)
 func (t *TaskController) ShowAllTasks(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
-	tasks, err := t.Service.ShowAllTasks(ctx)
+	tasks, err := t.Service.ShowAllTasks(ctx, principal.VisibleProjectIDs(ctx))
 	if err != nil {
 		utils.JSONErrorResponse(w, http.StatusInternalServerError, map[string]interface{}{
 			"status":  "error",
@@ -286,3 +292,51 @@ func (t *TaskController) DeleteTask(w http.ResponseWriter, r *http.Request) {
 		"message": "Task deleted successfully",
 	})
 }
+
+var taskExportHeaders = []string{"ID", "Project ID", "Project", "Name", "Description", "Status", "Start Date", "End Date",
+	"Assigned To ID", "Assigned To", "Required Permit", "Started At", "Completed At"}
+
+// ExportTasks downloads tasks as ?format=csv or xlsx, filtered by ?project_id=, ?assigned_to=, ?status=
+// and ?overdue=true
+func (t *TaskController) ExportTasks(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	filter, format, err := parseTaskExport(r)
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusBadRequest, map[string]interface{}{
+			"status":  "error",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	export.Stream(w, format, "Tasks", export.FileName("tasks", time.Now()), taskExportHeaders,
+		func(emit func(values ...interface{}) error) error {
+			return t.Service.ExportTasks(ctx, filter, func(task models.Task) error {
+				return emit(task.ID, task.ProjectID, task.Project.Name, task.Name, task.Description, task.Status, task.StartDate, task.EndDate,
+					task.AssignedToID, task.AssignedTo.Username, task.RequiredPermitType, task.StartedAt, task.CompletedAt)
+			})
+		})
+}
+
+func parseTaskExport(r *http.Request) (TaskFilter, string, error) {
+	var filter TaskFilter
+
+	format, err := export.ParseFormat(r)
+	if err != nil {
+		return filter, "", err
+	}
+	if filter.ProjectID, err = utils.ParseInt64Query(r, "project_id"); err != nil {
+		return filter, "", err
+	}
+	if filter.AssignedToID, err = utils.ParseInt64Query(r, "assigned_to"); err != nil {
+		return filter, "", err
+	}
+	filter.Status = strings.ToUpper(r.URL.Query().Get("status"))
+	if raw := r.URL.Query().Get("overdue"); raw != "" {
+		if filter.Overdue, err = strconv.ParseBool(raw); err != nil {
+			return filter, "", fmt.Errorf("invalid overdue")
+		}
+	}
+	return filter, format, nil
+}
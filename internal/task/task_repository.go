@@ -4,10 +4,13 @@ import (
 	"context"
 	"database/sql"
 	models "github.com/BerkatPS/internal"
+	"github.com/lib/pq"
+	"time"
 )
 
+// The listings take projectIDs to keep to the tasks of those projects; nil matches every project.
 type TaskRepository interface {
-	ShowAllTasks(ctx context.Context) ([]models.Task, error)
+	ShowAllTasks(ctx context.Context, projectIDs []int64) ([]models.Task, error)
 	FindTaskByID(ctx context.Context, id int64) (*models.Task, error)
 	FindTasksByProjectID(ctx context.Context, projectID int64) ([]models.Task, error)
 	CreateTask(ctx context.Context, task *models.Task) error
@@ -16,9 +19,12 @@ type TaskRepository interface {
 	TaskMarkAsDone(ctx context.Context, id int64) error
 	ArchiveCompletedTasks(ctx context.Context) error
 	TaskMarkAsInProgress(ctx context.Context, id int64) error
-	FindTasksByAssignedUser(ctx context.Context, userID int64) ([]models.Task, error)
-	FindOverdueTasks(ctx context.Context) ([]models.Task, error)
-
+	FindTasksByAssignedUser(ctx context.Context, userID int64, projectIDs []int64) ([]models.Task, error)
+	FindOverdueTasks(ctx context.Context, projectIDs []int64) ([]models.Task, error)
+	FindTaskProjectID(ctx context.Context, id int64) (int64, error)
+	// StreamTasks hands every task matching the filter to fn, ordered by project and start date, without
+	// collecting them. Project and AssignedTo carry the project name and assignee username.
+	StreamTasks(ctx context.Context, filter TaskFilter, at time.Time, fn func(models.Task) error) error
 }
 
 type taskRepository struct {
@@ -51,10 +57,10 @@ func (t *taskRepository) FindTasksByProjectID(ctx context.Context, projectID int
 }
 
 
-func (t *taskRepository) FindOverdueTasks(ctx context.Context) ([]models.Task, error) {
-	query := "SELECT * FROM tasks WHERE end_date < CURRENT_DATE AND status = 'IN_PROGRESS'"
+func (t *taskRepository) FindOverdueTasks(ctx context.Context, projectIDs []int64) ([]models.Task, error) {
+	query := "SELECT id, project_id, name, description, start_date, end_date, status FROM tasks WHERE end_date < CURRENT_DATE AND status = 'IN_PROGRESS' AND ($1::bigint[] IS NULL OR project_id = ANY($1))"
 
-	rows, err := t.db.QueryContext(ctx, query)
+	rows, err := t.db.QueryContext(ctx, query, pq.Array(projectIDs))
 	if err != nil {
 		return nil, err
 	}
@@ -72,10 +78,10 @@ func (t *taskRepository) FindOverdueTasks(ctx context.Context) ([]models.Task, e
 	return tasks, nil
 }
 
-func (t *taskRepository) FindTasksByAssignedUser(ctx context.Context, userID int64) ([]models.Task, error) {
-	query := "SELECT * FROM tasks WHERE assigned_user_id = $1"
+func (t *taskRepository) FindTasksByAssignedUser(ctx context.Context, userID int64, projectIDs []int64) ([]models.Task, error) {
+	query := "SELECT * FROM tasks WHERE assigned_user_id = $1 AND ($2::bigint[] IS NULL OR project_id = ANY($2))"
 
-	rows, err := t.db.QueryContext(ctx, query, userID)
+	rows, err := t.db.QueryContext(ctx, query, userID, pq.Array(projectIDs))
 	if err != nil {
 		return nil, err
 	}
@@ -94,7 +100,7 @@ func (t *taskRepository) FindTasksByAssignedUser(ctx context.Context, userID int
 }
 
 func (t *taskRepository) TaskMarkAsInProgress(ctx context.Context, id int64) error {
-	query := "UPDATE tasks SET status = 'IN_PROGRESS' WHERE id = $1"
+	query := "UPDATE tasks SET status = 'IN_PROGRESS', started_at = COALESCE(started_at, NOW()) WHERE id = $1"
 	_, err := t.db.ExecContext(ctx, query, id)
 	if err != nil {
 		return err
@@ -105,7 +111,7 @@ func (t *taskRepository) TaskMarkAsInProgress(ctx context.Context, id int64) err
 
 
 func (t *taskRepository) TaskMarkAsDone(ctx context.Context, id int64) error {
-	query := "UPDATE tasks SET status = 'DONE' WHERE id = $1"
+	query := "UPDATE tasks SET status = 'DONE', completed_at = NOW() WHERE id = $1"
 	_, err := t.db.ExecContext(ctx, query, id)
 	if err != nil {
 		return err
@@ -124,10 +130,10 @@ func (t *taskRepository) ArchiveCompletedTasks(ctx context.Context) error {
 
 
 
-func (t *taskRepository) ShowAllTasks(ctx context.Context) ([]models.Task, error) {
-	query := "SELECT * FROM tasks"
+func (t *taskRepository) ShowAllTasks(ctx context.Context, projectIDs []int64) ([]models.Task, error) {
+	query := "SELECT * FROM tasks WHERE $1::bigint[] IS NULL OR project_id = ANY($1)"
 
-	rows, err := t.db.QueryContext(ctx, query)
+	rows, err := t.db.QueryContext(ctx, query, pq.Array(projectIDs))
 	if err != nil {
 		return nil, err
 	}
@@ -145,6 +151,16 @@ func (t *taskRepository) ShowAllTasks(ctx context.Context) ([]models.Task, error
 	return tasks, nil
 }
 
+func (t *taskRepository) FindTaskProjectID(ctx context.Context, id int64) (int64, error) {
+	query := "SELECT project_id FROM tasks WHERE id = $1"
+
+	var projectID int64
+	if err := t.db.QueryRowContext(ctx, query, id).Scan(&projectID); err != nil {
+		return 0, err
+	}
+	return projectID, nil
+}
+
 func (t *taskRepository) FindTaskByID(ctx context.Context, id int64) (*models.Task, error) {
 	query := "SELECT * FROM tasks WHERE id = $1"
 
@@ -157,9 +173,9 @@ func (t *taskRepository) FindTaskByID(ctx context.Context, id int64) (*models.Ta
 }
 
 func (t *taskRepository) CreateTask(ctx context.Context, task *models.Task) error {
-	query := "INSERT INTO tasks (project_id, name, description, start_date, end_date, status) VALUES ($1, $2, $3, $4, $5, $6)"
+	query := "INSERT INTO tasks (project_id, name, description, start_date, end_date, status, required_permit_type) VALUES ($1, $2, $3, $4, $5, $6, $7)"
 
-	_, err := t.db.ExecContext(ctx, query, task.ProjectID, task.Name, task.Description, task.StartDate, task.EndDate, task.Status)
+	_, err := t.db.ExecContext(ctx, query, task.ProjectID, task.Name, task.Description, task.StartDate, task.EndDate, task.Status, task.RequiredPermitType)
 	if err != nil {
 		return err
 	}
@@ -167,9 +183,9 @@ func (t *taskRepository) CreateTask(ctx context.Context, task *models.Task) erro
 }
 
 func (t *taskRepository) UpdateTask(ctx context.Context, task *models.Task) error {
-	query := "UPDATE tasks SET project_id = $1, name = $2, description = $3, start_date = $4, end_date = $5, status = $6 WHERE id = $7"
+	query := "UPDATE tasks SET project_id = $1, name = $2, description = $3, start_date = $4, end_date = $5, status = $6, required_permit_type = $7 WHERE id = $8"
 
-	_, err := t.db.ExecContext(ctx, query, task.ProjectID, task.Name, task.Description, task.StartDate, task.EndDate, task.Status, task.ID)
+	_, err := t.db.ExecContext(ctx, query, task.ProjectID, task.Name, task.Description, task.StartDate, task.EndDate, task.Status, task.RequiredPermitType, task.ID)
 	if err != nil {
 		return err
 	}
@@ -185,3 +201,38 @@ func (t *taskRepository) DeleteTask(ctx context.Context, id int64) error {
 	}
 	return nil
 }
+
+func (t *taskRepository) StreamTasks(ctx context.Context, filter TaskFilter, at time.Time, fn func(models.Task) error) error {
+	query := `SELECT t.id, t.project_id, t.name, t.description, t.status, t.start_date, t.end_date, COALESCE(t.assigned_to_id, 0),
+		COALESCE(t.required_permit_type, ''), COALESCE(t.started_at, '0001-01-01'::timestamp), COALESCE(t.completed_at, '0001-01-01'::timestamp),
+		COALESCE(p.name, ''), COALESCE(u.username, '')
+		FROM tasks t
+		LEFT JOIN projects p ON p.id = t.project_id
+		LEFT JOIN users u ON u.id = t.assigned_to_id
+		WHERE ($1 = 0 OR t.project_id = $1)
+			AND ($2 = 0 OR t.assigned_to_id = $2)
+			AND ($3 = '' OR t.status = $3)
+			AND (NOT $4 OR (t.end_date < $5 AND t.status = 'IN_PROGRESS'))
+		ORDER BY t.project_id, t.start_date, t.id`
+
+	rows, err := t.db.QueryContext(ctx, query, filter.ProjectID, filter.AssignedToID, filter.Status, filter.Overdue, at)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		task := models.Task{Project: &models.Project{}, AssignedTo: &models.User{}}
+		if err := rows.Scan(&task.ID, &task.ProjectID, &task.Name, &task.Description, &task.Status, &task.StartDate, &task.EndDate, &task.AssignedToID,
+			&task.RequiredPermitType, &task.StartedAt, &task.CompletedAt, &task.Project.Name, &task.AssignedTo.Username); err != nil {
+			return err
+		}
+		task.Project.ID = task.ProjectID
+		task.AssignedTo.ID = task.AssignedToID
+		if err := fn(task); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
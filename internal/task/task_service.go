@@ -3,11 +3,18 @@ package task
 import (
 	"context"
 	"fmt"
+	"log"
+	"strings"
+	"time"
+
 	models "github.com/BerkatPS/internal"
+	"github.com/BerkatPS/internal/events"
+	"github.com/BerkatPS/internal/permit"
 )
 
+// The listings take projectIDs to keep to the tasks of those projects; nil matches every project.
 type TaskService interface {
-	ShowAllTasks(ctx context.Context) ([]models.Task, error)
+	ShowAllTasks(ctx context.Context, projectIDs []int64) ([]models.Task, error)
 	FindTaskByID(ctx context.Context, id int64) (*models.Task, error)
 	CreateTask(ctx context.Context, task *models.Task) error
 	UpdateTask(ctx context.Context, task *models.Task) error
@@ -15,18 +22,52 @@ type TaskService interface {
 	TaskMarkAsDone(ctx context.Context, id int64) error
 	ArchiveCompletedTasks(ctx context.Context) error
 	TaskMarkAsInProgress(ctx context.Context, id int64) error
-	FindTasksByAssignedUser(ctx context.Context, userID int64) ([]models.Task, error)
-	FindOverdueTasks(ctx context.Context) ([]models.Task, error)
+	FindTasksByAssignedUser(ctx context.Context, userID int64, projectIDs []int64) ([]models.Task, error)
+	FindOverdueTasks(ctx context.Context, projectIDs []int64) ([]models.Task, error)
 	FindTasksByProjectID(ctx context.Context, projectID int64) ([]models.Task, error)
+	// ExportTasks streams the tasks matching the filter to fn, for CSV and XLSX exports
+	ExportTasks(ctx context.Context, filter TaskFilter, fn func(models.Task) error) error
+}
+
+// TaskFilter narrows a task export. Zero fields are not applied; Overdue keeps in-progress tasks past their end date.
+type TaskFilter struct {
+	ProjectID    int64
+	AssignedToID int64
+	Status       string
+	Overdue      bool
+}
+
+// PermitChecker refuses to let a task start when it needs a permit-to-work that is not active
+type PermitChecker interface {
+	CheckTaskPermit(ctx context.Context, taskID int64) error
 }
 
 type taskService struct {
-	TaskRepo TaskRepository
+	TaskRepo      TaskRepository
+	PermitChecker PermitChecker
+	Events        events.Publisher
 }
 
+// NewTaskService creates a TaskService. permitChecker may be nil, in which case permits are not enforced;
+// publisher may be nil, in which case status changes are not streamed.
+func NewTaskService(taskRepo TaskRepository, permitChecker PermitChecker, publisher events.Publisher) TaskService {
+	return &taskService{taskRepo, permitChecker, publisher}
+}
 
-func NewTaskService(taskRepo TaskRepository) TaskService {
-	return &taskService{taskRepo}
+// publishStatus streams a task status change to the task's project. It never fails the request.
+func (t *taskService) publishStatus(ctx context.Context, id int64, status string) {
+	if t.Events == nil {
+		return
+	}
+	projectID, err := t.TaskRepo.FindTaskProjectID(ctx, id)
+	if err != nil {
+		log.Printf("failed to resolve project for task %d event: %v", id, err)
+		return
+	}
+	t.Events.Publish(projectID, events.EventTaskStatusChanged, map[string]interface{}{
+		"task_id": id,
+		"status":  status,
+	})
 }
 
 func (t *taskService) FindTasksByProjectID(ctx context.Context, projectID int64) ([]models.Task, error) {
@@ -38,8 +79,8 @@ func (t *taskService) FindTasksByProjectID(ctx context.Context, projectID int64)
 	return tasks, nil
 }
 
-func (t *taskService) FindOverdueTasks(ctx context.Context) ([]models.Task, error) {
-	tasks, err := t.TaskRepo.FindOverdueTasks(ctx)
+func (t *taskService) FindOverdueTasks(ctx context.Context, projectIDs []int64) ([]models.Task, error) {
+	tasks, err := t.TaskRepo.FindOverdueTasks(ctx, projectIDs)
 	if err != nil {
 		return nil, fmt.Errorf("failed to retrieve overdue tasks: %v", err)
 	}
@@ -47,8 +88,8 @@ func (t *taskService) FindOverdueTasks(ctx context.Context) ([]models.Task, erro
 	return tasks, nil
 }
 
-func (t *taskService) FindTasksByAssignedUser(ctx context.Context, userID int64) ([]models.Task, error){
-	tasks, err := t.TaskRepo.FindTasksByAssignedUser(ctx, userID)
+func (t *taskService) FindTasksByAssignedUser(ctx context.Context, userID int64, projectIDs []int64) ([]models.Task, error){
+	tasks, err := t.TaskRepo.FindTasksByAssignedUser(ctx, userID, projectIDs)
 	if err != nil {
 		return nil, fmt.Errorf("failed to retrieve tasks for user: %v", err)
 	}
@@ -69,11 +110,20 @@ func (t *taskService) TaskMarkAsInProgress(ctx context.Context, id int64) error
 	if id <= 0 {
 		return fmt.Errorf("invalid task ID")
 	}
+
+	if t.PermitChecker != nil {
+		if err := t.PermitChecker.CheckTaskPermit(ctx, id); err != nil {
+			return fmt.Errorf("task cannot start: %w", err)
+		}
+	}
+
 	err := t.TaskRepo.TaskMarkAsInProgress(ctx, id)
 	if err != nil {
 		return fmt.Errorf("failed to mark task as in progress: %v", err)
 	}
 
+	t.publishStatus(ctx, id, "IN_PROGRESS")
+
 	return nil
 }
 func (t *taskService) TaskMarkAsDone(ctx context.Context, id int64) error {
@@ -86,12 +136,14 @@ func (t *taskService) TaskMarkAsDone(ctx context.Context, id int64) error {
 		return fmt.Errorf("failed to mark task as done: %v", err)
 	}
 
+	t.publishStatus(ctx, id, "DONE")
+
 	return nil
 }
 
 
-func (t *taskService) ShowAllTasks(ctx context.Context) ([]models.Task, error) {
-	tasks, err := t.TaskRepo.ShowAllTasks(ctx)
+func (t *taskService) ShowAllTasks(ctx context.Context, projectIDs []int64) ([]models.Task, error) {
+	tasks, err := t.TaskRepo.ShowAllTasks(ctx, projectIDs)
 	if err != nil {
 		return nil, fmt.Errorf("failed to retrieve tasks: %v", err)
 	}
@@ -117,6 +169,10 @@ func (t *taskService) CreateTask(ctx context.Context, task *models.Task) error {
 		return fmt.Errorf("missing required task fields")
 	}
 
+	if err := normalizePermitType(task); err != nil {
+		return err
+	}
+
 	if err := t.TaskRepo.CreateTask(ctx, task); err != nil {
 		return fmt.Errorf("failed to create task: %v", err)
 	}
@@ -129,6 +185,10 @@ func (t *taskService) UpdateTask(ctx context.Context, task *models.Task) error {
 		return fmt.Errorf("missing required task fields")
 	}
 
+	if err := normalizePermitType(task); err != nil {
+		return err
+	}
+
 	err := t.TaskRepo.UpdateTask(ctx, task)
 	if err != nil {
 		return fmt.Errorf("failed to update task: %v", err)
@@ -137,6 +197,22 @@ func (t *taskService) UpdateTask(ctx context.Context, task *models.Task) error {
 	return nil
 }
 
+// normalizePermitType rewrites the task's required permit type to its canonical spelling. An empty type means the
+// task needs no permit.
+func normalizePermitType(task *models.Task) error {
+	if strings.TrimSpace(task.RequiredPermitType) == "" {
+		task.RequiredPermitType = ""
+		return nil
+	}
+
+	permitType, err := permit.NormalizeType(task.RequiredPermitType)
+	if err != nil {
+		return err
+	}
+	task.RequiredPermitType = permitType
+	return nil
+}
+
 func (t *taskService) DeleteTask(ctx context.Context, id int64) error {
 	if id <= 0 {
 		return fmt.Errorf("invalid task ID")
@@ -149,3 +225,17 @@ func (t *taskService) DeleteTask(ctx context.Context, id int64) error {
 
 	return nil
 }
+
+func (t *taskService) ExportTasks(ctx context.Context, filter TaskFilter, fn func(models.Task) error) error {
+	if filter.ProjectID < 0 || filter.AssignedToID < 0 {
+		return fmt.Errorf("invalid project or user ID")
+	}
+
+	// Overdue matches FindOverdueTasks: past the end date as of today
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	if err := t.TaskRepo.StreamTasks(ctx, filter, today, fn); err != nil {
+		return fmt.Errorf("failed to export tasks: %v", err)
+	}
+	return nil
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	models "github.com/BerkatPS/internal"
 	"github.com/BerkatPS/internal/database"
@@ -22,28 +23,56 @@ func main() {
 	}
 	fmt.Println("Connected to database")
 
-	server := server2.NewServer(db)
+	server := server2.NewServer(db, cfg)
 
 	err = database.AutoMigrate(db,
 		&models.Document{},
+		&models.DocumentRevision{},
 		&models.User{},
 		&models.QualityCheck{},
+		&models.QualityReport{},
+		&models.QualityReportCheck{},
+		&models.QualityReportDocument{},
 		&models.Expense{},
 		&models.Project{},
+		&models.ProjectTeam{},
 		&models.SafetyIncident{},
+		&models.CorrectiveAction{},
+		&models.IncidentTransition{},
+		&models.AnonymousReportToken{},
+		&models.AnonymousReportAttempt{},
 		&models.Task{},
+		&models.WorkPermit{},
+		&models.PermitChecklistItem{},
+		&models.MessageMention{},
+		&models.RFI{},
+		&models.RFIDocument{},
+		&models.RFITask{},
+		&models.Submittal{},
+		&models.SubmittalDocument{},
+		&models.SubmittalReview{},
 		&models.Message{},
 		&models.Report{},
 		&models.Presence{},
+		&models.JobRun{},
+		&models.ReportTemplate{},
+		&models.RefreshToken{},
+		&models.PasswordResetToken{},
+		&models.LoginAttempt{},
+		&models.APIKey{},
 	)
 	if err != nil {
 		log.Fatalf("Failed to auto migrate: %v", err)
 	}
 	fmt.Println("Auto migrated tables")
 
+	if cfg.SchedulerEnabled {
+		server.Scheduler.Start(context.Background())
+	}
+
 	log.Printf("starting server on %s", cfg.ServerAddress)
 
-	if err := http.ListenAndServe(cfg.ServerAddress, server.Router); err != nil {
+	if err := http.ListenAndServe(cfg.ServerAddress, server.Handler); err != nil {
 		log.Fatalf("Failed to start server: %v", err)
 	}
 }
@@ -3,6 +3,9 @@ package config
 import (
 	"log"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 )
 
 var AllowedIPs = []string{
@@ -13,6 +16,30 @@ type Config struct {
 	ServerAddress string
 	DatabaseURL   string
 	JwtSecret     string
+	StorageDir    string // Root directory of the local blob store for uploaded documents
+
+	TrustedProxies []string // Reverse proxy addresses or CIDR ranges whose X-Forwarded-For header is believed
+
+	AccessTokenTTL  time.Duration // Lifetime of the JWT access tokens
+	RefreshTokenTTL time.Duration // Lifetime of a refresh token; each refresh starts a new one
+
+	MailSender       string        // How mail is delivered: log or file
+	MailDir          string        // Directory the file sender writes messages to
+	PasswordResetTTL time.Duration // Lifetime of a password reset token
+	PasswordResetURL string        // Page the reset email links to with the token appended; the bare token is sent when empty
+
+	LoginMaxFailures   int           // Failed logins that lock an account
+	LoginIPMaxFailures int           // Failed logins that lock out an IP address, whichever accounts they tried
+	LoginLockout       time.Duration // How long a lockout lasts
+	LoginFailureWindow time.Duration // How long a failed login counts towards a lockout
+
+	AnonymousReportLimit  int           // Anonymous near-miss reports accepted from one IP address per window
+	AnonymousReportWindow time.Duration // How long an anonymous report counts towards the limit
+
+	SchedulerEnabled     bool   // Run background jobs in this process; every replica may enable it
+	ArchiveSchedule      string // Cron expression of the completed-task archiving job
+	OverdueSchedule      string // Cron expression of the overdue task and RFI sweep
+	WeeklyReportSchedule string // Cron expression of the weekly project report job
 }
 
 func LoadConfig() *Config {
@@ -20,6 +47,30 @@ func LoadConfig() *Config {
 		ServerAddress: getEnv("SERVER_ADDRESS", "localhost:8080"),
 		DatabaseURL:   getEnv("DATABASE_URL", "postgres://berkatsaragih:@localhost:5432/construction_track?sslmode=disable"),
 		JwtSecret:     getEnv("JWT_SECRET", "secret"),
+		StorageDir:    getEnv("STORAGE_DIR", "./storage"),
+
+		TrustedProxies: getList("TRUSTED_PROXIES"),
+
+		AccessTokenTTL:  getDuration("ACCESS_TOKEN_TTL", 15*time.Minute),
+		RefreshTokenTTL: getDuration("REFRESH_TOKEN_TTL", 30*24*time.Hour),
+
+		MailSender:       getEnv("MAIL_SENDER", "log"),
+		MailDir:          getEnv("MAIL_DIR", "./mail"),
+		PasswordResetTTL: getDuration("PASSWORD_RESET_TTL", time.Hour),
+		PasswordResetURL: getEnv("PASSWORD_RESET_URL", ""),
+
+		LoginMaxFailures:   getInt("LOGIN_MAX_FAILURES", 5),
+		LoginIPMaxFailures: getInt("LOGIN_IP_MAX_FAILURES", 20),
+		LoginLockout:       getDuration("LOGIN_LOCKOUT", 15*time.Minute),
+		LoginFailureWindow: getDuration("LOGIN_FAILURE_WINDOW", time.Hour),
+
+		AnonymousReportLimit:  getInt("ANONYMOUS_REPORT_LIMIT", 5),
+		AnonymousReportWindow: getDuration("ANONYMOUS_REPORT_WINDOW", 24*time.Hour),
+
+		SchedulerEnabled:     getEnv("SCHEDULER_ENABLED", "true") == "true",
+		ArchiveSchedule:      getEnv("ARCHIVE_SCHEDULE", "0 2 * * *"),
+		OverdueSchedule:      getEnv("OVERDUE_SCHEDULE", "0 7 * * *"),
+		WeeklyReportSchedule: getEnv("WEEKLY_REPORT_SCHEDULE", "0 6 * * MON"),
 	}
 }
 
@@ -33,4 +84,43 @@ func getEnv(key, defaultValue string) string {
 	return value
 }
 
+// getList reads a comma-separated list, which is empty when the variable is unset
+func getList(key string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
 
+// getDuration reads a duration such as 15m or 720h, falling back to defaultValue when it is unset or invalid
+func getDuration(key string, defaultValue time.Duration) time.Duration {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return defaultValue
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration <= 0 {
+		log.Printf("Invalid duration for %s: %q, using %s", key, value, defaultValue)
+		return defaultValue
+	}
+	return duration
+}
+
+// getInt reads a positive integer, falling back to defaultValue when it is unset or invalid
+func getInt(key string, defaultValue int) int {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return defaultValue
+	}
+
+	number, err := strconv.Atoi(value)
+	if err != nil || number <= 0 {
+		log.Printf("Invalid number for %s: %q, using %d", key, value, defaultValue)
+		return defaultValue
+	}
+	return number
+}
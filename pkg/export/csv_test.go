@@ -0,0 +1,51 @@
+package export
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestNeutralizeFormula(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{name: "empty", in: "", want: ""},
+		{name: "plain text", in: "Rebar delivery", want: "Rebar delivery"},
+		{name: "equals", in: "=HYPERLINK(\"http://evil\",\"x\")", want: "'=HYPERLINK(\"http://evil\",\"x\")"},
+		{name: "plus", in: "+1+cmd|' /C calc'!A0", want: "'+1+cmd|' /C calc'!A0"},
+		{name: "minus", in: "-2+3", want: "'-2+3"},
+		{name: "at sign", in: "@SUM(A1:A2)", want: "'@SUM(A1:A2)"},
+		{name: "leading tab", in: "\t=1+1", want: "'\t=1+1"},
+		{name: "leading carriage return", in: "\r=1+1", want: "'\r=1+1"},
+		{name: "formula character later", in: "a=b", want: "a=b"},
+		{name: "email address", in: "site@example.com", want: "site@example.com"},
+		{name: "already quoted", in: "'=1", want: "'=1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := neutralizeFormula(tt.in); got != tt.want {
+				t.Errorf("neutralizeFormula(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCSVWriterNeutralizesOnlyText(t *testing.T) {
+	var out bytes.Buffer
+	w := newCSVWriter(&out)
+	if err := w.WriteRow("=1+1", int64(-5), -2.5, "-note"); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Negative numbers are numbers, not formulas; only user-entered text is quoted
+	want := "'=1+1,-5,-2.5,'-note\n"
+	if out.String() != want {
+		t.Errorf("CSV = %q, want %q", out.String(), want)
+	}
+}
@@ -0,0 +1,150 @@
+// Package export streams tabular data as CSV or as a native XLSX workbook. Rows are written as they are
+// produced, so an export never holds the whole result set in memory.
+package export
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/BerkatPS/pkg/utils"
+)
+
+const (
+	FormatCSV  = "csv"
+	FormatXLSX = "xlsx"
+)
+
+// Writer receives the rows of one table. Values may be strings, integers, floats, bools or time.Time;
+// a zero time is written as an empty cell.
+type Writer interface {
+	WriteRow(values ...interface{}) error
+	// Close finishes the file. Nothing more may be written afterwards.
+	Close() error
+}
+
+// ParseFormat reads ?format=, defaulting to CSV
+func ParseFormat(r *http.Request) (string, error) {
+	format := strings.ToLower(r.URL.Query().Get("format"))
+	switch format {
+	case "":
+		return FormatCSV, nil
+	case FormatCSV, FormatXLSX:
+		return format, nil
+	}
+	return "", fmt.Errorf("unsupported export format %q, use csv or xlsx", format)
+}
+
+// NewWriter starts a file of the given format on w and writes the header row. sheet names the XLSX worksheet.
+func NewWriter(w io.Writer, format, sheet string, headers []string) (Writer, error) {
+	var writer Writer
+	switch format {
+	case FormatCSV:
+		writer = newCSVWriter(w)
+	case FormatXLSX:
+		xw, err := newXLSXWriter(w, sheet)
+		if err != nil {
+			return nil, err
+		}
+		writer = xw
+	default:
+		return nil, fmt.Errorf("unsupported export format %q", format)
+	}
+
+	values := make([]interface{}, len(headers))
+	for i, header := range headers {
+		values[i] = header
+	}
+	if err := writer.WriteRow(values...); err != nil {
+		return nil, err
+	}
+	return writer, nil
+}
+
+func ContentType(format string) string {
+	if format == FormatXLSX {
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+// Stream sends an export as a download named baseName plus the format extension. fill produces the rows
+// through emit. The response only starts with the first row, so a query that fails up front still gets a
+// JSON error; a failure mid-stream can only be logged and the download is cut short.
+func Stream(w http.ResponseWriter, format, sheet, baseName string, headers []string,
+	fill func(emit func(values ...interface{}) error) error) {
+
+	var writer Writer
+	start := func() error {
+		if writer != nil {
+			return nil
+		}
+		w.Header().Set("Content-Type", ContentType(format))
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", baseName+"."+format))
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.WriteHeader(http.StatusOK)
+
+		var err error
+		writer, err = NewWriter(w, format, sheet, headers)
+		return err
+	}
+
+	err := fill(func(values ...interface{}) error {
+		if err := start(); err != nil {
+			return err
+		}
+		return writer.WriteRow(values...)
+	})
+	if err != nil {
+		if writer == nil {
+			utils.JSONErrorResponse(w, http.StatusInternalServerError, map[string]interface{}{
+				"status":  "error",
+				"message": "Failed to export " + baseName + ": " + err.Error(),
+			})
+			return
+		}
+		log.Printf("failed to export %s: %v", baseName, err)
+		return
+	}
+
+	// An empty result is still a valid file with just the header row
+	if err := start(); err != nil {
+		log.Printf("failed to export %s: %v", baseName, err)
+		return
+	}
+	if err := writer.Close(); err != nil {
+		log.Printf("failed to export %s: %v", baseName, err)
+	}
+}
+
+// FileName builds a download name such as expenses-2026-10-17
+func FileName(prefix string, at time.Time) string {
+	return prefix + "-" + at.Format("2006-01-02")
+}
+
+// ParseDateRange reads the optional ?start_date= and ?end_date= (YYYY-MM-DD, both inclusive) and returns
+// them as a half-open range; an unset bound is the zero time
+func ParseDateRange(r *http.Request) (time.Time, time.Time, error) {
+	var from, to time.Time
+	if raw := r.URL.Query().Get("start_date"); raw != "" {
+		day, err := time.ParseInLocation("2006-01-02", raw, time.Local)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("start_date must be YYYY-MM-DD")
+		}
+		from = day
+	}
+	if raw := r.URL.Query().Get("end_date"); raw != "" {
+		day, err := time.ParseInLocation("2006-01-02", raw, time.Local)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("end_date must be YYYY-MM-DD")
+		}
+		to = day.AddDate(0, 0, 1)
+	}
+	if !from.IsZero() && !to.IsZero() && !to.After(from) {
+		return time.Time{}, time.Time{}, fmt.Errorf("end_date is before start_date")
+	}
+	return from, to, nil
+}
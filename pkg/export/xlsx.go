@@ -0,0 +1,243 @@
+package export
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/xml"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Style indexes into the cellXfs of xlsxStyles
+const (
+	styleDefault  = 0
+	styleHeader   = 1
+	styleDate     = 2
+	styleDateTime = 3
+	styleAmount   = 4
+)
+
+const xlsxContentTypes = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+	`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+	`<Default Extension="xml" ContentType="application/xml"/>` +
+	`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+	`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+	`<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>` +
+	`</Types>`
+
+const xlsxRootRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+	`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+	`</Relationships>`
+
+const xlsxWorkbookRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+	`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+	`<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>` +
+	`</Relationships>`
+
+const xlsxStyles = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+	`<numFmts count="2"><numFmt numFmtId="164" formatCode="yyyy-mm-dd"/><numFmt numFmtId="165" formatCode="yyyy-mm-dd hh:mm:ss"/></numFmts>` +
+	`<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>` +
+	`<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>` +
+	`<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>` +
+	`<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>` +
+	`<cellXfs count="5">` +
+	`<xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/>` +
+	`<xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/>` +
+	`<xf numFmtId="164" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>` +
+	`<xf numFmtId="165" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>` +
+	`<xf numFmtId="4" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>` +
+	`</cellXfs>` +
+	`<cellStyles count="1"><cellStyle name="Normal" xfId="0" builtinId="0"/></cellStyles>` +
+	`</styleSheet>`
+
+// The header row stays frozen while scrolling
+const xlsxSheetStart = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+	`<sheetViews><sheetView workbookViewId="0"><pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/></sheetView></sheetViews>` +
+	`<sheetData>`
+
+const xlsxSheetEnd = `</sheetData></worksheet>`
+
+// Excel counts days from 1899-12-30, which absorbs its 1900 leap year bug for every date after February 1900
+var excelEpoch = time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+
+// xlsxWriter streams a single-sheet workbook. The fixed parts are written first; the worksheet is the
+// last zip entry and grows row by row with inline strings, so no shared string table has to be kept.
+type xlsxWriter struct {
+	zip    *zip.Writer
+	sheet  *bufio.Writer
+	row    int
+	header bool // The first row is the header and is set in bold
+}
+
+func newXLSXWriter(w io.Writer, sheetName string) (*xlsxWriter, error) {
+	zw := zip.NewWriter(w)
+
+	workbook := `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+		`<sheets><sheet name="` + escapeXML(sanitizeSheetName(sheetName)) + `" sheetId="1" r:id="rId1"/></sheets></workbook>`
+
+	parts := []struct{ name, content string }{
+		{"[Content_Types].xml", xlsxContentTypes},
+		{"_rels/.rels", xlsxRootRels},
+		{"xl/workbook.xml", workbook},
+		{"xl/_rels/workbook.xml.rels", xlsxWorkbookRels},
+		{"xl/styles.xml", xlsxStyles},
+	}
+	for _, part := range parts {
+		f, err := zw.Create(part.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.WriteString(f, part.content); err != nil {
+			return nil, err
+		}
+	}
+
+	f, err := zw.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return nil, err
+	}
+	sheet := bufio.NewWriter(f)
+	if _, err := sheet.WriteString(xlsxSheetStart); err != nil {
+		return nil, err
+	}
+
+	return &xlsxWriter{zip: zw, sheet: sheet, header: true}, nil
+}
+
+func (x *xlsxWriter) WriteRow(values ...interface{}) error {
+	x.row++
+	rowRef := strconv.Itoa(x.row)
+
+	b := x.sheet
+	b.WriteString(`<row r="`)
+	b.WriteString(rowRef)
+	b.WriteString(`">`)
+	for i, value := range values {
+		ref := columnName(i) + rowRef
+		if x.header {
+			writeStringCell(b, ref, toString(value), styleHeader)
+			continue
+		}
+		writeCell(b, ref, value)
+	}
+	x.header = false
+	_, err := b.WriteString(`</row>`)
+	return err
+}
+
+func (x *xlsxWriter) Close() error {
+	if _, err := x.sheet.WriteString(xlsxSheetEnd); err != nil {
+		return err
+	}
+	if err := x.sheet.Flush(); err != nil {
+		return err
+	}
+	return x.zip.Close()
+}
+
+func writeCell(b *bufio.Writer, ref string, value interface{}) {
+	switch v := value.(type) {
+	case nil:
+		return
+	case string:
+		if v != "" {
+			writeStringCell(b, ref, v, styleDefault)
+		}
+	case int:
+		writeNumberCell(b, ref, strconv.Itoa(v), styleDefault)
+	case int64:
+		writeNumberCell(b, ref, strconv.FormatInt(v, 10), styleDefault)
+	case float64:
+		writeNumberCell(b, ref, strconv.FormatFloat(v, 'f', -1, 64), styleAmount)
+	case bool:
+		b.WriteString(`<c r="` + ref + `" t="b"><v>`)
+		if v {
+			b.WriteString("1")
+		} else {
+			b.WriteString("0")
+		}
+		b.WriteString(`</v></c>`)
+	case time.Time:
+		if v.IsZero() {
+			return
+		}
+		style := styleDateTime
+		if isMidnight(v) {
+			style = styleDate
+		}
+		writeNumberCell(b, ref, strconv.FormatFloat(excelSerial(v), 'f', -1, 64), style)
+	}
+}
+
+func writeStringCell(b *bufio.Writer, ref, value string, style int) {
+	b.WriteString(`<c r="` + ref + `" t="inlineStr"`)
+	if style != styleDefault {
+		b.WriteString(` s="` + strconv.Itoa(style) + `"`)
+	}
+	b.WriteString(`><is><t xml:space="preserve">`)
+	b.WriteString(escapeXML(value))
+	b.WriteString(`</t></is></c>`)
+}
+
+func writeNumberCell(b *bufio.Writer, ref, value string, style int) {
+	b.WriteString(`<c r="` + ref + `"`)
+	if style != styleDefault {
+		b.WriteString(` s="` + strconv.Itoa(style) + `"`)
+	}
+	b.WriteString(`><v>` + value + `</v></c>`)
+}
+
+// excelSerial converts the wall-clock time of t to an Excel date serial number
+func excelSerial(t time.Time) float64 {
+	wall := time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+	return wall.Sub(excelEpoch).Seconds() / 86400
+}
+
+// columnName turns a zero-based column index into A, B, ... Z, AA, AB ...
+func columnName(i int) string {
+	name := ""
+	for i >= 0 {
+		name = string(rune('A'+i%26)) + name
+		i = i/26 - 1
+	}
+	return name
+}
+
+func toString(value interface{}) string {
+	if s, ok := value.(string); ok {
+		return s
+	}
+	return csvValue(value)
+}
+
+func escapeXML(s string) string {
+	var sb strings.Builder
+	// EscapeText also replaces characters XML cannot carry, such as stray control bytes
+	xml.EscapeText(&sb, []byte(s))
+	return sb.String()
+}
+
+// sanitizeSheetName applies Excel's rules: at most 31 characters and none of []:*?/\
+func sanitizeSheetName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`[]:*?/\`, r) {
+			return '-'
+		}
+		return r
+	}, name)
+	if name == "" {
+		name = "Sheet1"
+	}
+	if runes := []rune(name); len(runes) > 31 {
+		name = string(runes[:31])
+	}
+	return name
+}
@@ -0,0 +1,38 @@
+// Package mail delivers the emails the application sends, such as password reset links
+package mail
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Message is one plain-text email
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Sender delivers messages. LogSender and FileSender are meant for local runs; an SMTP or provider-backed
+// sender only needs to implement Send.
+type Sender interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+const (
+	KindLog  = "log"
+	KindFile = "file"
+)
+
+// NewSender creates the sender of the given kind. FileSender writes below dir.
+func NewSender(kind, dir string) (Sender, error) {
+	switch strings.ToLower(strings.TrimSpace(kind)) {
+	case "", KindLog:
+		return NewLogSender(), nil
+	case KindFile:
+		return NewFileSender(dir), nil
+	default:
+		return nil, fmt.Errorf("unknown mail sender %q", kind)
+	}
+}
@@ -0,0 +1,60 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// LogSender writes each message to the standard logger instead of sending it
+type LogSender struct{}
+
+func NewLogSender() *LogSender {
+	return &LogSender{}
+}
+
+func (LogSender) Send(ctx context.Context, msg Message) error {
+	log.Printf("mail to %s: %s\n%s", msg.To, msg.Subject, msg.Body)
+	return nil
+}
+
+// FileSender stores each message as an .eml file in a directory, where a developer can open it
+type FileSender struct {
+	dir string
+	seq atomic.Int64
+}
+
+// NewFileSender creates a FileSender writing to dir. The directory is created on the first message.
+func NewFileSender(dir string) *FileSender {
+	return &FileSender{dir: dir}
+}
+
+func (f *FileSender) Send(ctx context.Context, msg Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(f.dir, 0o750); err != nil {
+		return fmt.Errorf("failed to create mail directory: %v", err)
+	}
+
+	now := time.Now()
+	name := fmt.Sprintf("%s-%d.eml", now.Format("20060102-150405.000000000"), f.seq.Add(1))
+	content := fmt.Sprintf("To: %s\r\nSubject: %s\r\nDate: %s\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n%s\r\n",
+		headerValue(msg.To), headerValue(msg.Subject), now.Format(time.RFC1123Z), msg.Body)
+
+	// Messages hold reset tokens, so only the owner may read them
+	if err := os.WriteFile(filepath.Join(f.dir, name), []byte(content), 0o600); err != nil {
+		return fmt.Errorf("failed to write mail: %v", err)
+	}
+	return nil
+}
+
+// headerValue keeps a value on one header line
+func headerValue(value string) string {
+	return strings.NewReplacer("\r", " ", "\n", " ").Replace(value)
+}
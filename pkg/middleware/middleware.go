@@ -1,6 +1,8 @@
 package middleware
 
 import (
+	"context"
+	"github.com/BerkatPS/pkg/principal"
 	"github.com/BerkatPS/pkg/utils"
 	"log"
 	"net/http"
@@ -13,7 +15,8 @@ func LoggingMiddleware(next http.Handler) http.Handler {
 
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
-		log.Printf("%s %s %s", r.Method, r.RequestURI, time.Since(start))
+		// The path alone: query strings may carry an access_token
+		log.Printf("%s %s %s", r.Method, r.URL.Path, time.Since(start))
 
 		next.ServeHTTP(w, r)
 	})
@@ -32,22 +35,90 @@ func RecoveryMiddleware(next http.Handler) http.Handler {
 	})
 }
 
-func AuthMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
-		authHeader := request.Header.Get("Authorization")
-		if authHeader == "" {
-			http.Error(writer, "Forbidden: no token provided", http.StatusForbidden)
-			return
-		}
+// PrincipalLoader builds the principal of a verified access token or of an API key
+type PrincipalLoader interface {
+	LoadPrincipal(ctx context.Context, claims *utils.AccessClaims) (*principal.Principal, error)
+	LoadAPIKeyPrincipal(ctx context.Context, apiKey string) (*principal.Principal, error)
+}
 
-		token := strings.TrimPrefix(authHeader, "Bearer ")
-		if !utils.ValidateToken(token) {
-			http.Error(writer, "Forbidden: invalid token provided", http.StatusForbidden)
-			return
-		}
+// AuthMiddleware verifies the bearer token of every request and places its principal in the request context.
+// publicRoutes, given as "METHOD /path", may also be called without a token. Event streams may pass the token
+// as ?access_token= because browsers' EventSource cannot set headers.
+// API keys come as a bearer token or in the X-API-Key header. They may only call paths below apiKeyPaths, whose
+// routes all check a permission the key's scopes have to grant.
+func AuthMiddleware(loader PrincipalLoader, publicRoutes []string, apiKeyPaths []string) func(http.Handler) http.Handler {
+	public := make(map[string]bool, len(publicRoutes))
+	for _, route := range publicRoutes {
+		public[route] = true
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			token := strings.TrimPrefix(request.Header.Get("Authorization"), "Bearer ")
+			if token == "" {
+				token = request.Header.Get("X-API-Key")
+			}
+			if token == "" && request.Method == http.MethodGet && strings.HasPrefix(request.URL.Path, "/events/") {
+				token = request.URL.Query().Get("access_token")
+			}
+
+			if token == "" {
+				if public[request.Method+" "+request.URL.Path] || request.Method == http.MethodOptions {
+					next.ServeHTTP(writer, request)
+					return
+				}
+				unauthorized(writer, "no token provided")
+				return
+			}
+
+			if utils.IsAPIKey(token) {
+				if !underAny(request.URL.Path, apiKeyPaths) {
+					utils.JSONErrorResponse(writer, http.StatusForbidden, map[string]interface{}{
+						"status":  "error",
+						"message": "Forbidden: API keys cannot call " + request.URL.Path,
+					})
+					return
+				}
+				p, err := loader.LoadAPIKeyPrincipal(request.Context(), token)
+				if err != nil {
+					unauthorized(writer, err.Error())
+					return
+				}
+				next.ServeHTTP(writer, request.WithContext(principal.NewContext(request.Context(), p)))
+				return
+			}
+
+			claims, err := utils.ParseAccessToken(request.Context(), token)
+			if err != nil {
+				unauthorized(writer, err.Error())
+				return
+			}
 
-		next.ServeHTTP(writer, request)
+			p, err := loader.LoadPrincipal(request.Context(), claims)
+			if err != nil {
+				unauthorized(writer, err.Error())
+				return
+			}
 
+			next.ServeHTTP(writer, request.WithContext(principal.NewContext(request.Context(), p)))
+		})
+	}
+}
+
+// underAny reports whether path is one of prefixes or below one of them
+func underAny(path string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if path == prefix || strings.HasPrefix(path, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func unauthorized(w http.ResponseWriter, reason string) {
+	utils.JSONErrorResponse(w, http.StatusUnauthorized, map[string]interface{}{
+		"status":  "error",
+		"message": "Unauthorized: " + reason,
 	})
 }
 
@@ -55,20 +126,23 @@ func CORSHandler(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
 		w.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, DELETE")
-		w.Header().Set("Access-Control-Allow-Headers", "Accept, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization")
+		w.Header().Set("Access-Control-Allow-Headers", "Accept, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, X-API-Key")
 		next.ServeHTTP(w, r)
 	})
 }
 
-func IPMiddleware(allowedIPs []string) func(http.Handler) http.Handler {
+// IPMiddleware resolves the address each request came from, believing X-Forwarded-For only when the peer is one
+// of trustedProxies, and stores it for utils.ClientIP
+func IPMiddleware(trustedProxies []string) func(http.Handler) http.Handler {
+	trusted, err := utils.ParseTrustedProxies(trustedProxies)
+	if err != nil {
+		log.Printf("Ignoring trusted proxies: %v", err)
+		trusted = nil
+	}
 	return func (next http.Handler) http.Handler  {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			ip := r.Header.Get("X-Forwarded-For")
-			if ip == "" {
-				ip = r.RemoteAddr
-			}
-			r.Header.Set("X-Forwarded-For", ip)
-			next.ServeHTTP(w, r)
+			ip := utils.ResolveClientIP(r, trusted)
+			next.ServeHTTP(w, r.WithContext(utils.WithClientIP(r.Context(), ip)))
 		})
 	}
 }
@@ -0,0 +1,307 @@
+// Package pdf writes simple PDF documents: text in the standard Helvetica fonts, lines, filled boxes and
+// JPEG or PNG images. It needs no external binaries or font files.
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	_ "image/png" // PNG photos are decoded and re-encoded as JPEG
+	"io"
+	"strings"
+	"time"
+)
+
+// A4 portrait, in points
+const (
+	PageWidth  = 595.28
+	PageHeight = 841.89
+)
+
+type Font int
+
+const (
+	Helvetica Font = iota
+	HelveticaBold
+)
+
+var fontNames = []string{"Helvetica", "Helvetica-Bold"}
+
+// Document is a PDF being built in memory. Pages may be drawn on in any order until WriteTo is called.
+type Document struct {
+	Title   string
+	Author  string
+	pages   []*Page
+	images  []*Image
+	created time.Time
+}
+
+// Page is one A4 page. Coordinates are in points from the top-left corner.
+type Page struct {
+	content bytes.Buffer
+}
+
+// Image is a picture embedded once in a document and drawable on any of its pages
+type Image struct {
+	Width, Height int // Pixels
+	name          string
+	colorSpace    string
+	data          []byte // DCT (JPEG) encoded
+}
+
+func New() *Document {
+	return &Document{created: time.Now()}
+}
+
+func (d *Document) AddPage() *Page {
+	page := &Page{}
+	d.pages = append(d.pages, page)
+	return page
+}
+
+func (d *Document) Pages() []*Page {
+	return d.pages
+}
+
+// AddImage embeds a JPEG or PNG image. JPEGs are embedded as they are; anything else is flattened onto a
+// white background and re-encoded as JPEG.
+func (d *Document) AddImage(content []byte) (*Image, error) {
+	config, format, err := image.DecodeConfig(bytes.NewReader(content))
+	if err != nil {
+		return nil, fmt.Errorf("unsupported image: %v", err)
+	}
+
+	img := &Image{Width: config.Width, Height: config.Height, name: fmt.Sprintf("Im%d", len(d.images)+1)}
+	switch {
+	case format == "jpeg" && config.ColorModel == color.YCbCrModel:
+		img.colorSpace, img.data = "DeviceRGB", content
+	case format == "jpeg" && config.ColorModel == color.GrayModel:
+		img.colorSpace, img.data = "DeviceGray", content
+	default:
+		decoded, _, err := image.Decode(bytes.NewReader(content))
+		if err != nil {
+			return nil, fmt.Errorf("unsupported image: %v", err)
+		}
+		flat := image.NewRGBA(decoded.Bounds())
+		draw.Draw(flat, flat.Bounds(), image.White, image.Point{}, draw.Src)
+		draw.Draw(flat, flat.Bounds(), decoded, decoded.Bounds().Min, draw.Over)
+
+		var encoded bytes.Buffer
+		if err := jpeg.Encode(&encoded, flat, &jpeg.Options{Quality: 85}); err != nil {
+			return nil, fmt.Errorf("failed to encode image: %v", err)
+		}
+		img.colorSpace, img.data = "DeviceRGB", encoded.Bytes()
+	}
+
+	d.images = append(d.images, img)
+	return img, nil
+}
+
+// Text draws s with its baseline at y
+func (p *Page) Text(x, y float64, font Font, size float64, s string) {
+	fmt.Fprintf(&p.content, "BT /F%d %.2f Tf %.2f %.2f Td (%s) Tj ET\n", font+1, size, x, PageHeight-y, escape(s))
+}
+
+// Line draws a straight line in the given gray level, 0 being black and 1 white
+func (p *Page) Line(x1, y1, x2, y2, width, gray float64) {
+	fmt.Fprintf(&p.content, "q %.2f G %.2f w %.2f %.2f m %.2f %.2f l S Q\n", gray, width, x1, PageHeight-y1, x2, PageHeight-y2)
+}
+
+// FillRect fills a box whose top-left corner is at x, y
+func (p *Page) FillRect(x, y, w, h, gray float64) {
+	fmt.Fprintf(&p.content, "q %.2f g %.2f %.2f %.2f %.2f re f Q\n", gray, x, PageHeight-y-h, w, h)
+}
+
+// StrokeRect outlines a box whose top-left corner is at x, y
+func (p *Page) StrokeRect(x, y, w, h, width, gray float64) {
+	fmt.Fprintf(&p.content, "q %.2f G %.2f w %.2f %.2f %.2f %.2f re S Q\n", gray, width, x, PageHeight-y-h, w, h)
+}
+
+// Image draws img scaled into the box whose top-left corner is at x, y
+func (p *Page) Image(img *Image, x, y, w, h float64) {
+	fmt.Fprintf(&p.content, "q %.2f 0 0 %.2f %.2f %.2f cm /%s Do Q\n", w, h, x, PageHeight-y-h, img.name)
+}
+
+// TextWidth returns the width of s in points when set in font at size
+func TextWidth(font Font, size float64, s string) float64 {
+	widths := helveticaWidths
+	if font == HelveticaBold {
+		widths = helveticaBoldWidths
+	}
+
+	var total int
+	for _, b := range encode(s) {
+		if b >= 32 && int(b-32) < len(widths) {
+			total += widths[b-32]
+		} else {
+			total += 556
+		}
+	}
+	return float64(total) * size / 1000
+}
+
+// WriteTo serialises the document
+func (d *Document) WriteTo(w io.Writer) (int64, error) {
+	out := &countingWriter{w: w}
+	var offsets []int64
+
+	// Objects are numbered in the order they are written: catalog, page tree, fonts, images, then each page and its content
+	fontBase := 3
+	imageBase := fontBase + len(fontNames)
+	pageBase := imageBase + len(d.images)
+
+	begin := func() {
+		offsets = append(offsets, out.n)
+		fmt.Fprintf(out, "%d 0 obj\n", len(offsets))
+	}
+	end := func() {
+		io.WriteString(out, "endobj\n")
+	}
+
+	io.WriteString(out, "%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+
+	begin()
+	io.WriteString(out, "<< /Type /Catalog /Pages 2 0 R >>\n")
+	end()
+
+	begin()
+	kids := make([]string, len(d.pages))
+	for i := range d.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", pageBase+2*i)
+	}
+	fmt.Fprintf(out, "<< /Type /Pages /Kids [%s] /Count %d >>\n", strings.Join(kids, " "), len(d.pages))
+	end()
+
+	for _, name := range fontNames {
+		begin()
+		fmt.Fprintf(out, "<< /Type /Font /Subtype /Type1 /BaseFont /%s /Encoding /WinAnsiEncoding >>\n", name)
+		end()
+	}
+
+	for _, img := range d.images {
+		begin()
+		fmt.Fprintf(out, "<< /Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /%s /BitsPerComponent 8 /Filter /DCTDecode /Length %d >>\nstream\n",
+			img.Width, img.Height, img.colorSpace, len(img.data))
+		out.Write(img.data)
+		io.WriteString(out, "\nendstream\n")
+		end()
+	}
+
+	var resources strings.Builder
+	resources.WriteString("<< /Font <<")
+	for i := range fontNames {
+		fmt.Fprintf(&resources, " /F%d %d 0 R", i+1, fontBase+i)
+	}
+	resources.WriteString(" >>")
+	if len(d.images) > 0 {
+		resources.WriteString(" /XObject <<")
+		for i, img := range d.images {
+			fmt.Fprintf(&resources, " /%s %d 0 R", img.name, imageBase+i)
+		}
+		resources.WriteString(" >>")
+	}
+	resources.WriteString(" >>")
+
+	for i, page := range d.pages {
+		begin()
+		fmt.Fprintf(out, "<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.2f %.2f] /Resources %s /Contents %d 0 R >>\n",
+			PageWidth, PageHeight, resources.String(), pageBase+2*i+1)
+		end()
+
+		var compressed bytes.Buffer
+		zw := zlib.NewWriter(&compressed)
+		zw.Write(page.content.Bytes())
+		zw.Close()
+
+		begin()
+		fmt.Fprintf(out, "<< /Length %d /Filter /FlateDecode >>\nstream\n", compressed.Len())
+		out.Write(compressed.Bytes())
+		io.WriteString(out, "\nendstream\n")
+		end()
+	}
+
+	begin()
+	fmt.Fprintf(out, "<< /Title (%s) /Author (%s) /Producer (construction-tracking) /CreationDate (D:%s) >>\n",
+		escape(d.Title), escape(d.Author), d.created.Format("20060102150405"))
+	end()
+	info := len(offsets)
+
+	xref := out.n
+	fmt.Fprintf(out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(out, "trailer\n<< /Size %d /Root 1 0 R /Info %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, info, xref)
+
+	return out.n, out.err
+}
+
+// encode converts s to WinAnsi; characters outside Latin-1 become '?'
+func encode(s string) []byte {
+	encoded := make([]byte, 0, len(s))
+	for _, r := range s {
+		switch {
+		case r == '\t':
+			encoded = append(encoded, ' ')
+		case r < 32:
+			continue
+		case r < 127 || (r >= 0xA0 && r <= 0xFF):
+			encoded = append(encoded, byte(r))
+		default:
+			encoded = append(encoded, '?')
+		}
+	}
+	return encoded
+}
+
+func escape(s string) string {
+	var b strings.Builder
+	for _, c := range encode(s) {
+		switch c {
+		case '\\', '(', ')':
+			b.WriteByte('\\')
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+// countingWriter tracks the byte offset needed by the cross-reference table and keeps the first write error
+type countingWriter struct {
+	w   io.Writer
+	n   int64
+	err error
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	if c.err != nil {
+		return 0, c.err
+	}
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	c.err = err
+	return n, err
+}
+
+// Glyph widths of the printable ASCII characters (32-126) in 1/1000 em, from the Adobe font metrics
+var helveticaWidths = []int{
+	278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278,
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556,
+	1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778,
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556,
+	333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556,
+	556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584,
+}
+
+var helveticaBoldWidths = []int{
+	278, 333, 474, 556, 556, 889, 722, 238, 333, 333, 389, 584, 278, 333, 278, 278,
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 333, 333, 584, 584, 584, 611,
+	975, 722, 722, 722, 722, 667, 611, 778, 722, 278, 556, 722, 611, 833, 722, 778,
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 333, 278, 333, 584, 556,
+	333, 556, 611, 556, 611, 556, 333, 611, 611, 278, 278, 556, 278, 889, 611, 611,
+	611, 611, 389, 556, 333, 611, 556, 778, 556, 556, 500, 389, 280, 389, 584,
+}
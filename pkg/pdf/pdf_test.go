@@ -0,0 +1,54 @@
+package pdf
+
+import "testing"
+
+func TestEncode(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{name: "ascii", in: "Slab pour, level 3", want: "Slab pour, level 3"},
+		{name: "empty", in: "", want: ""},
+		{name: "tab becomes space", in: "a\tb", want: "a b"},
+		{name: "control characters dropped", in: "a\nb\r\x00c", want: "abc"},
+		{name: "delete replaced", in: "a\x7fb", want: "a?b"},
+		{name: "latin-1 kept", in: "café ½", want: "caf\xe9 \xbd"},
+		{name: "no-break space kept", in: "a\u00a0b", want: "a\xa0b"},
+		{name: "C1 controls replaced", in: "a\u0085b", want: "a?b"},
+		{name: "outside latin-1 replaced", in: "€5 → 日本", want: "?5 ? ??"},
+		{name: "invalid UTF-8 replaced", in: "a\xffb", want: "a?b"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(encode(tt.in)); got != tt.want {
+				t.Errorf("encode(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEscape(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{name: "plain", in: "Daily report", want: "Daily report"},
+		{name: "parentheses", in: "Grid (A-C)", want: `Grid \(A-C\)`},
+		{name: "unbalanced parenthesis", in: "done)", want: `done\)`},
+		{name: "backslash", in: `C:\reports`, want: `C:\\reports`},
+		{name: "string terminator injection", in: `) Tj /F1 99 Tf (`, want: `\) Tj /F1 99 Tf \(`},
+		{name: "newline cannot end the operator", in: "a)\nET", want: `a\)ET`},
+		{name: "escapes after encoding", in: "(€)", want: `\(?\)`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := escape(tt.in); got != tt.want {
+				t.Errorf("escape(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,116 @@
+// Package principal carries the authenticated caller of a request. AuthMiddleware loads it once per request;
+// handlers and services read it from the context instead of parsing the token again or trusting IDs in bodies.
+package principal
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// ErrUnauthenticated is returned when a request carries no principal
+var ErrUnauthenticated = errors.New("not authenticated")
+
+// Membership is the caller's place on one project team
+type Membership struct {
+	ProjectID int64  `json:"project_id"`
+	Role      string `json:"role"` // Team role, or the user's own role when the team gave none
+}
+
+// Principal is who a request is made by
+type Principal struct {
+	UserID      int64        `json:"user_id"`
+	Role        string       `json:"role"`
+	Memberships []Membership `json:"memberships"`
+	TokenID     string       `json:"token_id"`   // jti of the access token
+	SessionID   string       `json:"session_id"` // Login session, the refresh token family
+
+	APIKeyID int64    `json:"api_key_id,omitempty"` // Set when the request authenticated with an API key
+	Scopes   []string `json:"scopes,omitempty"`     // What the API key may do, on top of what its role grants
+}
+
+// IsAPIKey reports whether the caller is an API key rather than a logged in user
+func (p *Principal) IsAPIKey() bool {
+	return p.APIKeyID != 0
+}
+
+// IsAdmin reports whether the caller holds the admin role
+func (p *Principal) IsAdmin() bool {
+	return strings.EqualFold(strings.TrimSpace(p.Role), "admin")
+}
+
+// ProjectRole returns the caller's role on a project and whether they are on its team
+func (p *Principal) ProjectRole(projectID int64) (string, bool) {
+	for _, membership := range p.Memberships {
+		if membership.ProjectID == projectID {
+			return membership.Role, true
+		}
+	}
+	return "", false
+}
+
+// IsMember reports whether the caller is on the project's team
+func (p *Principal) IsMember(projectID int64) bool {
+	_, ok := p.ProjectRole(projectID)
+	return ok
+}
+
+// ProjectIDs lists the projects the caller belongs to
+func (p *Principal) ProjectIDs() []int64 {
+	ids := make([]int64, 0, len(p.Memberships))
+	for _, membership := range p.Memberships {
+		ids = append(ids, membership.ProjectID)
+	}
+	return ids
+}
+
+// VisibleProjectIDs lists the projects whose rows the caller of ctx may see in listings: nil for admins,
+// meaning every project, and the projects they belong to otherwise. Without a caller it is empty, so a listing
+// filtered by it returns nothing.
+func VisibleProjectIDs(ctx context.Context) []int64 {
+	caller, ok := FromContext(ctx)
+	if !ok {
+		return []int64{}
+	}
+	if caller.IsAdmin() {
+		return nil
+	}
+	return caller.ProjectIDs()
+}
+
+type contextKey struct{}
+
+// NewContext returns a copy of ctx carrying p
+func NewContext(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, contextKey{}, p)
+}
+
+// FromContext returns the principal of ctx, if any
+func FromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(contextKey{}).(*Principal)
+	return p, ok && p != nil
+}
+
+// Require returns the principal of ctx or ErrUnauthenticated
+func Require(ctx context.Context) (*Principal, error) {
+	p, ok := FromContext(ctx)
+	if !ok {
+		return nil, ErrUnauthenticated
+	}
+	return p, nil
+}
+
+// UserID returns the ID of the authenticated user of ctx
+func UserID(ctx context.Context) (int64, error) {
+	p, err := Require(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return p.UserID, nil
+}
+
+// UserIDFromRequest returns the ID of the user making the request
+func UserIDFromRequest(r *http.Request) (int64, error) {
+	return UserID(r.Context())
+}
@@ -0,0 +1,148 @@
+package rbac
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/BerkatPS/pkg/principal"
+	"github.com/BerkatPS/pkg/utils"
+)
+
+// Enforcer guards handlers with the permission they need. The caller's role and project memberships come
+// from the principal AuthMiddleware placed in the request context; API keys are further limited to their scopes.
+type Enforcer struct {
+	scope ProjectScope
+}
+
+func NewEnforcer(scope ProjectScope) *Enforcer {
+	return &Enforcer{scope}
+}
+
+// RequireProject guards a handler working on one project. Admins always pass. Everyone else must be on the
+// project's team, and their role there must grant permission. A request that names no project, or a resource
+// without one, is refused: listings across projects filter by the caller's memberships instead.
+func (e *Enforcer) RequireProject(permission Permission, resolve ProjectResolver, next http.HandlerFunc) http.HandlerFunc {
+	return e.requireProject(permission, resolve, false, next)
+}
+
+// RequireProjectOrOrganization guards resources that belong either to one project or, when they have no
+// project, to the whole organization, such as report templates. Project resources are checked as RequireProject
+// does; organization ones only need the caller's own role to grant permission.
+func (e *Enforcer) RequireProjectOrOrganization(permission Permission, resolve ProjectResolver, next http.HandlerFunc) http.HandlerFunc {
+	return e.requireProject(permission, resolve, true, next)
+}
+
+func (e *Enforcer) requireProject(permission Permission, resolve ProjectResolver, organization bool, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		caller, ok := authenticate(w, r)
+		if !ok || !checkScope(w, caller, permission) {
+			return
+		}
+		if caller.IsAdmin() {
+			next(w, r)
+			return
+		}
+
+		projectID, err := resolve(r)
+		if errors.Is(err, ErrResourceNotFound) {
+			utils.JSONErrorResponse(w, http.StatusNotFound, map[string]interface{}{
+				"status":  "error",
+				"message": "Resource not found",
+			})
+			return
+		}
+		if err != nil {
+			utils.JSONErrorResponse(w, http.StatusBadRequest, map[string]interface{}{
+				"status":  "error",
+				"message": "Invalid project reference: " + err.Error(),
+			})
+			return
+		}
+		if projectID == 0 {
+			if !organization {
+				utils.JSONResponse(w, http.StatusForbidden, map[string]interface{}{
+					"status":     "error",
+					"message":    "Forbidden: " + string(permission) + " needs a project the caller is a member of",
+					"permission": permission,
+				})
+				return
+			}
+			if !Can(caller.Role, permission) {
+				forbidden(w, permission)
+				return
+			}
+			next(w, r)
+			return
+		}
+
+		role, member := caller.ProjectRole(projectID)
+		if !member {
+			utils.JSONResponse(w, http.StatusForbidden, map[string]interface{}{
+				"status":     "error",
+				"message":    "Forbidden: not a member of project " + strconv.FormatInt(projectID, 10),
+				"permission": permission,
+				"project_id": projectID,
+			})
+			return
+		}
+
+		if !Can(role, permission) {
+			forbidden(w, permission)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// Require lets a request through to next only when the caller's role grants permission. The principal is
+// loaded on every request, so a role change applies at once. A denial is a 403 naming the missing permission.
+func (e *Enforcer) Require(permission Permission, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		caller, ok := authenticate(w, r)
+		if !ok || !checkScope(w, caller, permission) {
+			return
+		}
+		if !Can(caller.Role, permission) {
+			forbidden(w, permission)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// authenticate returns the caller, answering the request itself when there is none
+func authenticate(w http.ResponseWriter, r *http.Request) (*principal.Principal, bool) {
+	caller, err := principal.Require(r.Context())
+	if err != nil {
+		utils.JSONErrorResponse(w, http.StatusUnauthorized, map[string]interface{}{
+			"status":  "error",
+			"message": "Unauthorized: " + err.Error(),
+		})
+		return nil, false
+	}
+	return caller, true
+}
+
+// checkScope lets an API key through only when one of its scopes grants permission, answering the request
+// itself otherwise. Logged in users carry no scopes and always pass.
+func checkScope(w http.ResponseWriter, caller *principal.Principal, permission Permission) bool {
+	if !caller.IsAPIKey() || ScopesGrant(caller.Scopes, permission) {
+		return true
+	}
+	utils.JSONResponse(w, http.StatusForbidden, map[string]interface{}{
+		"status":     "error",
+		"message":    "Forbidden: API key has no scope granting " + string(permission),
+		"permission": permission,
+		"scopes":     caller.Scopes,
+	})
+	return false
+}
+
+func forbidden(w http.ResponseWriter, permission Permission) {
+	utils.JSONResponse(w, http.StatusForbidden, map[string]interface{}{
+		"status":     "error",
+		"message":    "Forbidden: missing permission " + string(permission),
+		"permission": permission,
+	})
+}
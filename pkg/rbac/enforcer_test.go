@@ -0,0 +1,200 @@
+package rbac
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/BerkatPS/pkg/principal"
+)
+
+// fakeScope finds the project of a resource in a map; IDs missing from it do not exist
+type fakeScope map[int64]int64
+
+func (f fakeScope) FindProjectID(ctx context.Context, resource Resource, id int64) (int64, error) {
+	projectID, ok := f[id]
+	if !ok {
+		return 0, ErrResourceNotFound
+	}
+	return projectID, nil
+}
+
+func TestRequireProject(t *testing.T) {
+	enforcer := NewEnforcer(fakeScope{10: 1, 11: 2, 12: 0})
+
+	admin := &principal.Principal{UserID: 1, Role: RoleAdmin}
+	engineer := &principal.Principal{UserID: 2, Role: RoleWorker, Memberships: []principal.Membership{
+		{ProjectID: 1, Role: RoleSiteEngineer},
+		{ProjectID: 3, Role: RoleWorker},
+	}}
+	engineerKey := &principal.Principal{UserID: 2, Role: RoleWorker, APIKeyID: 7, Scopes: []string{ScopeTasksRead},
+		Memberships: engineer.Memberships}
+
+	tests := []struct {
+		name         string
+		caller       *principal.Principal
+		permission   Permission
+		resolve      ProjectResolver
+		organization bool
+		path         string
+		query        string
+		body         string
+		want         int
+	}{
+		{name: "no principal", permission: TaskRead, resolve: PathProject("id"), path: "1", want: http.StatusUnauthorized},
+		{name: "admin without project", caller: admin, permission: TaskUpdate, resolve: PathProject("id"), want: http.StatusOK},
+		{name: "admin of other project", caller: admin, permission: TaskUpdate, resolve: PathProject("id"), path: "9", want: http.StatusOK},
+		{name: "team role grants what user role does not", caller: engineer, permission: TaskUpdate, resolve: PathProject("id"), path: "1", want: http.StatusOK},
+		{name: "team role without permission", caller: engineer, permission: TaskUpdate, resolve: PathProject("id"), path: "3", want: http.StatusForbidden},
+		{name: "not a member", caller: engineer, permission: TaskRead, resolve: PathProject("id"), path: "2", want: http.StatusForbidden},
+		{name: "missing path ID", caller: engineer, permission: TaskRead, resolve: PathProject("id"), want: http.StatusForbidden},
+		{name: "malformed path ID", caller: engineer, permission: TaskRead, resolve: PathProject("id"), path: "abc", want: http.StatusBadRequest},
+		{name: "negative path ID", caller: engineer, permission: TaskRead, resolve: PathProject("id"), path: "-1", want: http.StatusBadRequest},
+		{name: "query project", caller: engineer, permission: TaskRead, resolve: QueryProject("project_id"), query: "project_id=1", want: http.StatusOK},
+		{name: "missing query project", caller: engineer, permission: TaskRead, resolve: QueryProject("project_id"), want: http.StatusForbidden},
+		{name: "body project", caller: engineer, permission: TaskRead, resolve: BodyProject("project_id"), body: `{"project_id": 1}`, want: http.StatusOK},
+		{name: "case-variant body key", caller: engineer, permission: TaskRead, resolve: BodyProject("project_id"), body: `{"Project_ID": 2}`, want: http.StatusForbidden},
+		{name: "later body key wins", caller: engineer, permission: TaskRead, resolve: BodyProject("project_id"), body: `{"project_id": 1, "PROJECT_ID": 2}`, want: http.StatusForbidden},
+		{name: "missing body project", caller: engineer, permission: TaskRead, resolve: BodyProject("project_id"), body: `{"name": "x"}`, want: http.StatusForbidden},
+		{name: "body not an object", caller: engineer, permission: TaskRead, resolve: BodyProject("project_id"), body: `[1]`, want: http.StatusBadRequest},
+		{name: "resource of own project", caller: engineer, permission: TaskRead, resolve: enforcer.PathResource(ResourceTask, "id"), path: "10", want: http.StatusOK},
+		{name: "resource of other project", caller: engineer, permission: TaskRead, resolve: enforcer.PathResource(ResourceTask, "id"), path: "11", want: http.StatusForbidden},
+		{name: "unknown resource", caller: engineer, permission: TaskRead, resolve: enforcer.PathResource(ResourceTask, "id"), path: "99", want: http.StatusNotFound},
+		{name: "resource without project", caller: engineer, permission: TaskRead, resolve: enforcer.PathResource(ResourceTask, "id"), path: "12", want: http.StatusForbidden},
+		{name: "body resource", caller: engineer, permission: TaskRead, resolve: enforcer.BodyResource(ResourceTask, "task_id"), body: `{"Task_Id": 11}`, want: http.StatusForbidden},
+		{name: "organization resource", caller: engineer, permission: TaskRead, resolve: enforcer.PathResource(ResourceTask, "id"), organization: true, path: "12", want: http.StatusOK},
+		{name: "organization resource without permission", caller: engineer, permission: TaskDelete, resolve: enforcer.PathResource(ResourceTask, "id"), organization: true, path: "12", want: http.StatusForbidden},
+		{name: "organization mode keeps project check", caller: engineer, permission: TaskRead, resolve: enforcer.PathResource(ResourceTask, "id"), organization: true, path: "11", want: http.StatusForbidden},
+		{name: "API key within scope", caller: engineerKey, permission: TaskRead, resolve: PathProject("id"), path: "1", want: http.StatusOK},
+		{name: "API key outside scope", caller: engineerKey, permission: TaskUpdate, resolve: PathProject("id"), path: "1", want: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body io.Reader
+			if tt.body != "" {
+				body = strings.NewReader(tt.body)
+			}
+			r := httptest.NewRequest(http.MethodPost, "/?"+tt.query, body)
+			r.SetPathValue("id", tt.path)
+			if tt.caller != nil {
+				r = r.WithContext(principal.NewContext(r.Context(), tt.caller))
+			}
+
+			next := func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}
+			handler := enforcer.RequireProject(tt.permission, tt.resolve, next)
+			if tt.organization {
+				handler = enforcer.RequireProjectOrOrganization(tt.permission, tt.resolve, next)
+			}
+
+			w := httptest.NewRecorder()
+			handler(w, r)
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.want, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestBodyProject(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		want    int64
+		wantErr bool
+	}{
+		{name: "exact key", body: `{"project_id": 4}`, want: 4},
+		{name: "capitalized key", body: `{"Project_id": 4}`, want: 4},
+		{name: "upper case key", body: `{"PROJECT_ID": 4}`, want: 4},
+		{name: "later key overrides", body: `{"project_id": 4, "Project_Id": 5}`, want: 5},
+		{name: "null is ignored", body: `{"project_id": 4, "PROJECT_ID": null}`, want: 4},
+		{name: "nested key is ignored", body: `{"task": {"project_id": 4}}`, want: 0},
+		{name: "missing key", body: `{"name": "slab"}`, want: 0},
+		{name: "empty body", body: ``, wantErr: true},
+		{name: "not an object", body: `"project_id"`, wantErr: true},
+		{name: "truncated object", body: `{"project_id": 4`, wantErr: true},
+		{name: "string ID", body: `{"project_id": "4"}`, wantErr: true},
+		{name: "negative ID", body: `{"project_id": -4}`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body))
+
+			got, err := BodyProject("project_id")(r)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, want error %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("project = %d, want %d", got, tt.want)
+			}
+
+			// The handler behind the resolver still reads the whole body
+			rest, _ := io.ReadAll(r.Body)
+			if string(rest) != tt.body {
+				t.Errorf("body left for the handler = %q, want %q", rest, tt.body)
+			}
+		})
+	}
+}
+
+func TestParseID(t *testing.T) {
+	tests := []struct {
+		raw     string
+		want    int64
+		wantErr bool
+	}{
+		{raw: "", want: 0},
+		{raw: "12", want: 12},
+		{raw: "12.pdf", want: 12},
+		{raw: "12.tar.gz", want: 12},
+		{raw: ".pdf", want: 0},
+		{raw: "x12", wantErr: true},
+		{raw: "-3", wantErr: true},
+		{raw: "99999999999999999999", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			got, err := parseID(tt.raw)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, want error %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ID = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCanAssignTeamRole(t *testing.T) {
+	tests := []struct {
+		assigner string
+		role     string
+		want     bool
+	}{
+		{assigner: RoleAdmin, role: RoleProjectManager, want: true},
+		{assigner: RoleAdmin, role: RoleAdmin, want: false},
+		{assigner: RoleProjectManager, role: RoleAdmin, want: false},
+		{assigner: RoleProjectManager, role: "Admin", want: false},
+		{assigner: RoleProjectManager, role: RoleProjectManager, want: true},
+		{assigner: RoleProjectManager, role: "Site Engineer", want: true},
+		{assigner: RoleProjectManager, role: RoleWorker, want: true},
+		{assigner: RoleSiteEngineer, role: RoleProjectManager, want: false},
+		{assigner: RoleWorker, role: RoleInspector, want: false},
+		{assigner: RoleProjectManager, role: "superintendent", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.assigner+" assigns "+tt.role, func(t *testing.T) {
+			if got := CanAssignTeamRole(tt.assigner, tt.role); got != tt.want {
+				t.Errorf("CanAssignTeamRole(%q, %q) = %v, want %v", tt.assigner, tt.role, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,196 @@
+// Package rbac maps user roles to the permissions they grant and enforces those permissions on HTTP handlers
+package rbac
+
+import "strings"
+
+const (
+	RoleAdmin          = "admin"
+	RoleProjectManager = "project_manager"
+	RoleSiteEngineer   = "site_engineer"
+	RoleInspector      = "inspector"
+	RoleAccountant     = "accountant"
+	RoleWorker         = "worker"
+)
+
+// Permission names one action on one kind of resource, as resource:action
+type Permission string
+
+const (
+	ProjectRead            Permission = "project:read"
+	ProjectCreate          Permission = "project:create"
+	ProjectUpdate          Permission = "project:update"
+	ProjectDelete          Permission = "project:delete"
+	ProjectManageTeam      Permission = "project:manage_team"
+	ProjectUpdateBudget    Permission = "project:update_budget"
+	ProjectManageDocuments Permission = "project:manage_documents"
+
+	TaskRead         Permission = "task:read"
+	TaskCreate       Permission = "task:create"
+	TaskUpdate       Permission = "task:update"
+	TaskDelete       Permission = "task:delete"
+	TaskUpdateStatus Permission = "task:update_status"
+	TaskArchive      Permission = "task:archive"
+
+	ExpenseRead    Permission = "expense:read"
+	ExpenseCreate  Permission = "expense:create"
+	ExpenseUpdate  Permission = "expense:update"
+	ExpenseDelete  Permission = "expense:delete"
+	ExpenseApprove Permission = "expense:approve"
+
+	QualityRead   Permission = "quality:read"
+	QualityCreate Permission = "quality:create"
+	QualityUpdate Permission = "quality:update"
+	QualityReport Permission = "quality:report"
+
+	PresenceRead   Permission = "presence:read"
+	PresenceCreate Permission = "presence:create"
+	PresenceUpdate Permission = "presence:update"
+
+	PermitRead   Permission = "permit:read"
+	PermitManage Permission = "permit:manage" // Drafting, issuing, suspending, resuming and closing permits
+	PermitAccept Permission = "permit:accept" // Signing a permit as the receiver of the work
+
+	MessageRead Permission = "message:read"
+	MessagePost Permission = "message:post"
+
+	SafetyRead           Permission = "safety:read"
+	SafetyReport         Permission = "safety:report"
+	SafetyInvestigate    Permission = "safety:investigate"     // Updating, investigating and closing incidents
+	SafetyCompleteAction Permission = "safety:complete_action" // Marking a corrective action done
+	SafetyPortfolio      Permission = "safety:portfolio"       // KPIs across every project; admins only
+
+	RFIRead    Permission = "rfi:read"
+	RFIManage  Permission = "rfi:manage" // Raising, issuing, closing, reopening and voiding RFIs and linking their tasks
+	RFIRespond Permission = "rfi:respond"
+
+	SubmittalRead   Permission = "submittal:read"
+	SubmittalSubmit Permission = "submittal:submit" // Creating and resubmitting submittals
+	SubmittalReview Permission = "submittal:review"
+
+	ReportRead            Permission = "report:read"
+	ReportCompile         Permission = "report:compile"          // Compiling daily reports and writing their notes
+	ReportManageTemplates Permission = "report:manage_templates" // Uploading and editing report templates; admins only
+
+	UserManage Permission = "user:manage" // Listing users, unlocking accounts and reading the login audit trail; admins only
+	JobManage  Permission = "job:manage"  // Reading and starting scheduled jobs; admins only
+)
+
+// rolePermissions is the policy. Admins are granted everything and are not listed. On a project the role is
+// the one held on its team, so the same user may approve expenses on one project and only read on another.
+var rolePermissions = map[string][]Permission{
+	RoleProjectManager: {
+		ProjectRead, ProjectCreate, ProjectUpdate, ProjectManageTeam, ProjectUpdateBudget, ProjectManageDocuments,
+		TaskRead, TaskCreate, TaskUpdate, TaskDelete, TaskUpdateStatus, TaskArchive,
+		ExpenseRead, ExpenseCreate, ExpenseUpdate, ExpenseDelete, ExpenseApprove,
+		QualityRead, QualityReport,
+		PresenceRead, PresenceCreate, PresenceUpdate,
+		PermitRead, PermitManage, PermitAccept,
+		MessageRead, MessagePost,
+		SafetyRead, SafetyReport, SafetyInvestigate, SafetyCompleteAction,
+		RFIRead, RFIManage, RFIRespond,
+		SubmittalRead, SubmittalSubmit, SubmittalReview,
+		ReportRead, ReportCompile,
+	},
+	RoleSiteEngineer: {
+		ProjectRead, ProjectManageDocuments,
+		TaskRead, TaskCreate, TaskUpdate, TaskUpdateStatus,
+		ExpenseRead, ExpenseCreate,
+		QualityRead,
+		PresenceRead, PresenceCreate, PresenceUpdate,
+		PermitRead, PermitManage, PermitAccept,
+		MessageRead, MessagePost,
+		SafetyRead, SafetyReport, SafetyInvestigate, SafetyCompleteAction,
+		RFIRead, RFIManage, RFIRespond,
+		SubmittalRead, SubmittalSubmit, SubmittalReview,
+		ReportRead, ReportCompile,
+	},
+	RoleInspector: {
+		ProjectRead,
+		TaskRead,
+		QualityRead, QualityCreate, QualityUpdate, QualityReport,
+		PresenceCreate,
+		PermitRead,
+		MessageRead, MessagePost,
+		SafetyRead, SafetyReport, SafetyInvestigate, SafetyCompleteAction,
+		RFIRead, RFIManage,
+		SubmittalRead, SubmittalReview,
+		ReportRead,
+	},
+	RoleAccountant: {
+		ProjectRead, ProjectUpdateBudget,
+		TaskRead,
+		ExpenseRead, ExpenseCreate, ExpenseUpdate, ExpenseDelete, ExpenseApprove,
+		PresenceRead,
+		MessageRead, MessagePost,
+		SafetyRead, SafetyReport,
+		SubmittalRead,
+		ReportRead,
+	},
+	RoleWorker: {
+		ProjectRead,
+		TaskRead, TaskUpdateStatus,
+		PresenceCreate,
+		PermitRead, PermitAccept,
+		MessageRead, MessagePost,
+		SafetyRead, SafetyReport, SafetyCompleteAction,
+		RFIRead,
+		SubmittalRead,
+	},
+}
+
+var grants = buildGrants()
+
+func buildGrants() map[string]map[Permission]bool {
+	grants := make(map[string]map[Permission]bool, len(rolePermissions))
+	for role, permissions := range rolePermissions {
+		grants[role] = make(map[Permission]bool, len(permissions))
+		for _, permission := range permissions {
+			grants[role][permission] = true
+		}
+	}
+	return grants
+}
+
+// NormalizeRole turns stored spellings such as "Project Manager" or "site-engineer" into the role constants
+func NormalizeRole(role string) string {
+	role = strings.ToLower(strings.TrimSpace(role))
+	return strings.NewReplacer(" ", "_", "-", "_").Replace(role)
+}
+
+// IsRole reports whether role is one of the known roles
+func IsRole(role string) bool {
+	role = NormalizeRole(role)
+	_, ok := rolePermissions[role]
+	return ok || role == RoleAdmin
+}
+
+// Can reports whether role grants permission. Unknown roles grant nothing.
+func Can(role string, permission Permission) bool {
+	role = NormalizeRole(role)
+	if role == RoleAdmin {
+		return true
+	}
+	return grants[role][permission]
+}
+
+// CanAssignTeamRole reports whether someone whose role on a project is assignerRole may give a team member
+// role there. Admin is an organization role and never a team role. Nobody but an admin may hand out a role
+// granting a permission they lack themselves.
+func CanAssignTeamRole(assignerRole, role string) bool {
+	role = NormalizeRole(role)
+	permissions, ok := rolePermissions[role]
+	if !ok {
+		return false
+	}
+	for _, permission := range permissions {
+		if !Can(assignerRole, permission) {
+			return false
+		}
+	}
+	return true
+}
+
+// Permissions lists what role grants; nil for admins, who are granted everything
+func Permissions(role string) []Permission {
+	return rolePermissions[NormalizeRole(role)]
+}
@@ -0,0 +1,214 @@
+package rbac
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Resource is a table whose rows belong to one project
+type Resource string
+
+const (
+	ResourceTask             Resource = "tasks"
+	ResourceExpense          Resource = "expenses"
+	ResourceQualityCheck     Resource = "quality_checks"
+	ResourceQualityReport    Resource = "quality_reports"
+	ResourcePresence         Resource = "presences"
+	ResourceDocument         Resource = "documents"
+	ResourceWorkPermit       Resource = "work_permits"
+	ResourceMessage          Resource = "messages"
+	ResourceSafetyIncident   Resource = "safety_incidents"
+	ResourceCorrectiveAction Resource = "corrective_actions"
+	ResourceRFI              Resource = "rfis"
+	ResourceSubmittal        Resource = "submittals"
+	ResourceReport           Resource = "reports"
+	ResourceReportTemplate   Resource = "report_templates"
+)
+
+// resources holds the query finding the project of one row of each resource. Most tables carry a project_id
+// column; the rest reach their project through their parent row.
+var resources = map[Resource]string{
+	ResourceTask:           projectColumn(ResourceTask),
+	ResourceExpense:        projectColumn(ResourceExpense),
+	ResourceQualityCheck:   projectColumn(ResourceQualityCheck),
+	ResourceQualityReport:  projectColumn(ResourceQualityReport),
+	ResourcePresence:       projectColumn(ResourcePresence),
+	ResourceDocument:       projectColumn(ResourceDocument),
+	ResourceWorkPermit:     projectColumn(ResourceWorkPermit),
+	ResourceMessage:        projectColumn(ResourceMessage),
+	ResourceSafetyIncident: projectColumn(ResourceSafetyIncident),
+	ResourceRFI:            projectColumn(ResourceRFI),
+	ResourceSubmittal:      projectColumn(ResourceSubmittal),
+	ResourceReport:         projectColumn(ResourceReport),
+	ResourceReportTemplate: projectColumn(ResourceReportTemplate),
+	ResourceCorrectiveAction: `SELECT COALESCE(i.project_id, 0) FROM corrective_actions a
+		JOIN safety_incidents i ON i.id = a.incident_id WHERE a.id = $1`,
+}
+
+func projectColumn(resource Resource) string {
+	return "SELECT COALESCE(project_id, 0) FROM " + string(resource) + " WHERE id = $1"
+}
+
+// maxScopedBodySize bounds the JSON bodies read to find the project of a request
+const maxScopedBodySize = 1 << 20
+
+// ErrResourceNotFound is returned by the resolvers when the resource a request names does not exist
+var ErrResourceNotFound = errors.New("resource not found")
+
+// ProjectScope finds the project a resource belongs to
+type ProjectScope interface {
+	// FindProjectID returns the project a row of resource belongs to, 0 when the row has no project, or
+	// ErrResourceNotFound when there is no such row
+	FindProjectID(ctx context.Context, resource Resource, id int64) (int64, error)
+}
+
+// ProjectResolver finds the project a request works on. 0 means the request names no project, which
+// RequireProject refuses for everyone but admins.
+type ProjectResolver func(r *http.Request) (int64, error)
+
+// PathProject reads the project ID from a path wildcard
+func PathProject(name string) ProjectResolver {
+	return func(r *http.Request) (int64, error) {
+		return parseID(r.PathValue(name))
+	}
+}
+
+// QueryProject reads the project ID from an optional query parameter
+func QueryProject(name string) ProjectResolver {
+	return func(r *http.Request) (int64, error) {
+		return parseID(r.URL.Query().Get(name))
+	}
+}
+
+// BodyProject reads the project ID from a field of the JSON body, leaving the body for the handler. The field
+// is matched the way encoding/json matches it, so the handler decodes the same value.
+func BodyProject(field string) ProjectResolver {
+	return func(r *http.Request) (int64, error) {
+		return bodyID(r, field)
+	}
+}
+
+// PathResource finds the project of the resource whose ID is in a path wildcard
+func (e *Enforcer) PathResource(resource Resource, name string) ProjectResolver {
+	return func(r *http.Request) (int64, error) {
+		id, err := parseID(r.PathValue(name))
+		if err != nil || id == 0 {
+			return 0, err
+		}
+		return e.scope.FindProjectID(r.Context(), resource, id)
+	}
+}
+
+// BodyResource finds the project of the resource whose ID is in a field of the JSON body
+func (e *Enforcer) BodyResource(resource Resource, field string) ProjectResolver {
+	return func(r *http.Request) (int64, error) {
+		id, err := bodyID(r, field)
+		if err != nil || id == 0 {
+			return 0, err
+		}
+		return e.scope.FindProjectID(r.Context(), resource, id)
+	}
+}
+
+// parseID reads an ID that may carry a file extension, such as 12.pdf. An empty value is 0.
+func parseID(raw string) (int64, error) {
+	raw, _, _ = strings.Cut(raw, ".")
+	if raw == "" {
+		return 0, nil
+	}
+	id, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil || id < 0 {
+		return 0, fmt.Errorf("invalid ID %q", raw)
+	}
+	return id, nil
+}
+
+func bodyID(r *http.Request, field string) (int64, error) {
+	if r.Body == nil {
+		return 0, nil
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxScopedBodySize+1))
+	r.Body.Close()
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("failed to read request body: %v", err)
+	}
+	if len(body) > maxScopedBodySize {
+		return 0, fmt.Errorf("request body is too large")
+	}
+
+	raw, err := findField(body, field)
+	if err != nil || raw == nil {
+		return 0, err
+	}
+	var id int64
+	if err := json.Unmarshal(raw, &id); err != nil || id < 0 {
+		return 0, fmt.Errorf("invalid %s", field)
+	}
+	return id, nil
+}
+
+// findField returns the value encoding/json would decode into a struct field tagged name: keys match without
+// regard to case, null leaves the field alone and a later key overrides an earlier one. It returns nil when
+// no key sets the field.
+func findField(body []byte, name string) (json.RawMessage, error) {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	if token, err := decoder.Token(); err != nil || token != json.Delim('{') {
+		return nil, fmt.Errorf("request body is not a JSON object")
+	}
+
+	var found json.RawMessage
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, fmt.Errorf("invalid JSON body: %v", err)
+		}
+		key, _ := token.(string)
+
+		var value json.RawMessage
+		if err := decoder.Decode(&value); err != nil {
+			return nil, fmt.Errorf("invalid JSON body: %v", err)
+		}
+		if strings.EqualFold(key, name) && string(bytes.TrimSpace(value)) != "null" {
+			found = value
+		}
+	}
+	if _, err := decoder.Token(); err != nil {
+		return nil, fmt.Errorf("invalid JSON body: %v", err)
+	}
+	return found, nil
+}
+
+type scopeRepository struct {
+	db *sql.DB
+}
+
+// NewScopeRepository creates the ProjectScope backed by the resource tables
+func NewScopeRepository(db *sql.DB) ProjectScope {
+	return &scopeRepository{db}
+}
+
+func (s *scopeRepository) FindProjectID(ctx context.Context, resource Resource, id int64) (int64, error) {
+	query, ok := resources[resource]
+	if !ok {
+		return 0, fmt.Errorf("unknown resource %q", resource)
+	}
+
+	var projectID int64
+	err := s.db.QueryRowContext(ctx, query, id).Scan(&projectID)
+	if err == sql.ErrNoRows {
+		return 0, ErrResourceNotFound
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to find project of %s %d: %v", resource, id, err)
+	}
+	return projectID, nil
+}
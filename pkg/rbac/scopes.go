@@ -0,0 +1,59 @@
+package rbac
+
+import (
+	"sort"
+	"strings"
+)
+
+// Scopes of API keys. A key may only do what both its scopes and the role it acts with grant.
+const (
+	ScopeProjectsRead  = "projects:read"
+	ScopeTasksRead     = "tasks:read"
+	ScopeTasksWrite    = "tasks:write"
+	ScopeExpensesRead  = "expenses:read"
+	ScopeExpensesWrite = "expenses:write"
+	ScopeQualityRead   = "quality:read"
+	ScopeQualityWrite  = "quality:write"
+	ScopePresenceRead  = "presence:read"
+	ScopePresenceWrite = "presence:write"
+)
+
+var scopePermissions = map[string][]Permission{
+	ScopeProjectsRead:  {ProjectRead},
+	ScopeTasksRead:     {TaskRead},
+	ScopeTasksWrite:    {TaskCreate, TaskUpdate, TaskUpdateStatus},
+	ScopeExpensesRead:  {ExpenseRead},
+	ScopeExpensesWrite: {ExpenseCreate, ExpenseUpdate},
+	ScopeQualityRead:   {QualityRead},
+	ScopeQualityWrite:  {QualityCreate, QualityUpdate},
+	ScopePresenceRead:  {PresenceRead},
+	ScopePresenceWrite: {PresenceCreate, PresenceUpdate},
+}
+
+// IsScope reports whether scope is one of the known API key scopes
+func IsScope(scope string) bool {
+	_, ok := scopePermissions[strings.ToLower(strings.TrimSpace(scope))]
+	return ok
+}
+
+// AllScopes lists the known scopes in alphabetical order
+func AllScopes() []string {
+	scopes := make([]string, 0, len(scopePermissions))
+	for scope := range scopePermissions {
+		scopes = append(scopes, scope)
+	}
+	sort.Strings(scopes)
+	return scopes
+}
+
+// ScopesGrant reports whether any of scopes grants permission
+func ScopesGrant(scopes []string, permission Permission) bool {
+	for _, scope := range scopes {
+		for _, granted := range scopePermissions[strings.ToLower(strings.TrimSpace(scope))] {
+			if granted == permission {
+				return true
+			}
+		}
+	}
+	return false
+}
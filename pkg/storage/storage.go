@@ -0,0 +1,22 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+// ErrNotFound is returned when no blob is stored under the key
+var ErrNotFound = errors.New("blob not found")
+
+// ErrInvalidKey is returned for keys that are empty, absolute or escape the store
+var ErrInvalidKey = errors.New("invalid blob key")
+
+// BlobStore keeps file contents under opaque, slash-separated keys.
+// LocalStore is the default; an S3-compatible store only needs to implement these three methods.
+type BlobStore interface {
+	// Put streams r into the store under key and returns the number of bytes written
+	Put(ctx context.Context, key string, r io.Reader) (int64, error)
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+}
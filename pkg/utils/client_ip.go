@@ -0,0 +1,101 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// TrustedProxies are the networks of the reverse proxies whose X-Forwarded-For header is believed
+type TrustedProxies []*net.IPNet
+
+// ParseTrustedProxies reads proxy addresses and CIDR ranges such as 10.0.0.1 or 10.0.0.0/8
+func ParseTrustedProxies(entries []string) (TrustedProxies, error) {
+	proxies := make(TrustedProxies, 0, len(entries))
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q", entry)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			proxies = append(proxies, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %v", entry, err)
+		}
+		proxies = append(proxies, network)
+	}
+	return proxies, nil
+}
+
+// Contains reports whether ip belongs to one of the trusted proxies
+func (t TrustedProxies) Contains(ip net.IP) bool {
+	for _, network := range t {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ResolveClientIP returns the address a request came from. That is the peer address unless the peer is a
+// trusted proxy, in which case X-Forwarded-For is walked from the right, skipping trusted proxies, and the
+// first hop that is not one is the client. Entries left of it were written by the client and are ignored.
+func ResolveClientIP(r *http.Request, trusted TrustedProxies) string {
+	peer := stripPort(r.RemoteAddr)
+	client := net.ParseIP(peer)
+	if client == nil || !trusted.Contains(client) {
+		return peer
+	}
+
+	var hops []string
+	for _, value := range r.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(value, ",")...)
+	}
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip := net.ParseIP(stripPort(strings.TrimSpace(hops[i])))
+		if ip == nil {
+			break
+		}
+		client = ip
+		if !trusted.Contains(ip) {
+			break
+		}
+	}
+	return client.String()
+}
+
+type clientIPKey struct{}
+
+// WithClientIP stores the address IPMiddleware resolved for a request
+func WithClientIP(ctx context.Context, ip string) context.Context {
+	return context.WithValue(ctx, clientIPKey{}, ip)
+}
+
+// ClientIP returns the address IPMiddleware resolved for the request, or the peer address without its port
+// when the request did not pass the middleware
+func ClientIP(r *http.Request) string {
+	if ip, ok := r.Context().Value(clientIPKey{}).(string); ok && ip != "" {
+		return ip
+	}
+	return stripPort(r.RemoteAddr)
+}
+
+func stripPort(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return strings.Trim(addr, "[]")
+}
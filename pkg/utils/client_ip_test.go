@@ -0,0 +1,65 @@
+package utils
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestResolveClientIP(t *testing.T) {
+	trusted, err := ParseTrustedProxies([]string{"10.0.0.0/8", " 192.0.2.1 ", "::1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		forwarded  []string
+		want       string
+	}{
+		{name: "direct client", remoteAddr: "198.51.100.7:52100", want: "198.51.100.7"},
+		{name: "forged header from untrusted peer", remoteAddr: "198.51.100.7:52100", forwarded: []string{"203.0.113.1"}, want: "198.51.100.7"},
+		{name: "trusted proxy", remoteAddr: "192.0.2.1:443", forwarded: []string{"198.51.100.7"}, want: "198.51.100.7"},
+		{name: "client-prepended entries ignored", remoteAddr: "192.0.2.1:443", forwarded: []string{"203.0.113.1, 198.51.100.7"}, want: "198.51.100.7"},
+		{name: "chain of trusted proxies", remoteAddr: "192.0.2.1:443", forwarded: []string{"198.51.100.7, 10.1.2.3, 10.4.5.6"}, want: "198.51.100.7"},
+		{name: "repeated headers", remoteAddr: "192.0.2.1:443", forwarded: []string{"203.0.113.1", "198.51.100.7, 10.1.2.3"}, want: "198.51.100.7"},
+		{name: "only trusted hops", remoteAddr: "192.0.2.1:443", forwarded: []string{"10.1.2.3"}, want: "10.1.2.3"},
+		{name: "garbage stops the walk", remoteAddr: "192.0.2.1:443", forwarded: []string{"not-an-ip, 10.1.2.3"}, want: "10.1.2.3"},
+		{name: "trusted proxy without header", remoteAddr: "192.0.2.1:443", want: "192.0.2.1"},
+		{name: "IPv6 proxy", remoteAddr: "[::1]:443", forwarded: []string{"2001:db8::5"}, want: "2001:db8::5"},
+		{name: "address without port", remoteAddr: "198.51.100.7", want: "198.51.100.7"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = tt.remoteAddr
+			for _, value := range tt.forwarded {
+				r.Header.Add("X-Forwarded-For", value)
+			}
+
+			if got := ResolveClientIP(r, trusted); got != tt.want {
+				t.Errorf("ResolveClientIP = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTrustedProxies(t *testing.T) {
+	tests := []struct {
+		entries []string
+		wantErr bool
+	}{
+		{entries: nil},
+		{entries: []string{"10.0.0.1", "172.16.0.0/12", "fd00::/8", ""}},
+		{entries: []string{"10.0.0"}, wantErr: true},
+		{entries: []string{"10.0.0.0/33"}, wantErr: true},
+		{entries: []string{"proxy.internal"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		if _, err := ParseTrustedProxies(tt.entries); (err != nil) != tt.wantErr {
+			t.Errorf("ParseTrustedProxies(%q) error = %v, want error %v", tt.entries, err, tt.wantErr)
+		}
+	}
+}
@@ -1,16 +1,38 @@
 package utils
 
 import (
+	"fmt"
 	"github.com/gorilla/mux"
 	"net/http"
 	"strconv"
 )
 
 func ParseInt64Param(r *http.Request) (int64, error) {
-	idStr := mux.Vars(r)["id"]
+	return ParseInt64PathValue(r, "id")
+}
+
+// ParseInt64PathValue reads a named wildcard from the request path, falling back to mux vars
+func ParseInt64PathValue(r *http.Request, name string) (int64, error) {
+	idStr := r.PathValue(name)
+	if idStr == "" {
+		idStr = mux.Vars(r)[name]
+	}
 	id, err := strconv.ParseInt(idStr, 10, 64)
 	if err != nil {
 		return 0, err
 	}
 	return id, nil
 }
+
+// ParseInt64Query reads an optional integer query parameter; a missing parameter is 0
+func ParseInt64Query(r *http.Request, name string) (int64, error) {
+	raw := r.URL.Query().Get(name)
+	if raw == "" {
+		return 0, nil
+	}
+	value, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("invalid %s", name)
+	}
+	return value, nil
+}
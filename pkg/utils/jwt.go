@@ -1,23 +1,63 @@
 package utils
 
 import (
+	"context"
 	"fmt"
 	"github.com/BerkatPS/pkg/config"
 	"github.com/dgrijalva/jwt-go"
 	"time"
 )
 
-func GenerateToken(userID int) (string, error) {
+// AccessClaims are the claims of a verified access token
+type AccessClaims struct {
+	UserID    int64
+	SessionID string // Login session the token was issued for; the refresh token family ID
+	TokenID   string
+	ExpiresAt time.Time
+}
+
+// SessionChecker reports whether a login session is still open, so access tokens stop working as soon as
+// their session is logged out instead of when they expire
+type SessionChecker interface {
+	SessionActive(ctx context.Context, userID int64, sessionID string) (bool, error)
+}
+
+var sessionChecker SessionChecker
+
+// SetSessionChecker installs the check every access token goes through. Without one only the signature and
+// expiry are verified.
+func SetSessionChecker(checker SessionChecker) {
+	sessionChecker = checker
+}
+
+// GenerateAccessToken signs a short-lived access token for the user's login session
+func GenerateAccessToken(userID int64, sessionID string) (string, time.Time, error) {
 	cfg := config.LoadConfig()
+
+	tokenID, err := GenerateSecureToken(16)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(cfg.AccessTokenTTL)
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
 		"user_id": userID,
-		"exp":     time.Now().Add(time.Hour * 72).Unix(),
+		"sid":     sessionID,
+		"jti":     tokenID,
+		"iat":     now.Unix(),
+		"exp":     expiresAt.Unix(),
 	})
 
-	return token.SignedString([]byte(cfg.JwtSecret))
+	signed, err := token.SignedString([]byte(cfg.JwtSecret))
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return signed, expiresAt, nil
 }
 
-func ValidateToken(tokenString string) bool {
+// ParseAccessToken verifies an access token and that its session has not been logged out
+func ParseAccessToken(ctx context.Context, tokenString string) (*AccessClaims, error) {
 	cfg := config.LoadConfig()
 	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
@@ -25,9 +65,41 @@ func ValidateToken(tokenString string) bool {
 		}
 		return []byte(cfg.JwtSecret), nil
 	})
-
 	if err != nil || !token.Valid {
-		return false
+		return nil, fmt.Errorf("invalid token")
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, fmt.Errorf("invalid token claims")
+	}
+
+	userID, ok := claims["user_id"].(float64)
+	if !ok || userID <= 0 {
+		return nil, fmt.Errorf("token has no user")
+	}
+	sessionID, _ := claims["sid"].(string)
+	if sessionID == "" {
+		return nil, fmt.Errorf("token has no session")
+	}
+	tokenID, _ := claims["jti"].(string)
+	exp, _ := claims["exp"].(float64)
+
+	accessClaims := &AccessClaims{
+		UserID:    int64(userID),
+		SessionID: sessionID,
+		TokenID:   tokenID,
+		ExpiresAt: time.Unix(int64(exp), 0),
+	}
+
+	if sessionChecker != nil {
+		active, err := sessionChecker.SessionActive(ctx, accessClaims.UserID, sessionID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check session: %v", err)
+		}
+		if !active {
+			return nil, fmt.Errorf("session has been logged out")
+		}
 	}
-	return true
+	return accessClaims, nil
 }